@@ -13,6 +13,7 @@ import (
 	"github.com/Jaylenwa/Vfoy/pkg/conf"
 	"github.com/Jaylenwa/Vfoy/pkg/crontab"
 	"github.com/Jaylenwa/Vfoy/pkg/email"
+	"github.com/Jaylenwa/Vfoy/pkg/filesystem"
 	"github.com/Jaylenwa/Vfoy/pkg/mq"
 	"github.com/Jaylenwa/Vfoy/pkg/task"
 	"github.com/Jaylenwa/Vfoy/pkg/wopi"
@@ -62,18 +63,48 @@ func Init(path string, statics fs.FS) {
 				model.Init()
 			},
 		},
+		{
+			"master",
+			func() {
+				mq.Init()
+			},
+		},
 		{
 			"both",
 			func() {
 				cache.Restore(filepath.Join(model.GetSettingByName("temp_path"), cache.DefaultCacheFile))
 			},
 		},
+		{
+			"master",
+			func() {
+				filesystem.RestoreUploadSessions()
+			},
+		},
 		{
 			"both",
 			func() {
 				task.Init()
 			},
 		},
+		{
+			"master",
+			func() {
+				filesystem.InitContentIndexer()
+			},
+		},
+		{
+			"master",
+			func() {
+				filesystem.InitReplicator()
+			},
+		},
+		{
+			"master",
+			func() {
+				filesystem.InitChecksumWorker()
+			},
+		},
 		{
 			"master",
 			func() {
@@ -17,7 +17,6 @@ import (
 
 	model "github.com/Jaylenwa/Vfoy/models"
 	"github.com/Jaylenwa/Vfoy/pkg/auth"
-	"github.com/Jaylenwa/Vfoy/pkg/cache"
 	"github.com/Jaylenwa/Vfoy/pkg/serializer"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
@@ -87,45 +86,82 @@ func WebDAVAuth() gin.HandlerFunc {
 			return
 		}
 
-		username, password, ok := c.Request.BasicAuth()
+		expectedUser, webdav, ok := webdavBasicAuth(c)
 		if !ok {
-			c.Writer.Header()["WWW-Authenticate"] = []string{`Basic realm="vfoy"`}
-			c.Status(http.StatusUnauthorized)
 			c.Abort()
 			return
 		}
 
-		expectedUser, err := model.GetActiveUserByEmail(username)
-		if err != nil {
-			c.Status(http.StatusUnauthorized)
-			c.Abort()
-			return
-		}
+		useWebDAVAccount(c, expectedUser, webdav)
+		c.Next()
+	}
+}
 
-		// 密码正确？
-		webdav, err := model.GetWebdavByPassword(password, expectedUser.ID)
-		if err != nil {
-			c.Status(http.StatusUnauthorized)
-			c.Abort()
-			return
-		}
+// webdavBasicAuth 使用邮箱与 WebDAV 账户密码进行 Basic 认证，并校验账户有效期、
+// 来源 IP、请求方法与请求频率。认证失败时已写入响应状态，返回 false
+func webdavBasicAuth(c *gin.Context) (*model.User, *model.Webdav, bool) {
+	username, password, ok := c.Request.BasicAuth()
+	if !ok {
+		c.Writer.Header()["WWW-Authenticate"] = []string{`Basic realm="vfoy"`}
+		c.Status(http.StatusUnauthorized)
+		return nil, nil, false
+	}
 
-		// 用户组已启用WebDAV？
-		if !expectedUser.Group.WebDAVEnabled {
-			c.Status(http.StatusForbidden)
-			c.Abort()
-			return
-		}
+	expectedUser, err := model.GetActiveUserByEmail(username)
+	if err != nil {
+		c.Status(http.StatusUnauthorized)
+		return nil, nil, false
+	}
 
-		// 用户组已启用WebDAV代理？
-		if !expectedUser.Group.OptionsSerialized.WebDAVProxy {
-			webdav.UseProxy = false
-		}
+	// 密码正确？
+	webdav, err := model.GetWebdavByPassword(password, expectedUser.ID)
+	if err != nil {
+		c.Status(http.StatusUnauthorized)
+		return nil, nil, false
+	}
 
-		c.Set("user", &expectedUser)
-		c.Set("webdav", webdav)
-		c.Next()
+	// 用户组已启用WebDAV？
+	if !expectedUser.Group.WebDAVEnabled {
+		c.Status(http.StatusForbidden)
+		return nil, nil, false
+	}
+
+	// 用户组已启用WebDAV代理？
+	if !expectedUser.Group.OptionsSerialized.WebDAVProxy {
+		webdav.UseProxy = false
+	}
+
+	// 账户已过期？
+	if webdav.Expired() {
+		c.Status(http.StatusUnauthorized)
+		return nil, nil, false
+	}
+
+	// 来源 IP 与请求方法是否允许？
+	if !webdav.AllowIP(c.ClientIP()) || !webdav.AllowMethod(c.Request.Method) {
+		c.Status(http.StatusForbidden)
+		return nil, nil, false
 	}
+
+	// 请求频率与带宽限制
+	if !applyWebDAVLimits(c, webdav) {
+		return nil, nil, false
+	}
+
+	return &expectedUser, webdav, true
+}
+
+// useWebDAVAccount 记录账户的使用情况，并将认证通过的用户与账户写入上下文。
+// 同一 IP 持续访问时，最后使用记录至多每分钟写入一次
+func useWebDAVAccount(c *gin.Context, user *model.User, webdav *model.Webdav) {
+	if ip := c.ClientIP(); webdav.NeedTouch(ip) {
+		if err := webdav.Touch(ip); err != nil {
+			util.Log().Warning("Failed to update last used time of WebDAV account %d: %s", webdav.ID, err)
+		}
+	}
+
+	c.Set("user", user)
+	c.Set("webdav", webdav)
 }
 
 // 对上传会话进行验证
@@ -151,19 +187,18 @@ func uploadCallbackCheck(c *gin.Context, policyType string) serializer.Response
 		return serializer.ParamErr("Session ID cannot be empty", nil)
 	}
 
-	callbackSessionRaw, exist := cache.Get(filesystem.UploadSessionCachePrefix + sessionID)
+	callbackSession, exist := filesystem.GetUploadSession(sessionID)
 	if !exist {
 		return serializer.Err(serializer.CodeUploadSessionExpired, "上传会话不存在或已过期", nil)
 	}
 
-	callbackSession := callbackSessionRaw.(serializer.UploadSession)
-	c.Set(filesystem.UploadSessionCtx, &callbackSession)
+	c.Set(filesystem.UploadSessionCtx, callbackSession)
 	if callbackSession.Policy.Type != policyType {
 		return serializer.Err(serializer.CodePolicyNotAllowed, "", nil)
 	}
 
 	// 清理回调会话
-	_ = cache.Deletes([]string{sessionID}, filesystem.UploadSessionCachePrefix)
+	filesystem.DeleteUploadSessions(sessionID)
 
 	// 查找用户
 	user, err := model.GetActiveUserByID(callbackSession.UID)
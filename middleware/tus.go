@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"net/http"
+
+	model "github.com/Jaylenwa/Vfoy/models"
+	"github.com/Jaylenwa/Vfoy/pkg/tus"
+	"github.com/gin-gonic/gin"
+)
+
+// TusResumable 校验 tus 协议版本，并在响应中声明服务端版本
+func TusResumable() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header(tus.HeaderResumable, tus.Version)
+
+		// OPTIONS 请求用于探测服务端能力，不要求协议版本
+		if c.Request.Method != "OPTIONS" && c.GetHeader(tus.HeaderResumable) != tus.Version {
+			c.Header(tus.HeaderVersion, tus.Version)
+			c.Status(http.StatusPreconditionFailed)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// TusAuth tus 上传鉴权，支持登录会话，或使用邮箱与 WebDAV 应用密码进行 Basic 认证
+func TusAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method == "OPTIONS" {
+			c.Next()
+			return
+		}
+
+		// 已通过会话登录
+		if user, _ := c.Get("user"); user != nil {
+			if _, ok := user.(*model.User); ok {
+				c.Next()
+				return
+			}
+		}
+
+		expectedUser, webdav, ok := webdavBasicAuth(c)
+		if !ok {
+			c.Abort()
+			return
+		}
+
+		// 只读账户不能上传
+		if webdav.Readonly {
+			c.Status(http.StatusForbidden)
+			c.Abort()
+			return
+		}
+
+		useWebDAVAccount(c, expectedUser, webdav)
+		c.Next()
+	}
+}
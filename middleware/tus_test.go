@@ -0,0 +1,150 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/Jaylenwa/Vfoy/models"
+	"github.com/Jaylenwa/Vfoy/pkg/tus"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTusResumable(t *testing.T) {
+	asserts := assert.New(t)
+	resumable := TusResumable()
+
+	// OPTIONS 请求不要求协议版本
+	{
+		rec := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(rec)
+		c.Request, _ = http.NewRequest("OPTIONS", "/test", nil)
+		resumable(c)
+		asserts.False(c.IsAborted())
+		asserts.Equal(tus.Version, rec.Header().Get(tus.HeaderResumable))
+	}
+
+	// 协议版本不支持
+	{
+		rec := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(rec)
+		c.Request, _ = http.NewRequest("HEAD", "/test", nil)
+		c.Request.Header.Set(tus.HeaderResumable, "0.2.2")
+		resumable(c)
+		asserts.True(c.IsAborted())
+		asserts.Equal(http.StatusPreconditionFailed, c.Writer.Status())
+		asserts.Equal(tus.Version, rec.Header().Get(tus.HeaderVersion))
+	}
+
+	// 正常
+	{
+		rec := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(rec)
+		c.Request, _ = http.NewRequest("HEAD", "/test", nil)
+		c.Request.Header.Set(tus.HeaderResumable, tus.Version)
+		resumable(c)
+		asserts.False(c.IsAborted())
+	}
+}
+
+func TestTusAuth(t *testing.T) {
+	asserts := assert.New(t)
+	authFunc := TusAuth()
+	expectUser := func(webdavEnabled bool) {
+		mock.ExpectQuery("SELECT(.+)users(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "email", "group_id", "options"}).AddRow(1, "who@vfoy.org", 1, "{}"))
+		mock.ExpectQuery("SELECT(.+)groups(.+)").WillReturnRows(sqlmock.NewRows([]string{"id", "web_dav_enabled"}).AddRow(1, webdavEnabled))
+	}
+
+	// 已通过会话登录
+	{
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request, _ = http.NewRequest("POST", "/test", nil)
+		c.Set("user", &model.User{})
+		authFunc(c)
+		asserts.False(c.IsAborted())
+	}
+
+	// 未提供凭证
+	{
+		rec := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(rec)
+		c.Request, _ = http.NewRequest("POST", "/test", nil)
+		authFunc(c)
+		asserts.True(c.IsAborted())
+		asserts.Equal(http.StatusUnauthorized, c.Writer.Status())
+		asserts.NotEmpty(rec.Header()["WWW-Authenticate"])
+	}
+
+	// 应用密码错误
+	{
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request, _ = http.NewRequest("POST", "/test", nil)
+		c.Request.SetBasicAuth("who@vfoy.org", "admin")
+		expectUser(true)
+		mock.ExpectQuery("SELECT(.+)webdav(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		authFunc(c)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.True(c.IsAborted())
+		asserts.Equal(http.StatusUnauthorized, c.Writer.Status())
+	}
+
+	// 只读账户
+	{
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request, _ = http.NewRequest("POST", "/test", nil)
+		c.Request.SetBasicAuth("who@vfoy.org", "admin")
+		expectUser(true)
+		mock.ExpectQuery("SELECT(.+)webdav(.+)").WillReturnRows(sqlmock.NewRows([]string{"id", "readonly"}).AddRow(1, true))
+		authFunc(c)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.True(c.IsAborted())
+		asserts.Equal(http.StatusForbidden, c.Writer.Status())
+	}
+
+	// 账户不允许该请求方法
+	{
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request, _ = http.NewRequest("PATCH", "/test", nil)
+		c.Request.SetBasicAuth("who@vfoy.org", "admin")
+		expectUser(true)
+		mock.ExpectQuery("SELECT(.+)webdav(.+)").WillReturnRows(sqlmock.NewRows([]string{"id", "allowed_methods"}).AddRow(1, "GET,PROPFIND"))
+		authFunc(c)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.True(c.IsAborted())
+		asserts.Equal(http.StatusForbidden, c.Writer.Status())
+	}
+
+	// 用户组未启用 WebDAV
+	{
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request, _ = http.NewRequest("POST", "/test", nil)
+		c.Request.SetBasicAuth("who@vfoy.org", "admin")
+		expectUser(false)
+		mock.ExpectQuery("SELECT(.+)webdav(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		authFunc(c)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal(http.StatusForbidden, c.Writer.Status())
+	}
+
+	// 正常
+	{
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request, _ = http.NewRequest("PATCH", "/test", nil)
+		c.Request.SetBasicAuth("who@vfoy.org", "admin")
+		expectUser(true)
+		mock.ExpectQuery("SELECT(.+)webdav(.+)").WillReturnRows(sqlmock.NewRows([]string{"id", "root"}).AddRow(2, "/uploads"))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)webdavs(.+)").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		authFunc(c)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.False(c.IsAborted())
+		user, _ := c.Get("user")
+		asserts.EqualValues(1, user.(*model.User).ID)
+		webdav, _ := c.Get("webdav")
+		asserts.Equal("/uploads", webdav.(*model.Webdav).Root)
+	}
+}
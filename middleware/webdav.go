@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+
+	model "github.com/Jaylenwa/Vfoy/models"
+	"github.com/gin-gonic/gin"
+	"github.com/juju/ratelimit"
+	"golang.org/x/time/rate"
+)
+
+// webdavLimiter WebDAV 账户的请求频率与带宽限制，仅在当前节点内生效
+type webdavLimiter struct {
+	requestLimit int
+	speedLimit   int
+	requests     *rate.Limiter
+	bandwidth    *ratelimit.Bucket
+}
+
+var (
+	webdavLimitersMu sync.Mutex
+	webdavLimiters   = make(map[uint]*webdavLimiter)
+)
+
+// webdavLimiterOf 取得账户的限制器，账户限制变化后重新创建，未设定限制时返回 nil
+func webdavLimiterOf(account *model.Webdav) *webdavLimiter {
+	webdavLimitersMu.Lock()
+	defer webdavLimitersMu.Unlock()
+
+	if account.RequestLimit <= 0 && account.SpeedLimit <= 0 {
+		delete(webdavLimiters, account.ID)
+		return nil
+	}
+
+	limiter, ok := webdavLimiters[account.ID]
+	if ok && limiter.requestLimit == account.RequestLimit && limiter.speedLimit == account.SpeedLimit {
+		return limiter
+	}
+
+	limiter = &webdavLimiter{
+		requestLimit: account.RequestLimit,
+		speedLimit:   account.SpeedLimit,
+	}
+	if account.RequestLimit > 0 {
+		limiter.requests = rate.NewLimiter(rate.Limit(float64(account.RequestLimit)/60), account.RequestLimit)
+	}
+	if account.SpeedLimit > 0 {
+		limiter.bandwidth = ratelimit.NewBucketWithRate(float64(account.SpeedLimit), int64(account.SpeedLimit))
+	}
+
+	webdavLimiters[account.ID] = limiter
+	return limiter
+}
+
+// throttledBody 限速后的请求正文
+type throttledBody struct {
+	io.Reader
+	io.Closer
+}
+
+// throttledWriter 限速后的响应
+type throttledWriter struct {
+	gin.ResponseWriter
+	w io.Writer
+}
+
+func (w *throttledWriter) Write(p []byte) (int, error) {
+	return w.w.Write(p)
+}
+
+func (w *throttledWriter) WriteString(s string) (int, error) {
+	return w.w.Write([]byte(s))
+}
+
+// applyWebDAVLimits 执行账户的请求频率与带宽限制，超出请求频率时返回 false
+func applyWebDAVLimits(c *gin.Context, account *model.Webdav) bool {
+	limiter := webdavLimiterOf(account)
+	if limiter == nil {
+		return true
+	}
+
+	if limiter.requests != nil && !limiter.requests.Allow() {
+		retry := math.Ceil(60 / float64(limiter.requestLimit))
+		c.Header("Retry-After", strconv.Itoa(int(retry)))
+		c.Status(http.StatusTooManyRequests)
+		return false
+	}
+
+	if limiter.bandwidth != nil {
+		// 上传与下载共享同一带宽
+		if c.Request.Body != nil {
+			c.Request.Body = &throttledBody{
+				Reader: ratelimit.Reader(c.Request.Body, limiter.bandwidth),
+				Closer: c.Request.Body,
+			}
+		}
+		c.Writer = &throttledWriter{
+			ResponseWriter: c.Writer,
+			w:              ratelimit.Writer(c.Writer, limiter.bandwidth),
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	model "github.com/Jaylenwa/Vfoy/models"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyWebDAVLimits(t *testing.T) {
+	asserts := assert.New(t)
+	account := &model.Webdav{}
+	account.ID = 100
+
+	// 未设定限制
+	{
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request, _ = http.NewRequest("GET", "/test", nil)
+		asserts.True(applyWebDAVLimits(c, account))
+		asserts.Nil(webdavLimiterOf(account))
+	}
+
+	// 超出请求频率
+	{
+		account.RequestLimit = 2
+		for i := 0; i < 2; i++ {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request, _ = http.NewRequest("GET", "/test", nil)
+			asserts.True(applyWebDAVLimits(c, account))
+		}
+
+		rec := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(rec)
+		c.Request, _ = http.NewRequest("GET", "/test", nil)
+		asserts.False(applyWebDAVLimits(c, account))
+		asserts.Equal(http.StatusTooManyRequests, c.Writer.Status())
+		asserts.Equal("30", c.Writer.Header().Get("Retry-After"))
+	}
+
+	// 限制变化后重新创建限制器
+	{
+		account.RequestLimit = 0
+		account.SpeedLimit = 1024
+		rec := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(rec)
+		c.Request, _ = http.NewRequest("PUT", "/test", bytes.NewReader([]byte("content")))
+		asserts.True(applyWebDAVLimits(c, account))
+
+		body, err := ioutil.ReadAll(c.Request.Body)
+		asserts.NoError(err)
+		asserts.Equal("content", string(body))
+		asserts.NoError(c.Request.Body.Close())
+
+		_, err = c.Writer.WriteString("response")
+		asserts.NoError(err)
+		asserts.Equal("response", rec.Body.String())
+	}
+}
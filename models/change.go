@@ -0,0 +1,80 @@
+package model
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 变更日志中的变更类型
+const (
+	ChangeCreate = "create"
+	ChangeUpdate = "update"
+	ChangeMove   = "move"
+	ChangeRename = "rename"
+	ChangeDelete = "delete"
+)
+
+// Change 用户文件系统的变更日志，ID 单调递增，用作同步客户端的游标
+type Change struct {
+	ID        uint      `gorm:"primary_key"`
+	CreatedAt time.Time `gorm:"index:idx_change_created_at"`
+	UserID    uint      `gorm:"index:idx_change_user"`
+	Type      string
+	IsFolder  bool
+	ObjectID  uint
+	ParentID  uint   // 变更后所在的父目录，删除时为删除前的父目录
+	Name      string // 变更后的对象名，未知时为空
+}
+
+// RecordChanges 写入变更日志
+func RecordChanges(changes []Change) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	tx := DB.Begin()
+	for i := range changes {
+		if err := tx.Create(&changes[i]).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+// GetChangesAfter 列出用户游标 cursor 之后的变更，按 ID 升序
+func GetChangesAfter(uid, cursor uint, limit int) ([]Change, error) {
+	var changes []Change
+	result := DB.Where("user_id = ? and id > ?", uid, cursor).Order("id asc").Limit(limit).Find(&changes)
+	return changes, result.Error
+}
+
+// GetChangeCursorRange 返回变更日志中保留的最小和最大 ID，日志为空时均为 0
+func GetChangeCursorRange() (uint, uint, error) {
+	var first, last Change
+	if err := DB.Order("id asc").First(&first).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return 0, 0, nil
+		}
+		return 0, 0, err
+	}
+
+	if err := DB.Order("id desc").First(&last).Error; err != nil {
+		return 0, 0, err
+	}
+
+	return first.ID, last.ID, nil
+}
+
+// TruncateChanges 删除早于 before 的变更日志，最新的一条总会被保留以标记当前游标
+func TruncateChanges(before time.Time) (int64, error) {
+	_, last, err := GetChangeCursorRange()
+	if err != nil || last == 0 {
+		return 0, err
+	}
+
+	result := DB.Where("created_at < ? and id < ?", before, last).Delete(&Change{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,113 @@
+package model
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordChanges(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 无变更
+	asserts.NoError(RecordChanges(nil))
+
+	// 插入失败
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)changes(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT(.+)changes(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		err := RecordChanges([]Change{{Type: ChangeCreate}, {Type: ChangeDelete}})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+	}
+
+	// 成功
+	{
+		changes := []Change{{Type: ChangeCreate}, {Type: ChangeDelete}}
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)changes(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT(.+)changes(.+)").WillReturnResult(sqlmock.NewResult(2, 1))
+		mock.ExpectCommit()
+		asserts.NoError(RecordChanges(changes))
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.EqualValues(1, changes[0].ID)
+		asserts.EqualValues(2, changes[1].ID)
+	}
+}
+
+func TestGetChangesAfter(t *testing.T) {
+	asserts := assert.New(t)
+
+	mock.ExpectQuery("SELECT(.+)changes(.+)user_id = (.+)id > (.+)LIMIT 11").
+		WithArgs(1, 5).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(6).AddRow(8))
+	changes, err := GetChangesAfter(1, 5, 11)
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	asserts.Len(changes, 2)
+}
+
+func TestGetChangeCursorRange(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 日志为空
+	{
+		mock.ExpectQuery("SELECT(.+)changes(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		first, last, err := GetChangeCursorRange()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.EqualValues(0, first)
+		asserts.EqualValues(0, last)
+	}
+
+	// 查询失败
+	{
+		mock.ExpectQuery("SELECT(.+)changes(.+)").WillReturnError(errors.New("error"))
+		_, _, err := GetChangeCursorRange()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+	}
+
+	// 成功
+	{
+		mock.ExpectQuery("SELECT(.+)changes(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+		mock.ExpectQuery("SELECT(.+)changes(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(10))
+		first, last, err := GetChangeCursorRange()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.EqualValues(3, first)
+		asserts.EqualValues(10, last)
+	}
+}
+
+func TestTruncateChanges(t *testing.T) {
+	asserts := assert.New(t)
+	before := time.Now()
+
+	// 日志为空
+	{
+		mock.ExpectQuery("SELECT(.+)changes(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		deleted, err := TruncateChanges(before)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.EqualValues(0, deleted)
+	}
+
+	// 保留最新的一条
+	{
+		mock.ExpectQuery("SELECT(.+)changes(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+		mock.ExpectQuery("SELECT(.+)changes(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(10))
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)changes(.+)").WithArgs(before, 10).WillReturnResult(sqlmock.NewResult(0, 7))
+		mock.ExpectCommit()
+		deleted, err := TruncateChanges(before)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.EqualValues(7, deleted)
+	}
+}
@@ -41,6 +41,7 @@ var defaultSettings = []Setting{
 	{Name: "share_download_session_timeout", Value: `2073600`, Type: "timeout"},
 	{Name: "onedrive_callback_check", Value: `20`, Type: "timeout"},
 	{Name: "folder_props_timeout", Value: `300`, Type: "timeout"},
+	{Name: "change_journal_retention", Value: `2592000`, Type: "timeout"},
 	{Name: "chunk_retries", Value: `5`, Type: "retry"},
 	{Name: "onedrive_source_timeout", Value: `1800`, Type: "timeout"},
 	{Name: "reset_after_upload_failed", Value: `0`, Type: "upload"},
@@ -70,6 +71,8 @@ Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; verti
 	{Name: "themes", Value: `{"#3f51b5":{"palette":{"primary":{"main":"#3f51b5"},"secondary":{"main":"#f50057"}}},"#2196f3":{"palette":{"primary":{"main":"#2196f3"},"secondary":{"main":"#FFC107"}}},"#673AB7":{"palette":{"primary":{"main":"#673AB7"},"secondary":{"main":"#2196F3"}}},"#E91E63":{"palette":{"primary":{"main":"#E91E63"},"secondary":{"main":"#42A5F5","contrastText":"#fff"}}},"#FF5722":{"palette":{"primary":{"main":"#FF5722"},"secondary":{"main":"#3F51B5"}}},"#FFC107":{"palette":{"primary":{"main":"#FFC107"},"secondary":{"main":"#26C6DA"}}},"#8BC34A":{"palette":{"primary":{"main":"#8BC34A","contrastText":"#fff"},"secondary":{"main":"#FF8A65","contrastText":"#fff"}}},"#009688":{"palette":{"primary":{"main":"#009688"},"secondary":{"main":"#4DD0E1","contrastText":"#fff"}}},"#607D8B":{"palette":{"primary":{"main":"#607D8B"},"secondary":{"main":"#F06292"}}},"#795548":{"palette":{"primary":{"main":"#795548"},"secondary":{"main":"#4CAF50","contrastText":"#fff"}}}}`, Type: "basic"},
 	{Name: "max_worker_num", Value: `10`, Type: "task"},
 	{Name: "max_parallel_transfer", Value: `4`, Type: "task"},
+	{Name: "replica_max_task_count", Value: `1`, Type: "task"},
+	{Name: "checksum_max_task_count", Value: `1`, Type: "task"},
 	{Name: "secret_key", Value: util.RandStringRunes(256), Type: "auth"},
 	{Name: "temp_path", Value: "temp", Type: "path"},
 	{Name: "avatar_path", Value: "avatar", Type: "path"},
@@ -81,6 +84,9 @@ Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; verti
 	{Name: "share_view_method", Value: "list", Type: "view"},
 	{Name: "cron_garbage_collect", Value: "@hourly", Type: "cron"},
 	{Name: "cron_recycle_upload_session", Value: "@every 1h30m", Type: "cron"},
+	{Name: "cron_purge_trash", Value: "@hourly", Type: "cron"},
+	{Name: "cron_lifecycle", Value: "@daily", Type: "cron"},
+	{Name: "cron_trim_change_journal", Value: "@daily", Type: "cron"},
 	{Name: "authn_enabled", Value: "0", Type: "authn"},
 	{Name: "captcha_type", Value: "normal", Type: "captcha"},
 	{Name: "captcha_height", Value: "60", Type: "captcha"},
@@ -121,6 +127,9 @@ Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; verti
 	{Name: "thumb_proxy_enabled", Value: "0", Type: "thumb"},
 	{Name: "thumb_proxy_policy", Value: "[]", Type: "thumb"},
 	{Name: "thumb_max_src_size", Value: "31457280", Type: "thumb"},
+	{Name: "search_index_enabled", Value: "1", Type: "search"},
+	{Name: "search_index_max_src_size", Value: "10485760", Type: "search"},
+	{Name: "search_index_max_task_count", Value: "1", Type: "search"},
 	{Name: "pwa_small_icon", Value: "/static/img/favicon.ico", Type: "pwa"},
 	{Name: "pwa_medium_icon", Value: "/static/img/logo192.png", Type: "pwa"},
 	{Name: "pwa_large_icon", Value: "/static/img/logo512.png", Type: "pwa"},
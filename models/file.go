@@ -27,6 +27,8 @@ type File struct {
 	PolicyID        uint
 	UploadSessionID *string `gorm:"index:session_id;unique_index:session_only_one"`
 	Metadata        string  `gorm:"type:text"`
+	Hash            string  `gorm:"size:64;index:file_hash"`
+	AccessedAt      *time.Time
 
 	// 关联模型
 	Policy Policy `gorm:"PRELOAD:false,association_autoupdate:false"`
@@ -46,6 +48,21 @@ const (
 	ThumbSidecarMetadataKey = "thumb_sidecar"
 
 	ChecksumMetadataKey = "webdav_checksum"
+
+	// 服务端计算的校验和，SHA-256 记录在 Hash 字段中
+	ChecksumMD5MetadataKey  = "checksum_md5"
+	ChecksumSHA1MetadataKey = "checksum_sha1"
+
+	// 分片上传时已计算部分的摘要状态
+	HashStateMetadataKey  = "hash_state"
+	HashOffsetMetadataKey = "hash_offset"
+)
+
+// 校验和算法
+const (
+	ChecksumMD5    = "md5"
+	ChecksumSHA1   = "sha1"
+	ChecksumSHA256 = "sha256"
 )
 
 func init() {
@@ -137,7 +154,7 @@ func GetFilesByIDsFromTX(tx *gorm.DB, ids []uint, uid uint) ([]File, error) {
 	return files, result.Error
 }
 
-// GetFilesByKeywords 根据关键字搜索文件,
+// GetFilesByKeywords 根据关键字搜索文件，keywords 为以 EscapeLike 转义字面字符的 LIKE 模式,
 // UID为0表示忽略用户，只根据文件ID检索. 如果 parents 非空， 则只限制在 parent 包含的目录下搜索
 func GetFilesByKeywords(uid uint, parents []uint, keywords ...interface{}) ([]File, error) {
 	var (
@@ -148,7 +165,7 @@ func GetFilesByKeywords(uid uint, parents []uint, keywords ...interface{}) ([]Fi
 
 	// 生成查询条件
 	for i := 0; i < len(keywords); i++ {
-		conditions += "name like ?"
+		conditions += "name like ? escape '" + likeEscapeChar + "'"
 		if i != len(keywords)-1 {
 			conditions += " or "
 		}
@@ -211,12 +228,19 @@ func RemoveFilesWithSoftLinks(files []File) ([]File, error) {
 		return filteredFiles, nil
 	}
 
+	// 同一批次中的文件互相引用时不应视为软链接，物理文件在最后一个引用被删除时才删除
+	ids := make([]uint, 0, len(files))
+	for _, file := range files {
+		ids = append(ids, file.ID)
+	}
+
 	// 查询软链接的文件
 	filesWithSoftLinks := make([]File, 0)
 	for _, file := range files {
 		var softLinkFile File
 		res := DB.
-			Where("source_name = ? and policy_id = ? and id != ?", file.SourceName, file.PolicyID, file.ID).
+			Unscoped().
+			Where("source_name = ? and policy_id = ? and id not in (?)", file.SourceName, file.PolicyID, ids).
 			First(&softLinkFile)
 		if res.Error == nil {
 			filesWithSoftLinks = append(filesWithSoftLinks, softLinkFile)
@@ -388,6 +412,74 @@ func (file *File) UpdateSourceName(value string) error {
 	}).Error
 }
 
+// accessTimeResolution 最后访问时间的记录精度，避免每次访问都写入数据库
+const accessTimeResolution = time.Hour
+
+// UpdateAccessTime 记录文件的最后访问时间，距上次记录不足 accessTimeResolution 时忽略
+func (file *File) UpdateAccessTime(now time.Time) error {
+	if file.ID == 0 || (file.AccessedAt != nil && now.Sub(*file.AccessedAt) < accessTimeResolution) {
+		return nil
+	}
+
+	file.AccessedAt = &now
+	return DB.Model(file).UpdateColumn("accessed_at", now).Error
+}
+
+// UpdateHash 更新文件内容摘要及其他校验和，并清除分片上传时的摘要状态
+func (file *File) UpdateHash(value string, checksums map[string]string) error {
+	file.Hash = value
+	if file.MetadataSerialized == nil {
+		file.MetadataSerialized = make(map[string]string)
+	}
+
+	for _, key := range []string{HashStateMetadataKey, HashOffsetMetadataKey, ChecksumMD5MetadataKey, ChecksumSHA1MetadataKey} {
+		delete(file.MetadataSerialized, key)
+	}
+
+	for k, v := range checksums {
+		file.MetadataSerialized[k] = v
+	}
+
+	metaValue, err := json.Marshal(&file.MetadataSerialized)
+	if err != nil {
+		return err
+	}
+
+	file.Metadata = string(metaValue)
+	return DB.Model(file).Set("gorm:association_autoupdate", false).UpdateColumns(map[string]interface{}{
+		"hash":     file.Hash,
+		"metadata": file.Metadata,
+	}).Error
+}
+
+// Checksums 返回服务端计算的校验和，键为算法名称
+func (file *File) Checksums() map[string]string {
+	checksums := make(map[string]string)
+	if file.Hash != "" {
+		checksums[ChecksumSHA256] = file.Hash
+	}
+
+	if sum := file.MetadataSerialized[ChecksumMD5MetadataKey]; sum != "" {
+		checksums[ChecksumMD5] = sum
+	}
+
+	if sum := file.MetadataSerialized[ChecksumSHA1MetadataKey]; sum != "" {
+		checksums[ChecksumSHA1] = sum
+	}
+
+	return checksums
+}
+
+// GetFileByHash 查找用户在存储策略下内容摘要与大小均匹配的已完成上传的文件。
+// 客户端仅提供摘要而未证明持有内容，因此只查找用户自己的文件
+func GetFileByHash(hash string, size uint64, policyID, uid uint) (*File, error) {
+	var file File
+	result := DB.
+		Where("hash = ? and size = ? and policy_id = ? and user_id = ? and upload_session_id is NULL", hash, size, policyID, uid).
+		First(&file)
+	return &file, result.Error
+}
+
 func (file *File) PopChunkToFile(lastModified *time.Time, picInfo string) error {
 	file.UploadSessionID = nil
 	if lastModified != nil {
@@ -470,3 +562,62 @@ func (file *File) ShouldLoadThumb() bool {
 func (file *File) ThumbFile() string {
 	return file.SourceName + GetSettingByNameWithDefault("thumb_file_suffix", "._thumb")
 }
+
+// MigrateScope 存储策略迁移的文件范围，值为 0 的字段不作限制
+type MigrateScope struct {
+	UserID    uint
+	GroupID   uint
+	PolicyID  uint
+	FolderIDs []uint
+}
+
+// GetFilesToMigrate 按 ID 顺序列出范围内不在目标存储策略上的文件，包含回收站中的文件，
+// 跳过未完成上传的文件
+func GetFilesToMigrate(dst uint, scope *MigrateScope, after uint, limit int) ([]File, error) {
+	var files []File
+	tx := DB.Unscoped().Where("id > ? and policy_id <> ? and upload_session_id is NULL", after, dst)
+	if scope.UserID > 0 {
+		tx = tx.Where("user_id = ?", scope.UserID)
+	}
+
+	if scope.GroupID > 0 {
+		tx = tx.Where("user_id in (?)", DB.Model(&User{}).Select("id").Where("group_id = ?", scope.GroupID).QueryExpr())
+	}
+
+	if scope.PolicyID > 0 {
+		tx = tx.Where("policy_id = ?", scope.PolicyID)
+	}
+
+	if len(scope.FolderIDs) > 0 {
+		tx = tx.Where("folder_id in (?)", scope.FolderIDs)
+	}
+
+	result := tx.Order("id asc").Limit(limit).Find(&files)
+	return files, result.Error
+}
+
+// MigrateFileObject 将引用同一物理文件的文件及历史版本记录指向迁移后的物理文件
+func MigrateFileObject(policyID uint, source string, dstPolicyID uint, dstSource string) error {
+	tx := DB.Begin()
+	if err := tx.Unscoped().Model(&File{}).
+		Where("policy_id = ? and source_name = ?", policyID, source).
+		UpdateColumns(map[string]interface{}{
+			"policy_id":   dstPolicyID,
+			"source_name": dstSource,
+		}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Model(&FileVersion{}).
+		Where("policy_id = ? and source_name = ?", policyID, source).
+		UpdateColumns(map[string]interface{}{
+			"policy_id":   dstPolicyID,
+			"source_name": dstSource,
+		}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
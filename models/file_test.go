@@ -323,10 +323,10 @@ func TestRemoveFilesWithSoftLinks(t *testing.T) {
 	// 全都没有
 	{
 		mock.ExpectQuery("SELECT(.+)files(.+)").
-			WithArgs("1.txt", 23, 1).
+			WithArgs("1.txt", 23, 1, 2).
 			WillReturnRows(sqlmock.NewRows([]string{"id", "policy_id", "source_name"}))
 		mock.ExpectQuery("SELECT(.+)files(.+)").
-			WithArgs("2.txt", 24, 2).
+			WithArgs("2.txt", 24, 1, 2).
 			WillReturnRows(sqlmock.NewRows([]string{"id", "policy_id", "source_name"}))
 		file, err := RemoveFilesWithSoftLinks(files)
 		asserts.NoError(mock.ExpectationsWereMet())
@@ -337,10 +337,10 @@ func TestRemoveFilesWithSoftLinks(t *testing.T) {
 	// 第二个是软链
 	{
 		mock.ExpectQuery("SELECT(.+)files(.+)").
-			WithArgs("1.txt", 23, 1).
+			WithArgs("1.txt", 23, 1, 2).
 			WillReturnRows(sqlmock.NewRows([]string{"id", "policy_id", "source_name"}))
 		mock.ExpectQuery("SELECT(.+)files(.+)").
-			WithArgs("2.txt", 24, 2).
+			WithArgs("2.txt", 24, 1, 2).
 			WillReturnRows(
 				sqlmock.NewRows([]string{"id", "policy_id", "source_name"}).
 					AddRow(3, 24, "2.txt"),
@@ -354,13 +354,13 @@ func TestRemoveFilesWithSoftLinks(t *testing.T) {
 	// 第一个是软链
 	{
 		mock.ExpectQuery("SELECT(.+)files(.+)").
-			WithArgs("1.txt", 23, 1).
+			WithArgs("1.txt", 23, 1, 2).
 			WillReturnRows(
 				sqlmock.NewRows([]string{"id", "policy_id", "source_name"}).
 					AddRow(3, 23, "1.txt"),
 			)
 		mock.ExpectQuery("SELECT(.+)files(.+)").
-			WithArgs("2.txt", 24, 2).
+			WithArgs("2.txt", 24, 1, 2).
 			WillReturnRows(sqlmock.NewRows([]string{"id", "policy_id", "source_name"}))
 		file, err := RemoveFilesWithSoftLinks(files)
 		asserts.NoError(mock.ExpectationsWereMet())
@@ -370,13 +370,13 @@ func TestRemoveFilesWithSoftLinks(t *testing.T) {
 	// 全部是软链
 	{
 		mock.ExpectQuery("SELECT(.+)files(.+)").
-			WithArgs("1.txt", 23, 1).
+			WithArgs("1.txt", 23, 1, 2).
 			WillReturnRows(
 				sqlmock.NewRows([]string{"id", "policy_id", "source_name"}).
 					AddRow(3, 23, "1.txt"),
 			)
 		mock.ExpectQuery("SELECT(.+)files(.+)").
-			WithArgs("2.txt", 24, 2).
+			WithArgs("2.txt", 24, 1, 2).
 			WillReturnRows(
 				sqlmock.NewRows([]string{"id", "policy_id", "source_name"}).
 					AddRow(3, 24, "2.txt"),
@@ -660,7 +660,7 @@ func TestGetFilesByKeywords(t *testing.T) {
 
 	// 未指定用户
 	{
-		mock.ExpectQuery("SELECT(.+)").WithArgs("k1", "k2").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectQuery("SELECT(.+)name like (.+) escape (.+)").WithArgs("k1", "k2").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 		res, err := GetFilesByKeywords(0, nil, "k1", "k2")
 		asserts.NoError(mock.ExpectationsWereMet())
 		asserts.NoError(err)
@@ -783,3 +783,97 @@ func TestFile_ThumbFile(t *testing.T) {
 
 	a.Equal("test._thumb", file.ThumbFile())
 }
+
+func TestFile_UpdateHash(t *testing.T) {
+	asserts := assert.New(t)
+	file := &File{
+		Model:              gorm.Model{ID: 1},
+		MetadataSerialized: map[string]string{HashStateMetadataKey: "state", HashOffsetMetadataKey: "10", ChecksumSHA1MetadataKey: "old", "k": "v"},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)files(.+)").WithArgs("hash", `{"checksum_md5":"md5","k":"v"}`, 1).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	asserts.NoError(file.UpdateHash("hash", map[string]string{ChecksumMD5MetadataKey: "md5"}))
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.Equal("hash", file.Hash)
+	asserts.NotContains(file.MetadataSerialized, HashStateMetadataKey)
+	asserts.Equal(map[string]string{ChecksumSHA256: "hash", ChecksumMD5: "md5"}, file.Checksums())
+}
+
+func TestGetFileByHash(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 找到
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)upload_session_id is NULL(.+)").
+			WithArgs("hash", 10, 1, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "source_name"}).AddRow(2, "1.txt"))
+		file, err := GetFileByHash("hash", 10, 1, 2)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Equal("1.txt", file.SourceName)
+	}
+
+	// 未找到
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		_, err := GetFileByHash("hash", 10, 1, 2)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+	}
+}
+
+func TestGetFilesToMigrate(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 不限制范围
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)policy_id <> \\?(.+)").
+			WithArgs(10, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(11).AddRow(12))
+		files, err := GetFilesToMigrate(2, &MigrateScope{}, 10, 100)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Len(files, 2)
+	}
+
+	// 限定用户组、存储策略和目录
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)users(.+)group_id(.+)").
+			WithArgs(0, 2, 3, 1, 4, 5).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		files, err := GetFilesToMigrate(2, &MigrateScope{GroupID: 3, PolicyID: 1, FolderIDs: []uint{4, 5}}, 0, 100)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Len(files, 0)
+	}
+}
+
+func TestMigrateFileObject(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 成功
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)").
+			WithArgs(2, "new.txt", 1, "old.txt").
+			WillReturnResult(sqlmock.NewResult(1, 2))
+		mock.ExpectExec("UPDATE(.+)file_versions(.+)").
+			WithArgs(2, "new.txt", 1, "old.txt").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		asserts.NoError(MigrateFileObject(1, "old.txt", 2, "new.txt"))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 失败
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)").WillReturnResult(sqlmock.NewResult(1, 2))
+		mock.ExpectExec("UPDATE(.+)file_versions(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		asserts.Error(MigrateFileObject(1, "old.txt", 2, "new.txt"))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+}
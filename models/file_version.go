@@ -0,0 +1,180 @@
+package model
+
+import (
+	"github.com/Jaylenwa/Vfoy/pkg/util"
+	"github.com/jinzhu/gorm"
+)
+
+// FileVersion 文件的历史版本，保存被覆盖前的物理文件
+type FileVersion struct {
+	gorm.Model
+	FileID     uint   `gorm:"index:version_file_id"`
+	UserID     uint   // 文件所有者
+	AuthorID   uint   // 产生此版本的更新操作者
+	SourceName string `gorm:"type:text"`
+	PolicyID   uint
+	Size       uint64
+}
+
+// Create 创建历史版本记录，历史版本占用文件所有者的容量
+func (version *FileVersion) Create() error {
+	tx := DB.Begin()
+	if err := tx.Create(version).Error; err != nil {
+		util.Log().Warning("Failed to insert file version record: %s", err)
+		tx.Rollback()
+		return err
+	}
+
+	user := &User{}
+	user.ID = version.UserID
+	if err := user.ChangeStorage(tx, "+", version.Size); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// AsFile 将历史版本转换为文件对象，用于下载、删除等物理文件操作
+func (version *FileVersion) AsFile(file *File) File {
+	res := *file
+	res.SourceName = version.SourceName
+	res.PolicyID = version.PolicyID
+	res.Size = version.Size
+	res.UpdatedAt = version.CreatedAt
+	res.MetadataSerialized = nil
+	return res
+}
+
+// GetFileVersions 列出文件的全部历史版本，按时间倒序排列
+func GetFileVersions(fileID, uid uint) ([]FileVersion, error) {
+	var versions []FileVersion
+	result := DB.Where("file_id = ? and user_id = ?", fileID, uid).Order("id desc").Find(&versions)
+	return versions, result.Error
+}
+
+// GetFileVersionByID 根据ID查找文件的历史版本
+func GetFileVersionByID(id, fileID, uid uint) (*FileVersion, error) {
+	version := &FileVersion{}
+	result := DB.Where("id = ? and file_id = ? and user_id = ?", id, fileID, uid).First(version)
+	return version, result.Error
+}
+
+// GetFileVersionsByFileIDs 列出给定文件的全部历史版本
+func GetFileVersionsByFileIDs(ids []uint) ([]FileVersion, error) {
+	var versions []FileVersion
+	result := DB.Where("file_id in (?)", ids).Find(&versions)
+	return versions, result.Error
+}
+
+// DeleteFileVersions 删除历史版本记录，并归还所有者容量
+func DeleteFileVersions(versions []FileVersion) error {
+	if len(versions) == 0 {
+		return nil
+	}
+
+	tx := DB.Begin()
+	sizes := make(map[uint]uint64)
+	for _, version := range versions {
+		if err := tx.Unscoped().Delete(&FileVersion{}, version.ID).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+		sizes[version.UserID] += version.Size
+	}
+
+	for uid, size := range sizes {
+		user := &User{}
+		user.ID = uid
+		if err := user.ChangeStorage(tx, "-", size); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+// RemoveFilesReferencedByVersions 去除物理文件仍被历史版本引用的文件
+func RemoveFilesReferencedByVersions(files []File, exclude []uint) ([]File, error) {
+	if len(files) == 0 {
+		return files, nil
+	}
+
+	sources := make([]string, len(files))
+	for i, file := range files {
+		sources[i] = file.SourceName
+	}
+
+	var versions []FileVersion
+	query := DB.Where("source_name in (?)", sources)
+	if len(exclude) > 0 {
+		query = query.Where("id not in (?)", exclude)
+	}
+	if err := query.Find(&versions).Error; err != nil {
+		return nil, err
+	}
+
+	if len(versions) == 0 {
+		return files, nil
+	}
+
+	filtered := make([]File, 0, len(files))
+	for _, file := range files {
+		referenced := false
+		for _, version := range versions {
+			if version.SourceName == file.SourceName && version.PolicyID == file.PolicyID {
+				referenced = true
+				break
+			}
+		}
+
+		if !referenced {
+			filtered = append(filtered, file)
+		}
+	}
+
+	return filtered, nil
+}
+
+// RestoreVersion 将文件内容恢复为给定的历史版本，current 不为空时当前内容会保存为新的历史版本
+func (file *File) RestoreVersion(version *FileVersion, current *FileVersion) error {
+	tx := DB.Begin()
+	user := &User{}
+	user.ID = file.UserID
+	originSize := file.Size
+
+	if err := tx.Model(file).Set("gorm:association_autoupdate", false).UpdateColumns(map[string]interface{}{
+		"source_name": version.SourceName,
+		"policy_id":   version.PolicyID,
+		"size":        version.Size,
+	}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Unscoped().Delete(version).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// 文件大小变为历史版本大小，历史版本本身不再单独计算容量
+	if err := user.ChangeStorage(tx, "-", originSize); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if current != nil {
+		if err := tx.Create(current).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		if err := user.ChangeStorage(tx, "+", current.Size); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit().Error
+}
@@ -0,0 +1,116 @@
+package model
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileVersion_Create(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 成功
+	{
+		version := &FileVersion{FileID: 1, UserID: 2, Size: 10}
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)file_versions").WillReturnResult(sqlmock.NewResult(5, 1))
+		mock.ExpectExec("UPDATE(.+)users(.+)storage(.+)").WithArgs(10, sqlmock.AnyArg(), 2).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		asserts.NoError(version.Create())
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.EqualValues(5, version.ID)
+	}
+
+	// 插入失败
+	{
+		version := &FileVersion{FileID: 1, UserID: 2, Size: 10}
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)file_versions").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		asserts.Error(version.Create())
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestFileVersion_AsFile(t *testing.T) {
+	asserts := assert.New(t)
+	file := &File{Model: gorm.Model{ID: 1}, Name: "1.txt", SourceName: "new", PolicyID: 1, Size: 2}
+	version := &FileVersion{SourceName: "old", PolicyID: 3, Size: 4}
+
+	res := version.AsFile(file)
+	asserts.EqualValues(1, res.ID)
+	asserts.Equal("1.txt", res.Name)
+	asserts.Equal("old", res.SourceName)
+	asserts.EqualValues(3, res.PolicyID)
+	asserts.EqualValues(4, res.Size)
+	asserts.Equal("new", file.SourceName)
+}
+
+func TestDeleteFileVersions(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 空列表
+	asserts.NoError(DeleteFileVersions(nil))
+
+	// 成功
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)file_versions").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("DELETE(.+)file_versions").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE(.+)users(.+)storage(.+)").WithArgs(3, sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		asserts.NoError(DeleteFileVersions([]FileVersion{
+			{Model: gorm.Model{ID: 1}, UserID: 1, Size: 1},
+			{Model: gorm.Model{ID: 2}, UserID: 1, Size: 2},
+		}))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestRemoveFilesReferencedByVersions(t *testing.T) {
+	asserts := assert.New(t)
+	files := []File{
+		{SourceName: "1.txt", PolicyID: 1},
+		{SourceName: "2.txt", PolicyID: 1},
+	}
+
+	// 没有引用
+	{
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		res, err := RemoveFilesReferencedByVersions(files, nil)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Equal(files, res)
+	}
+
+	// 第二个被引用
+	{
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)not in(.+)").
+			WithArgs("1.txt", "2.txt", 3).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "source_name", "policy_id"}).AddRow(4, "2.txt", 1))
+		res, err := RemoveFilesReferencedByVersions(files, []uint{3})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Equal(files[:1], res)
+	}
+}
+
+func TestFile_RestoreVersion(t *testing.T) {
+	asserts := assert.New(t)
+	file := &File{Model: gorm.Model{ID: 1}, UserID: 1, SourceName: "new", Size: 10}
+	version := &FileVersion{Model: gorm.Model{ID: 2}, SourceName: "old", Size: 5}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)files(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("DELETE(.+)file_versions").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("UPDATE(.+)users(.+)storage(.+)").WithArgs(10, sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT(.+)file_versions").WillReturnResult(sqlmock.NewResult(3, 1))
+	mock.ExpectExec("UPDATE(.+)users(.+)storage(.+)").WithArgs(10, sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	asserts.NoError(file.RestoreVersion(version, &FileVersion{SourceName: "new", Size: 10}))
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.Equal("old", file.SourceName)
+}
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"encoding/json"
 	"errors"
 	"path"
 	"time"
@@ -16,10 +17,35 @@ type Folder struct {
 	Name     string `gorm:"unique_index:idx_only_one_name"`
 	ParentID *uint  `gorm:"index:parent_id;unique_index:idx_only_one_name"`
 	OwnerID  uint   `gorm:"index:owner_id"`
+	Metadata string `gorm:"type:text"`
 
 	// 数据库忽略字段
-	Position      string `gorm:"-"`
-	WebdavDstName string `gorm:"-"`
+	Position           string            `gorm:"-"`
+	WebdavDstName      string            `gorm:"-"`
+	MetadataSerialized map[string]string `gorm:"-"`
+}
+
+// AfterFind 找到目录后的钩子
+func (folder *Folder) AfterFind() (err error) {
+	// 反序列化目录元数据
+	if folder.Metadata != "" {
+		err = json.Unmarshal([]byte(folder.Metadata), &folder.MetadataSerialized)
+	} else {
+		folder.MetadataSerialized = make(map[string]string)
+	}
+
+	return
+}
+
+// BeforeSave Save策略前的钩子
+func (folder *Folder) BeforeSave() (err error) {
+	if len(folder.MetadataSerialized) > 0 {
+		metaValue, err := json.Marshal(&folder.MetadataSerialized)
+		folder.Metadata = string(metaValue)
+		return err
+	}
+
+	return nil
 }
 
 // Create 创建目录
@@ -141,11 +167,38 @@ func GetFoldersByIDs(ids []uint, uid uint) ([]Folder, error) {
 	return folders, result.Error
 }
 
+// GetFolderByID 根据ID查找目录，不限定所有者
+func GetFolderByID(id uint) (*Folder, error) {
+	var folder Folder
+	result := DB.First(&folder, id)
+	return &folder, result.Error
+}
+
+// GetAncestorFolderIDs 返回目录自身及其所有上级目录的ID，由近及远排列
+func GetAncestorFolderIDs(id, uid uint) ([]uint, error) {
+	ids := make([]uint, 0)
+	current := &id
+
+	// 最大递归65535次
+	for i := 0; i < 65535 && current != nil; i++ {
+		var folder Folder
+		if err := DB.Where("id = ? and owner_id = ?", *current, uid).First(&folder).Error; err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, folder.ID)
+		current = folder.ParentID
+	}
+
+	return ids, nil
+}
+
 // MoveOrCopyFileTo 将此目录下的files移动或复制至dstFolder，
-// 返回此操作新增的容量
-func (folder *Folder) MoveOrCopyFileTo(files []uint, dstFolder *Folder, isCopy bool) (uint64, error) {
+// 返回此操作新增的容量，复制时同时返回新建的文件记录
+func (folder *Folder) MoveOrCopyFileTo(files []uint, dstFolder *Folder, isCopy bool) (uint64, []File, error) {
 	// 已复制文件的总大小
 	var copiedSize uint64
+	var copied []File
 
 	if isCopy {
 		// 检索出要复制的文件
@@ -156,10 +209,11 @@ func (folder *Folder) MoveOrCopyFileTo(files []uint, dstFolder *Folder, isCopy b
 			folder.OwnerID,
 			folder.ID,
 		).Find(&originFiles).Error; err != nil {
-			return 0, err
+			return 0, nil, err
 		}
 
 		// 复制文件记录
+		copied = make([]File, 0, len(originFiles))
 		for _, oldFile := range originFiles {
 			if !oldFile.CanCopy() {
 				util.Log().Warning("Cannot copy file %q because it's being uploaded now, skipping...", oldFile.Name)
@@ -176,10 +230,11 @@ func (folder *Folder) MoveOrCopyFileTo(files []uint, dstFolder *Folder, isCopy b
 			}
 
 			if err := DB.Create(&oldFile).Error; err != nil {
-				return copiedSize, err
+				return copiedSize, copied, err
 			}
 
 			copiedSize += oldFile.Size
+			copied = append(copied, oldFile)
 		}
 
 	} else {
@@ -201,22 +256,22 @@ func (folder *Folder) MoveOrCopyFileTo(files []uint, dstFolder *Folder, isCopy b
 			Update(updates).
 			Error
 		if err != nil {
-			return 0, err
+			return 0, nil, err
 		}
 
 	}
 
-	return copiedSize, nil
+	return copiedSize, copied, nil
 
 }
 
 // CopyFolderTo 将此目录及其子目录及文件递归复制至dstFolder
-// 返回此操作新增的容量
-func (folder *Folder) CopyFolderTo(folderID uint, dstFolder *Folder) (size uint64, err error) {
+// 返回此操作新增的容量，以及新建的目录和文件记录
+func (folder *Folder) CopyFolderTo(folderID uint, dstFolder *Folder) (size uint64, folders []Folder, files []File, err error) {
 	// 列出所有子目录
 	subFolders, err := GetRecursiveChildFolder([]uint{folderID}, folder.OwnerID, true)
 	if err != nil {
-		return 0, err
+		return 0, nil, nil, err
 	}
 
 	// 抽离所有子目录的ID
@@ -227,6 +282,7 @@ func (folder *Folder) CopyFolderTo(folderID uint, dstFolder *Folder) (size uint6
 
 	// 复制子目录
 	var newIDCache = make(map[uint]uint)
+	folders = make([]Folder, 0, len(subFolders))
 	for _, folder := range subFolders {
 		// 新的父目录指向
 		var newID uint
@@ -241,7 +297,7 @@ func (folder *Folder) CopyFolderTo(folderID uint, dstFolder *Folder) (size uint6
 			newID = IDCache
 		} else {
 			util.Log().Warning("Failed to get parent folder %q", *folder.ParentID)
-			return size, errors.New("Failed to get parent folder")
+			return size, folders, files, errors.New("Failed to get parent folder")
 		}
 
 		// 插入新的目录记录
@@ -250,10 +306,11 @@ func (folder *Folder) CopyFolderTo(folderID uint, dstFolder *Folder) (size uint6
 		folder.ParentID = &newID
 		folder.OwnerID = dstFolder.OwnerID
 		if err = DB.Create(&folder).Error; err != nil {
-			return size, err
+			return size, folders, files, err
 		}
 		// 记录新的ID以便其子目录使用
 		newIDCache[oldID] = folder.ID
+		folders = append(folders, folder)
 
 	}
 
@@ -264,10 +321,11 @@ func (folder *Folder) CopyFolderTo(folderID uint, dstFolder *Folder) (size uint6
 		folder.OwnerID,
 		subFolderIDs,
 	).Find(&originFiles).Error; err != nil {
-		return 0, err
+		return 0, folders, nil, err
 	}
 
 	// 复制文件记录
+	files = make([]File, 0, len(originFiles))
 	for _, oldFile := range originFiles {
 		if !oldFile.CanCopy() {
 			util.Log().Warning("Cannot copy file %q because it's being uploaded now, skipping...", oldFile.Name)
@@ -278,13 +336,14 @@ func (folder *Folder) CopyFolderTo(folderID uint, dstFolder *Folder) (size uint6
 		oldFile.FolderID = newIDCache[oldFile.FolderID]
 		oldFile.UserID = dstFolder.OwnerID
 		if err := DB.Create(&oldFile).Error; err != nil {
-			return size, err
+			return size, folders, files, err
 		}
 
 		size += oldFile.Size
+		files = append(files, oldFile)
 	}
 
-	return size, nil
+	return size, folders, files, nil
 
 }
 
@@ -0,0 +1,121 @@
+package model
+
+import (
+	"github.com/Jaylenwa/Vfoy/pkg/util"
+	"github.com/jinzhu/gorm"
+)
+
+// FolderQuota 目录配额，用量统计目录下所有层级的文件
+type FolderQuota struct {
+	gorm.Model
+	FolderID  uint   `gorm:"unique_index:idx_quota_folder"`
+	OwnerID   uint   `gorm:"index:idx_quota_owner"`
+	MaxSize   uint64 // 最大容量，0 表示不限
+	MaxFiles  uint64 // 最大文件数，0 表示不限
+	UsedSize  uint64 // 已用容量
+	UsedFiles uint64 // 已有文件数
+}
+
+// FolderUsage 目录中文件的总大小和数量
+type FolderUsage struct {
+	Size  uint64
+	Files uint64
+}
+
+// IsZero 用量是否为空
+func (usage FolderUsage) IsZero() bool {
+	return usage.Size == 0 && usage.Files == 0
+}
+
+// Add 合并用量
+func (usage FolderUsage) Add(other FolderUsage) FolderUsage {
+	return FolderUsage{Size: usage.Size + other.Size, Files: usage.Files + other.Files}
+}
+
+// Allows 在现有用量基础上增加 usage 后是否仍在配额内
+func (quota *FolderQuota) Allows(usage FolderUsage) bool {
+	if quota.MaxSize > 0 && usage.Size > 0 && quota.UsedSize+usage.Size > quota.MaxSize {
+		return false
+	}
+
+	if quota.MaxFiles > 0 && usage.Files > 0 && quota.UsedFiles+usage.Files > quota.MaxFiles {
+		return false
+	}
+
+	return true
+}
+
+// Create 创建目录配额记录
+func (quota *FolderQuota) Create() error {
+	if err := DB.Create(quota).Error; err != nil {
+		util.Log().Warning("Failed to insert folder quota record: %s", err)
+		return err
+	}
+	return nil
+}
+
+// Update 更新配额上限，并以 usage 校准已用量
+func (quota *FolderQuota) Update(maxSize, maxFiles uint64, usage FolderUsage) error {
+	quota.MaxSize = maxSize
+	quota.MaxFiles = maxFiles
+	quota.UsedSize = usage.Size
+	quota.UsedFiles = usage.Files
+	return DB.Model(quota).Updates(map[string]interface{}{
+		"max_size":   maxSize,
+		"max_files":  maxFiles,
+		"used_size":  usage.Size,
+		"used_files": usage.Files,
+	}).Error
+}
+
+// GetFolderQuotaByFolderID 根据目录ID查找配额
+func GetFolderQuotaByFolderID(folderID uint) (*FolderQuota, error) {
+	var quota FolderQuota
+	result := DB.Where("folder_id = ?", folderID).First(&quota)
+	return &quota, result.Error
+}
+
+// GetFolderQuotasByFolderIDs 根据目录ID批量查找配额
+func GetFolderQuotasByFolderIDs(ids []uint) ([]FolderQuota, error) {
+	var quotas []FolderQuota
+	result := DB.Where("folder_id in (?)", ids).Find(&quotas)
+	return quotas, result.Error
+}
+
+// GetFolderQuotasByOwner 列出用户目录上的全部配额
+func GetFolderQuotasByOwner(uid uint) ([]FolderQuota, error) {
+	var quotas []FolderQuota
+	result := DB.Where("owner_id = ?", uid).Find(&quotas)
+	return quotas, result.Error
+}
+
+// DeleteFolderQuotasByFolderIDs 删除目录上的配额
+func DeleteFolderQuotasByFolderIDs(ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	return DB.Unscoped().Where("folder_id in (?)", ids).Delete(&FolderQuota{}).Error
+}
+
+// ChangeFolderQuotaUsage 增减给定目录配额的已用量，减少时最低为 0
+func ChangeFolderQuotaUsage(folderIDs []uint, operator string, usage FolderUsage) error {
+	if len(folderIDs) == 0 || usage.IsZero() {
+		return nil
+	}
+
+	var updates map[string]interface{}
+	if operator == "+" {
+		updates = map[string]interface{}{
+			"used_size":  gorm.Expr("used_size + ?", usage.Size),
+			"used_files": gorm.Expr("used_files + ?", usage.Files),
+		}
+	} else {
+		updates = map[string]interface{}{
+			"used_size":  gorm.Expr("CASE WHEN used_size > ? THEN used_size - ? ELSE 0 END", usage.Size, usage.Size),
+			"used_files": gorm.Expr("CASE WHEN used_files > ? THEN used_files - ? ELSE 0 END", usage.Files, usage.Files),
+		}
+	}
+
+	return DB.Model(&FolderQuota{}).Where("folder_id in (?)", folderIDs).UpdateColumns(updates).Error
+}
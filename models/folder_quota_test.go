@@ -0,0 +1,122 @@
+package model
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFolderQuota_Allows(t *testing.T) {
+	asserts := assert.New(t)
+	quota := &FolderQuota{MaxSize: 10, MaxFiles: 2, UsedSize: 5, UsedFiles: 1}
+
+	asserts.True(quota.Allows(FolderUsage{Size: 5, Files: 1}))
+	asserts.False(quota.Allows(FolderUsage{Size: 6}))
+	asserts.False(quota.Allows(FolderUsage{Files: 2}))
+
+	// 不限制
+	quota = &FolderQuota{UsedSize: 5, UsedFiles: 1}
+	asserts.True(quota.Allows(FolderUsage{Size: 100, Files: 100}))
+
+	// 已超出时仍允许不增加用量的操作
+	quota = &FolderQuota{MaxSize: 10, MaxFiles: 1, UsedSize: 20, UsedFiles: 1}
+	asserts.True(quota.Allows(FolderUsage{Files: 0}))
+}
+
+func TestFolderQuota_Create(t *testing.T) {
+	asserts := assert.New(t)
+	quota := &FolderQuota{FolderID: 1, OwnerID: 1, MaxSize: 10}
+
+	// 成功
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)folder_quota(.+)").WillReturnResult(sqlmock.NewResult(5, 1))
+		mock.ExpectCommit()
+		asserts.NoError(quota.Create())
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.EqualValues(5, quota.ID)
+	}
+
+	// 失败
+	{
+		quota.ID = 0
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)folder_quota(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		asserts.Error(quota.Create())
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestFolderQuota_Update(t *testing.T) {
+	asserts := assert.New(t)
+	quota := &FolderQuota{}
+	quota.ID = 1
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)folder_quota(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	asserts.NoError(quota.Update(10, 2, FolderUsage{Size: 3, Files: 1}))
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.EqualValues(10, quota.MaxSize)
+	asserts.EqualValues(3, quota.UsedSize)
+}
+
+func TestChangeFolderQuotaUsage(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 无需更新
+	{
+		asserts.NoError(ChangeFolderQuotaUsage(nil, "+", FolderUsage{Size: 1}))
+		asserts.NoError(ChangeFolderQuotaUsage([]uint{1}, "+", FolderUsage{}))
+	}
+
+	// 增加
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)folder_quota(.+)used_size \\+(.+)").
+			WithArgs(1, 10, 1).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		asserts.NoError(ChangeFolderQuotaUsage([]uint{1}, "+", FolderUsage{Size: 10, Files: 1}))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 减少
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)folder_quota(.+)CASE WHEN(.+)").
+			WithArgs(1, 1, 10, 10, 1).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		asserts.NoError(ChangeFolderQuotaUsage([]uint{1}, "-", FolderUsage{Size: 10, Files: 1}))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestGetAncestorFolderIDs(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 成功
+	{
+		mock.ExpectQuery("SELECT(.+)folders(.+)").WithArgs(3, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "parent_id"}).AddRow(3, 2))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").WithArgs(2, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "parent_id"}).AddRow(2, nil))
+		ids, err := GetAncestorFolderIDs(3, 1)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Equal([]uint{3, 2}, ids)
+	}
+
+	// 目录不存在
+	{
+		mock.ExpectQuery("SELECT(.+)folders(.+)").WithArgs(3, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "parent_id"}))
+		_, err := GetAncestorFolderIDs(3, 1)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+	}
+}
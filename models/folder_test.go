@@ -237,7 +237,7 @@ func TestFolder_MoveOrCopyFileTo(t *testing.T) {
 		mock.ExpectBegin()
 		mock.ExpectExec("INSERT(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectCommit()
-		storage, err := folder.MoveOrCopyFileTo(
+		storage, copied, err := folder.MoveOrCopyFileTo(
 			[]uint{1, 2, 3},
 			&dstFolder,
 			true,
@@ -245,6 +245,7 @@ func TestFolder_MoveOrCopyFileTo(t *testing.T) {
 		asserts.NoError(err)
 		asserts.NoError(mock.ExpectationsWereMet())
 		asserts.Equal(uint64(30), storage)
+		asserts.Len(copied, 2)
 	}
 
 	// 复制文件, 检索文件出错
@@ -257,7 +258,7 @@ func TestFolder_MoveOrCopyFileTo(t *testing.T) {
 				1,
 			).WillReturnError(errors.New("error"))
 
-		storage, err := folder.MoveOrCopyFileTo(
+		storage, _, err := folder.MoveOrCopyFileTo(
 			[]uint{1, 2},
 			&dstFolder,
 			true,
@@ -286,7 +287,7 @@ func TestFolder_MoveOrCopyFileTo(t *testing.T) {
 		mock.ExpectBegin()
 		mock.ExpectExec("INSERT(.+)").WillReturnError(errors.New("error"))
 		mock.ExpectRollback()
-		storage, err := folder.MoveOrCopyFileTo(
+		storage, _, err := folder.MoveOrCopyFileTo(
 			[]uint{1, 2},
 			&dstFolder,
 			true,
@@ -303,7 +304,7 @@ func TestFolder_MoveOrCopyFileTo(t *testing.T) {
 			WithArgs(10, sqlmock.AnyArg(), 1, 2, 1, 1).
 			WillReturnResult(sqlmock.NewResult(1, 2))
 		mock.ExpectCommit()
-		storage, err := folder.MoveOrCopyFileTo(
+		storage, _, err := folder.MoveOrCopyFileTo(
 			[]uint{1, 2},
 			&dstFolder,
 			false,
@@ -320,7 +321,7 @@ func TestFolder_MoveOrCopyFileTo(t *testing.T) {
 			WithArgs(10, sqlmock.AnyArg(), 1, 2, 1, 1).
 			WillReturnError(errors.New("error"))
 		mock.ExpectRollback()
-		storage, err := folder.MoveOrCopyFileTo(
+		storage, _, err := folder.MoveOrCopyFileTo(
 			[]uint{1, 2},
 			&dstFolder,
 			false,
@@ -386,10 +387,13 @@ func TestFolder_CopyFolderTo(t *testing.T) {
 		mock.ExpectExec("INSERT(.+)").WillReturnResult(sqlmock.NewResult(6, 1))
 		mock.ExpectCommit()
 
-		size, err := parFolder.CopyFolderTo(2, &dstFolder)
+		size, folders, files, err := parFolder.CopyFolderTo(2, &dstFolder)
 		asserts.NoError(mock.ExpectationsWereMet())
 		asserts.NoError(err)
 		asserts.Equal(uint64(30), size)
+		asserts.Len(folders, 3)
+		asserts.EqualValues(5, folders[0].ID)
+		asserts.Len(files, 2)
 	}
 
 	// 递归查询失败
@@ -397,7 +401,7 @@ func TestFolder_CopyFolderTo(t *testing.T) {
 		// GetRecursiveChildFolder
 		mock.ExpectQuery("SELECT(.+)").WithArgs(1, 2).WillReturnError(errors.New("error"))
 
-		size, err := parFolder.CopyFolderTo(2, &dstFolder)
+		size, _, _, err := parFolder.CopyFolderTo(2, &dstFolder)
 		asserts.NoError(mock.ExpectationsWereMet())
 		asserts.Error(err)
 		asserts.Equal(uint64(0), size)
@@ -416,7 +420,7 @@ func TestFolder_CopyFolderTo(t *testing.T) {
 		mock.ExpectExec("INSERT(.+)").WillReturnResult(sqlmock.NewResult(5, 1))
 		mock.ExpectCommit()
 
-		size, err := parFolder.CopyFolderTo(2, &dstFolder)
+		size, _, _, err := parFolder.CopyFolderTo(2, &dstFolder)
 		asserts.NoError(mock.ExpectationsWereMet())
 		asserts.Error(err)
 		asserts.Equal(uint64(0), size)
@@ -446,7 +450,7 @@ func TestFolder_CopyFolderTo(t *testing.T) {
 			WithArgs(1, 2, 3, 4).
 			WillReturnError(errors.New("error"))
 
-		size, err := parFolder.CopyFolderTo(2, &dstFolder)
+		size, _, _, err := parFolder.CopyFolderTo(2, &dstFolder)
 		asserts.NoError(mock.ExpectationsWereMet())
 		asserts.Error(err)
 		asserts.Equal(uint64(0), size)
@@ -488,7 +492,7 @@ func TestFolder_CopyFolderTo(t *testing.T) {
 		mock.ExpectExec("INSERT(.+)").WillReturnError(errors.New("error"))
 		mock.ExpectRollback()
 
-		size, err := parFolder.CopyFolderTo(2, &dstFolder)
+		size, _, _, err := parFolder.CopyFolderTo(2, &dstFolder)
 		asserts.NoError(mock.ExpectationsWereMet())
 		asserts.Error(err)
 		asserts.Equal(uint64(10), size)
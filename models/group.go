@@ -36,6 +36,9 @@ type GroupOption struct {
 	Aria2BatchSize   int                    `json:"aria2_batch,omitempty"`
 	AdvanceDelete    bool                   `json:"advance_delete,omitempty"`
 	WebDAVProxy      bool                   `json:"webdav_proxy,omitempty"`
+	TrashRetention   int                    `json:"trash_retention,omitempty"`   // 回收站保留天数，0 为不启用
+	MaxFileVersions  int                    `json:"max_file_versions,omitempty"` // 单个文件保留的历史版本数，0 为不启用
+	MaxVersionSize   uint64                 `json:"max_version_size,omitempty"`  // 单个文件历史版本的总大小上限
 }
 
 // GetGroupByID 用ID获取用户组
@@ -0,0 +1,153 @@
+package model
+
+import (
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// 生命周期规则的动作
+const (
+	// LifecycleActionTransition 迁移到其他存储策略
+	LifecycleActionTransition = "transition"
+	// LifecycleActionDelete 删除文件
+	LifecycleActionDelete = "delete"
+)
+
+// LifecycleRule 生命周期规则，同时满足全部条件的文件将执行规则的动作，条件为零值时不作限制
+type LifecycleRule struct {
+	gorm.Model
+	Name    string
+	Enabled bool
+	Action  string
+
+	// 条件
+	UserID   uint   // 限定用户
+	GroupID  uint   // 限定用户组
+	PolicyID uint   // 限定所在存储策略
+	Path     string `gorm:"type:text"` // 限定每个用户的目录，包含子目录
+	MinAge   int    // 创建超过的天数
+	MinIdle  int    // 未被访问的天数，从未被访问的文件按创建时间计算
+
+	// 迁移的目标存储策略
+	DstPolicyID uint
+}
+
+// GetLifecycleRuleByID 用ID获取生命周期规则
+func GetLifecycleRuleByID(id uint) (*LifecycleRule, error) {
+	var rule LifecycleRule
+	result := DB.First(&rule, id)
+	return &rule, result.Error
+}
+
+// GetEnabledLifecycleRules 列出已启用的生命周期规则
+func GetEnabledLifecycleRules() ([]LifecycleRule, error) {
+	var rules []LifecycleRule
+	result := DB.Where("enabled = ?", true).Order("id asc").Find(&rules)
+	return rules, result.Error
+}
+
+// Save 创建或更新生命周期规则
+func (rule *LifecycleRule) Save() error {
+	return DB.Save(rule).Error
+}
+
+// Delete 删除生命周期规则
+func (rule *LifecycleRule) Delete() error {
+	return DB.Delete(rule).Error
+}
+
+// FolderIDs 返回规则限定的全部目录ID，未限定目录时返回 nil
+func (rule *LifecycleRule) FolderIDs() ([]uint, error) {
+	names := strings.Split(strings.Trim(rule.Path, "/"), "/")
+	if names[0] == "" {
+		return nil, nil
+	}
+
+	// 逐级查找各用户下与路径匹配的目录
+	var folders []Folder
+	tx := DB.Where("parent_id in (?) and name = ?", DB.Model(&Folder{}).Select("id").Where("parent_id is NULL").QueryExpr(), names[0])
+	if rule.UserID > 0 {
+		tx = tx.Where("owner_id = ?", rule.UserID)
+	}
+	if err := tx.Find(&folders).Error; err != nil {
+		return nil, err
+	}
+
+	for _, name := range names[1:] {
+		if len(folders) == 0 {
+			break
+		}
+
+		parents := make([]uint, len(folders))
+		for i, folder := range folders {
+			parents[i] = folder.ID
+		}
+
+		folders = nil
+		if err := DB.Where("parent_id in (?) and name = ?", parents, name).Find(&folders).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	// 按用户分组查找子目录
+	owners := make(map[uint][]uint)
+	for _, folder := range folders {
+		owners[folder.OwnerID] = append(owners[folder.OwnerID], folder.ID)
+	}
+
+	res := make([]uint, 0, len(folders))
+	for owner, dirs := range owners {
+		children, err := GetRecursiveChildFolder(dirs, owner, true)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, child := range children {
+			res = append(res, child.ID)
+		}
+	}
+
+	return res, nil
+}
+
+// GetLifecycleMatches 列出满足规则条件且 ID 大于 after 的文件，按 ID 升序。folderIDs 为规则限定的目录，
+// 为 nil 时不限定目录
+func GetLifecycleMatches(rule *LifecycleRule, folderIDs []uint, now time.Time, after uint, limit int) ([]File, error) {
+	var files []File
+	tx := DB.Where("id > ? and upload_session_id is NULL", after)
+	if rule.UserID > 0 {
+		tx = tx.Where("user_id = ?", rule.UserID)
+	}
+
+	if rule.GroupID > 0 {
+		tx = tx.Where("user_id in (?)", DB.Model(&User{}).Select("id").Where("group_id = ?", rule.GroupID).QueryExpr())
+	}
+
+	if rule.PolicyID > 0 {
+		tx = tx.Where("policy_id = ?", rule.PolicyID)
+	}
+
+	if rule.Action == LifecycleActionTransition {
+		tx = tx.Where("policy_id <> ?", rule.DstPolicyID)
+	}
+
+	if folderIDs != nil {
+		if len(folderIDs) == 0 {
+			return files, nil
+		}
+		tx = tx.Where("folder_id in (?)", folderIDs)
+	}
+
+	if rule.MinAge > 0 {
+		tx = tx.Where("created_at < ?", now.AddDate(0, 0, -rule.MinAge))
+	}
+
+	if rule.MinIdle > 0 {
+		tx = tx.Where("COALESCE(accessed_at, created_at) < ?", now.AddDate(0, 0, -rule.MinIdle))
+	}
+
+	result := tx.Order("id asc").Limit(limit).Find(&files)
+	return files, result.Error
+}
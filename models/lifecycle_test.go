@@ -0,0 +1,165 @@
+package model
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetLifecycleRuleByID(t *testing.T) {
+	asserts := assert.New(t)
+
+	mock.ExpectQuery("SELECT(.+)lifecycle_rules(.+)").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "action"}).AddRow(1, LifecycleActionDelete))
+	rule, err := GetLifecycleRuleByID(1)
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	asserts.Equal(LifecycleActionDelete, rule.Action)
+
+	mock.ExpectQuery("SELECT(.+)lifecycle_rules(.+)").
+		WithArgs(true).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+	rules, err := GetEnabledLifecycleRules()
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	asserts.Len(rules, 2)
+}
+
+func TestLifecycleRule_SaveAndDelete(t *testing.T) {
+	asserts := assert.New(t)
+	rule := &LifecycleRule{Name: "rule", Action: LifecycleActionDelete}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT(.+)lifecycle_rules(.+)").WillReturnResult(sqlmock.NewResult(3, 1))
+	mock.ExpectCommit()
+	asserts.NoError(rule.Save())
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.EqualValues(3, rule.ID)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)lifecycle_rules(.+)deleted_at(.+)").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	asserts.NoError(rule.Delete())
+	asserts.NoError(mock.ExpectationsWereMet())
+}
+
+func TestLifecycleRule_FolderIDs(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 未限定目录
+	{
+		res, err := (&LifecycleRule{Path: "/"}).FolderIDs()
+		asserts.NoError(err)
+		asserts.Nil(res)
+	}
+
+	// 查找失败
+	{
+		mock.ExpectQuery("SELECT(.+)folders(.+)parent_id is NULL(.+)").WillReturnError(errors.New("error"))
+		res, err := (&LifecycleRule{Path: "/tmp"}).FolderIDs()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+		asserts.Nil(res)
+	}
+
+	// 路径不存在
+	{
+		mock.ExpectQuery("SELECT(.+)folders(.+)parent_id is NULL(.+)").
+			WithArgs("a", 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}).AddRow(2, 1))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WithArgs(2, "b").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}))
+		res, err := (&LifecycleRule{Path: "/a/b/", UserID: 1}).FolderIDs()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Empty(res)
+		asserts.NotNil(res)
+	}
+
+	// 成功
+	{
+		mock.ExpectQuery("SELECT(.+)folders(.+)parent_id is NULL(.+)").
+			WithArgs("tmp").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}).AddRow(2, 1))
+		// 子目录
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WithArgs(1, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}).AddRow(2, 1))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WithArgs(1, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}).AddRow(3, 1))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WithArgs(1, 3).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}))
+		res, err := (&LifecycleRule{Path: "/tmp"}).FolderIDs()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Equal([]uint{2, 3}, res)
+	}
+}
+
+func TestGetLifecycleMatches(t *testing.T) {
+	asserts := assert.New(t)
+	now := time.Now()
+
+	// 限定目录为空
+	{
+		files, err := GetLifecycleMatches(&LifecycleRule{}, []uint{}, now, 0, 100)
+		asserts.NoError(err)
+		asserts.Empty(files)
+	}
+
+	// 删除
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)user_id = \\?(.+)created_at <(.+)").
+			WithArgs(10, 1, now.AddDate(0, 0, -30)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(11))
+		files, err := GetLifecycleMatches(&LifecycleRule{Action: LifecycleActionDelete, UserID: 1, MinAge: 30}, nil, now, 10, 100)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Len(files, 1)
+	}
+
+	// 迁移
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)users(.+)policy_id <> \\?(.+)folder_id in(.+)COALESCE\\(accessed_at, created_at\\)(.+)").
+			WithArgs(0, 3, 1, 2, 4, 5, now.AddDate(0, 0, -90)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		files, err := GetLifecycleMatches(&LifecycleRule{
+			Action:      LifecycleActionTransition,
+			GroupID:     3,
+			PolicyID:    1,
+			DstPolicyID: 2,
+			MinIdle:     90,
+		}, []uint{4, 5}, now, 0, 100)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Empty(files)
+	}
+}
+
+func TestFile_UpdateAccessTime(t *testing.T) {
+	asserts := assert.New(t)
+	now := time.Now()
+
+	// 未保存的文件
+	asserts.NoError((&File{}).UpdateAccessTime(now))
+
+	// 距上次记录时间过短
+	recent := now.Add(-time.Minute)
+	asserts.NoError((&File{Model: gorm.Model{ID: 1}, AccessedAt: &recent}).UpdateAccessTime(now))
+
+	// 成功
+	file := &File{Model: gorm.Model{ID: 1}}
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)files(.+)accessed_at(.+)").WithArgs(now, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	asserts.NoError(file.UpdateAccessTime(now))
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.Equal(now, *file.AccessedAt)
+}
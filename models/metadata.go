@@ -0,0 +1,288 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// UserMetadataPrefix 用户自定义元数据键的命名空间前缀，与系统元数据隔离
+const UserMetadataPrefix = "user."
+
+// 用户自定义元数据的限制
+const (
+	MaxUserMetadataCount    = 64
+	MaxUserMetadataKeyLen   = 128
+	MaxUserMetadataValueLen = 1024
+)
+
+var (
+	ErrInvalidMetadataKey    = errors.New("invalid metadata key")
+	ErrMetadataValueTooLong  = errors.New("metadata value is too long")
+	ErrTooManyUserMetadata   = errors.New("too many metadata entries")
+	userMetadataKeyValidator = regexp.MustCompile(`^[\w.\-:]+$`)
+)
+
+// ValidateUserMetadataKey 检查用户元数据键是否合法
+func ValidateUserMetadataKey(key string) error {
+	if key == "" || len(key) > MaxUserMetadataKeyLen || !userMetadataKeyValidator.MatchString(key) {
+		return ErrInvalidMetadataKey
+	}
+
+	return nil
+}
+
+// UserMetadata 返回元数据中用户自定义的部分，键不含命名空间前缀
+func UserMetadata(metadata map[string]string) map[string]string {
+	res := make(map[string]string)
+	for k, v := range metadata {
+		if strings.HasPrefix(k, UserMetadataPrefix) {
+			res[strings.TrimPrefix(k, UserMetadataPrefix)] = v
+		}
+	}
+
+	return res
+}
+
+// likeEscapeChar LIKE 模式中的转义字符，查询时需附带 escape 子句
+const likeEscapeChar = "!"
+
+var likeEscaper = strings.NewReplacer(likeEscapeChar, likeEscapeChar+likeEscapeChar, "%", likeEscapeChar+"%", "_", likeEscapeChar+"_")
+
+// EscapeLike 转义 LIKE 模式中的通配符，使 s 按字面匹配
+func EscapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+// EscapeLikeEscapeChar 只转义模式中的转义字符，保留其中的通配符
+func EscapeLikeEscapeChar(pattern string) string {
+	return strings.ReplaceAll(pattern, likeEscapeChar, likeEscapeChar+likeEscapeChar)
+}
+
+// MetadataSearchPattern 生成匹配序列化后用户元数据的 LIKE 模式，value 为空时只匹配键，
+// 模式中的通配符已转义
+func MetadataSearchPattern(key, value string) string {
+	encodedKey, _ := json.Marshal(UserMetadataPrefix + key)
+	pattern := string(encodedKey) + ":"
+	if value != "" {
+		encodedValue, _ := json.Marshal(value)
+		pattern += string(encodedValue)
+	}
+
+	return "%" + EscapeLike(pattern) + "%"
+}
+
+// mergeUserMetadata 在 metadata 上设置和删除用户元数据，返回序列化后的结果
+func mergeUserMetadata(metadata map[string]string, set map[string]string, remove []string) (string, error) {
+	for _, k := range remove {
+		if err := ValidateUserMetadataKey(k); err != nil {
+			return "", err
+		}
+	}
+
+	for k, v := range set {
+		if err := ValidateUserMetadataKey(k); err != nil {
+			return "", err
+		}
+		if len(v) > MaxUserMetadataValueLen {
+			return "", ErrMetadataValueTooLong
+		}
+	}
+
+	merged := make(map[string]string, len(metadata)+len(set))
+	for k, v := range metadata {
+		merged[k] = v
+	}
+	for _, k := range remove {
+		delete(merged, UserMetadataPrefix+k)
+	}
+	for k, v := range set {
+		merged[UserMetadataPrefix+k] = v
+	}
+
+	if len(UserMetadata(merged)) > MaxUserMetadataCount {
+		return "", ErrTooManyUserMetadata
+	}
+
+	metaValue, err := json.Marshal(merged)
+	if err != nil {
+		return "", err
+	}
+
+	for k := range metadata {
+		delete(metadata, k)
+	}
+	for k, v := range merged {
+		metadata[k] = v
+	}
+
+	return string(metaValue), nil
+}
+
+// UpdateUserMetadata 设置和删除文件的用户自定义元数据，系统元数据不受影响
+func (file *File) UpdateUserMetadata(set map[string]string, remove []string) error {
+	if file.MetadataSerialized == nil {
+		file.MetadataSerialized = make(map[string]string)
+	}
+
+	metaValue, err := mergeUserMetadata(file.MetadataSerialized, set, remove)
+	if err != nil {
+		return err
+	}
+
+	file.Metadata = metaValue
+	return DB.Model(&file).Set("gorm:association_autoupdate", false).UpdateColumn("metadata", metaValue).Error
+}
+
+// UpdateUserMetadata 设置和删除目录的用户自定义元数据
+func (folder *Folder) UpdateUserMetadata(set map[string]string, remove []string) error {
+	if folder.MetadataSerialized == nil {
+		folder.MetadataSerialized = make(map[string]string)
+	}
+
+	metaValue, err := mergeUserMetadata(folder.MetadataSerialized, set, remove)
+	if err != nil {
+		return err
+	}
+
+	folder.Metadata = metaValue
+	return DB.Model(&folder).UpdateColumn("metadata", metaValue).Error
+}
+
+// GetFilesByUserMetadata 根据关键字搜索用户自定义元数据的键或值匹配的文件，
+// keywords 为以 likeEscapeChar 转义的 SQL LIKE 模式
+func GetFilesByUserMetadata(uid uint, parents []uint, keywords ...interface{}) ([]File, error) {
+	var (
+		files      []File
+		conditions = make([]string, 0, len(keywords))
+		args       = make([]interface{}, 0, len(keywords))
+		patterns   = make([]*regexp.Regexp, 0, len(keywords))
+	)
+
+	for _, keyword := range keywords {
+		pattern, ok := keyword.(string)
+		if !ok {
+			continue
+		}
+
+		re, err := likeRegexp(pattern)
+		if err != nil {
+			continue
+		}
+
+		conditions = append(conditions, "metadata like ? escape '"+likeEscapeChar+"'")
+		args = append(args, metadataLikePattern(pattern))
+		patterns = append(patterns, re)
+	}
+
+	if len(patterns) == 0 {
+		return []File{}, nil
+	}
+
+	result := DB.Where("metadata like ?", `%"`+UserMetadataPrefix+"%").
+		Where("("+strings.Join(conditions, " or ")+")", args...)
+
+	if uid != 0 {
+		result = result.Where("user_id = ?", uid)
+	}
+
+	if len(parents) > 0 {
+		result = result.Where("folder_id in (?)", parents)
+	}
+
+	if err := result.Find(&files).Error; err != nil {
+		return nil, err
+	}
+
+	// 数据库只能匹配序列化后的整段元数据，需逐条确认命中的是用户元数据的键或值
+	matched := make([]File, 0, len(files))
+	for _, file := range files {
+		if userMetadataMatch(file.MetadataSerialized, patterns) {
+			matched = append(matched, file)
+		}
+	}
+
+	return matched, nil
+}
+
+// metadataLikePattern 将匹配单个键或值的 LIKE 模式转换为匹配序列化后元数据的模式，
+// 字面部分按 JSON 编码后转义，通配符统一放宽为 %，结果只用于缩小候选范围
+func metadataLikePattern(pattern string) string {
+	var (
+		res     strings.Builder
+		literal strings.Builder
+	)
+
+	flush := func() {
+		if literal.Len() == 0 {
+			return
+		}
+		encoded, _ := json.Marshal(literal.String())
+		res.WriteString(EscapeLike(string(encoded[1 : len(encoded)-1])))
+		res.WriteString("%")
+		literal.Reset()
+	}
+
+	res.WriteString("%")
+	splitLike(pattern, func(c rune, wildcard bool) {
+		if wildcard {
+			flush()
+		} else {
+			literal.WriteRune(c)
+		}
+	})
+	flush()
+
+	return res.String()
+}
+
+// userMetadataMatch 判断用户元数据的键或值是否匹配任一模式
+func userMetadataMatch(metadata map[string]string, patterns []*regexp.Regexp) bool {
+	for k, v := range UserMetadata(metadata) {
+		for _, re := range patterns {
+			if re.MatchString(k) || re.MatchString(v) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// likeRegexp 将 SQL LIKE 模式转换为不区分大小写的正则表达式
+func likeRegexp(pattern string) (*regexp.Regexp, error) {
+	var expr strings.Builder
+	expr.WriteString("(?is)^")
+	splitLike(pattern, func(c rune, wildcard bool) {
+		switch {
+		case wildcard && c == '%':
+			expr.WriteString(".*")
+		case wildcard:
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	})
+	expr.WriteString("$")
+
+	return regexp.Compile(expr.String())
+}
+
+// splitLike 逐字符解析以 likeEscapeChar 转义的 LIKE 模式，wildcard 表示该字符是否为通配符
+func splitLike(pattern string, fn func(c rune, wildcard bool)) {
+	escaped := false
+	for _, c := range pattern {
+		switch {
+		case escaped:
+			fn(c, false)
+			escaped = false
+		case string(c) == likeEscapeChar:
+			escaped = true
+		case c == '%' || c == '_':
+			fn(c, true)
+		default:
+			fn(c, false)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package model
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserMetadata(t *testing.T) {
+	a := assert.New(t)
+	a.Equal(map[string]string{"project": "alpha"}, UserMetadata(map[string]string{
+		ThumbStatusMetadataKey: ThumbStatusNotAvailable,
+		"user.project":         "alpha",
+	}))
+	a.Empty(UserMetadata(nil))
+}
+
+func TestMetadataSearchPattern(t *testing.T) {
+	a := assert.New(t)
+	a.Equal(`%"user.project":"alpha"%`, MetadataSearchPattern("project", "alpha"))
+	a.Equal(`%"user.project":%`, MetadataSearchPattern("project", ""))
+	a.Equal(`%"user.a!_b":"50!%!!"%`, MetadataSearchPattern("a_b", "50%!"))
+}
+
+func TestEscapeLike(t *testing.T) {
+	a := assert.New(t)
+	a.Equal("50!%!_off!!", EscapeLike("50%_off!"))
+	a.Equal("%.jpg!!", EscapeLikeEscapeChar("%.jpg!"))
+}
+
+func TestMetadataLikePattern(t *testing.T) {
+	a := assert.New(t)
+	a.Equal("%alpha%", metadataLikePattern("%alpha%"))
+	a.Equal("%.jpg%", metadataLikePattern("%.jpg"))
+	a.Equal(`%a%c%`, metadataLikePattern("a_c"))
+	a.Equal(`%say \"hi\"!!%`, metadataLikePattern(`%say "hi"!!%`))
+	a.Equal(`%50!%!_off%`, metadataLikePattern(`%50!%!_off%`))
+	a.Equal(`%\u003cb\u003e%`, metadataLikePattern("<b>"))
+}
+
+func TestFile_UpdateUserMetadata(t *testing.T) {
+	a := assert.New(t)
+	file := &File{MetadataSerialized: map[string]string{
+		ThumbStatusMetadataKey: ThumbStatusNotAvailable,
+		"user.obsolete":        "1",
+	}}
+	file.ID = 1
+
+	// 非法的键，不修改已有元数据
+	{
+		a.ErrorIs(file.UpdateUserMetadata(map[string]string{"bad key": "1"}, nil), ErrInvalidMetadataKey)
+		a.ErrorIs(file.UpdateUserMetadata(nil, []string{""}), ErrInvalidMetadataKey)
+		a.ErrorIs(file.UpdateUserMetadata(map[string]string{"k": strings.Repeat("v", MaxUserMetadataValueLen+1)}, nil), ErrMetadataValueTooLong)
+		a.Equal("1", file.MetadataSerialized["user.obsolete"])
+	}
+
+	// 超出数量限制
+	{
+		set := make(map[string]string)
+		for i := 0; i < MaxUserMetadataCount; i++ {
+			set[strings.Repeat("k", i+1)] = "v"
+		}
+		a.ErrorIs(file.UpdateUserMetadata(set, nil), ErrTooManyUserMetadata)
+	}
+
+	// 更新失败
+	{
+		expectedErr := errors.New("error")
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)").WithArgs(sqlmock.AnyArg(), 1).WillReturnError(expectedErr)
+		mock.ExpectRollback()
+		a.ErrorIs(file.UpdateUserMetadata(map[string]string{"project": "alpha"}, nil), expectedErr)
+		a.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 成功，系统元数据不受影响
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)").WithArgs(sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		a.NoError(file.UpdateUserMetadata(map[string]string{"thumb_status": "user"}, []string{"obsolete"}))
+		a.NoError(mock.ExpectationsWereMet())
+		a.Equal(ThumbStatusNotAvailable, file.MetadataSerialized[ThumbStatusMetadataKey])
+		a.Equal(map[string]string{"project": "alpha", "thumb_status": "user"}, UserMetadata(file.MetadataSerialized))
+		a.NotContains(file.MetadataSerialized, "user.obsolete")
+	}
+}
+
+func TestFolder_UpdateUserMetadata(t *testing.T) {
+	a := assert.New(t)
+	folder := &Folder{}
+	folder.ID = 1
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)folders(.+)").WithArgs(`{"user.project":"alpha"}`, 1).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	a.NoError(folder.UpdateUserMetadata(map[string]string{"project": "alpha"}, nil))
+	a.NoError(mock.ExpectationsWereMet())
+	a.Equal(`{"user.project":"alpha"}`, folder.Metadata)
+}
+
+func TestFolder_AfterFind(t *testing.T) {
+	a := assert.New(t)
+	folder := &Folder{Metadata: `{"user.project":"alpha"}`}
+	a.NoError(folder.AfterFind())
+	a.Equal("alpha", folder.MetadataSerialized["user.project"])
+
+	folder = &Folder{}
+	a.NoError(folder.AfterFind())
+	a.NotNil(folder.MetadataSerialized)
+}
+
+func TestGetFilesByUserMetadata(t *testing.T) {
+	a := assert.New(t)
+
+	// 查询失败
+	{
+		mock.ExpectQuery("SELECT(.+)").WillReturnError(errors.New("error"))
+		_, err := GetFilesByUserMetadata(1, nil, "%alpha%")
+		a.NoError(mock.ExpectationsWereMet())
+		a.Error(err)
+	}
+
+	// 只匹配用户元数据的键或值
+	{
+		mock.ExpectQuery("SELECT(.+)metadata like (.+) escape (.+)").
+			WithArgs(`%"user.%`, "%alpha%", 1, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "metadata"}).
+				AddRow(1, `{"user.project":"Alpha"}`).
+				AddRow(2, `{"user.alpha_ref":"1"}`).
+				AddRow(3, `{"user.project":"beta","thumb_sidecar":"alpha"}`))
+		files, err := GetFilesByUserMetadata(1, []uint{2}, "%alpha%")
+		a.NoError(mock.ExpectationsWereMet())
+		a.NoError(err)
+		a.Len(files, 2)
+		a.EqualValues(1, files[0].ID)
+		a.EqualValues(2, files[1].ID)
+	}
+
+	// 没有有效的关键字
+	{
+		files, err := GetFilesByUserMetadata(1, nil, 1)
+		a.NoError(mock.ExpectationsWereMet())
+		a.NoError(err)
+		a.Empty(files)
+	}
+}
+
+func TestLikeRegexp(t *testing.T) {
+	a := assert.New(t)
+	likeMatch := func(pattern, s string) bool {
+		re, err := likeRegexp(pattern)
+		a.NoError(err)
+		return re.MatchString(s)
+	}
+	a.True(likeMatch("%.jpg", "A.JPG"))
+	a.True(likeMatch("a_c", "abc"))
+	a.False(likeMatch("a_c", "abbc"))
+	a.False(likeMatch("%.jpg", "a.jpg.txt"))
+	a.True(likeMatch("%(1)%", "copy (1).txt"))
+	a.True(likeMatch("50!%!_off", "50%_off"))
+	a.False(likeMatch("50!%", "500"))
+	a.True(likeMatch("a!!b", "a!b"))
+}
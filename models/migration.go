@@ -42,7 +42,9 @@ func migration() {
 	}
 
 	DB.AutoMigrate(&User{}, &Setting{}, &Group{}, &Policy{}, &Folder{}, &File{}, &Share{},
-		&Task{}, &Download{}, &Tag{}, &Webdav{}, &Node{}, &SourceLink{})
+		&Task{}, &Download{}, &Tag{}, &Webdav{}, &Node{}, &SourceLink{}, &Trash{}, &FileVersion{},
+		&SearchIndex{}, &SearchTerm{}, &FolderQuota{}, &Replica{}, &LifecycleRule{}, &Change{}, &Star{}, &RecentFile{},
+		&UploadSession{})
 
 	// 创建初始存储策略
 	addDefaultPolicy()
@@ -72,6 +72,8 @@ type PolicyOption struct {
 	S3ForcePathStyle bool `json:"s3_path_style"`
 	// File extensions that support thumbnail generation using native policy API.
 	ThumbExts []string `json:"thumb_exts,omitempty"`
+	// 副本存储策略，上传的文件将异步复制到此存储策略
+	ReplicaPolicyID uint `json:"replica_policy_id,omitempty"`
 }
 
 func init() {
@@ -0,0 +1,68 @@
+package model
+
+import (
+	"time"
+)
+
+// 最近文件的访问类型
+const (
+	RecentDownload = "download"
+	RecentPreview  = "preview"
+	RecentEdit     = "edit"
+	RecentUpload   = "upload"
+)
+
+// MaxRecentFiles 每个用户保留的最近文件记录数
+const MaxRecentFiles = 100
+
+// RecentFile 用户最近访问或修改的文件，每个文件只保留最后一次记录
+type RecentFile struct {
+	ID         uint      `gorm:"primary_key"`
+	UserID     uint      `gorm:"unique_index:idx_recent_file"`
+	FileID     uint      `gorm:"unique_index:idx_recent_file"`
+	Action     string    // 最后一次访问的类型
+	AccessedAt time.Time `gorm:"index:idx_recent_accessed_at"`
+}
+
+// RecordRecentFile 记录用户访问文件，超出保留数量的旧记录会被清除
+func RecordRecentFile(uid, fileID uint, action string, now time.Time) error {
+	result := DB.Model(&RecentFile{}).Where("user_id = ? and file_id = ?", uid, fileID).
+		Updates(map[string]interface{}{"action": action, "accessed_at": now})
+	if result.Error != nil || result.RowsAffected > 0 {
+		return result.Error
+	}
+
+	if err := DB.Create(&RecentFile{UserID: uid, FileID: fileID, Action: action, AccessedAt: now}).Error; err != nil {
+		return err
+	}
+
+	// 新增记录后清除超出保留数量的部分
+	var expired []RecentFile
+	if err := DB.Select("id").Where("user_id = ?", uid).Order("accessed_at desc, id desc").
+		Offset(MaxRecentFiles).Limit(MaxRecentFiles).Find(&expired).Error; err != nil || len(expired) == 0 {
+		return err
+	}
+
+	ids := make([]uint, len(expired))
+	for i, recent := range expired {
+		ids[i] = recent.ID
+	}
+
+	return DB.Where("id in (?)", ids).Delete(&RecentFile{}).Error
+}
+
+// ListRecentFiles 列出用户最近的文件记录，最近的在前
+func ListRecentFiles(uid uint, limit int) ([]RecentFile, error) {
+	var recents []RecentFile
+	result := DB.Where("user_id = ?", uid).Order("accessed_at desc, id desc").Limit(limit).Find(&recents)
+	return recents, result.Error
+}
+
+// DeleteRecentFilesByFileIDs 删除文件被删除后遗留的最近文件记录
+func DeleteRecentFilesByFileIDs(ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	return DB.Where("file_id in (?)", ids).Delete(&RecentFile{}).Error
+}
@@ -0,0 +1,92 @@
+package model
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordRecentFile(t *testing.T) {
+	a := assert.New(t)
+	now := time.Now()
+
+	// 已有记录，更新访问时间
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)recent_files(.+)").
+			WithArgs(now, RecentPreview, 1, 2).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		a.NoError(RecordRecentFile(1, 2, RecentPreview, now))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 更新失败
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)recent_files(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		a.Error(RecordRecentFile(1, 2, RecentPreview, now))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 新增记录，未超出保留数量
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)recent_files(.+)").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)recent_files(.+)").
+			WithArgs(1, 2, RecentUpload, now).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		mock.ExpectQuery("SELECT id FROM (.+)recent_files(.+)OFFSET 100").WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		a.NoError(RecordRecentFile(1, 2, RecentUpload, now))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 新增记录，清除超出保留数量的部分
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)recent_files(.+)").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)recent_files(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		mock.ExpectQuery("SELECT id FROM (.+)recent_files(.+)").WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3).AddRow(4))
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)recent_files(.+)").WithArgs(3, 4).WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectCommit()
+		a.NoError(RecordRecentFile(1, 2, RecentUpload, now))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestListRecentFiles(t *testing.T) {
+	a := assert.New(t)
+
+	mock.ExpectQuery("SELECT(.+)recent_files(.+)ORDER BY accessed_at desc, id desc LIMIT 10").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "file_id"}).AddRow(2, 3))
+	recents, err := ListRecentFiles(1, 10)
+	a.NoError(mock.ExpectationsWereMet())
+	a.NoError(err)
+	a.Len(recents, 1)
+	a.EqualValues(3, recents[0].FileID)
+}
+
+func TestDeleteRecentFilesByFileIDs(t *testing.T) {
+	a := assert.New(t)
+	a.NoError(DeleteRecentFilesByFileIDs(nil))
+
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE(.+)recent_files(.+)").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	a.NoError(DeleteRecentFilesByFileIDs([]uint{1}))
+	a.NoError(mock.ExpectationsWereMet())
+}
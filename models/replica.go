@@ -0,0 +1,91 @@
+package model
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+// Replica 物理文件在副本存储策略上的副本
+type Replica struct {
+	gorm.Model
+	PolicyID        uint   `gorm:"index:idx_replica_policy"`
+	SourceName      string `gorm:"type:text"`
+	ReplicaPolicyID uint
+	ReplicaSource   string `gorm:"type:text"`
+	Size            uint64
+	Hash            string `gorm:"size:64"`
+}
+
+// GetReplica 根据主存储策略及物理路径查找副本
+func GetReplica(policyID uint, source string) (*Replica, error) {
+	var replica Replica
+	result := DB.Where("policy_id = ? and source_name = ?", policyID, source).First(&replica)
+	return &replica, result.Error
+}
+
+// GetReplicasBySources 批量查找主存储策略上物理文件的副本
+func GetReplicasBySources(policyID uint, sources []string) ([]Replica, error) {
+	var replicas []Replica
+	result := DB.Where("policy_id = ? and source_name in (?)", policyID, sources).Find(&replicas)
+	return replicas, result.Error
+}
+
+// Save 创建或更新副本记录
+func (replica *Replica) Save() error {
+	return DB.Save(replica).Error
+}
+
+// UpToDate 副本是否与文件当前内容一致，文件未记录摘要时无法判断
+func (replica *Replica) UpToDate(file *File, replicaPolicyID uint) bool {
+	return replica.ID > 0 &&
+		replica.ReplicaPolicyID == replicaPolicyID &&
+		file.Hash != "" &&
+		replica.Hash == file.Hash &&
+		replica.Size == file.Size
+}
+
+// DeleteReplicas 删除副本记录
+func DeleteReplicas(replicas []Replica) error {
+	if len(replicas) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, len(replicas))
+	for i, replica := range replicas {
+		ids[i] = replica.ID
+	}
+
+	return DB.Unscoped().Where("id in (?)", ids).Delete(&Replica{}).Error
+}
+
+// GetReplicatedPolicies 列出设定了副本存储策略的存储策略
+func GetReplicatedPolicies() ([]Policy, error) {
+	var policies []Policy
+	if err := DB.Order("id asc").Find(&policies).Error; err != nil {
+		return nil, err
+	}
+
+	res := make([]Policy, 0, len(policies))
+	for _, policy := range policies {
+		if replica := policy.OptionsSerialized.ReplicaPolicyID; replica > 0 && replica != policy.ID {
+			res = append(res, policy)
+		}
+	}
+
+	return res, nil
+}
+
+// GetFilesMissingReplica 列出存储策略上缺少副本或副本已过期的文件，按 ID 升序
+func GetFilesMissingReplica(policyID, replicaPolicyID, after uint, limit int) ([]File, error) {
+	var files []File
+	result := DB.
+		Where("id > ? and policy_id = ? and upload_session_id is NULL", after, policyID).
+		Where("not exists (?)", DB.Model(&Replica{}).Select("id").Where(
+			"replicas.policy_id = files.policy_id and replicas.source_name = files.source_name"+
+				" and replicas.replica_policy_id = ? and replicas.size = files.size and replicas.hash = files.hash",
+			replicaPolicyID,
+		).QueryExpr()).
+		Order("id asc").
+		Limit(limit).
+		Find(&files)
+	return files, result.Error
+}
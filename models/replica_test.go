@@ -0,0 +1,121 @@
+package model
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetReplica(t *testing.T) {
+	asserts := assert.New(t)
+
+	mock.ExpectQuery("SELECT(.+)replicas(.+)").
+		WithArgs(1, "a.txt").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "replica_policy_id", "replica_source"}).AddRow(3, 2, "b.txt"))
+	replica, err := GetReplica(1, "a.txt")
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	asserts.EqualValues(2, replica.ReplicaPolicyID)
+	asserts.Equal("b.txt", replica.ReplicaSource)
+
+	mock.ExpectQuery("SELECT(.+)replicas(.+)").
+		WithArgs(1, "a.txt", "c.txt").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3).AddRow(4))
+	replicas, err := GetReplicasBySources(1, []string{"a.txt", "c.txt"})
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	asserts.Len(replicas, 2)
+}
+
+func TestReplica_Save(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 新建
+	{
+		replica := &Replica{PolicyID: 1, SourceName: "a.txt", ReplicaPolicyID: 2, ReplicaSource: "b.txt"}
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)replicas(.+)").WillReturnResult(sqlmock.NewResult(5, 1))
+		mock.ExpectCommit()
+		asserts.NoError(replica.Save())
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.EqualValues(5, replica.ID)
+	}
+
+	// 更新
+	{
+		replica := &Replica{Model: gorm.Model{ID: 5}, Size: 10}
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)replicas(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		asserts.Error(replica.Save())
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestReplica_UpToDate(t *testing.T) {
+	asserts := assert.New(t)
+	replica := &Replica{Model: gorm.Model{ID: 1}, ReplicaPolicyID: 2, Size: 10, Hash: "hash"}
+
+	asserts.True(replica.UpToDate(&File{Size: 10, Hash: "hash"}, 2))
+	asserts.False(replica.UpToDate(&File{Size: 10, Hash: "hash"}, 3))
+	asserts.False(replica.UpToDate(&File{Size: 11, Hash: "hash"}, 2))
+	asserts.False(replica.UpToDate(&File{Size: 10, Hash: "other"}, 2))
+	asserts.False(replica.UpToDate(&File{Size: 10}, 2))
+	asserts.False((&Replica{ReplicaPolicyID: 2, Size: 10, Hash: "hash"}).UpToDate(&File{Size: 10, Hash: "hash"}, 2))
+}
+
+func TestDeleteReplicas(t *testing.T) {
+	asserts := assert.New(t)
+
+	asserts.NoError(DeleteReplicas(nil))
+
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE(.+)replicas(.+)").
+		WithArgs(1, 2).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	asserts.NoError(DeleteReplicas([]Replica{{Model: gorm.Model{ID: 1}}, {Model: gorm.Model{ID: 2}}}))
+	asserts.NoError(mock.ExpectationsWereMet())
+}
+
+func TestGetReplicatedPolicies(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 查询失败
+	{
+		mock.ExpectQuery("SELECT(.+)policies(.+)").WillReturnError(errors.New("error"))
+		res, err := GetReplicatedPolicies()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+		asserts.Nil(res)
+	}
+
+	// 成功
+	{
+		mock.ExpectQuery("SELECT(.+)policies(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "options"}).
+				AddRow(1, `{"replica_policy_id":2}`).
+				AddRow(2, `{}`).
+				AddRow(3, `{"replica_policy_id":3}`))
+		res, err := GetReplicatedPolicies()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Len(res, 1)
+		asserts.EqualValues(1, res[0].ID)
+	}
+}
+
+func TestGetFilesMissingReplica(t *testing.T) {
+	asserts := assert.New(t)
+
+	mock.ExpectQuery("SELECT(.+)files(.+)not exists(.+)replicas(.+)").
+		WithArgs(10, 1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(11).AddRow(12))
+	files, err := GetFilesMissingReplica(1, 2, 10, 100)
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	asserts.Len(files, 2)
+}
@@ -0,0 +1,148 @@
+package model
+
+import (
+	"math"
+	"sort"
+
+	"github.com/jinzhu/gorm"
+)
+
+// SearchIndex 文件内容的全文索引记录，保存提取出的文本用于生成摘要
+type SearchIndex struct {
+	gorm.Model
+	FileID  uint   `gorm:"unique_index:idx_search_file"`
+	UserID  uint   `gorm:"index:idx_search_user"`
+	Content string `gorm:"type:text"`
+}
+
+// SearchTerm 倒排索引中的索引词
+type SearchTerm struct {
+	ID        uint   `gorm:"primary_key"`
+	Term      string `gorm:"size:64;index:idx_search_term"`
+	FileID    uint   `gorm:"index:idx_search_term_file"`
+	UserID    uint   `gorm:"index:idx_search_term"`
+	Frequency int
+}
+
+// ContentSearchHit 全文搜索的命中结果
+type ContentSearchHit struct {
+	FileID uint
+	Score  float64
+}
+
+// Save 保存文件的索引记录及索引词，替换已有的索引
+func (index *SearchIndex) Save(terms map[string]int) error {
+	tx := DB.Begin()
+	if err := deleteSearchIndex(tx, []uint{index.FileID}); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Create(index).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for term, frequency := range terms {
+		if err := tx.Create(&SearchTerm{
+			Term:      term,
+			FileID:    index.FileID,
+			UserID:    index.UserID,
+			Frequency: frequency,
+		}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+func deleteSearchIndex(tx *gorm.DB, fileIDs []uint) error {
+	if err := tx.Unscoped().Where("file_id in (?)", fileIDs).Delete(&SearchIndex{}).Error; err != nil {
+		return err
+	}
+
+	return tx.Where("file_id in (?)", fileIDs).Delete(&SearchTerm{}).Error
+}
+
+// DeleteSearchIndexByFileIDs 删除文件的全文索引
+func DeleteSearchIndexByFileIDs(fileIDs []uint) error {
+	if len(fileIDs) == 0 {
+		return nil
+	}
+
+	tx := DB.Begin()
+	if err := deleteSearchIndex(tx, fileIDs); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// GetSearchIndexByFileIDs 根据文件ID批量获取索引记录
+func GetSearchIndexByFileIDs(fileIDs []uint) ([]SearchIndex, error) {
+	var indexes []SearchIndex
+	result := DB.Where("file_id in (?)", fileIDs).Find(&indexes)
+	return indexes, result.Error
+}
+
+// GetIndexableFiles 分页列出用户已完成上传的文件，用于重建索引
+func GetIndexableFiles(uid, afterID uint, limit int) ([]File, error) {
+	var files []File
+	result := DB.
+		Where("user_id = ? and id > ? and upload_session_id is NULL", uid, afterID).
+		Order("id asc").
+		Limit(limit).
+		Find(&files)
+	return files, result.Error
+}
+
+// SearchFileContent 在用户的全文索引中查找包含全部索引词的文件，按相关度降序返回
+func SearchFileContent(uid uint, terms []string) ([]ContentSearchHit, error) {
+	if len(terms) == 0 {
+		return []ContentSearchHit{}, nil
+	}
+
+	var matched []SearchTerm
+	if err := DB.Where("user_id = ? and term in (?)", uid, terms).Find(&matched).Error; err != nil {
+		return nil, err
+	}
+
+	var total int
+	if err := DB.Model(&SearchIndex{}).Where("user_id = ?", uid).Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	// 统计包含每个索引词的文件数
+	docFrequency := make(map[string]int, len(terms))
+	for _, term := range matched {
+		docFrequency[term.Term]++
+	}
+
+	// 按 TF-IDF 计算相关度，只保留包含全部索引词的文件
+	scores := make(map[uint]float64)
+	termCount := make(map[uint]int)
+	for _, term := range matched {
+		idf := math.Log(1 + float64(total)/float64(docFrequency[term.Term]))
+		scores[term.FileID] += (1 + math.Log(float64(term.Frequency))) * idf
+		termCount[term.FileID]++
+	}
+
+	hits := make([]ContentSearchHit, 0, len(scores))
+	for fileID, score := range scores {
+		if termCount[fileID] == len(terms) {
+			hits = append(hits, ContentSearchHit{FileID: fileID, Score: score})
+		}
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].FileID > hits[j].FileID
+	})
+
+	return hits, nil
+}
@@ -0,0 +1,94 @@
+package model
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchIndex_Save(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 成功
+	{
+		index := &SearchIndex{FileID: 1, UserID: 2, Content: "hello"}
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)search_ind(.+)").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("DELETE(.+)search_terms(.+)").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT(.+)search_ind(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT(.+)search_terms(.+)").WithArgs("hello", 1, 2, 1).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		asserts.NoError(index.Save(map[string]int{"hello": 1}))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 插入失败
+	{
+		index := &SearchIndex{FileID: 1, UserID: 2, Content: "hello"}
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)search_ind(.+)").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("DELETE(.+)search_terms(.+)").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT(.+)search_ind(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		asserts.Error(index.Save(map[string]int{"hello": 1}))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestDeleteSearchIndexByFileIDs(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 空列表
+	asserts.NoError(DeleteSearchIndexByFileIDs(nil))
+
+	// 成功
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)search_ind(.+)").WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec("DELETE(.+)search_terms(.+)").WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectCommit()
+		asserts.NoError(DeleteSearchIndexByFileIDs([]uint{1, 2}))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestSearchFileContent(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 空关键字
+	{
+		res, err := SearchFileContent(1, nil)
+		asserts.NoError(err)
+		asserts.Empty(res)
+	}
+
+	// 只返回包含全部索引词的文件，按相关度排序
+	{
+		mock.ExpectQuery("SELECT(.+)search_terms(.+)").WithArgs(1, "foo", "bar").WillReturnRows(
+			sqlmock.NewRows([]string{"term", "file_id", "frequency"}).
+				AddRow("foo", 1, 1).
+				AddRow("bar", 1, 1).
+				AddRow("foo", 2, 5).
+				AddRow("bar", 2, 3).
+				AddRow("foo", 3, 10),
+		)
+		mock.ExpectQuery("SELECT count(.+)search_ind(.+)").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(10))
+		res, err := SearchFileContent(1, []string{"foo", "bar"})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Len(res, 2)
+		asserts.EqualValues(2, res[0].FileID)
+		asserts.EqualValues(1, res[1].FileID)
+		asserts.True(res[0].Score > res[1].Score)
+	}
+
+	// 查询失败
+	{
+		mock.ExpectQuery("SELECT(.+)search_terms(.+)").WillReturnError(errors.New("error"))
+		_, err := SearchFileContent(1, []string{"foo"})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+	}
+}
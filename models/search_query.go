@@ -0,0 +1,163 @@
+package model
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// ObjectSearchCondition 结构化搜索的查询条件，各条件之间为“与”的关系
+type ObjectSearchCondition struct {
+	// Parents 限定对象所在的父目录，为空时不限
+	Parents []uint
+	// NameGroups 名称的 LIKE 匹配模式，同组内满足其一即可，字面字符以 EscapeLike 转义
+	NameGroups [][]string
+	// MetadataPatterns 用户元数据的 LIKE 匹配模式，需全部满足，通配符以 EscapeLike 转义
+	MetadataPatterns []string
+	// FilesOnly、FoldersOnly 只搜索文件/目录
+	FilesOnly   bool
+	FoldersOnly bool
+	// MinSize、MaxSize 文件大小的闭区间，nil 表示不限
+	MinSize *uint64
+	MaxSize *uint64
+	// 修改时间、创建时间范围 [After, Before)，零值表示不限
+	ModifiedAfter  time.Time
+	ModifiedBefore time.Time
+	CreatedAfter   time.Time
+	CreatedBefore  time.Time
+
+	// OrderBy 排序字段，可选 name、size、created_at、updated_at
+	OrderBy string
+	Desc    bool
+	// Offset、Limit 分页，目录排在文件之前
+	Offset int
+	Limit  int
+}
+
+// searchOrderColumns 允许排序的字段
+var searchOrderColumns = map[string]bool{
+	"name":       true,
+	"size":       true,
+	"created_at": true,
+	"updated_at": true,
+}
+
+// order 生成排序语句，目录不支持按大小排序时退化为按名称排序
+func (cond *ObjectSearchCondition) order(isFolder bool) string {
+	column := cond.OrderBy
+	if !searchOrderColumns[column] || (isFolder && column == "size") {
+		column = "name"
+	}
+
+	if cond.Desc {
+		return column + " desc, id desc"
+	}
+	return column + " asc, id asc"
+}
+
+// where 生成目录和文件共用的查询条件
+func (cond *ObjectSearchCondition) where(db *gorm.DB) *gorm.DB {
+	for _, group := range cond.NameGroups {
+		if len(group) == 0 {
+			continue
+		}
+
+		conditions := ""
+		args := make([]interface{}, len(group))
+		for i, pattern := range group {
+			if i > 0 {
+				conditions += " or "
+			}
+			conditions += "name like ? escape '" + likeEscapeChar + "'"
+			args[i] = pattern
+		}
+		db = db.Where("("+conditions+")", args...)
+	}
+
+	for _, pattern := range cond.MetadataPatterns {
+		db = db.Where("metadata like ? escape '"+likeEscapeChar+"'", pattern)
+	}
+
+	if !cond.ModifiedAfter.IsZero() {
+		db = db.Where("updated_at >= ?", cond.ModifiedAfter)
+	}
+	if !cond.ModifiedBefore.IsZero() {
+		db = db.Where("updated_at < ?", cond.ModifiedBefore)
+	}
+	if !cond.CreatedAfter.IsZero() {
+		db = db.Where("created_at >= ?", cond.CreatedAfter)
+	}
+	if !cond.CreatedBefore.IsZero() {
+		db = db.Where("created_at < ?", cond.CreatedBefore)
+	}
+
+	return db
+}
+
+func (cond *ObjectSearchCondition) folderQuery(uid uint) *gorm.DB {
+	db := DB.Model(&Folder{}).Where("owner_id = ?", uid)
+	if len(cond.Parents) > 0 {
+		db = db.Where("parent_id in (?)", cond.Parents)
+	}
+
+	return cond.where(db)
+}
+
+func (cond *ObjectSearchCondition) fileQuery(uid uint) *gorm.DB {
+	db := DB.Model(&File{}).Where("user_id = ? and upload_session_id is NULL", uid)
+	if len(cond.Parents) > 0 {
+		db = db.Where("folder_id in (?)", cond.Parents)
+	}
+	if cond.MinSize != nil {
+		db = db.Where("size >= ?", *cond.MinSize)
+	}
+	if cond.MaxSize != nil {
+		db = db.Where("size <= ?", *cond.MaxSize)
+	}
+
+	return cond.where(db)
+}
+
+// SearchObjects 按条件搜索用户的目录和文件，返回当前页的目录、文件及符合条件的总数
+func SearchObjects(uid uint, cond *ObjectSearchCondition) ([]Folder, []File, int, error) {
+	var (
+		folders     = make([]Folder, 0)
+		files       = make([]File, 0)
+		folderTotal int
+		fileTotal   int
+	)
+
+	if !cond.FilesOnly {
+		if err := cond.folderQuery(uid).Count(&folderTotal).Error; err != nil {
+			return nil, nil, 0, err
+		}
+	}
+
+	if !cond.FoldersOnly {
+		if err := cond.fileQuery(uid).Count(&fileTotal).Error; err != nil {
+			return nil, nil, 0, err
+		}
+	}
+
+	// 当前页中先列出目录，不足的部分再以文件补齐
+	if cond.Offset < folderTotal {
+		if err := cond.folderQuery(uid).Order(cond.order(true)).
+			Offset(cond.Offset).Limit(cond.Limit).Find(&folders).Error; err != nil {
+			return nil, nil, 0, err
+		}
+	}
+
+	fileOffset := cond.Offset - folderTotal
+	if fileOffset < 0 {
+		fileOffset = 0
+	}
+
+	if remain := cond.Limit - len(folders); remain > 0 && fileOffset < fileTotal {
+		if err := cond.fileQuery(uid).Order(cond.order(false)).
+			Offset(fileOffset).Limit(remain).Find(&files).Error; err != nil {
+			return nil, nil, 0, err
+		}
+	}
+
+	return folders, files, folderTotal + fileTotal, nil
+}
@@ -0,0 +1,75 @@
+package model
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchObjects(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 目录和文件跨页
+	{
+		cond := &ObjectSearchCondition{
+			Parents:       []uint{1},
+			NameGroups:    [][]string{{"%report%"}, {"%.pdf", "%.docx"}},
+			ModifiedAfter: time.Now(),
+			OrderBy:       "size",
+			Desc:          true,
+			Offset:        1,
+			Limit:         2,
+		}
+		mock.ExpectQuery("SELECT count(.+)folders(.+)").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+		mock.ExpectQuery("SELECT count(.+)files(.+)").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+		mock.ExpectQuery("SELECT(.+)folders(.+)ORDER BY name desc, id desc(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(2, "report"))
+		mock.ExpectQuery("SELECT(.+)files(.+)ORDER BY size desc, id desc(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(3, "report.pdf"))
+		folders, files, total, err := SearchObjects(1, cond)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Len(folders, 1)
+		asserts.Len(files, 1)
+		asserts.Equal(5, total)
+	}
+
+	// 只搜索文件，非法排序字段
+	{
+		size := uint64(10)
+		cond := &ObjectSearchCondition{FilesOnly: true, MinSize: &size, OrderBy: "id; drop", Limit: 10}
+		mock.ExpectQuery("SELECT count(.+)files(.+)").WithArgs(1, size).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectQuery("SELECT(.+)files(.+)ORDER BY name asc, id asc(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(3, "report.pdf"))
+		folders, files, total, err := SearchObjects(1, cond)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Len(folders, 0)
+		asserts.Len(files, 1)
+		asserts.Equal(1, total)
+	}
+
+	// 超出范围的页码
+	{
+		cond := &ObjectSearchCondition{FoldersOnly: true, Offset: 10, Limit: 10}
+		mock.ExpectQuery("SELECT count(.+)folders(.+)").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		folders, files, total, err := SearchObjects(1, cond)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Len(folders, 0)
+		asserts.Len(files, 0)
+		asserts.Equal(1, total)
+	}
+
+	// 查询失败
+	{
+		cond := &ObjectSearchCondition{Limit: 10}
+		mock.ExpectQuery("SELECT count(.+)folders(.+)").WillReturnError(errors.New("error"))
+		_, _, _, err := SearchObjects(1, cond)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+	}
+}
@@ -0,0 +1,73 @@
+package model
+
+import (
+	"time"
+)
+
+// Star 用户收藏的文件或目录
+type Star struct {
+	ID        uint      `gorm:"primary_key"`
+	CreatedAt time.Time `gorm:"index:idx_star_created_at"`
+	UserID    uint      `gorm:"unique_index:idx_star_object"`
+	IsFolder  bool      `gorm:"unique_index:idx_star_object"`
+	ObjectID  uint      `gorm:"unique_index:idx_star_object"`
+}
+
+// StarObjects 收藏对象，已收藏的对象会被忽略
+func StarObjects(uid uint, isFolder bool, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var existed []Star
+	if err := DB.Where("user_id = ? and is_folder = ? and object_id in (?)", uid, isFolder, ids).
+		Find(&existed).Error; err != nil {
+		return err
+	}
+
+	starred := make(map[uint]bool, len(existed))
+	for _, star := range existed {
+		starred[star.ObjectID] = true
+	}
+
+	tx := DB.Begin()
+	for _, id := range ids {
+		if starred[id] {
+			continue
+		}
+
+		starred[id] = true
+		if err := tx.Create(&Star{UserID: uid, IsFolder: isFolder, ObjectID: id}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+// UnstarObjects 取消收藏对象
+func UnstarObjects(uid uint, isFolder bool, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	return DB.Where("user_id = ? and is_folder = ? and object_id in (?)", uid, isFolder, ids).
+		Delete(&Star{}).Error
+}
+
+// ListStars 列出用户的收藏，新收藏的在前
+func ListStars(uid uint) ([]Star, error) {
+	var stars []Star
+	result := DB.Where("user_id = ?", uid).Order("created_at desc, id desc").Find(&stars)
+	return stars, result.Error
+}
+
+// DeleteStarsByObjects 删除对象被删除后遗留的收藏
+func DeleteStarsByObjects(isFolder bool, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	return DB.Where("is_folder = ? and object_id in (?)", isFolder, ids).Delete(&Star{}).Error
+}
@@ -0,0 +1,82 @@
+package model
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStarObjects(t *testing.T) {
+	a := assert.New(t)
+
+	// 空列表
+	a.NoError(StarObjects(1, false, nil))
+
+	// 查询失败
+	{
+		mock.ExpectQuery("SELECT(.+)stars(.+)").WillReturnError(errors.New("error"))
+		a.Error(StarObjects(1, false, []uint{1}))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 跳过已收藏和重复的对象
+	{
+		mock.ExpectQuery("SELECT(.+)stars(.+)").
+			WithArgs(1, true, 1, 2, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "object_id"}).AddRow(1, 1))
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)stars(.+)").
+			WithArgs(sqlmock.AnyArg(), 1, true, 2).
+			WillReturnResult(sqlmock.NewResult(2, 1))
+		mock.ExpectCommit()
+		a.NoError(StarObjects(1, true, []uint{1, 2, 2}))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 插入失败
+	{
+		mock.ExpectQuery("SELECT(.+)stars(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)stars(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		a.Error(StarObjects(1, false, []uint{1}))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestUnstarObjects(t *testing.T) {
+	a := assert.New(t)
+	a.NoError(UnstarObjects(1, false, nil))
+
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE(.+)stars(.+)").WithArgs(1, false, 3).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	a.NoError(UnstarObjects(1, false, []uint{3}))
+	a.NoError(mock.ExpectationsWereMet())
+}
+
+func TestListStars(t *testing.T) {
+	a := assert.New(t)
+
+	mock.ExpectQuery("SELECT(.+)stars(.+)ORDER BY created_at desc, id desc").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "is_folder", "object_id"}).AddRow(2, true, 3).AddRow(1, false, 4))
+	stars, err := ListStars(1)
+	a.NoError(mock.ExpectationsWereMet())
+	a.NoError(err)
+	a.Len(stars, 2)
+	a.True(stars[0].IsFolder)
+}
+
+func TestDeleteStarsByObjects(t *testing.T) {
+	a := assert.New(t)
+	a.NoError(DeleteStarsByObjects(true, []uint{}))
+
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE(.+)stars(.+)").WithArgs(true, 1, 2).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	a.NoError(DeleteStarsByObjects(true, []uint{1, 2}))
+	a.NoError(mock.ExpectationsWereMet())
+}
@@ -13,6 +13,7 @@ type Tag struct {
 	Color      string // 图标颜色
 	Type       int    // 标签类型（文件分类/目录直达）
 	Expression string `gorm:"type:text"` // 搜索表表达式/直达路径
+	IsQuery    bool   // 文件分类标签的表达式是否为结构化搜索语句
 	UserID     uint   // 创建者ID
 }
 
@@ -51,3 +52,10 @@ func GetTagsByID(id, uid uint) (*Tag, error) {
 	result := DB.Where("user_id = ? and id = ?", uid, id).First(&tag)
 	return &tag, result.Error
 }
+
+// GetFileTagByName 根据名称查找用户的文件分类标签
+func GetFileTagByName(name string, uid uint) (*Tag, error) {
+	var tag Tag
+	result := DB.Where("user_id = ? and type = ? and name = ?", uid, FileTagType, name).First(&tag)
+	return &tag, result.Error
+}
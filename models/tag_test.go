@@ -61,3 +61,12 @@ func TestGetTagsByID(t *testing.T) {
 	asserts.NoError(err)
 	asserts.EqualValues("tag", res.Name)
 }
+
+func TestGetFileTagByName(t *testing.T) {
+	asserts := assert.New(t)
+	mock.ExpectQuery("SELECT(.+)tags(.+)").WithArgs(1, FileTagType, "work").WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("work"))
+	res, err := GetFileTagByName("work", 1)
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	asserts.EqualValues("work", res.Name)
+}
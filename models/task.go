@@ -35,6 +35,12 @@ func (task *Task) SetProgress(progress int) error {
 	return DB.Model(task).Select("progress").Updates(map[string]interface{}{"progress": progress}).Error
 }
 
+// SetProps 更新任务属性
+func (task *Task) SetProps(props string) error {
+	task.Props = props
+	return DB.Model(task).Select("props").Updates(map[string]interface{}{"props": props}).Error
+}
+
 // SetError 设定错误信息
 func (task *Task) SetError(err string) error {
 	return DB.Model(task).Select("error").Updates(map[string]interface{}{"error": err}).Error
@@ -71,6 +71,19 @@ func TestTask_SetProgress(t *testing.T) {
 	asserts.NoError(mock.ExpectationsWereMet())
 }
 
+func TestTask_SetProps(t *testing.T) {
+	asserts := assert.New(t)
+	task := Task{
+		Model: gorm.Model{ID: 1},
+	}
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)props(.+)").WithArgs("{}", sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	asserts.NoError(task.SetProps("{}"))
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.Equal("{}", task.Props)
+}
+
 func TestGetTasksByID(t *testing.T) {
 	asserts := assert.New(t)
 	mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
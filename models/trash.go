@@ -0,0 +1,226 @@
+package model
+
+import (
+	"time"
+
+	"github.com/Jaylenwa/Vfoy/pkg/util"
+	"github.com/jinzhu/gorm"
+)
+
+// Trash 回收站中的对象记录
+type Trash struct {
+	gorm.Model
+	UserID       uint   `gorm:"index:trash_user_id"`
+	ObjectID     uint   // 被删除的顶级文件或目录ID
+	IsFolder     bool   // 对象是否为目录
+	Name         string // 删除前的对象名
+	Key          string `gorm:"unique_index"` // 对象在回收站中的占位名
+	OriginalPath string `gorm:"type:text"`    // 删除前所在父目录的路径
+	ParentID     uint   // 删除前所在父目录ID
+	Size         uint64 // 对象及其子文件的总大小
+	ExpiredAt    *time.Time
+}
+
+// Create 将对象及其子对象移入回收站，顶级对象会被重命名为占位名以释放原名称
+func (trash *Trash) Create(folders, files []uint) error {
+	tx := DB.Begin()
+
+	table := "files"
+	if trash.IsFolder {
+		table = "folders"
+	}
+	if err := tx.Table(table).Where("id = ?", trash.ObjectID).
+		UpdateColumn("name", trash.Key).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := SoftDeleteObjects(tx, folders, files); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Create(trash).Error; err != nil {
+		util.Log().Warning("Failed to insert trash record: %s", err)
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// Restore 将回收站中的对象恢复到指定目录下，并使用给定的名称
+func (trash *Trash) Restore(name string, parentID uint, folders, files []uint) error {
+	tx := DB.Begin()
+
+	if err := RestoreObjects(tx, folders, files); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	var err error
+	if trash.IsFolder {
+		err = tx.Table("folders").Where("id = ?", trash.ObjectID).
+			UpdateColumns(map[string]interface{}{"name": name, "parent_id": parentID}).Error
+	} else {
+		err = tx.Table("files").Where("id = ?", trash.ObjectID).
+			UpdateColumns(map[string]interface{}{"name": name, "folder_id": parentID}).Error
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Unscoped().Delete(trash).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// IsExpired 返回回收站记录是否已过期
+func (trash *Trash) IsExpired() bool {
+	return trash.ExpiredAt != nil && time.Now().After(*trash.ExpiredAt)
+}
+
+// GetTrashByIDs 根据ID和用户查找回收站记录，ids 为空时返回用户的全部记录
+func GetTrashByIDs(ids []uint, uid uint) ([]Trash, error) {
+	var items []Trash
+	query := DB.Where("user_id = ?", uid)
+	if len(ids) > 0 {
+		query = query.Where("id in (?)", ids)
+	}
+	result := query.Order("created_at desc").Find(&items)
+	return items, result.Error
+}
+
+// ListTrash 列出用户回收站中的记录
+func ListTrash(uid uint, page, pageSize int, order string) ([]Trash, int) {
+	var (
+		items []Trash
+		total int
+	)
+	dbChain := DB.Where("user_id = ?", uid)
+
+	// 计算总数用于分页
+	dbChain.Model(&Trash{}).Count(&total)
+
+	// 查询记录
+	dbChain.Limit(pageSize).Offset((page - 1) * pageSize).Order(order).Find(&items)
+
+	return items, total
+}
+
+// GetExpiredTrash 列出所有已过期的回收站记录
+func GetExpiredTrash() []Trash {
+	var items []Trash
+	DB.Where("expired_at is not NULL and expired_at < ?", time.Now()).Find(&items)
+	return items
+}
+
+// DeleteTrashByIDs 删除给定ID的回收站记录
+func DeleteTrashByIDs(ids []uint) error {
+	return DB.Where("id in (?)", ids).Unscoped().Delete(&Trash{}).Error
+}
+
+// GetTrashRootIDs 返回用户回收站中所有顶级对象的ID，分别为目录和文件
+func GetTrashRootIDs(uid uint) (folders, files []uint) {
+	var items []Trash
+	DB.Where("user_id = ?", uid).Find(&items)
+	for _, item := range items {
+		if item.IsFolder {
+			folders = append(folders, item.ObjectID)
+		} else {
+			files = append(files, item.ObjectID)
+		}
+	}
+	return
+}
+
+// GetTrashedFolderTree 递归列出回收站中给定目录及其子目录，exclude 中的目录
+// 属于其他回收站记录，不会被列出
+func GetTrashedFolderTree(id, uid uint, exclude []uint) ([]Folder, error) {
+	folders := make([]Folder, 0)
+
+	var parFolders []Folder
+	result := DB.Unscoped().Where("owner_id = ? and id = ?", uid, id).Find(&parFolders)
+	if result.Error != nil {
+		return folders, result.Error
+	}
+
+	// 递归查询子目录,最大递归65535次
+	for i := 0; i < 65535 && len(parFolders) > 0; i++ {
+		parentIDs := make([]uint, 0, len(parFolders))
+		for _, folder := range parFolders {
+			parentIDs = append(parentIDs, folder.ID)
+		}
+
+		folders = append(folders, parFolders...)
+		parFolders = []Folder{}
+
+		query := DB.Unscoped().Where("owner_id = ? and parent_id in (?)", uid, parentIDs)
+		if len(exclude) > 0 {
+			query = query.Where("id not in (?)", exclude)
+		}
+		if err := query.Find(&parFolders).Error; err != nil {
+			return folders, err
+		}
+	}
+
+	return folders, nil
+}
+
+// GetTrashedFiles 列出回收站中给定目录下的文件，exclude 中的文件属于其他回收站记录
+func GetTrashedFiles(folderIDs []uint, uid uint, exclude []uint) ([]File, error) {
+	var files []File
+	query := DB.Unscoped().Where("user_id = ? and folder_id in (?)", uid, folderIDs)
+	if len(exclude) > 0 {
+		query = query.Where("id not in (?)", exclude)
+	}
+	result := query.Find(&files)
+	return files, result.Error
+}
+
+// GetTrashedFileByID 根据ID查找回收站中的文件
+func GetTrashedFileByID(id, uid uint) (*File, error) {
+	file := &File{}
+	result := DB.Unscoped().Where("id = ? and user_id = ?", id, uid).First(file)
+	return file, result.Error
+}
+
+// SoftDeleteObjects 将给定的目录和文件标记为已删除
+func SoftDeleteObjects(tx *gorm.DB, folders, files []uint) error {
+	if len(folders) > 0 {
+		if err := tx.Where("id in (?)", folders).Delete(&Folder{}).Error; err != nil {
+			return err
+		}
+	}
+
+	if len(files) > 0 {
+		if err := tx.Where("id in (?)", files).Delete(&File{}).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RestoreObjects 清除给定目录和文件的删除标记
+func RestoreObjects(tx *gorm.DB, folders, files []uint) error {
+	if len(folders) > 0 {
+		if err := tx.Unscoped().Model(&Folder{}).Where("id in (?)", folders).
+			UpdateColumn("deleted_at", nil).Error; err != nil {
+			return err
+		}
+	}
+
+	if len(files) > 0 {
+		if err := tx.Unscoped().Model(&File{}).Where("id in (?)", files).
+			UpdateColumn("deleted_at", nil).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,116 @@
+package model
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrash_Create(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 成功
+	{
+		trash := &Trash{ObjectID: 1, IsFolder: true, Key: "key"}
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)folders(.+)").WithArgs("key", 1).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE(.+)folders(.+)deleted_at").WillReturnResult(sqlmock.NewResult(1, 2))
+		mock.ExpectExec("UPDATE(.+)files(.+)deleted_at").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT(.+)trashes").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		asserts.NoError(trash.Create([]uint{1, 2}, []uint{3}))
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.EqualValues(1, trash.ID)
+	}
+
+	// 插入记录失败
+	{
+		trash := &Trash{ObjectID: 1, Key: "key"}
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)").WithArgs("key", 1).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE(.+)files(.+)deleted_at").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT(.+)trashes").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		asserts.Error(trash.Create(nil, []uint{1}))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestTrash_Restore(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 成功
+	{
+		trash := &Trash{ObjectID: 1}
+		trash.ID = 2
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)deleted_at").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE(.+)files(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("DELETE(.+)trashes").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		asserts.NoError(trash.Restore("name", 3, nil, []uint{1}))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 恢复失败
+	{
+		trash := &Trash{ObjectID: 1, IsFolder: true}
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)folders(.+)deleted_at").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		asserts.Error(trash.Restore("name", 3, []uint{1}, nil))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestTrash_IsExpired(t *testing.T) {
+	asserts := assert.New(t)
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	asserts.False((&Trash{}).IsExpired())
+	asserts.True((&Trash{ExpiredAt: &past}).IsExpired())
+	asserts.False((&Trash{ExpiredAt: &future}).IsExpired())
+}
+
+func TestGetTrashRootIDs(t *testing.T) {
+	asserts := assert.New(t)
+	mock.ExpectQuery("SELECT(.+)trashes(.+)").WithArgs(1).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "object_id", "is_folder"}).
+			AddRow(1, 2, true).
+			AddRow(2, 3, false),
+	)
+	folders, files := GetTrashRootIDs(1)
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.Equal([]uint{2}, folders)
+	asserts.Equal([]uint{3}, files)
+}
+
+func TestGetTrashedFolderTree(t *testing.T) {
+	asserts := assert.New(t)
+	mock.ExpectQuery("SELECT(.+)folders(.+)").WithArgs(1, 1).WillReturnRows(
+		sqlmock.NewRows([]string{"id"}).AddRow(1),
+	)
+	mock.ExpectQuery("SELECT(.+)folders(.+)not in(.+)").WithArgs(1, 1, 5).WillReturnRows(
+		sqlmock.NewRows([]string{"id"}).AddRow(2),
+	)
+	mock.ExpectQuery("SELECT(.+)folders(.+)not in(.+)").WithArgs(1, 2, 5).WillReturnRows(
+		sqlmock.NewRows([]string{"id"}),
+	)
+	folders, err := GetTrashedFolderTree(1, 1, []uint{5})
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	asserts.Len(folders, 2)
+}
+
+func TestDeleteTrashByIDs(t *testing.T) {
+	asserts := assert.New(t)
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE(.+)trashes").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	asserts.NoError(DeleteTrashByIDs([]uint{1}))
+	asserts.NoError(mock.ExpectationsWereMet())
+}
@@ -0,0 +1,60 @@
+package model
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// UploadSession 持久化的上传会话，与缓存中的会话内容一致，用于重启后恢复
+type UploadSession struct {
+	gorm.Model
+	SessionID      string `gorm:"size:64;unique_index:idx_upload_session_id"`
+	UserID         uint   `gorm:"index:idx_upload_session_user"`
+	VirtualPath    string `gorm:"type:text"`
+	Name           string
+	Size           uint64
+	SavePath       string `gorm:"type:text"`
+	LastModified   *time.Time
+	PolicyID       uint
+	Callback       string `gorm:"type:text"`
+	CallbackSecret string
+	UploadURL      string    `gorm:"type:text"`
+	UploadID       string    `gorm:"type:text"`
+	Credential     string    `gorm:"type:text"`
+	Hash           string    `gorm:"size:64"`
+	ExpiredAt      time.Time `gorm:"index:idx_upload_session_expired"`
+}
+
+// Create 创建上传会话记录
+func (session *UploadSession) Create() error {
+	return DB.Create(session).Error
+}
+
+// GetUploadSessionByID 根据会话 ID 查找未过期的上传会话
+func GetUploadSessionByID(id string) (*UploadSession, error) {
+	var session UploadSession
+	result := DB.Where("session_id = ? and expired_at > ?", id, time.Now()).First(&session)
+	return &session, result.Error
+}
+
+// GetActiveUploadSessions 列出全部未过期的上传会话
+func GetActiveUploadSessions() ([]UploadSession, error) {
+	var sessions []UploadSession
+	result := DB.Where("expired_at > ?", time.Now()).Find(&sessions)
+	return sessions, result.Error
+}
+
+// DeleteUploadSessionsByIDs 根据会话 ID 删除上传会话
+func DeleteUploadSessionsByIDs(ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	return DB.Unscoped().Where("session_id in (?)", ids).Delete(&UploadSession{}).Error
+}
+
+// DeleteExpiredUploadSessions 删除已过期的上传会话
+func DeleteExpiredUploadSessions() error {
+	return DB.Unscoped().Where("expired_at <= ?", time.Now()).Delete(&UploadSession{}).Error
+}
@@ -0,0 +1,72 @@
+package model
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUploadSession_Create(t *testing.T) {
+	asserts := assert.New(t)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT(.+)upload_sessions(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	session := &UploadSession{SessionID: "1", UserID: 1}
+	asserts.NoError(session.Create())
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.EqualValues(1, session.ID)
+}
+
+func TestGetUploadSessionByID(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 存在
+	mock.ExpectQuery("SELECT(.+)upload_sessions(.+)").
+		WithArgs("1", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "session_id", "policy_id"}).AddRow(1, "1", 2))
+	session, err := GetUploadSessionByID("1")
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	asserts.EqualValues(2, session.PolicyID)
+
+	// 不存在或已过期
+	mock.ExpectQuery("SELECT(.+)upload_sessions(.+)").WillReturnError(errors.New("not found"))
+	_, err = GetUploadSessionByID("2")
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.Error(err)
+}
+
+func TestGetActiveUploadSessions(t *testing.T) {
+	asserts := assert.New(t)
+
+	mock.ExpectQuery("SELECT(.+)upload_sessions(.+)expired_at(.+)").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "session_id"}).AddRow(1, "1").AddRow(2, "2"))
+	sessions, err := GetActiveUploadSessions()
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	asserts.Len(sessions, 2)
+}
+
+func TestDeleteUploadSessions(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 未指定会话
+	asserts.NoError(DeleteUploadSessionsByIDs(nil))
+
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE(.+)upload_sessions(.+)").
+		WithArgs("1", "2").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	asserts.NoError(DeleteUploadSessionsByIDs([]string{"1", "2"}))
+	asserts.NoError(mock.ExpectationsWereMet())
+
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE(.+)upload_sessions(.+)expired_at(.+)").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	asserts.NoError(DeleteExpiredUploadSessions())
+	asserts.NoError(mock.ExpectationsWereMet())
+}
@@ -1,9 +1,20 @@
 package model
 
 import (
+	"errors"
+	"net"
+	"strings"
+	"time"
+
 	"github.com/jinzhu/gorm"
 )
 
+// webdavTouchInterval 同一 IP 持续访问时，最后使用时间的最短更新间隔
+const webdavTouchInterval = time.Minute
+
+// ErrInvalidAllowedIP 无效的 IP 或 CIDR
+var ErrInvalidAllowedIP = errors.New("invalid IP or CIDR")
+
 // Webdav 应用账户
 type Webdav struct {
 	gorm.Model
@@ -13,6 +24,15 @@ type Webdav struct {
 	Root     string `gorm:"type:text"`                     // 根目录
 	Readonly bool   `gorm:"type:bool"`                     // 是否只读
 	UseProxy bool   `gorm:"type:bool"`                     // 是否进行反代
+
+	// 访问控制
+	AllowedIPs     string     `gorm:"type:text"` // 允许访问的 IP 或 CIDR，逗号分隔，为空时不限
+	AllowedMethods string     // 允许的 HTTP 方法，逗号分隔，为空时不限
+	ExpiresAt      *time.Time // 过期时间，为空时永不过期
+	RequestLimit   int        // 每分钟最多请求数，0 表示不限
+	SpeedLimit     int        // 传输速率上限，单位为字节/秒，0 表示不限
+	LastUsedAt     *time.Time // 最后使用时间
+	LastUsedIP     string     // 最后使用的 IP
 }
 
 // Create 创建账户
@@ -46,3 +66,124 @@ func DeleteWebDAVAccountByID(id, uid uint) {
 func UpdateWebDAVAccountByID(id, uid uint, updates map[string]interface{}) {
 	DB.Model(&Webdav{Model: gorm.Model{ID: id}, UserID: uid}).Updates(updates)
 }
+
+// GetWebDAVAccountByID 根据账户ID和UID查找账户
+func GetWebDAVAccountByID(id, uid uint) (*Webdav, error) {
+	webdav := &Webdav{}
+	res := DB.Where("user_id = ? and id = ?", uid, id).First(webdav)
+	return webdav, res.Error
+}
+
+// UpdateRestrictions 更新账户的访问控制
+func (webdav *Webdav) UpdateRestrictions(updates map[string]interface{}) error {
+	return DB.Model(webdav).Updates(updates).Error
+}
+
+// Expired 账户是否已过期
+func (webdav *Webdav) Expired() bool {
+	return webdav.ExpiresAt != nil && !time.Now().Before(*webdav.ExpiresAt)
+}
+
+// AllowIP 是否允许从给定 IP 访问
+func (webdav *Webdav) AllowIP(ip string) bool {
+	if strings.TrimSpace(webdav.AllowedIPs) == "" {
+		return true
+	}
+
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+
+	for _, entry := range splitList(webdav.AllowedIPs) {
+		if network, err := parseCIDR(entry); err == nil && network.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AllowMethod 是否允许使用给定的 HTTP 方法
+func (webdav *Webdav) AllowMethod(method string) bool {
+	methods := splitList(webdav.AllowedMethods)
+	if len(methods) == 0 {
+		return true
+	}
+
+	for _, m := range methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NeedTouch 返回是否需要更新最后使用记录：来源 IP 变化，或距上次记录已超过最短间隔
+func (webdav *Webdav) NeedTouch(ip string) bool {
+	return webdav.LastUsedAt == nil || webdav.LastUsedIP != ip ||
+		time.Since(*webdav.LastUsedAt) >= webdavTouchInterval
+}
+
+// Touch 记录账户的最后使用时间和 IP
+func (webdav *Webdav) Touch(ip string) error {
+	now := time.Now()
+	webdav.LastUsedAt = &now
+	webdav.LastUsedIP = ip
+	return DB.Model(webdav).UpdateColumns(map[string]interface{}{
+		"last_used_at": now,
+		"last_used_ip": ip,
+	}).Error
+}
+
+// NormalizeAllowedIPs 校验并合并 IP 或 CIDR 列表
+func NormalizeAllowedIPs(entries []string) (string, error) {
+	res := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if _, err := parseCIDR(entry); err != nil {
+			return "", ErrInvalidAllowedIP
+		}
+
+		res = append(res, entry)
+	}
+
+	return strings.Join(res, ","), nil
+}
+
+// parseCIDR 解析 CIDR，单个 IP 视为只包含自身的网段
+func parseCIDR(entry string) (*net.IPNet, error) {
+	if !strings.Contains(entry, "/") {
+		ip := net.ParseIP(entry)
+		if ip == nil {
+			return nil, ErrInvalidAllowedIP
+		}
+
+		bits := 128
+		if ip.To4() != nil {
+			ip = ip.To4()
+			bits = 32
+		}
+
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+
+	_, network, err := net.ParseCIDR(entry)
+	return network, err
+}
+
+func splitList(list string) []string {
+	res := make([]string, 0)
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			res = append(res, item)
+		}
+	}
+
+	return res
+}
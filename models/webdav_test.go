@@ -5,6 +5,7 @@ import (
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
 func TestWebdav_Create(t *testing.T) {
@@ -58,3 +59,97 @@ func TestDeleteWebDAVAccountByID(t *testing.T) {
 	DeleteWebDAVAccountByID(1, 1)
 	asserts.NoError(mock.ExpectationsWereMet())
 }
+
+func TestWebdav_Expired(t *testing.T) {
+	asserts := assert.New(t)
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Minute)
+
+	asserts.False((&Webdav{}).Expired())
+	asserts.True((&Webdav{ExpiresAt: &past}).Expired())
+	asserts.False((&Webdav{ExpiresAt: &future}).Expired())
+}
+
+func TestWebdav_AllowIP(t *testing.T) {
+	asserts := assert.New(t)
+
+	asserts.True((&Webdav{}).AllowIP("1.2.3.4"))
+
+	account := &Webdav{AllowedIPs: "10.0.0.0/8, 192.168.1.1,::1"}
+	asserts.True(account.AllowIP("10.1.2.3"))
+	asserts.True(account.AllowIP("192.168.1.1"))
+	asserts.True(account.AllowIP("::1"))
+	asserts.False(account.AllowIP("192.168.1.2"))
+	asserts.False(account.AllowIP("invalid"))
+}
+
+func TestWebdav_AllowMethod(t *testing.T) {
+	asserts := assert.New(t)
+
+	asserts.True((&Webdav{}).AllowMethod("DELETE"))
+
+	account := &Webdav{AllowedMethods: "PUT,propfind"}
+	asserts.True(account.AllowMethod("PUT"))
+	asserts.True(account.AllowMethod("PROPFIND"))
+	asserts.False(account.AllowMethod("DELETE"))
+}
+
+func TestWebdav_Touch(t *testing.T) {
+	asserts := assert.New(t)
+	account := &Webdav{}
+	account.ID = 1
+
+	// 首次使用
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)webdavs(.+)").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	asserts.NoError(account.Touch("1.2.3.4"))
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.Equal("1.2.3.4", account.LastUsedIP)
+	asserts.NotNil(account.LastUsedAt)
+
+}
+
+func TestWebdav_NeedTouch(t *testing.T) {
+	asserts := assert.New(t)
+	account := &Webdav{}
+
+	// 从未使用
+	asserts.True(account.NeedTouch("1.2.3.4"))
+
+	// 同一 IP 短时间内不重复写入
+	recent := time.Now().Add(-10 * time.Second)
+	account.LastUsedAt = &recent
+	account.LastUsedIP = "1.2.3.4"
+	asserts.False(account.NeedTouch("1.2.3.4"))
+
+	// IP 变化
+	asserts.True(account.NeedTouch("5.6.7.8"))
+
+	// 超过最短间隔
+	stale := time.Now().Add(-2 * time.Minute)
+	account.LastUsedAt = &stale
+	asserts.True(account.NeedTouch("1.2.3.4"))
+}
+
+func TestNormalizeAllowedIPs(t *testing.T) {
+	asserts := assert.New(t)
+
+	res, err := NormalizeAllowedIPs([]string{" 10.0.0.0/8", "", "::1"})
+	asserts.NoError(err)
+	asserts.Equal("10.0.0.0/8,::1", res)
+
+	_, err = NormalizeAllowedIPs([]string{"10.0.0.0/33"})
+	asserts.ErrorIs(err, ErrInvalidAllowedIP)
+	_, err = NormalizeAllowedIPs([]string{"host"})
+	asserts.ErrorIs(err, ErrInvalidAllowedIP)
+}
+
+func TestGetWebDAVAccountByID(t *testing.T) {
+	asserts := assert.New(t)
+	mock.ExpectQuery("SELECT(.+)webdavs(.+)").WithArgs(1, 2).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	account, err := GetWebDAVAccountByID(2, 1)
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	asserts.EqualValues(2, account.ID)
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/Jaylenwa/Vfoy/pkg/filesystem"
 	"github.com/Jaylenwa/Vfoy/pkg/filesystem/fsctx"
 	"github.com/Jaylenwa/Vfoy/pkg/mq"
+	"github.com/Jaylenwa/Vfoy/pkg/serializer"
 	"github.com/Jaylenwa/Vfoy/pkg/task"
 	"github.com/Jaylenwa/Vfoy/pkg/util"
 )
@@ -73,6 +74,18 @@ func (monitor *Monitor) Loop(mqClient mq.MQ) {
 
 // Update 更新状态，返回值表示是否退出监控
 func (monitor *Monitor) Update() bool {
+	// 状态变化时推送给任务创建者
+	previous := monitor.Task.Status
+	defer func() {
+		if monitor.Task.Status != previous {
+			mq.PublishUserEvent(monitor.Task.UserID, mq.EventDownloadUpdate, serializer.DownloadEvent{
+				GID:    monitor.Task.GID,
+				Status: monitor.Task.Status,
+				Error:  monitor.Task.Error,
+			})
+		}
+	}()
+
 	status, err := monitor.node.GetAria2Instance().Status(monitor.Task)
 
 	if err != nil {
@@ -60,6 +60,10 @@ type Driver interface {
 	// 删除值
 	Delete(keys []string, prefix string) error
 
+	// 原子地读取并更新值，fn 根据当前值返回新值及过期时间，新值为 nil 时删除该键，
+	// fn 返回错误时不做修改。其他实例并发修改同一键时会重新调用 fn
+	Update(key string, fn UpdateFunc) error
+
 	// Save in-memory cache to disk
 	Persist(path string) error
 
@@ -67,6 +71,9 @@ type Driver interface {
 	Restore(path string) error
 }
 
+// UpdateFunc 根据当前值计算新值，ok 表示当前值是否存在，返回的 ttl 单位为秒
+type UpdateFunc func(value interface{}, ok bool) (newValue interface{}, ttl int, err error)
+
 // Set 设置缓存值
 func Set(key string, value interface{}, ttl int) error {
 	return Store.Set(key, value, ttl)
@@ -82,6 +89,11 @@ func Deletes(keys []string, prefix string) error {
 	return Store.Delete(keys, prefix)
 }
 
+// Update 原子地读取并更新缓存值
+func Update(key string, fn UpdateFunc) error {
+	return Store.Update(key, fn)
+}
+
 // GetSettings 根据名称批量获取设置项缓存
 func GetSettings(keys []string, prefix string) (map[string]string, []string) {
 	raw, miss := Store.Gets(keys, prefix)
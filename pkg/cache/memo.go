@@ -13,6 +13,9 @@ import (
 // MemoStore 内存存储驱动
 type MemoStore struct {
 	Store *sync.Map
+
+	// updateMu 保证 Update 的读取与写入不会交错
+	updateMu sync.Mutex
 }
 
 // item 存储的对象
@@ -116,6 +119,25 @@ func (store *MemoStore) Delete(keys []string, prefix string) error {
 	return nil
 }
 
+// Update 原子地读取并更新值
+func (store *MemoStore) Update(key string, fn UpdateFunc) error {
+	store.updateMu.Lock()
+	defer store.updateMu.Unlock()
+
+	value, ok := store.Get(key)
+	newValue, ttl, err := fn(value, ok)
+	if err != nil {
+		return err
+	}
+
+	if newValue == nil {
+		store.Store.Delete(key)
+		return nil
+	}
+
+	return store.Set(key, newValue, ttl)
+}
+
 // Persist write memory store into cache
 func (store *MemoStore) Persist(path string) error {
 	persisted := make(map[string]itemWithTTL)
@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"errors"
 	"github.com/stretchr/testify/assert"
 	"path/filepath"
 	"testing"
@@ -137,6 +138,42 @@ func TestMemoStore_Delete(t *testing.T) {
 	asserts.Equal(map[string]interface{}{"3": "3.val", "4": "4.val"}, values)
 }
 
+func TestMemoStore_Update(t *testing.T) {
+	asserts := assert.New(t)
+	store := NewMemoStore()
+
+	// 不存在时写入
+	err := store.Update("test", func(value interface{}, ok bool) (interface{}, int, error) {
+		asserts.False(ok)
+		return 1, 0, nil
+	})
+	asserts.NoError(err)
+
+	// 基于当前值更新
+	err = store.Update("test", func(value interface{}, ok bool) (interface{}, int, error) {
+		asserts.True(ok)
+		return value.(int) + 1, 0, nil
+	})
+	asserts.NoError(err)
+	value, _ := store.Get("test")
+	asserts.Equal(2, value)
+
+	// 返回错误时不修改
+	err = store.Update("test", func(value interface{}, ok bool) (interface{}, int, error) {
+		return 3, 0, errors.New("error")
+	})
+	asserts.Error(err)
+	value, _ = store.Get("test")
+	asserts.Equal(2, value)
+
+	// 新值为 nil 时删除
+	asserts.NoError(store.Update("test", func(value interface{}, ok bool) (interface{}, int, error) {
+		return nil, 0, nil
+	}))
+	_, ok := store.Get("test")
+	asserts.False(ok)
+}
+
 func TestMemoStore_GarbageCollect(t *testing.T) {
 	asserts := assert.New(t)
 	store := NewMemoStore()
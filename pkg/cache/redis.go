@@ -3,6 +3,7 @@ package cache
 import (
 	"bytes"
 	"encoding/gob"
+	"errors"
 	"strconv"
 	"time"
 
@@ -203,6 +204,71 @@ func (store *RedisStore) Delete(keys []string, prefix string) error {
 	return nil
 }
 
+// redisUpdateRetries Update 因并发修改失败时的最大重试次数
+const redisUpdateRetries = 10
+
+// ErrUpdateConflict 并发修改过于频繁，Update 重试后仍未成功
+var ErrUpdateConflict = errors.New("too many concurrent updates")
+
+// Update 使用 WATCH/MULTI/EXEC 原子地读取并更新值，键在读取后被修改时重试
+func (store *RedisStore) Update(key string, fn UpdateFunc) error {
+	rc := store.pool.Get()
+	defer rc.Close()
+	if rc.Err() != nil {
+		return rc.Err()
+	}
+
+	for i := 0; i < redisUpdateRetries; i++ {
+		if _, err := rc.Do("WATCH", key); err != nil {
+			return err
+		}
+
+		var (
+			value interface{}
+			ok    bool
+		)
+		raw, err := redis.Bytes(rc.Do("GET", key))
+		if err != nil && err != redis.ErrNil {
+			rc.Do("UNWATCH")
+			return err
+		}
+		if err == nil {
+			value, err = deserializer(raw)
+			ok = err == nil
+		}
+
+		newValue, ttl, err := fn(value, ok)
+		if err != nil {
+			rc.Do("UNWATCH")
+			return err
+		}
+
+		rc.Send("MULTI")
+		if newValue == nil {
+			rc.Send("DEL", key)
+		} else {
+			serialized, err := serializer(newValue)
+			if err != nil {
+				rc.Do("DISCARD")
+				return err
+			}
+
+			if ttl > 0 {
+				rc.Send("SETEX", key, ttl, serialized)
+			} else {
+				rc.Send("SET", key, serialized)
+			}
+		}
+
+		// 键在 WATCH 之后被修改时 EXEC 返回 nil
+		if _, err := redis.Values(rc.Do("EXEC")); err != redis.ErrNil {
+			return err
+		}
+	}
+
+	return ErrUpdateConflict
+}
+
 // DeleteAll 批量所有键
 func (store *RedisStore) DeleteAll() error {
 	rc := store.pool.Get()
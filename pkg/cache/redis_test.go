@@ -322,3 +322,76 @@ func TestRedisStore_Delete(t *testing.T) {
 		asserts.Error(err)
 	}
 }
+
+func TestRedisStore_Update(t *testing.T) {
+	asserts := assert.New(t)
+	conn := redigomock.NewConn()
+	pool := &redis.Pool{
+		Dial:    func() (redis.Conn, error) { return conn, nil },
+		MaxIdle: 10,
+	}
+	store := &RedisStore{pool: pool}
+	stored, _ := serializer("old")
+
+	// 键被并发修改后重试成功
+	{
+		conn.Command("WATCH", "test").Expect("OK")
+		get := conn.Command("GET", "test").Expect(nil).Expect(stored)
+		conn.Command("MULTI").Expect("OK")
+		conn.Command("SETEX", "test", 10, redigomock.NewAnyData()).Expect("QUEUED")
+		exec := conn.Command("EXEC").Expect(nil).Expect([]interface{}{"OK"})
+		var seen []interface{}
+		err := store.Update("test", func(value interface{}, ok bool) (interface{}, int, error) {
+			seen = append(seen, value)
+			return "new", 10, nil
+		})
+		asserts.NoError(err)
+		asserts.Equal([]interface{}{nil, "old"}, seen)
+		asserts.Equal(2, conn.Stats(get))
+		asserts.Equal(2, conn.Stats(exec))
+	}
+
+	// 新值为 nil 时删除
+	{
+		conn.Clear()
+		conn.Command("WATCH", "test").Expect("OK")
+		conn.Command("GET", "test").Expect(stored)
+		conn.Command("MULTI").Expect("OK")
+		del := conn.Command("DEL", "test").Expect("QUEUED")
+		conn.Command("EXEC").Expect([]interface{}{int64(1)})
+		err := store.Update("test", func(value interface{}, ok bool) (interface{}, int, error) {
+			return nil, 0, nil
+		})
+		asserts.NoError(err)
+		asserts.Equal(1, conn.Stats(del))
+	}
+
+	// fn 返回错误时不修改
+	{
+		conn.Clear()
+		conn.Command("WATCH", "test").Expect("OK")
+		conn.Command("GET", "test").Expect(stored)
+		unwatch := conn.Command("UNWATCH").Expect("OK")
+		multi := conn.Command("MULTI").Expect("OK")
+		err := store.Update("test", func(value interface{}, ok bool) (interface{}, int, error) {
+			return nil, 0, errors.New("error")
+		})
+		asserts.Error(err)
+		asserts.Equal(1, conn.Stats(unwatch))
+		asserts.Equal(0, conn.Stats(multi))
+	}
+
+	// 持续冲突
+	{
+		conn.Clear()
+		conn.Command("WATCH", "test").Expect("OK")
+		conn.Command("GET", "test").Expect(nil)
+		conn.Command("MULTI").Expect("OK")
+		conn.Command("SET", "test", redigomock.NewAnyData()).Expect("QUEUED")
+		conn.Command("EXEC").Expect(nil)
+		err := store.Update("test", func(value interface{}, ok bool) (interface{}, int, error) {
+			return "new", 0, nil
+		})
+		asserts.Equal(ErrUpdateConflict, err)
+	}
+}
@@ -4,7 +4,7 @@ package conf
 var BackendVersion = "1.0.0"
 
 // RequiredDBVersion 与当前版本匹配的数据库版本
-var RequiredDBVersion = "1.0.0"
+var RequiredDBVersion = "1.1.0"
 
 // RequiredStaticVersion 与当前版本匹配的静态资源版本
 var RequiredStaticVersion = "1.0.0"
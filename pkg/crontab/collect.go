@@ -58,12 +58,26 @@ func collectCache(store *cache.MemoStore) {
 
 func uploadSessionCollect() {
 	placeholders := model.GetUploadPlaceholderFiles(0)
+	sessions, err := model.GetActiveUploadSessions()
+	if err != nil {
+		util.Log().Warning("Failed to list upload sessions: %s", err)
+		return
+	}
+
+	activeSessions := make(map[string]bool, len(sessions))
+	for _, session := range sessions {
+		activeSessions[session.SessionID] = true
+	}
 
 	// 将过期的上传会话按照用户分组
 	userToFiles := make(map[uint][]uint)
 	for _, file := range placeholders {
-		_, sessionExist := cache.Get(filesystem.UploadSessionCachePrefix + *file.UploadSessionID)
-		if sessionExist {
+		if activeSessions[*file.UploadSessionID] {
+			continue
+		}
+
+		// 兼容仅存在于缓存中的会话
+		if _, sessionExist := cache.Get(filesystem.UploadSessionCachePrefix + *file.UploadSessionID); sessionExist {
 			continue
 		}
 
@@ -95,5 +109,43 @@ func uploadSessionCollect() {
 		fs.Recycle()
 	}
 
+	// 清理过期的会话记录
+	if err := model.DeleteExpiredUploadSessions(); err != nil {
+		util.Log().Warning("Failed to delete expired upload session records: %s", err)
+	}
+
 	util.Log().Info("Crontab job \"cron_recycle_upload_session\" complete.")
 }
+
+func purgeExpiredTrash() {
+	expired := model.GetExpiredTrash()
+
+	// 将过期的回收站记录按照用户分组
+	userToTrash := make(map[uint][]model.Trash)
+	for _, item := range expired {
+		userToTrash[item.UserID] = append(userToTrash[item.UserID], item)
+	}
+
+	// 彻底删除过期对象
+	for uid, items := range userToTrash {
+		user, err := model.GetUserByID(uid)
+		if err != nil {
+			util.Log().Warning("Owner of the trash items cannot be found: %s", err)
+			continue
+		}
+
+		fs, err := filesystem.NewFileSystem(&user)
+		if err != nil {
+			util.Log().Warning("Failed to initialize filesystem: %s", err)
+			continue
+		}
+
+		if err = fs.PurgeTrash(context.Background(), items); err != nil {
+			util.Log().Warning("Failed to purge expired trash items: %s", err)
+		}
+
+		fs.Recycle()
+	}
+
+	util.Log().Info("Crontab job \"cron_purge_trash\" complete.")
+}
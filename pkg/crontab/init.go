@@ -24,6 +24,9 @@ func Init() {
 	options := model.GetSettingByNames(
 		"cron_garbage_collect",
 		"cron_recycle_upload_session",
+		"cron_purge_trash",
+		"cron_lifecycle",
+		"cron_trim_change_journal",
 	)
 	Cron := cron.New()
 	for k, v := range options {
@@ -33,6 +36,12 @@ func Init() {
 			handler = garbageCollect
 		case "cron_recycle_upload_session":
 			handler = uploadSessionCollect
+		case "cron_purge_trash":
+			handler = purgeExpiredTrash
+		case "cron_lifecycle":
+			handler = applyLifecycleRules
+		case "cron_trim_change_journal":
+			handler = trimChangeJournal
 		default:
 			util.Log().Warning("Unknown crontab job type %q, skipping...", k)
 			continue
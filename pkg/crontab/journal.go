@@ -0,0 +1,19 @@
+package crontab
+
+import (
+	"time"
+
+	model "github.com/Jaylenwa/Vfoy/models"
+	"github.com/Jaylenwa/Vfoy/pkg/util"
+)
+
+func trimChangeJournal() {
+	retention := model.GetIntSetting("change_journal_retention", 2592000)
+	deleted, err := model.TruncateChanges(time.Now().Add(-time.Duration(retention) * time.Second))
+	if err != nil {
+		util.Log().Warning("Failed to trim change journal: %s", err)
+		return
+	}
+
+	util.Log().Info("Crontab job \"cron_trim_change_journal\" complete, %d change(s) deleted.", deleted)
+}
@@ -0,0 +1,33 @@
+package crontab
+
+import (
+	model "github.com/Jaylenwa/Vfoy/models"
+	"github.com/Jaylenwa/Vfoy/pkg/task"
+	"github.com/Jaylenwa/Vfoy/pkg/util"
+)
+
+// applyLifecycleRules 为已启用的生命周期规则创建执行任务，上次任务未结束的规则将被跳过
+func applyLifecycleRules() {
+	rules, err := model.GetEnabledLifecycleRules()
+	if err != nil {
+		util.Log().Warning("Failed to list lifecycle rules: %s", err)
+		return
+	}
+
+	running := task.RunningLifecycleRules()
+	for _, rule := range rules {
+		if running[rule.ID] {
+			util.Log().Info("Lifecycle rule %q is still running, skipping...", rule.Name)
+			continue
+		}
+
+		job, err := task.NewLifecycleTask(model.NewAnonymousUser(), rule.ID)
+		if err != nil {
+			util.Log().Warning("Failed to create task for lifecycle rule %q: %s", rule.Name, err)
+			continue
+		}
+		task.TaskPoll.Submit(job)
+	}
+
+	util.Log().Info("Crontab job \"cron_lifecycle\" complete.")
+}
@@ -0,0 +1,71 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	aeszip "github.com/alexmullins/zip"
+	"github.com/mholt/archiver/v4"
+)
+
+// EncryptedZip 使用密码解压 AES 加密的 zip 文件，未加密的条目照常解压
+type EncryptedZip struct {
+	Password string
+}
+
+// Extract 解压 zip 文件，sourceArchive 需支持随机读取
+func (z EncryptedZip) Extract(ctx context.Context, sourceArchive io.Reader, pathsInArchive []string, handleFile archiver.FileHandler) error {
+	sra, ok := sourceArchive.(interface {
+		io.ReaderAt
+		io.Seeker
+	})
+	if !ok {
+		return errors.New("input type must be an io.ReaderAt and io.Seeker because of zip format constraints")
+	}
+
+	size, err := sra.Seek(0, io.SeekEnd)
+	if err != nil {
+		return fmt.Errorf("determining stream size: %w", err)
+	}
+
+	zr, err := aeszip.NewReader(sra, size)
+	if err != nil {
+		return err
+	}
+
+	for i, f := range zr.File {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		f := f
+		if f.IsEncrypted() {
+			f.SetPassword(z.Password)
+		}
+
+		file := archiver.File{
+			FileInfo:      f.FileInfo(),
+			Header:        f.FileHeader,
+			NameInArchive: f.Name,
+			Open:          func() (io.ReadCloser, error) { return openEncryptedZipFile(f) },
+		}
+
+		if err := handleFile(ctx, file); err != nil {
+			return fmt.Errorf("handling file %d: %s: %w", i, f.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// openEncryptedZipFile 打开 zip 文件中的条目，密码错误时返回 ErrArchivePassword
+func openEncryptedZipFile(f *aeszip.File) (io.ReadCloser, error) {
+	rc, err := f.Open()
+	if errors.Is(err, aeszip.ErrPassword) {
+		return nil, ErrArchivePassword
+	}
+
+	return rc, err
+}
@@ -0,0 +1,152 @@
+package filesystem
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	model "github.com/Jaylenwa/Vfoy/models"
+	"github.com/Jaylenwa/Vfoy/pkg/filesystem/fsctx"
+	aeszip "github.com/alexmullins/zip"
+	"github.com/jinzhu/gorm"
+	"github.com/mholt/archiver/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// encryptedZip 创建使用 password 加密的 zip 文件
+func encryptedZip(t *testing.T, password string) []byte {
+	buf := &bytes.Buffer{}
+	w, err := newArchiveWriter(buf, CompressOption{Password: password})
+	assert.NoError(t, err)
+
+	fw, err := w.Create("docs/a.txt", 5, time.Now())
+	assert.NoError(t, err)
+	fw.Write([]byte("hello"))
+	assert.NoError(t, w.Close())
+
+	return buf.Bytes()
+}
+
+func TestNewArchiveWriter_Encrypted(t *testing.T) {
+	asserts := assert.New(t)
+	data := encryptedZip(t, "123456")
+
+	zr, err := aeszip.NewReader(bytes.NewReader(data), int64(len(data)))
+	asserts.NoError(err)
+	asserts.Len(zr.File, 1)
+	asserts.True(zr.File[0].IsEncrypted())
+
+	// 密码错误
+	zr.File[0].SetPassword("654321")
+	_, err = openEncryptedZipFile(zr.File[0])
+	asserts.Equal(ErrArchivePassword, err)
+
+	// 密码正确
+	zr.File[0].SetPassword("123456")
+	rc, err := openEncryptedZipFile(zr.File[0])
+	asserts.NoError(err)
+	content, _ := io.ReadAll(rc)
+	asserts.Equal("hello", string(content))
+
+	// tar 格式不支持加密
+	_, err = newArchiveWriter(&bytes.Buffer{}, CompressOption{Format: CompressFormatTarGz, Password: "123456"})
+	asserts.Equal(ErrUnsupportedArchive, err)
+}
+
+func TestEncryptedZip_Extract(t *testing.T) {
+	asserts := assert.New(t)
+	data := encryptedZip(t, "123456")
+
+	// 不支持随机读取
+	err := EncryptedZip{}.Extract(context.Background(), bytes.NewBufferString(""), nil, nil)
+	asserts.Error(err)
+
+	// 密码正确
+	var names []string
+	err = EncryptedZip{Password: "123456"}.Extract(context.Background(), bytes.NewReader(data), nil,
+		func(ctx context.Context, f archiver.File) error {
+			names = append(names, f.NameInArchive)
+			rc, err := f.Open()
+			if err != nil {
+				return err
+			}
+			content, _ := io.ReadAll(rc)
+			asserts.Equal("hello", string(content))
+			return rc.Close()
+		})
+	asserts.NoError(err)
+	asserts.Equal([]string{"docs/a.txt"}, names)
+
+	// 密码错误
+	err = EncryptedZip{Password: "654321"}.Extract(context.Background(), bytes.NewReader(data), nil,
+		func(ctx context.Context, f archiver.File) error {
+			_, err := f.Open()
+			return err
+		})
+	asserts.ErrorIs(err, ErrArchivePassword)
+}
+
+func TestFileSystem_OpenArchive_Encrypted(t *testing.T) {
+	asserts := assert.New(t)
+	fs := &FileSystem{User: &model.User{Model: gorm.Model{ID: 1}}}
+
+	open := func(data []byte, password string) (*ArchiveEntry, error) {
+		fs.Handler = &rangeHandlerMock{data: data}
+		file := &model.File{Size: uint64(len(data)), SourceName: "1.zip", Policy: model.Policy{Type: "mock"}}
+		file.Policy.ID = 1
+
+		ctx := context.WithValue(context.Background(), fsctx.ArchivePasswordCtx, password)
+		archive, err := fs.OpenArchive(ctx, file, "")
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range archive.Entries {
+			if archive.Entries[i].Regular() {
+				return &archive.Entries[i], nil
+			}
+		}
+
+		return nil, ErrArchiveEntryNotExist
+	}
+
+	// zip 未提供密码
+	data := encryptedZip(t, "123456")
+	entry, err := open(data, "")
+	asserts.NoError(err)
+	asserts.True(entry.Encrypted)
+	_, err = entry.Open()
+	asserts.Equal(ErrArchiveEncrypted, err)
+
+	// zip 密码错误
+	entry, err = open(data, "654321")
+	asserts.NoError(err)
+	_, err = entry.Open()
+	asserts.Equal(ErrArchivePassword, err)
+
+	// zip 密码正确
+	entry, err = open(data, "123456")
+	asserts.NoError(err)
+	rc, err := entry.Open()
+	asserts.NoError(err)
+	content, _ := io.ReadAll(rc)
+	asserts.Equal("hello", string(content))
+
+	// 7z 文件头加密
+	data, err = os.ReadFile("tests/encrypted.7z")
+	asserts.NoError(err)
+	_, err = open(data, "")
+	asserts.Equal(ErrArchiveEncrypted, err)
+	_, err = open(data, "wrong")
+	asserts.Equal(ErrArchivePassword, err)
+	entry, err = open(data, "password")
+	asserts.NoError(err)
+	asserts.True(entry.Encrypted)
+	rc, err = entry.Open()
+	asserts.NoError(err)
+	content, _ = io.ReadAll(rc)
+	asserts.NotEmpty(content)
+}
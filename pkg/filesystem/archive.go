@@ -3,20 +3,22 @@ package filesystem
 import (
 	"archive/zip"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path"
 	"path/filepath"
-	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	model "github.com/Jaylenwa/Vfoy/models"
 	"github.com/Jaylenwa/Vfoy/pkg/filesystem/fsctx"
 	"github.com/Jaylenwa/Vfoy/pkg/util"
 	"github.com/gin-gonic/gin"
 	"github.com/mholt/archiver/v4"
+	"golang.org/x/text/encoding/ianaindex"
 )
 
 /* ===============
@@ -24,18 +26,58 @@ import (
    ===============
 */
 
-// Compress 创建给定目录和文件的压缩文件
+// Compress 创建给定目录和文件的 zip 压缩文件，isArchive 为真时仅归档不压缩
 func (fs *FileSystem) Compress(ctx context.Context, writer io.Writer, folderIDs, fileIDs []uint, isArchive bool) error {
+	folders, files, ctx, err := fs.compressTargets(ctx, folderIDs, fileIDs)
+	if err != nil {
+		return err
+	}
+
+	// 指定是压缩还是归档
+	method := zip.Deflate
+	if isArchive {
+		method = zip.Store
+	}
+
+	// 创建压缩文件Writer
+	zipWriter := newZipArchiveWriter(writer, method, 0)
+	defer zipWriter.Close()
+
+	return fs.compressTo(ctx, zipWriter, folders, files)
+}
+
+// CompressAs 按指定的格式与压缩级别创建给定目录和文件的压缩文件
+func (fs *FileSystem) CompressAs(ctx context.Context, writer io.Writer, folderIDs, fileIDs []uint, option CompressOption) error {
+	folders, files, ctx, err := fs.compressTargets(ctx, folderIDs, fileIDs)
+	if err != nil {
+		return err
+	}
+
+	archiveWriter, err := newArchiveWriter(writer, option)
+	if err != nil {
+		return err
+	}
+
+	if err := fs.compressTo(ctx, archiveWriter, folders, files); err != nil {
+		archiveWriter.Close()
+		return err
+	}
+
+	return archiveWriter.Close()
+}
+
+// compressTargets 查找待压缩的目录和文件，返回用于检查用户取消任务的上下文
+func (fs *FileSystem) compressTargets(ctx context.Context, folderIDs, fileIDs []uint) ([]model.Folder, []model.File, context.Context, error) {
 	// 查找待压缩目录
 	folders, err := model.GetFoldersByIDs(folderIDs, fs.User.ID)
 	if err != nil && len(folderIDs) != 0 {
-		return ErrDBListObjects
+		return nil, nil, ctx, ErrDBListObjects
 	}
 
 	// 查找待压缩文件
 	files, err := model.GetFilesByIDs(fileIDs, fs.User.ID)
 	if err != nil && len(fileIDs) != 0 {
-		return ErrDBListObjects
+		return nil, nil, ctx, ErrDBListObjects
 	}
 
 	// 如果上下文限制了父目录，则进行检查
@@ -43,14 +85,14 @@ func (fs *FileSystem) Compress(ctx context.Context, writer io.Writer, folderIDs,
 		// 检查目录
 		for _, folder := range folders {
 			if *folder.ParentID != parent.ID {
-				return ErrObjectNotExist
+				return nil, nil, ctx, ErrObjectNotExist
 			}
 		}
 
 		// 检查文件
 		for _, file := range files {
 			if file.FolderID != parent.ID {
-				return ErrObjectNotExist
+				return nil, nil, ctx, ErrObjectNotExist
 			}
 		}
 	}
@@ -70,37 +112,36 @@ func (fs *FileSystem) Compress(ctx context.Context, writer io.Writer, folderIDs,
 		files[i].Position = ""
 	}
 
-	// 创建压缩文件Writer
-	zipWriter := zip.NewWriter(writer)
-	defer zipWriter.Close()
-
-	ctx = reqContext
+	return folders, files, reqContext, nil
+}
 
+// compressTo 将目录和文件写入压缩文件
+func (fs *FileSystem) compressTo(ctx context.Context, writer archiveWriter, folders []model.Folder, files []model.File) error {
 	// 压缩各个目录及文件
 	for i := 0; i < len(folders); i++ {
 		select {
-		case <-reqContext.Done():
+		case <-ctx.Done():
 			// 取消压缩请求
 			return ErrClientCanceled
 		default:
-			fs.doCompress(reqContext, nil, &folders[i], zipWriter, isArchive)
+			fs.doCompress(ctx, nil, &folders[i], writer)
 		}
 
 	}
 	for i := 0; i < len(files); i++ {
 		select {
-		case <-reqContext.Done():
+		case <-ctx.Done():
 			// 取消压缩请求
 			return ErrClientCanceled
 		default:
-			fs.doCompress(reqContext, &files[i], nil, zipWriter, isArchive)
+			fs.doCompress(ctx, &files[i], nil, writer)
 		}
 	}
 
 	return nil
 }
 
-func (fs *FileSystem) doCompress(ctx context.Context, file *model.File, folder *model.Folder, zipWriter *zip.Writer, isArchive bool) {
+func (fs *FileSystem) doCompress(ctx context.Context, file *model.File, folder *model.Folder, archiveWriter archiveWriter) {
 	// 如果对象是文件
 	if file != nil {
 		// 切换上传策略
@@ -125,20 +166,11 @@ func (fs *FileSystem) doCompress(ctx context.Context, file *model.File, folder *
 		}
 
 		// 创建压缩文件头
-		header := &zip.FileHeader{
-			Name:               filepath.FromSlash(path.Join(file.Position, file.Name)),
-			Modified:           file.UpdatedAt,
-			UncompressedSize64: file.Size,
-		}
-
-		// 指定是压缩还是归档
-		if isArchive {
-			header.Method = zip.Store
-		} else {
-			header.Method = zip.Deflate
-		}
-
-		writer, err := zipWriter.CreateHeader(header)
+		writer, err := archiveWriter.Create(
+			filepath.FromSlash(path.Join(file.Position, file.Name)),
+			file.Size,
+			file.UpdatedAt,
+		)
 		if err != nil {
 			return
 		}
@@ -150,7 +182,7 @@ func (fs *FileSystem) doCompress(ctx context.Context, file *model.File, folder *
 		subFiles, err := folder.GetChildFiles()
 		if err == nil && len(subFiles) > 0 {
 			for i := 0; i < len(subFiles); i++ {
-				fs.doCompress(ctx, &subFiles[i], nil, zipWriter, isArchive)
+				fs.doCompress(ctx, &subFiles[i], nil, archiveWriter)
 			}
 
 		}
@@ -158,13 +190,16 @@ func (fs *FileSystem) doCompress(ctx context.Context, file *model.File, folder *
 		subFolders, err := folder.GetChildFolder()
 		if err == nil && len(subFolders) > 0 {
 			for i := 0; i < len(subFolders); i++ {
-				fs.doCompress(ctx, nil, &subFolders[i], zipWriter, isArchive)
+				fs.doCompress(ctx, nil, &subFolders[i], archiveWriter)
 			}
 		}
 	}
 }
 
-// Decompress 解压缩给定压缩文件到dst目录
+// DecompressProgressFunc 解压进度回调，参数为已解压的文件数量和大小
+type DecompressProgressFunc func(files int, size uint64)
+
+// Decompress 解压缩给定压缩文件到dst目录，压缩格式根据文件头识别
 func (fs *FileSystem) Decompress(ctx context.Context, src, dst, encoding string) error {
 	err := fs.ResetFileIfNotExist(ctx, src)
 	if err != nil {
@@ -181,7 +216,6 @@ func (fs *FileSystem) Decompress(ctx context.Context, src, dst, encoding string)
 		}
 	}()
 
-	// 下载压缩文件到临时目录
 	fileStream, err := fs.Handler.Get(ctx, fs.FileTarget[0].SourceName)
 	if err != nil {
 		return err
@@ -189,43 +223,58 @@ func (fs *FileSystem) Decompress(ctx context.Context, src, dst, encoding string)
 
 	defer fileStream.Close()
 
-	tempZipFilePath = filepath.Join(
-		util.RelativePath(model.GetSettingByName("temp_path")),
-		"decompress",
-		fmt.Sprintf("archive_%d.zip", time.Now().UnixNano()),
-	)
-
-	zipFile, err := util.CreatNestedFile(tempZipFilePath)
+	// 下载前先判断是否是可解压的格式，只依据文件头，不信任扩展名
+	format, readStream, err := archiver.Identify("", fileStream)
 	if err != nil {
-		util.Log().Warning("Failed to create temp archive file %q: %s", tempZipFilePath, err)
-		tempZipFilePath = ""
-		return err
-	}
-	defer zipFile.Close()
+		if errors.Is(err, archiver.ErrNoMatch) {
+			return ErrUnsupportedArchive
+		}
 
-	// 下载前先判断是否是可解压的格式
-	format, readStream, err := archiver.Identify(fs.FileTarget[0].SourceName, fileStream)
-	if err != nil {
 		util.Log().Warning("Failed to detect compressed format of file %q: %s", fs.FileTarget[0].SourceName, err)
 		return err
 	}
 
 	extractor, ok := format.(archiver.Extractor)
 	if !ok {
-		return fmt.Errorf("file not an extractor %s", fs.FileTarget[0].SourceName)
+		return ErrUnsupportedArchive
 	}
 
+	// zip 与 7z 需要随机读取，必须下载到本地，其余的可以边下载边解压；
 	// 只有zip格式可以多个文件同时上传
-	var isZip bool
+	var isZip, seekable, decodeName bool
+	password, _ := ctx.Value(fsctx.ArchivePasswordCtx).(string)
 	switch extractor.(type) {
 	case archiver.Zip:
-		extractor = archiver.Zip{TextEncoding: encoding}
-		isZip = true
+		if password != "" {
+			extractor = EncryptedZip{Password: password}
+			decodeName = true
+		} else {
+			extractor = archiver.Zip{TextEncoding: encoding}
+		}
+		isZip, seekable = true, true
+	case SevenZip:
+		extractor = SevenZip{Password: password}
+		seekable, decodeName = true, true
+	default:
+		decodeName = true
 	}
 
-	// 除了zip必须下载到本地，其余的可以边下载边解压
 	reader := readStream
-	if isZip {
+	if seekable {
+		tempZipFilePath = filepath.Join(
+			util.RelativePath(model.GetSettingByName("temp_path")),
+			"decompress",
+			fmt.Sprintf("archive_%d%s", time.Now().UnixNano(), format.Name()),
+		)
+
+		zipFile, err := util.CreatNestedFile(tempZipFilePath)
+		if err != nil {
+			util.Log().Warning("Failed to create temp archive file %q: %s", tempZipFilePath, err)
+			tempZipFilePath = ""
+			return err
+		}
+		defer zipFile.Close()
+
 		_, err = io.Copy(zipFile, readStream)
 		if err != nil {
 			util.Log().Warning("Failed to write temp archive file %q: %s", tempZipFilePath, err)
@@ -253,6 +302,14 @@ func (fs *FileSystem) Decompress(ctx context.Context, src, dst, encoding string)
 		worker <- i
 	}
 
+	// 解压进度
+	var (
+		progressLock   sync.Mutex
+		extractedFiles int
+		extractedSize  uint64
+	)
+	progress, _ := ctx.Value(fsctx.ProgressFuncCtx).(DecompressProgressFunc)
+
 	// 上传文件函数
 	uploadFunc := func(fileStream io.ReadCloser, size int64, savePath, rawPath string) {
 		defer func() {
@@ -275,15 +332,35 @@ func (fs *FileSystem) Decompress(ctx context.Context, src, dst, encoding string)
 		fileStream.Close()
 		if err != nil {
 			util.Log().Debug("Failed to upload file %q in archive file: %s, skipping...", rawPath, err)
+			return
+		}
+
+		progressLock.Lock()
+		defer progressLock.Unlock()
+		extractedFiles++
+		extractedSize += uint64(size)
+		if progress != nil {
+			progress(extractedFiles, extractedSize)
 		}
 	}
 
-	// 解压缩文件，回调函数如果出错会停止解压的下一步进行，全部return nil
+	// 解压后的总大小限制，按文件头中记录的原始大小累计
+	var (
+		sizeLimit = fs.User.Group.OptionsSerialized.DecompressSize
+		totalSize uint64
+	)
+
+	// 解压缩文件，回调函数如果出错会停止解压的下一步进行，除超出大小限制与密码错误外全部return nil
 	err = extractor.Extract(ctx, reader, nil, func(ctx context.Context, f archiver.File) error {
-		rawPath := util.FormSlash(f.NameInArchive)
-		savePath := path.Join(dst, rawPath)
+		name := f.NameInArchive
+		if decodeName {
+			name = decodeArchiveText(name, encoding)
+		}
+
+		rawPath := util.FormSlash(name)
+		savePath, ok := archiveSavePath(dst, rawPath)
 		// 路径是否合法
-		if !strings.HasPrefix(savePath, util.FillSlash(path.Clean(dst))) {
+		if !ok {
 			util.Log().Warning("%s: illegal file path", f.NameInArchive)
 			return nil
 		}
@@ -294,19 +371,46 @@ func (fs *FileSystem) Decompress(ctx context.Context, src, dst, encoding string)
 			return nil
 		}
 
+		// 跳过符号链接、设备文件等
+		if !f.FileInfo.Mode().IsRegular() || f.FileInfo.Size() < 0 {
+			util.Log().Debug("Skipping irregular file %q in archive file.", rawPath)
+			return nil
+		}
+
+		// 未提供密码时无法解压加密的条目
+		if header, ok := f.Header.(zip.FileHeader); ok && header.Flags&0x1 != 0 {
+			return ErrArchiveEncrypted
+		}
+
+		size := f.FileInfo.Size()
+		if sizeLimit > 0 && totalSize+uint64(size) > sizeLimit {
+			return ErrDecompressSizeExceeded
+		}
+		totalSize += uint64(size)
+
 		// 上传文件
 		fileStream, err := f.Open()
 		if err != nil {
+			if errors.Is(err, ErrArchivePassword) {
+				return err
+			}
+
 			util.Log().Warning("Failed to open file %q in archive file: %s, skipping...", rawPath, err)
 			return nil
 		}
 
+		// 读取的内容不超过文件头中记录的大小
+		fileStream = &limitedReadCloser{
+			Reader: io.LimitReader(fileStream, size),
+			Closer: fileStream,
+		}
+
 		if !isZip {
-			uploadFunc(fileStream, f.FileInfo.Size(), savePath, rawPath)
+			uploadFunc(fileStream, size, savePath, rawPath)
 		} else {
 			<-worker
 			wg.Add(1)
-			go uploadFunc(fileStream, f.FileInfo.Size(), savePath, rawPath)
+			go uploadFunc(fileStream, size, savePath, rawPath)
 		}
 		return nil
 	})
@@ -314,3 +418,28 @@ func (fs *FileSystem) Decompress(ctx context.Context, src, dst, encoding string)
 	return err
 
 }
+
+// limitedReadCloser 限制读取长度的 ReadCloser
+type limitedReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// decodeArchiveText 将非 UTF-8 编码的文件名按指定编码转换
+func decodeArchiveText(text, encoding string) string {
+	if encoding == "" || utf8.ValidString(text) {
+		return text
+	}
+
+	enc, err := ianaindex.IANA.Encoding(encoding)
+	if err != nil || enc == nil {
+		return text
+	}
+
+	decoded, err := enc.NewDecoder().String(text)
+	if err != nil {
+		return text
+	}
+
+	return decoded
+}
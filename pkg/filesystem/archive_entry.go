@@ -0,0 +1,453 @@
+package filesystem
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	model "github.com/Jaylenwa/Vfoy/models"
+	"github.com/Jaylenwa/Vfoy/pkg/filesystem/driver"
+	"github.com/Jaylenwa/Vfoy/pkg/filesystem/fsctx"
+	"github.com/Jaylenwa/Vfoy/pkg/filesystem/response"
+	"github.com/Jaylenwa/Vfoy/pkg/util"
+	aeszip "github.com/alexmullins/zip"
+)
+
+/* ================================
+     按字节范围读取压缩文件中的条目
+   ================================
+*/
+
+const (
+	// rangeBlockSize 每次按范围读取的数据块大小
+	rangeBlockSize = 256 << 10
+	// rangeCacheBlocks 最多缓存的数据块数量
+	rangeCacheBlocks = 32
+)
+
+var (
+	zipHeader      = []byte("PK\x03\x04")
+	emptyZipHeader = []byte("PK\x05\x06")
+)
+
+// rangeReader 通过存储策略适配器按字节范围读取文件，实现 io.ReaderAt
+type rangeReader struct {
+	mu      sync.Mutex
+	ctx     context.Context
+	handler driver.Handler
+	file    model.File
+	size    int64
+
+	// 存储策略返回的文件流可随机读取时直接使用，如本机存储策略
+	direct io.ReaderAt
+	closer io.Closer
+
+	blocks map[int64][]byte
+	order  []int64
+}
+
+// newRangeReader 创建文件的 rangeReader，并读取第一个数据块
+func newRangeReader(ctx context.Context, handler driver.Handler, file *model.File) (*rangeReader, error) {
+	r := &rangeReader{
+		ctx:     ctx,
+		handler: handler,
+		file:    *file,
+		size:    int64(file.Size),
+		blocks:  make(map[int64][]byte),
+	}
+
+	rs, err := r.get(0)
+	if err != nil {
+		return nil, err
+	}
+
+	if ra, ok := rs.(io.ReaderAt); ok {
+		r.direct, r.closer = ra, rs
+		return r, nil
+	}
+
+	defer rs.Close()
+	if _, err := r.store(0, rs); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// get 读取指定的数据块
+func (r *rangeReader) get(index int64) (response.RSCloser, error) {
+	ctx := context.WithValue(r.ctx, fsctx.FileModelCtx, r.file)
+	ctx = context.WithValue(ctx, fsctx.RangeCtx, fsctx.Range{
+		Offset: index * rangeBlockSize,
+		Length: r.blockLen(index),
+	})
+
+	return r.handler.Get(ctx, r.file.SourceName)
+}
+
+// blockLen 数据块的长度
+func (r *rangeReader) blockLen(index int64) int64 {
+	if remain := r.size - index*rangeBlockSize; remain < rangeBlockSize {
+		return remain
+	}
+
+	return rangeBlockSize
+}
+
+// store 读取并缓存数据块，超出缓存数量时淘汰最早读取的数据块
+func (r *rangeReader) store(index int64, reader io.Reader) ([]byte, error) {
+	buf := make([]byte, r.blockLen(index))
+	if _, err := io.ReadFull(reader, buf); err != nil {
+		return nil, err
+	}
+
+	if len(r.order) >= rangeCacheBlocks {
+		delete(r.blocks, r.order[0])
+		r.order = r.order[1:]
+	}
+
+	r.blocks[index] = buf
+	r.order = append(r.order, index)
+	return buf, nil
+}
+
+// block 取得数据块，未缓存时通过存储策略读取
+func (r *rangeReader) block(index int64) ([]byte, error) {
+	if buf, ok := r.blocks[index]; ok {
+		return buf, nil
+	}
+
+	rs, err := r.get(index)
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+
+	return r.store(index, rs)
+}
+
+// ReadAt 实现 io.ReaderAt
+func (r *rangeReader) ReadAt(p []byte, off int64) (int, error) {
+	if r.direct != nil {
+		return r.direct.ReadAt(p, off)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := 0
+	for n < len(p) && off+int64(n) < r.size {
+		pos := off + int64(n)
+		buf, err := r.block(pos / rangeBlockSize)
+		if err != nil {
+			return n, err
+		}
+
+		n += copy(p[n:], buf[pos%rangeBlockSize:])
+	}
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+// Close 关闭存储策略返回的文件流
+func (r *rangeReader) Close() error {
+	if r.closer != nil {
+		return r.closer.Close()
+	}
+
+	return nil
+}
+
+// ArchiveEntry 压缩文件中的条目
+type ArchiveEntry struct {
+	Name      string
+	Size      uint64
+	Modified  time.Time
+	IsDir     bool
+	Encrypted bool
+
+	mode os.FileMode
+	open func() (io.ReadCloser, error)
+	// 已提供密码，可读取加密的条目
+	unlocked bool
+}
+
+// Regular 条目是否为普通文件
+func (e *ArchiveEntry) Regular() bool {
+	return e.mode.IsRegular()
+}
+
+// Open 读取条目内容，读取的长度不超过条目记录的原始大小
+func (e *ArchiveEntry) Open() (io.ReadCloser, error) {
+	if e.Encrypted && !e.unlocked {
+		return nil, ErrArchiveEncrypted
+	}
+
+	rc, err := e.open()
+	if err != nil {
+		return nil, err
+	}
+
+	return &limitedReadCloser{
+		Reader: io.LimitReader(rc, int64(e.Size)),
+		Closer: rc,
+	}, nil
+}
+
+// Archive 按字节范围读取的压缩文件
+type Archive struct {
+	Entries []ArchiveEntry
+	reader  *rangeReader
+}
+
+// Entry 根据名称查找条目
+func (a *Archive) Entry(name string) (*ArchiveEntry, bool) {
+	name = entryName(name)
+	for i := range a.Entries {
+		if a.Entries[i].Name == name {
+			return &a.Entries[i], true
+		}
+	}
+
+	return nil, false
+}
+
+// Close 关闭压缩文件
+func (a *Archive) Close() error {
+	return a.reader.Close()
+}
+
+// OpenArchive 按字节范围读取压缩文件并列出其中的条目，无需下载完整的文件，
+// 仅支持 zip 与 7z 等可随机读取的格式。上下文中的密码用于读取加密的条目
+func (fs *FileSystem) OpenArchive(ctx context.Context, file *model.File, encoding string) (*Archive, error) {
+	fs.Policy = file.GetPolicy()
+	if err := fs.DispatchHandler(); err != nil {
+		return nil, err
+	}
+
+	reader, err := newRangeReader(ctx, fs.Handler, file)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, len(sevenZipHeader))
+	n, err := reader.ReadAt(header, 0)
+	if err != nil && err != io.EOF {
+		reader.Close()
+		return nil, err
+	}
+
+	var entries []ArchiveEntry
+	password, _ := ctx.Value(fsctx.ArchivePasswordCtx).(string)
+	header = header[:n]
+	switch {
+	case bytes.HasPrefix(header, zipHeader), bytes.HasPrefix(header, emptyZipHeader):
+		entries, err = zipEntries(reader, encoding, password)
+	case bytes.Equal(header, sevenZipHeader):
+		entries, err = sevenZipEntries(reader, password)
+	default:
+		err = ErrUnsupportedArchive
+	}
+
+	if err != nil {
+		reader.Close()
+		return nil, err
+	}
+
+	return &Archive{Entries: entries, reader: reader}, nil
+}
+
+// zipEntries 列出 zip 文件中的条目，提供密码时加密的条目使用 AES 解密读取
+func zipEntries(reader *rangeReader, encoding, password string) ([]ArchiveEntry, error) {
+	zr, err := zip.NewReader(reader, reader.size)
+	if err != nil {
+		return nil, err
+	}
+
+	var encrypted []*aeszip.File
+	entries := make([]ArchiveEntry, 0, len(zr.File))
+	for i, f := range zr.File {
+		entry := ArchiveEntry{
+			Name:      entryName(decodeArchiveText(f.Name, encoding)),
+			Size:      f.UncompressedSize64,
+			Modified:  f.Modified,
+			IsDir:     f.FileInfo().IsDir(),
+			Encrypted: f.Flags&0x1 != 0,
+			mode:      f.Mode(),
+			open:      f.Open,
+		}
+
+		if entry.Encrypted && password != "" {
+			// 两者按相同顺序读取中央目录，条目一一对应
+			if encrypted == nil {
+				ar, err := aeszip.NewReader(reader, reader.size)
+				if err != nil || len(ar.File) != len(zr.File) {
+					return nil, ErrUnsupportedArchive
+				}
+				encrypted = ar.File
+			}
+
+			ef := encrypted[i]
+			ef.SetPassword(password)
+			entry.open = func() (io.ReadCloser, error) { return openEncryptedZipFile(ef) }
+			entry.unlocked = true
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// sevenZipEntries 列出 7z 文件中的条目
+func sevenZipEntries(reader *rangeReader, password string) ([]ArchiveEntry, error) {
+	zr, err := openSevenZip(reader, reader.size, password)
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted := sevenZipEncrypted(reader)
+	entries := make([]ArchiveEntry, 0, len(zr.File))
+	for _, f := range zr.File {
+		info := f.FileInfo()
+		entries = append(entries, ArchiveEntry{
+			Name:      entryName(f.Name),
+			Size:      uint64(info.Size()),
+			Modified:  info.ModTime(),
+			IsDir:     info.IsDir(),
+			Encrypted: encrypted,
+			mode:      info.Mode(),
+			open:      f.Open,
+			unlocked:  password != "",
+		})
+	}
+
+	return entries, nil
+}
+
+// entryName 统一条目名称的格式，去掉开头和末尾的 /
+func entryName(name string) string {
+	return strings.TrimPrefix(util.FormSlash(name), "/")
+}
+
+// archiveSavePath 返回条目解压后的保存路径，路径超出 dst 时返回 false
+func archiveSavePath(dst, name string) (string, bool) {
+	savePath := path.Join(dst, util.FormSlash(name))
+	return savePath, strings.HasPrefix(savePath, util.FillSlash(path.Clean(dst)))
+}
+
+// ExtractEntries 解压压缩文件中选中的条目到 dst 目录，无需下载完整的压缩文件。
+// 选中目录时解压其下的全部条目，保存时去掉所选条目的上级目录
+func (fs *FileSystem) ExtractEntries(ctx context.Context, src string, names []string, dst, encoding string) error {
+	err := fs.ResetFileIfNotExist(ctx, src)
+	if err != nil {
+		return err
+	}
+
+	archive, err := fs.OpenArchive(ctx, &fs.FileTarget[0], encoding)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	// 重设存储策略
+	fs.Policy = &fs.User.Policy
+	err = fs.DispatchHandler()
+	if err != nil {
+		return err
+	}
+
+	var (
+		sizeLimit      = fs.User.Group.OptionsSerialized.DecompressSize
+		totalSize      uint64
+		extractedFiles int
+		extractedSize  uint64
+		extracted      = make(map[string]bool)
+	)
+	progress, _ := ctx.Value(fsctx.ProgressFuncCtx).(DecompressProgressFunc)
+
+	for _, name := range names {
+		name = entryName(name)
+		parent := path.Dir(name)
+
+		for i := range archive.Entries {
+			entry := &archive.Entries[i]
+			if name != "." && entry.Name != name && !strings.HasPrefix(entry.Name, name+"/") {
+				continue
+			}
+
+			if extracted[entry.Name] {
+				continue
+			}
+			extracted[entry.Name] = true
+
+			rel := entry.Name
+			if parent != "." {
+				rel = strings.TrimPrefix(rel, parent+"/")
+			}
+
+			savePath, ok := archiveSavePath(dst, rel)
+			if !ok {
+				util.Log().Warning("%s: illegal file path", entry.Name)
+				continue
+			}
+
+			if entry.IsDir {
+				fs.CreateDirectory(ctx, savePath)
+				continue
+			}
+
+			// 跳过符号链接、设备文件等
+			if !entry.Regular() {
+				util.Log().Debug("Skipping file %q in archive file.", entry.Name)
+				continue
+			}
+
+			if sizeLimit > 0 && totalSize+entry.Size > sizeLimit {
+				return ErrDecompressSizeExceeded
+			}
+			totalSize += entry.Size
+
+			fileStream, err := entry.Open()
+			if err != nil {
+				if errors.Is(err, ErrArchiveEncrypted) || errors.Is(err, ErrArchivePassword) {
+					return err
+				}
+
+				util.Log().Warning("Failed to open file %q in archive file: %s, skipping...", entry.Name, err)
+				continue
+			}
+
+			err = fs.UploadFromStream(ctx, &fsctx.FileStream{
+				File:        fileStream,
+				Size:        entry.Size,
+				Name:        path.Base(savePath),
+				VirtualPath: path.Dir(savePath),
+			}, true)
+			fileStream.Close()
+			if err != nil {
+				util.Log().Debug("Failed to upload file %q in archive file: %s, skipping...", entry.Name, err)
+				continue
+			}
+
+			extractedFiles++
+			extractedSize += entry.Size
+			if progress != nil {
+				progress(extractedFiles, extractedSize)
+			}
+		}
+	}
+
+	return nil
+}
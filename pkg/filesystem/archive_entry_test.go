@@ -0,0 +1,118 @@
+package filesystem
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	model "github.com/Jaylenwa/Vfoy/models"
+	"github.com/Jaylenwa/Vfoy/pkg/filesystem/fsctx"
+	"github.com/Jaylenwa/Vfoy/pkg/filesystem/response"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+// rangeHandlerMock 按上下文中的字节范围返回文件内容
+type rangeHandlerMock struct {
+	FileHeaderMock
+	data   []byte
+	ranges []fsctx.Range
+}
+
+func (m *rangeHandlerMock) Get(ctx context.Context, path string) (response.RSCloser, error) {
+	r := ctx.Value(fsctx.RangeCtx).(fsctx.Range)
+	m.ranges = append(m.ranges, r)
+	return MockRSC{rs: bytes.NewReader(m.data[r.Offset : r.Offset+r.Length])}, nil
+}
+
+func TestRangeReader_ReadAt(t *testing.T) {
+	asserts := assert.New(t)
+	data := bytes.Repeat([]byte("0123456789"), rangeBlockSize/5)
+	handler := &rangeHandlerMock{data: data}
+	file := &model.File{Size: uint64(len(data)), SourceName: "1.zip"}
+
+	r, err := newRangeReader(context.Background(), handler, file)
+	asserts.NoError(err)
+	asserts.Len(handler.ranges, 1)
+
+	// 跨越数据块读取
+	buf := make([]byte, 20)
+	n, err := r.ReadAt(buf, rangeBlockSize-10)
+	asserts.NoError(err)
+	asserts.Equal(20, n)
+	asserts.Equal(data[rangeBlockSize-10:rangeBlockSize+10], buf)
+	asserts.Len(handler.ranges, 2)
+	asserts.EqualValues(rangeBlockSize, handler.ranges[1].Offset)
+
+	// 使用缓存
+	_, err = r.ReadAt(buf, 0)
+	asserts.NoError(err)
+	asserts.Len(handler.ranges, 2)
+
+	// 读到文件末尾
+	n, err = r.ReadAt(buf, int64(len(data))-5)
+	asserts.Equal(io.EOF, err)
+	asserts.Equal(5, n)
+}
+
+func TestFileSystem_OpenArchive(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	zw.Create("docs/")
+	w, _ := zw.Create("docs/a.txt")
+	w.Write([]byte("hello"))
+	zw.Create("../evil.txt")
+	zw.Close()
+
+	fs := &FileSystem{User: &model.User{Model: gorm.Model{ID: 1}}}
+	file := &model.File{Size: uint64(buf.Len()), SourceName: "1.zip", Policy: model.Policy{Type: "mock"}}
+	file.Policy.ID = 1
+
+	// 列出条目
+	{
+		fs.Handler = &rangeHandlerMock{data: buf.Bytes()}
+		archive, err := fs.OpenArchive(ctx, file, "")
+		asserts.NoError(err)
+		asserts.Len(archive.Entries, 3)
+		asserts.Equal("docs", archive.Entries[0].Name)
+		asserts.True(archive.Entries[0].IsDir)
+
+		entry, ok := archive.Entry("/docs/a.txt")
+		asserts.True(ok)
+		asserts.EqualValues(5, entry.Size)
+		asserts.True(entry.Regular())
+		rc, err := entry.Open()
+		asserts.NoError(err)
+		content, _ := io.ReadAll(rc)
+		asserts.Equal("hello", string(content))
+		asserts.NoError(archive.Close())
+	}
+
+	// 不支持的格式
+	{
+		fs.Handler = &rangeHandlerMock{data: []byte("not an archive")}
+		file.Size = 14
+		_, err := fs.OpenArchive(ctx, file, "")
+		asserts.Equal(ErrUnsupportedArchive, err)
+	}
+}
+
+func TestArchiveSavePath(t *testing.T) {
+	asserts := assert.New(t)
+
+	savePath, ok := archiveSavePath("/dst", "a/b.txt")
+	asserts.True(ok)
+	asserts.Equal("/dst/a/b.txt", savePath)
+
+	_, ok = archiveSavePath("/dst", "../b.txt")
+	asserts.False(ok)
+
+	savePath, ok = archiveSavePath("/", "../b.txt")
+	asserts.True(ok)
+	asserts.Equal("/b.txt", savePath)
+}
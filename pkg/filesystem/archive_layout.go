@@ -0,0 +1,684 @@
+package filesystem
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"path"
+	"sort"
+	"time"
+	"unicode/utf8"
+
+	model "github.com/Jaylenwa/Vfoy/models"
+	"github.com/Jaylenwa/Vfoy/pkg/cache"
+	"github.com/Jaylenwa/Vfoy/pkg/filesystem/fsctx"
+)
+
+/* ====================================
+     预先计算布局的不压缩 zip 打包下载
+   ====================================
+*/
+
+const (
+	uint16max = 1<<16 - 1
+	uint32max = 1<<32 - 1
+
+	zipCentralHeaderLen  = 46
+	zipEndLen            = 22
+	zip64EndLen          = 56
+	zip64LocatorLen      = 20
+	zipTimestampExtraLen = 9
+	zip64ExtraLen        = 28
+	zip64LocalExtraLen   = 20
+
+	zipVersion20 = 20
+	zipVersion45 = 45
+
+	// zipFlagDataDescriptor 文件头中不记录 CRC 与大小，写在文件内容之后
+	zipFlagDataDescriptor = 0x8
+	// zipFlagUTF8 文件名使用 UTF-8 编码
+	zipFlagUTF8 = 0x800
+)
+
+var (
+	// ErrArchiveSizeMismatch 文件实际大小与数据库记录不符
+	ErrArchiveSizeMismatch = errors.New("file size does not match the record")
+	// ErrArchiveChanged 打包会话创建后有文件被修改或删除
+	ErrArchiveChanged = errors.New("archived files have been changed since the session was created")
+)
+
+func init() {
+	gob.Register(ArchiveManifest{})
+}
+
+// ArchiveManifestEntry 创建打包会话时记录的文件快照
+type ArchiveManifestEntry struct {
+	FileID    uint
+	Name      string
+	Size      uint64
+	UpdatedAt time.Time
+}
+
+// ArchiveManifest 打包会话的文件清单，同一会话的各次请求均按清单生成内容相同的 zip 文件
+type ArchiveManifest struct {
+	Entries []ArchiveManifestEntry
+}
+
+// ETag 根据文件清单生成的实体标签，清单不变时 zip 文件内容不变
+func (m *ArchiveManifest) ETag() string {
+	h := sha1.New()
+	for _, e := range m.Entries {
+		fmt.Fprintf(h, "%d\x00%s\x00%d\x00%d\n", e.FileID, e.Name, e.Size, e.UpdatedAt.UnixNano())
+	}
+
+	return "\"" + hex.EncodeToString(h.Sum(nil)) + "\""
+}
+
+type archiveSegmentKind int
+
+const (
+	segmentHeader archiveSegmentKind = iota
+	segmentData
+	segmentDescriptor
+	segmentDirectory
+)
+
+// archiveSegment zip 文件中的一段连续内容
+type archiveSegment struct {
+	kind   archiveSegmentKind
+	offset int64
+	size   int64
+	member *archiveMember
+	// 文件头的内容，与 CRC 无关，可预先生成
+	header []byte
+}
+
+// archiveMember zip 文件中的一个文件
+type archiveMember struct {
+	file   *model.File
+	name   string
+	offset uint64
+	crc    uint32
+	crcOK  bool
+}
+
+// zip64 文件大小是否需要使用 ZIP64 格式
+func (m *archiveMember) zip64() bool {
+	return m.file.Size >= uint32max
+}
+
+// ArchiveLayout 不压缩的 zip 文件布局，根据数据库中记录的文件大小预先计算，
+// 下载前即可得到完整的大小，并能按字节范围读取
+type ArchiveLayout struct {
+	Size int64
+
+	fs        *FileSystem
+	members   []*archiveMember
+	segments  []archiveSegment
+	directory []byte
+
+	// CRC 缓存键前缀与有效期，前缀为空时不缓存
+	crcPrefix string
+	crcTTL    int
+}
+
+// NewArchiveManifest 列出给定目录和文件打包时包含的文件，文件顺序与 Compress 一致
+func (fs *FileSystem) NewArchiveManifest(ctx context.Context, folderIDs, fileIDs []uint) (*ArchiveManifest, error) {
+	folders, files, _, err := fs.compressTargets(ctx, folderIDs, fileIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	layout := &ArchiveLayout{fs: fs}
+	for i := 0; i < len(folders); i++ {
+		layout.addFolder(&folders[i])
+	}
+	for i := 0; i < len(files); i++ {
+		layout.addFile(&files[i])
+	}
+
+	manifest := &ArchiveManifest{Entries: make([]ArchiveManifestEntry, 0, len(layout.members))}
+	for _, m := range layout.members {
+		manifest.Entries = append(manifest.Entries, ArchiveManifestEntry{
+			FileID:    m.file.ID,
+			Name:      m.name,
+			Size:      m.file.Size,
+			UpdatedAt: m.file.UpdatedAt,
+		})
+	}
+
+	return manifest, nil
+}
+
+// NewArchiveLayout 按打包会话的文件清单计算 zip 布局，清单中的文件被修改或删除时返回 ErrArchiveChanged
+func (fs *FileSystem) NewArchiveLayout(ctx context.Context, manifest *ArchiveManifest) (*ArchiveLayout, error) {
+	ids := make([]uint, 0, len(manifest.Entries))
+	for _, e := range manifest.Entries {
+		ids = append(ids, e.FileID)
+	}
+
+	files, err := model.GetFilesByIDs(ids, fs.User.ID)
+	if err != nil && len(ids) > 0 {
+		return nil, ErrDBListObjects.WithError(err)
+	}
+
+	current := make(map[uint]*model.File, len(files))
+	for i := range files {
+		current[files[i].ID] = &files[i]
+	}
+
+	layout := &ArchiveLayout{fs: fs}
+	for _, e := range manifest.Entries {
+		file, ok := current[e.FileID]
+		if !ok || file.Size != e.Size || !file.UpdatedAt.Equal(e.UpdatedAt) {
+			return nil, ErrArchiveChanged
+		}
+
+		layout.members = append(layout.members, &archiveMember{file: file, name: e.Name})
+	}
+
+	layout.build()
+	return layout, nil
+}
+
+// CacheCRC 将计算得到的 CRC 以 prefix 为前缀缓存 ttl 秒，供同一会话的后续请求使用，
+// 已缓存的 CRC 随之续期
+func (l *ArchiveLayout) CacheCRC(prefix string, ttl int) {
+	l.crcPrefix, l.crcTTL = prefix, ttl
+	for _, m := range l.members {
+		if crc, ok := cache.Get(l.crcKey(m)); ok {
+			m.crc, m.crcOK = crc.(uint32)
+			if m.crcOK {
+				cache.Set(l.crcKey(m), m.crc, ttl)
+			}
+		}
+	}
+}
+
+func (l *ArchiveLayout) crcKey(m *archiveMember) string {
+	return fmt.Sprintf("%s%d", l.crcPrefix, m.file.ID)
+}
+
+// setCRC 记录文件的 CRC
+func (l *ArchiveLayout) setCRC(m *archiveMember, crc uint32) {
+	m.crc, m.crcOK = crc, true
+	if l.crcPrefix != "" {
+		cache.Set(l.crcKey(m), crc, l.crcTTL)
+	}
+}
+
+func (l *ArchiveLayout) addFolder(folder *model.Folder) {
+	subFiles, err := folder.GetChildFiles()
+	if err == nil {
+		for i := 0; i < len(subFiles); i++ {
+			l.addFile(&subFiles[i])
+		}
+	}
+
+	subFolders, err := folder.GetChildFolder()
+	if err == nil {
+		for i := 0; i < len(subFolders); i++ {
+			l.addFolder(&subFolders[i])
+		}
+	}
+}
+
+func (l *ArchiveLayout) addFile(file *model.File) {
+	l.members = append(l.members, &archiveMember{
+		file: file,
+		name: path.Join(file.Position, file.Name),
+	})
+}
+
+// build 计算各段的位置与大小
+func (l *ArchiveLayout) build() {
+	var offset int64
+	add := func(seg archiveSegment) {
+		seg.offset = offset
+		if seg.header != nil {
+			seg.size = int64(len(seg.header))
+		}
+		l.segments = append(l.segments, seg)
+		offset += seg.size
+	}
+
+	for _, m := range l.members {
+		m.offset = uint64(offset)
+		add(archiveSegment{kind: segmentHeader, member: m, header: localHeader(m)})
+		add(archiveSegment{kind: segmentData, member: m, size: int64(m.file.Size)})
+		add(archiveSegment{kind: segmentDescriptor, member: m, size: descriptorLen(m)})
+	}
+
+	add(archiveSegment{kind: segmentDirectory, size: l.directoryLen(uint64(offset))})
+	l.Size = offset
+}
+
+// segment 查找 offset 所在的段
+func (l *ArchiveLayout) segment(offset int64) *archiveSegment {
+	i := sort.Search(len(l.segments), func(i int) bool {
+		return l.segments[i].offset+l.segments[i].size > offset
+	})
+	return &l.segments[i]
+}
+
+// bytes 取得文件头、数据描述符或中央目录的内容
+func (l *ArchiveLayout) bytes(ctx context.Context, seg *archiveSegment) ([]byte, error) {
+	switch seg.kind {
+	case segmentHeader:
+		return seg.header, nil
+	case segmentDescriptor:
+		if err := l.ensureCRC(ctx, seg.member); err != nil {
+			return nil, err
+		}
+		return dataDescriptor(seg.member), nil
+	}
+
+	if l.directory == nil {
+		for _, m := range l.members {
+			if err := l.ensureCRC(ctx, m); err != nil {
+				return nil, err
+			}
+		}
+		l.directory = l.centralDirectory(uint64(seg.offset))
+	}
+
+	return l.directory, nil
+}
+
+// ensureCRC 文件的 CRC 未知时读取完整的文件计算
+func (l *ArchiveLayout) ensureCRC(ctx context.Context, m *archiveMember) error {
+	if m.crcOK {
+		return nil
+	}
+
+	if m.file.Size == 0 {
+		l.setCRC(m, 0)
+		return nil
+	}
+
+	rc, err := l.open(ctx, m, 0)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	h := crc32.NewIEEE()
+	if err := copyExactly(h, rc, int64(m.file.Size)); err != nil {
+		return err
+	}
+
+	l.setCRC(m, h.Sum32())
+	return nil
+}
+
+// open 从 offset 处开始读取文件内容
+func (l *ArchiveLayout) open(ctx context.Context, m *archiveMember, offset int64) (io.ReadCloser, error) {
+	l.fs.Policy = m.file.GetPolicy()
+	if err := l.fs.DispatchHandler(); err != nil {
+		return nil, err
+	}
+
+	ctx = context.WithValue(ctx, fsctx.FileModelCtx, *m.file)
+	if offset > 0 {
+		ctx = context.WithValue(ctx, fsctx.RangeCtx, fsctx.Range{Offset: offset, Length: int64(m.file.Size) - offset})
+	}
+
+	rs, err := l.fs.Handler.Get(ctx, m.file.SourceName)
+	if err != nil {
+		return nil, err
+	}
+
+	// 本机存储策略不处理字节范围，直接随机读取
+	if ra, ok := rs.(io.ReaderAt); ok && offset > 0 {
+		return &limitedReadCloser{
+			Reader: io.NewSectionReader(ra, offset, int64(m.file.Size)-offset),
+			Closer: rs,
+		}, nil
+	}
+
+	return rs, nil
+}
+
+// copyExactly 复制 n 字节，内容长度不符时返回 ErrArchiveSizeMismatch
+func copyExactly(dst io.Writer, src io.Reader, n int64) error {
+	written, err := io.CopyN(dst, src, n)
+	if err == io.EOF || written != n {
+		return ErrArchiveSizeMismatch
+	}
+
+	return err
+}
+
+// Reader 返回按字节范围读取 zip 文件的 io.ReadSeeker，可用于 http.ServeContent
+func (l *ArchiveLayout) Reader(ctx context.Context) *ArchiveReader {
+	return &ArchiveReader{ctx: ctx, layout: l}
+}
+
+// ArchiveReader 按需读取 zip 文件内容，文件内容从存储策略读取，其余部分即时生成
+type ArchiveReader struct {
+	ctx    context.Context
+	layout *ArchiveLayout
+	offset int64
+
+	// 正在读取的文件内容
+	stream    io.ReadCloser
+	streamAt  int64
+	streamSeg *archiveSegment
+	// 从头读取文件时同时计算 CRC
+	hash hash.Hash32
+}
+
+// Seek 实现 io.Seeker
+func (r *ArchiveReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.layout.Size
+	}
+
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+
+	r.offset = offset
+	return offset, nil
+}
+
+// Read 实现 io.Reader
+func (r *ArchiveReader) Read(p []byte) (int, error) {
+	if r.offset >= r.layout.Size {
+		return 0, io.EOF
+	}
+
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	seg := r.layout.segment(r.offset)
+	remain := seg.offset + seg.size - r.offset
+	if int64(len(p)) > remain {
+		p = p[:remain]
+	}
+
+	if seg.kind == segmentData {
+		return r.readData(seg, p)
+	}
+
+	content, err := r.layout.bytes(r.ctx, seg)
+	if err != nil {
+		return 0, err
+	}
+
+	n := copy(p, content[r.offset-seg.offset:])
+	r.offset += int64(n)
+	return n, nil
+}
+
+// readData 读取文件内容
+func (r *ArchiveReader) readData(seg *archiveSegment, p []byte) (int, error) {
+	if r.stream == nil || r.streamSeg != seg || r.streamAt != r.offset {
+		r.closeStream()
+		stream, err := r.layout.open(r.ctx, seg.member, r.offset-seg.offset)
+		if err != nil {
+			return 0, err
+		}
+
+		r.stream, r.streamSeg, r.streamAt = stream, seg, r.offset
+		r.hash = nil
+		if r.offset == seg.offset && !seg.member.crcOK {
+			r.hash = crc32.NewIEEE()
+		}
+	}
+
+	n, err := r.stream.Read(p)
+	if r.hash != nil {
+		r.hash.Write(p[:n])
+	}
+	r.offset += int64(n)
+	r.streamAt = r.offset
+
+	// 文件读取完毕
+	if r.offset == seg.offset+seg.size {
+		if r.hash != nil {
+			r.layout.setCRC(seg.member, r.hash.Sum32())
+		}
+		r.closeStream()
+		return n, nil
+	}
+
+	if err == io.EOF {
+		return n, ErrArchiveSizeMismatch
+	}
+
+	return n, err
+}
+
+func (r *ArchiveReader) closeStream() {
+	if r.stream != nil {
+		r.stream.Close()
+		r.stream, r.streamSeg, r.hash = nil, nil, nil
+	}
+}
+
+// Close 关闭正在读取的文件
+func (r *ArchiveReader) Close() error {
+	r.closeStream()
+	return nil
+}
+
+/* zip 文件结构，参见 APPNOTE.TXT */
+
+// zipWriteBuf 小端序写入
+type zipWriteBuf struct {
+	bytes.Buffer
+}
+
+func (b *zipWriteBuf) uint16(v uint16) {
+	binary.Write(&b.Buffer, binary.LittleEndian, v)
+}
+
+func (b *zipWriteBuf) uint32(v uint32) {
+	binary.Write(&b.Buffer, binary.LittleEndian, v)
+}
+
+func (b *zipWriteBuf) uint64(v uint64) {
+	binary.Write(&b.Buffer, binary.LittleEndian, v)
+}
+
+// zipFlags 通用标记
+func zipFlags(name string) uint16 {
+	flags := uint16(zipFlagDataDescriptor)
+	for i := 0; i < len(name); i++ {
+		if name[i] >= utf8.RuneSelf {
+			return flags | zipFlagUTF8
+		}
+	}
+
+	return flags
+}
+
+// msDosTime 转换为 MS-DOS 格式的日期与时间
+func msDosTime(t time.Time) (uint16, uint16) {
+	if t.Year() < 1980 {
+		return 1<<5 | 1, 0
+	}
+
+	date := uint16(t.Day() + int(t.Month())<<5 + (t.Year()-1980)<<9)
+	clock := uint16(t.Second()/2 + t.Minute()<<5 + t.Hour()<<11)
+	return date, clock
+}
+
+// writeTimestamp 写入扩展时间戳，记录精确的修改时间
+func writeTimestamp(b *zipWriteBuf, t time.Time) {
+	b.uint16(0x5455)
+	b.uint16(5)
+	b.WriteByte(1)
+	b.uint32(uint32(t.Unix()))
+}
+
+// localHeader 生成文件头，CRC 与大小记录在数据描述符中；
+// 大文件额外写入 ZIP64 扩展字段，以便解压程序按 ZIP64 格式解析数据描述符
+func localHeader(m *archiveMember) []byte {
+	b := &zipWriteBuf{}
+	date, clock := msDosTime(m.file.UpdatedAt)
+	version, size, extraLen := uint16(zipVersion20), uint32(0), uint16(zipTimestampExtraLen)
+	if m.zip64() {
+		version, size, extraLen = zipVersion45, uint32max, extraLen+zip64LocalExtraLen
+	}
+
+	b.uint32(0x04034b50)
+	b.uint16(version)
+	b.uint16(zipFlags(m.name))
+	b.uint16(0) // 不压缩
+	b.uint16(clock)
+	b.uint16(date)
+	b.uint32(0)
+	b.uint32(size)
+	b.uint32(size)
+	b.uint16(uint16(len(m.name)))
+	b.uint16(extraLen)
+	b.WriteString(m.name)
+	writeTimestamp(b, m.file.UpdatedAt)
+	if m.zip64() {
+		b.uint16(0x0001)
+		b.uint16(16)
+		b.uint64(m.file.Size)
+		b.uint64(m.file.Size)
+	}
+	return b.Bytes()
+}
+
+// descriptorLen 数据描述符长度
+func descriptorLen(m *archiveMember) int64 {
+	if m.zip64() {
+		return 24
+	}
+
+	return 16
+}
+
+// dataDescriptor 生成数据描述符
+func dataDescriptor(m *archiveMember) []byte {
+	b := &zipWriteBuf{}
+	b.uint32(0x08074b50)
+	b.uint32(m.crc)
+	if m.zip64() {
+		b.uint64(m.file.Size)
+		b.uint64(m.file.Size)
+	} else {
+		b.uint32(uint32(m.file.Size))
+		b.uint32(uint32(m.file.Size))
+	}
+
+	return b.Bytes()
+}
+
+// centralZip64 中央目录中的文件记录是否需要 ZIP64 扩展字段
+func centralZip64(m *archiveMember) bool {
+	return m.zip64() || m.offset >= uint32max
+}
+
+// directoryLen 中央目录及结尾记录的长度
+func (l *ArchiveLayout) directoryLen(offset uint64) int64 {
+	var size int64
+	for _, m := range l.members {
+		size += int64(zipCentralHeaderLen + len(m.name) + zipTimestampExtraLen)
+		if centralZip64(m) {
+			size += zip64ExtraLen
+		}
+	}
+
+	if needZip64End(len(l.members), uint64(size), offset) {
+		size += zip64EndLen + zip64LocatorLen
+	}
+
+	return size + zipEndLen
+}
+
+// needZip64End 是否需要 ZIP64 结尾记录
+func needZip64End(records int, size, offset uint64) bool {
+	return records >= uint16max || size >= uint32max || offset >= uint32max
+}
+
+// centralDirectory 生成中央目录及结尾记录，offset 为中央目录的起始位置
+func (l *ArchiveLayout) centralDirectory(offset uint64) []byte {
+	b := &zipWriteBuf{}
+	for _, m := range l.members {
+		zip64 := centralZip64(m)
+		version, extraLen := uint16(zipVersion20), uint16(zipTimestampExtraLen)
+		size, headerOffset := uint32(m.file.Size), uint32(m.offset)
+		if zip64 {
+			version, extraLen = zipVersion45, extraLen+zip64ExtraLen
+			size, headerOffset = uint32max, uint32max
+		}
+
+		date, clock := msDosTime(m.file.UpdatedAt)
+		b.uint32(0x02014b50)
+		b.uint16(zipVersion45)
+		b.uint16(version)
+		b.uint16(zipFlags(m.name))
+		b.uint16(0)
+		b.uint16(clock)
+		b.uint16(date)
+		b.uint32(m.crc)
+		b.uint32(size)
+		b.uint32(size)
+		b.uint16(uint16(len(m.name)))
+		b.uint16(extraLen)
+		b.uint16(0) // 注释长度
+		b.uint16(0) // 起始磁盘
+		b.uint16(0) // 内部属性
+		b.uint32(0) // 外部属性
+		b.uint32(headerOffset)
+		b.WriteString(m.name)
+		writeTimestamp(b, m.file.UpdatedAt)
+		if zip64 {
+			b.uint16(0x0001)
+			b.uint16(24)
+			b.uint64(m.file.Size)
+			b.uint64(m.file.Size)
+			b.uint64(m.offset)
+		}
+	}
+
+	records, size := uint64(len(l.members)), uint64(b.Len())
+	if needZip64End(len(l.members), size, offset) {
+		end := offset + size
+		b.uint32(0x06064b50)
+		b.uint64(zip64EndLen - 12)
+		b.uint16(zipVersion45)
+		b.uint16(zipVersion45)
+		b.uint32(0)
+		b.uint32(0)
+		b.uint64(records)
+		b.uint64(records)
+		b.uint64(size)
+		b.uint64(offset)
+
+		b.uint32(0x07064b50)
+		b.uint32(0)
+		b.uint64(end)
+		b.uint32(1)
+
+		records, size, offset = uint16max, uint32max, uint32max
+	}
+
+	b.uint32(0x06054b50)
+	b.uint16(0)
+	b.uint16(0)
+	b.uint16(uint16(records))
+	b.uint16(uint16(records))
+	b.uint32(uint32(size))
+	b.uint32(uint32(offset))
+	b.uint16(0)
+	return b.Bytes()
+}
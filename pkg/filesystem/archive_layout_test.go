@@ -0,0 +1,245 @@
+package filesystem
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/Jaylenwa/Vfoy/models"
+	"github.com/Jaylenwa/Vfoy/pkg/cache"
+	"github.com/Jaylenwa/Vfoy/pkg/filesystem/fsctx"
+	"github.com/Jaylenwa/Vfoy/pkg/filesystem/response"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+// layoutHandlerMock 按文件路径返回内容，上下文中指定字节范围时仅返回该范围
+type layoutHandlerMock struct {
+	FileHeaderMock
+	files  map[string][]byte
+	ranges []fsctx.Range
+}
+
+func (m *layoutHandlerMock) Get(ctx context.Context, path string) (response.RSCloser, error) {
+	data, ok := m.files[path]
+	r, ranged := ctx.Value(fsctx.RangeCtx).(fsctx.Range)
+	if ranged {
+		m.ranges = append(m.ranges, r)
+	}
+
+	// 未指定内容的文件全部为 0
+	if !ok {
+		return MockRSC{rs: io.NewSectionReader(zeroReaderAt{}, 0, r.Length)}, nil
+	}
+
+	if ranged {
+		data = data[r.Offset : r.Offset+r.Length]
+	}
+
+	return MockRSC{rs: bytes.NewReader(data)}, nil
+}
+
+type zeroReaderAt struct{}
+
+func (zeroReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	return zeroReader{}.Read(p)
+}
+
+// readerAt 通过 Seek 与 Read 实现 io.ReaderAt
+type readerAt struct {
+	r io.ReadSeeker
+}
+
+func (r readerAt) ReadAt(p []byte, off int64) (int, error) {
+	if _, err := r.r.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return io.ReadFull(r.r, p)
+}
+
+func newTestLayout(handler *layoutHandlerMock, files ...model.File) *ArchiveLayout {
+	fs := &FileSystem{User: &model.User{Model: gorm.Model{ID: 1}}, Handler: handler}
+	layout := &ArchiveLayout{fs: fs}
+	for i := range files {
+		files[i].Policy = model.Policy{Type: "mock"}
+		files[i].Policy.ID = 1
+		layout.addFile(&files[i])
+	}
+	layout.build()
+	return layout
+}
+
+func TestArchiveLayout_Reader(t *testing.T) {
+	asserts := assert.New(t)
+	modified := time.Date(2022, 1, 2, 3, 4, 6, 0, time.UTC)
+	handler := &layoutHandlerMock{files: map[string][]byte{
+		"1": []byte("hello"),
+		"2": bytes.Repeat([]byte("world"), 100),
+		"3": {},
+	}}
+	files := func() []model.File {
+		return []model.File{
+			{Model: gorm.Model{ID: 1, UpdatedAt: modified}, Name: "1.txt", SourceName: "1", Size: 5},
+			{Model: gorm.Model{ID: 2, UpdatedAt: modified}, Name: "测试.txt", Position: "docs", SourceName: "2", Size: 500},
+			{Model: gorm.Model{ID: 3, UpdatedAt: modified}, Name: "empty", SourceName: "3"},
+		}
+	}
+
+	// 完整读取
+	layout := newTestLayout(handler, files()...)
+	layout.CacheCRC("archive_crc_test_", 0)
+	full, err := io.ReadAll(layout.Reader(context.Background()))
+	asserts.NoError(err)
+	asserts.EqualValues(layout.Size, len(full))
+	asserts.Empty(handler.ranges)
+
+	zr, err := zip.NewReader(bytes.NewReader(full), int64(len(full)))
+	asserts.NoError(err)
+	asserts.Len(zr.File, 3)
+	asserts.Equal("docs/测试.txt", zr.File[1].Name)
+	asserts.True(modified.Equal(zr.File[1].Modified))
+	for i, f := range zr.File {
+		rc, err := f.Open()
+		asserts.NoError(err)
+		content, err := io.ReadAll(rc)
+		asserts.NoError(err, "CRC of file %d", i)
+		asserts.Equal(handler.files[files()[i].SourceName], content)
+	}
+
+	// 读取时计算的 CRC 已缓存
+	cached := newTestLayout(handler, files()...)
+	cached.CacheCRC("archive_crc_test_", 0)
+	for _, m := range cached.members {
+		asserts.True(m.crcOK)
+	}
+	_, ok := cache.Get("archive_crc_test_2")
+	asserts.True(ok)
+
+	// 从第二个文件中间开始读取，CRC 未知时需完整读取一次文件
+	{
+		handler.ranges = nil
+		layout := newTestLayout(handler, files()...)
+		start := layout.segment(int64(layout.members[1].offset)).size + int64(layout.members[1].offset) + 100
+		reader := layout.Reader(context.Background())
+		_, err := reader.Seek(start, io.SeekStart)
+		asserts.NoError(err)
+		partial, err := io.ReadAll(reader)
+		asserts.NoError(err)
+		asserts.Equal(full[start:], partial)
+		asserts.Equal([]fsctx.Range{{Offset: 100, Length: 400}}, handler.ranges)
+	}
+
+	// 文件大小与记录不符
+	{
+		broken := files()
+		broken[0].Size = 6
+		layout := newTestLayout(handler, broken...)
+		_, err := io.ReadAll(layout.Reader(context.Background()))
+		asserts.Equal(ErrArchiveSizeMismatch, err)
+	}
+}
+
+func TestArchiveLayout_ServeContent(t *testing.T) {
+	asserts := assert.New(t)
+	handler := &layoutHandlerMock{files: map[string][]byte{"1": []byte("hello")}}
+	layout := newTestLayout(handler, model.File{Model: gorm.Model{ID: 1}, Name: "1.txt", SourceName: "1", Size: 5})
+
+	full, err := io.ReadAll(layout.Reader(context.Background()))
+	asserts.NoError(err)
+
+	req := httptest.NewRequest("GET", "/archive.zip", nil)
+	req.Header.Set("Range", "bytes=10-")
+	rec := httptest.NewRecorder()
+	rec.Header().Set("Content-Type", "application/zip")
+	http.ServeContent(rec, req, "archive.zip", time.Time{}, layout.Reader(context.Background()))
+	asserts.Equal(http.StatusPartialContent, rec.Code)
+	asserts.Equal(full[10:], rec.Body.Bytes())
+}
+
+func TestArchiveLayout_Zip64(t *testing.T) {
+	asserts := assert.New(t)
+	handler := &layoutHandlerMock{files: map[string][]byte{"2": []byte("hello")}}
+	layout := newTestLayout(handler,
+		model.File{Model: gorm.Model{ID: 1}, Name: "large.bin", SourceName: "1", Size: 5 << 30},
+		model.File{Model: gorm.Model{ID: 2}, Name: "small.txt", SourceName: "2", Size: 5},
+	)
+	// 跳过大文件的 CRC 计算
+	layout.members[0].crcOK = true
+	asserts.Greater(layout.members[1].offset, uint64(uint32max))
+
+	// 大文件的文件头声明 ZIP64 版本并携带扩展字段
+	header := layout.segments[0].header
+	asserts.EqualValues(zipVersion45, header[4])
+	asserts.Len(header, 30+len("large.bin")+zipTimestampExtraLen+zip64LocalExtraLen)
+	asserts.EqualValues(zipVersion20, layout.segments[3].header[4])
+
+	zr, err := zip.NewReader(readerAt{layout.Reader(context.Background())}, layout.Size)
+	asserts.NoError(err)
+	asserts.Len(zr.File, 2)
+	asserts.EqualValues(5<<30, zr.File[0].UncompressedSize64)
+
+	rc, err := zr.File[1].Open()
+	asserts.NoError(err)
+	content, err := io.ReadAll(rc)
+	asserts.NoError(err)
+	asserts.Equal("hello", string(content))
+}
+
+func TestFileSystem_NewArchiveLayout(t *testing.T) {
+	asserts := assert.New(t)
+	fs := &FileSystem{User: &model.User{Model: gorm.Model{ID: 1}}}
+	modified := time.Date(2022, 1, 2, 3, 4, 6, 0, time.UTC)
+	manifest := &ArchiveManifest{Entries: []ArchiveManifestEntry{
+		{FileID: 1, Name: "docs/1.txt", Size: 5, UpdatedAt: modified},
+		{FileID: 2, Name: "2.txt", Size: 6, UpdatedAt: modified},
+	}}
+	etag := manifest.ETag()
+
+	// 文件未变化，按清单中的路径生成布局
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnRows(
+			sqlmock.NewRows([]string{"id", "name", "size", "updated_at"}).
+				AddRow(2, "renamed.txt", 6, modified).
+				AddRow(1, "1.txt", 5, modified),
+		)
+		layout, err := fs.NewArchiveLayout(context.Background(), manifest)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Len(layout.members, 2)
+		asserts.Equal("docs/1.txt", layout.members[0].name)
+		asserts.Equal("2.txt", layout.members[1].name)
+	}
+
+	// 文件被覆盖
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnRows(
+			sqlmock.NewRows([]string{"id", "name", "size", "updated_at"}).
+				AddRow(1, "1.txt", 5, modified).
+				AddRow(2, "2.txt", 6, modified.Add(time.Second)),
+		)
+		_, err := fs.NewArchiveLayout(context.Background(), manifest)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal(ErrArchiveChanged, err)
+	}
+
+	// 文件被删除
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnRows(
+			sqlmock.NewRows([]string{"id", "name", "size", "updated_at"}).
+				AddRow(1, "1.txt", 5, modified),
+		)
+		_, err := fs.NewArchiveLayout(context.Background(), manifest)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal(ErrArchiveChanged, err)
+	}
+
+	// 清单变化时 ETag 随之变化
+	manifest.Entries[1].Size = 7
+	asserts.NotEqual(etag, manifest.ETag())
+}
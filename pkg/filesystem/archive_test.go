@@ -1,7 +1,9 @@
 package filesystem
 
 import (
+	"archive/tar"
 	"bytes"
+	"compress/gzip"
 	"context"
 	"errors"
 	"io"
@@ -255,3 +257,74 @@ func TestFileSystem_Decompress(t *testing.T) {
 		testHandler.AssertExpectations(t)
 	}
 }
+
+func TestFileSystem_DecompressTarGz(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+
+	archive := func(entries ...*tar.Header) []byte {
+		buf := &bytes.Buffer{}
+		gw := gzip.NewWriter(buf)
+		tw := tar.NewWriter(gw)
+		for _, hdr := range entries {
+			tw.WriteHeader(hdr)
+			if hdr.Typeflag == tar.TypeReg {
+				tw.Write(bytes.Repeat([]byte("1"), int(hdr.Size)))
+			}
+		}
+		tw.Close()
+		gw.Close()
+		return buf.Bytes()
+	}
+
+	newFs := func(content []byte, limit uint64) *FileSystem {
+		fs := &FileSystem{
+			User: &model.User{Model: gorm.Model{ID: 1}},
+		}
+		fs.User.Policy.Type = "mock"
+		fs.User.Group.OptionsSerialized.DecompressSize = limit
+		fs.FileTarget = []model.File{{SourceName: "1.tar.gz", Policy: model.Policy{Type: "mock"}}}
+		fs.FileTarget[0].Policy.ID = 1
+		testHandler := new(FileHeaderMock)
+		testHandler.On("Get", testMock.Anything, "1.tar.gz").
+			Return(MockRSC{rs: bytes.NewReader(content)}, nil)
+		fs.Handler = testHandler
+		return fs
+	}
+
+	// 跳过非法路径与符号链接，不计入大小限制
+	{
+		content := archive(
+			&tar.Header{Name: "../evil.txt", Typeflag: tar.TypeReg, Size: 100, Mode: 0644},
+			&tar.Header{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd", Mode: 0777},
+			&tar.Header{Name: "a.txt", Typeflag: tar.TypeReg, Size: 4, Mode: 0644},
+		)
+		err := newFs(content, 6).Decompress(ctx, "/1.tar.gz", "/dst", "")
+		asserts.NoError(err)
+	}
+
+	// 超出解压大小限制
+	{
+		content := archive(
+			&tar.Header{Name: "a.txt", Typeflag: tar.TypeReg, Size: 4, Mode: 0644},
+			&tar.Header{Name: "b.txt", Typeflag: tar.TypeReg, Size: 4, Mode: 0644},
+		)
+		err := newFs(content, 6).Decompress(ctx, "/1.tar.gz", "/dst", "")
+		asserts.ErrorIs(err, ErrDecompressSizeExceeded)
+	}
+
+	// 不支持的格式
+	{
+		err := newFs([]byte("not an archive"), 0).Decompress(ctx, "/1.tar.gz", "/dst", "")
+		asserts.Equal(ErrUnsupportedArchive, err)
+	}
+}
+
+func TestDecodeArchiveText(t *testing.T) {
+	asserts := assert.New(t)
+
+	asserts.Equal("测试.txt", decodeArchiveText("测试.txt", "gbk"))
+	asserts.Equal("测试.txt", decodeArchiveText("\xb2\xe2\xca\xd4.txt", "gbk"))
+	asserts.Equal("\xb2\xe2\xca\xd4.txt", decodeArchiveText("\xb2\xe2\xca\xd4.txt", ""))
+	asserts.Equal("\xb2\xe2\xca\xd4.txt", decodeArchiveText("\xb2\xe2\xca\xd4.txt", "unknown"))
+}
@@ -0,0 +1,248 @@
+package filesystem
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"time"
+
+	"github.com/Jaylenwa/Vfoy/pkg/util"
+	aeszip "github.com/alexmullins/zip"
+	"github.com/klauspost/compress/zstd"
+)
+
+// 压缩文件格式
+const (
+	CompressFormatZip    = "zip"
+	CompressFormatTarGz  = "tar.gz"
+	CompressFormatTarZst = "tar.zst"
+	CompressFormat7z     = "7z"
+)
+
+// compressMaxLevels 各格式支持的最大压缩级别，最小为 1
+var compressMaxLevels = map[string]int{
+	CompressFormatZip:    flate.BestCompression,
+	CompressFormatTarGz:  gzip.BestCompression,
+	CompressFormatTarZst: 22,
+	CompressFormat7z:     9,
+}
+
+// CompressOption 压缩选项
+type CompressOption struct {
+	// 压缩文件格式，默认为 zip
+	Format string
+	// 压缩级别，0 为各格式的默认级别，zip、tar.gz 与 7z 为 1-9，tar.zst 为 1-22
+	Level int
+	// 密码，不为空时创建 AES-256 加密的 zip 文件，仅支持 zip 格式，且只能使用默认压缩级别
+	Password string
+}
+
+// Validate 检查压缩格式是否支持，以及压缩级别、密码是否适用于该格式
+func (option *CompressOption) Validate() error {
+	format := option.Format
+	if format == "" {
+		format = CompressFormatZip
+	}
+
+	maxLevel, ok := compressMaxLevels[format]
+	if !ok {
+		return ErrUnsupportedArchive
+	}
+
+	if option.Password != "" {
+		if format != CompressFormatZip {
+			return ErrUnsupportedArchive
+		}
+
+		if option.Level != 0 {
+			return ErrInvalidCompressOption
+		}
+	}
+
+	if option.Level < 0 || option.Level > maxLevel {
+		return ErrInvalidCompressOption
+	}
+
+	return nil
+}
+
+// archiveWriter 压缩文件写入器
+type archiveWriter interface {
+	// Create 写入文件头，返回写入文件内容的 Writer
+	Create(name string, size uint64, modified time.Time) (io.Writer, error)
+	// Close 完成写入
+	Close() error
+}
+
+// newArchiveWriter 根据压缩选项创建压缩文件写入器
+func newArchiveWriter(writer io.Writer, option CompressOption) (archiveWriter, error) {
+	if err := option.Validate(); err != nil {
+		return nil, err
+	}
+
+	switch option.Format {
+	case "", CompressFormatZip:
+		if option.Password != "" {
+			return &encryptedZipArchiveWriter{zw: aeszip.NewWriter(writer), password: option.Password}, nil
+		}
+
+		return newZipArchiveWriter(writer, zip.Deflate, option.Level), nil
+	case CompressFormatTarGz:
+		level := gzip.DefaultCompression
+		if option.Level > 0 {
+			level = option.Level
+		}
+
+		gw, err := gzip.NewWriterLevel(writer, level)
+		if err != nil {
+			return nil, err
+		}
+
+		return &tarArchiveWriter{tw: tar.NewWriter(gw), compressor: gw}, nil
+	case CompressFormatTarZst:
+		level := zstd.SpeedDefault
+		if option.Level > 0 {
+			level = zstd.EncoderLevelFromZstd(option.Level)
+		}
+
+		zw, err := zstd.NewWriter(writer, zstd.WithEncoderLevel(level))
+		if err != nil {
+			return nil, err
+		}
+
+		return &tarArchiveWriter{tw: tar.NewWriter(zw), compressor: zw}, nil
+	case CompressFormat7z:
+		return newSevenZipArchiveWriter(writer, option.Level)
+	}
+
+	return nil, ErrUnsupportedArchive
+}
+
+// zipArchiveWriter zip 格式写入器
+type zipArchiveWriter struct {
+	zw     *zip.Writer
+	method uint16
+}
+
+func newZipArchiveWriter(writer io.Writer, method uint16, level int) *zipArchiveWriter {
+	zw := zip.NewWriter(writer)
+	if method == zip.Deflate && level > 0 {
+		zw.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+			return flate.NewWriter(out, level)
+		})
+	}
+
+	return &zipArchiveWriter{zw: zw, method: method}
+}
+
+func (w *zipArchiveWriter) Create(name string, size uint64, modified time.Time) (io.Writer, error) {
+	return w.zw.CreateHeader(&zip.FileHeader{
+		Name:               name,
+		Modified:           modified,
+		UncompressedSize64: size,
+		Method:             w.method,
+	})
+}
+
+func (w *zipArchiveWriter) Close() error {
+	return w.zw.Close()
+}
+
+// encryptedZipArchiveWriter AES-256 加密的 zip 格式写入器，使用默认压缩级别
+type encryptedZipArchiveWriter struct {
+	zw       *aeszip.Writer
+	password string
+}
+
+func (w *encryptedZipArchiveWriter) Create(name string, size uint64, modified time.Time) (io.Writer, error) {
+	header := &aeszip.FileHeader{
+		Name:               name,
+		UncompressedSize64: size,
+		Method:             aeszip.Deflate,
+	}
+	header.SetModTime(modified)
+	header.SetPassword(w.password)
+
+	return w.zw.CreateHeader(header)
+}
+
+func (w *encryptedZipArchiveWriter) Close() error {
+	return w.zw.Close()
+}
+
+// tarArchiveWriter tar 格式写入器，文件头中只包含普通文件
+type tarArchiveWriter struct {
+	tw         *tar.Writer
+	compressor io.WriteCloser
+	current    *tarEntryWriter
+}
+
+// tarEntryWriter 记录当前文件剩余未写入的长度
+type tarEntryWriter struct {
+	tw     *tar.Writer
+	remain int64
+}
+
+func (w *tarEntryWriter) Write(p []byte) (int, error) {
+	n, err := w.tw.Write(p)
+	w.remain -= int64(n)
+	return n, err
+}
+
+// finish 文件内容不足文件头中记录的大小时以 0 补齐，避免后续文件错位
+func (w *tarArchiveWriter) finish() error {
+	if w.current == nil || w.current.remain <= 0 {
+		return nil
+	}
+
+	util.Log().Warning("File content is shorter than expected, padding %d bytes.", w.current.remain)
+	_, err := io.CopyN(w.tw, zeroReader{}, w.current.remain)
+	w.current = nil
+	return err
+}
+
+func (w *tarArchiveWriter) Create(name string, size uint64, modified time.Time) (io.Writer, error) {
+	if err := w.finish(); err != nil {
+		return nil, err
+	}
+
+	err := w.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     util.FormSlash(name),
+		Size:     int64(size),
+		Mode:     0644,
+		ModTime:  modified,
+		Format:   tar.FormatPAX,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	w.current = &tarEntryWriter{tw: w.tw, remain: int64(size)}
+	return w.current, nil
+}
+
+func (w *tarArchiveWriter) Close() error {
+	if err := w.finish(); err != nil {
+		return err
+	}
+
+	if err := w.tw.Close(); err != nil {
+		return err
+	}
+
+	return w.compressor.Close()
+}
+
+// zeroReader 读取到的内容全部为 0
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+
+	return len(p), nil
+}
@@ -0,0 +1,145 @@
+package filesystem
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bodgit/sevenzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewArchiveWriter_Tar(t *testing.T) {
+	asserts := assert.New(t)
+	modified := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	for format, level := range map[string]int{CompressFormatTarGz: 9, CompressFormatTarZst: 22} {
+		buf := &bytes.Buffer{}
+		w, err := newArchiveWriter(buf, CompressOption{Format: format, Level: level})
+		asserts.NoError(err)
+
+		// 内容与文件头大小一致
+		fw, err := w.Create("/docs/a.txt", 5, modified)
+		asserts.NoError(err)
+		fw.Write([]byte("hello"))
+
+		// 内容不足时以 0 补齐
+		fw, err = w.Create("b.txt", 4, modified)
+		asserts.NoError(err)
+		fw.Write([]byte("hi"))
+		asserts.NoError(w.Close())
+
+		var reader io.Reader
+		if format == CompressFormatTarGz {
+			reader, err = gzip.NewReader(buf)
+		} else {
+			reader, err = zstd.NewReader(buf)
+		}
+		asserts.NoError(err)
+
+		tr := tar.NewReader(reader)
+		header, err := tr.Next()
+		asserts.NoError(err)
+		asserts.EqualValues(tar.TypeReg, header.Typeflag)
+		asserts.Equal("/docs/a.txt", header.Name)
+		asserts.True(modified.Equal(header.ModTime))
+		content, _ := io.ReadAll(tr)
+		asserts.Equal("hello", string(content))
+
+		header, err = tr.Next()
+		asserts.NoError(err)
+		asserts.Equal("b.txt", header.Name)
+		content, _ = io.ReadAll(tr)
+		asserts.Equal([]byte("hi\x00\x00"), content)
+
+		_, err = tr.Next()
+		asserts.Equal(io.EOF, err)
+	}
+}
+
+func TestNewArchiveWriter_7z(t *testing.T) {
+	asserts := assert.New(t)
+	modified := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+	content := bytes.Repeat([]byte("hello world "), 10000)
+
+	// 输出不支持 Seek
+	_, err := newArchiveWriter(&bytes.Buffer{}, CompressOption{Format: CompressFormat7z})
+	asserts.ErrorIs(err, ErrUnsupportedArchive)
+
+	for _, level := range []int{0, 1, 9} {
+		path := filepath.Join(t.TempDir(), "test.7z")
+		f, err := os.Create(path)
+		asserts.NoError(err)
+
+		w, err := newArchiveWriter(f, CompressOption{Format: CompressFormat7z, Level: level})
+		asserts.NoError(err)
+
+		fw, err := w.Create("/docs/测试.txt", uint64(len(content)), modified)
+		asserts.NoError(err)
+		fw.Write(content)
+		_, err = w.Create("empty", 0, modified)
+		asserts.NoError(err)
+		fw, err = w.Create("b.txt", 2, modified)
+		asserts.NoError(err)
+		fw.Write([]byte("hi"))
+		asserts.NoError(w.Close())
+		asserts.NoError(f.Close())
+
+		r, err := sevenzip.OpenReader(path)
+		asserts.NoError(err, "level %d", level)
+		asserts.Len(r.File, 3)
+		expected := map[string][]byte{"docs/测试.txt": content, "empty": {}, "b.txt": []byte("hi")}
+		for _, file := range r.File {
+			asserts.True(modified.Equal(file.Modified), file.Name)
+			rc, err := file.Open()
+			asserts.NoError(err)
+			data, err := io.ReadAll(rc)
+			rc.Close()
+			asserts.NoError(err, file.Name)
+			asserts.Equal(expected[file.Name], data, file.Name)
+		}
+		r.Close()
+	}
+
+	// 空压缩文件
+	{
+		path := filepath.Join(t.TempDir(), "empty.7z")
+		f, err := os.Create(path)
+		asserts.NoError(err)
+		w, err := newArchiveWriter(f, CompressOption{Format: CompressFormat7z})
+		asserts.NoError(err)
+		asserts.NoError(w.Close())
+		stat, _ := f.Stat()
+		asserts.EqualValues(sevenZipSignatureHeaderLen, stat.Size())
+		asserts.NoError(f.Close())
+	}
+}
+
+func TestNewArchiveWriter_Unsupported(t *testing.T) {
+	asserts := assert.New(t)
+	_, err := newArchiveWriter(&bytes.Buffer{}, CompressOption{Format: "rar"})
+	asserts.Equal(ErrUnsupportedArchive, err)
+}
+
+func TestCompressOption_Validate(t *testing.T) {
+	asserts := assert.New(t)
+	asserts.NoError((&CompressOption{}).Validate())
+	asserts.NoError((&CompressOption{Format: CompressFormatZip, Level: 9}).Validate())
+	asserts.NoError((&CompressOption{Format: CompressFormatTarZst, Level: 22}).Validate())
+	asserts.NoError((&CompressOption{Format: CompressFormat7z, Level: 9}).Validate())
+	asserts.NoError((&CompressOption{Password: "123"}).Validate())
+
+	asserts.Equal(ErrUnsupportedArchive, (&CompressOption{Format: "rar"}).Validate())
+	asserts.Equal(ErrInvalidCompressOption, (&CompressOption{Level: 10}).Validate())
+	asserts.Equal(ErrInvalidCompressOption, (&CompressOption{Format: CompressFormatTarGz, Level: 22}).Validate())
+	asserts.Equal(ErrInvalidCompressOption, (&CompressOption{Format: CompressFormat7z, Level: 10}).Validate())
+	asserts.Equal(ErrInvalidCompressOption, (&CompressOption{Format: CompressFormatTarZst, Level: -1}).Validate())
+	asserts.Equal(ErrUnsupportedArchive, (&CompressOption{Format: CompressFormatTarGz, Password: "123"}).Validate())
+	asserts.Equal(ErrInvalidCompressOption, (&CompressOption{Password: "123", Level: 5}).Validate())
+}
@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"net/url"
 	"path"
 	"path/filepath"
@@ -200,18 +201,22 @@ func (handler *Driver) Get(ctx context.Context, path string) (response.RSCloser,
 	}
 
 	// 获取文件数据流
+	rangeHeader, status := fsctx.RangeHeader(ctx)
 	resp, err := handler.HTTPClient.Request(
 		"GET",
 		downloadURL,
 		nil,
 		request.WithContext(ctx),
+		request.WithHeader(rangeHeader),
 		request.WithTimeout(time.Duration(0)),
-	).CheckHTTPResponse(200).GetRSCloser()
+	).CheckHTTPResponse(status).GetRSCloser()
 	if err != nil {
 		return nil, err
 	}
 
-	resp.SetFirstFakeChunk()
+	if status == http.StatusOK {
+		resp.SetFirstFakeChunk()
+	}
 
 	// 尝试自主获取文件大小
 	if file, ok := ctx.Value(fsctx.FileModelCtx).(model.File); ok {
@@ -126,21 +126,25 @@ func (handler *Driver) Get(ctx context.Context, path string) (response.RSCloser,
 
 	// 获取文件数据流
 	client := request.NewClient()
+	rangeHeader, status := fsctx.RangeHeader(ctx)
 	resp, err := client.Request(
 		"GET",
 		downloadURL,
 		nil,
 		request.WithContext(ctx),
+		request.WithHeader(rangeHeader),
 		request.WithHeader(
 			http.Header{"Cache-Control": {"no-cache", "no-store", "must-revalidate"}},
 		),
 		request.WithTimeout(time.Duration(0)),
-	).CheckHTTPResponse(200).GetRSCloser()
+	).CheckHTTPResponse(status).GetRSCloser()
 	if err != nil {
 		return nil, err
 	}
 
-	resp.SetFirstFakeChunk()
+	if status == http.StatusOK {
+		resp.SetFirstFakeChunk()
+	}
 
 	// 尝试自主获取文件大小
 	if file, ok := ctx.Value(fsctx.FileModelCtx).(model.File); ok {
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
 	"path"
 	"path/filepath"
@@ -130,19 +131,23 @@ func (handler *Driver) Get(ctx context.Context, path string) (response.RSCloser,
 	}
 
 	// 获取文件数据流
+	rangeHeader, status := fsctx.RangeHeader(ctx)
 	resp, err := handler.Client.Request(
 		"GET",
 		downloadURL,
 		nil,
 		request.WithContext(ctx),
+		request.WithHeader(rangeHeader),
 		request.WithTimeout(time.Duration(0)),
 		request.WithMasterMeta(),
-	).CheckHTTPResponse(200).GetRSCloser()
+	).CheckHTTPResponse(status).GetRSCloser()
 	if err != nil {
 		return nil, err
 	}
 
-	resp.SetFirstFakeChunk()
+	if status == http.StatusOK {
+		resp.SetFirstFakeChunk()
+	}
 
 	// 尝试获取文件大小
 	if file, ok := ctx.Value(fsctx.FileModelCtx).(model.File); ok {
@@ -17,10 +17,22 @@ var (
 	ErrInsertFileRecord         = serializer.NewError(serializer.CodeDBError, "Failed to create file record", nil)
 	ErrFileExisted              = serializer.NewError(serializer.CodeObjectExist, "Object existed", nil)
 	ErrFileUploadSessionExisted = serializer.NewError(serializer.CodeConflictUploadOngoing, "Upload session existed", nil)
+	ErrUploadSessionExpired     = serializer.NewError(serializer.CodeUploadSessionExpired, "Upload session not exist or expired", nil)
 	ErrPathNotExist             = serializer.NewError(serializer.CodeParentNotExist, "Path not exist", nil)
 	ErrObjectNotExist           = serializer.NewError(serializer.CodeParentNotExist, "Object not exist", nil)
 	ErrIO                       = serializer.NewError(serializer.CodeIOFailed, "Failed to read file data", nil)
 	ErrDBListObjects            = serializer.NewError(serializer.CodeDBError, "Failed to list object records", nil)
 	ErrDBDeleteObjects          = serializer.NewError(serializer.CodeDBError, "Failed to delete object records", nil)
 	ErrOneObjectOnly            = serializer.ParamErr("You can only copy one object at the same time", nil)
+	ErrChecksumMismatch         = serializer.NewError(serializer.CodeMetaMismatch, "Checksum mismatch", nil)
+	ErrTagNotExist              = serializer.NewError(serializer.CodeNotFound, "Tag not exist", nil)
+	ErrFolderQuotaExceeded      = serializer.NewError(serializer.CodeFolderQuotaExceeded, "Folder quota exceeded", nil)
+	ErrInvalidMetadata          = serializer.NewError(serializer.CodeParamErr, "Invalid metadata", nil)
+	ErrReplicaNotExist          = serializer.NewError(serializer.CodeNotFound, "Replica not exist", nil)
+	ErrUnsupportedArchive       = serializer.NewError(serializer.CodeUnsupportedArchiveType, "Unsupported archive format", nil)
+	ErrInvalidCompressOption    = serializer.NewError(serializer.CodeParamErr, "Compression level or password is not supported by the archive format", nil)
+	ErrDecompressSizeExceeded   = serializer.NewError(serializer.CodeFileTooLarge, "Decompressed size exceeds limit", nil)
+	ErrArchiveEncrypted         = serializer.NewError(serializer.CodeUnsupportedArchiveType, "Archive is encrypted, password required", nil)
+	ErrArchivePassword          = serializer.NewError(serializer.CodeIncorrectPassword, "Incorrect archive password", nil)
+	ErrArchiveEntryNotExist     = serializer.NewError(serializer.CodeNotFound, "Archive entry not exist", nil)
 )
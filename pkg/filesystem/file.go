@@ -4,9 +4,9 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"time"
 
 	model "github.com/Jaylenwa/Vfoy/models"
-	"github.com/Jaylenwa/Vfoy/pkg/cache"
 	"github.com/Jaylenwa/Vfoy/pkg/conf"
 	"github.com/Jaylenwa/Vfoy/pkg/filesystem/fsctx"
 	"github.com/Jaylenwa/Vfoy/pkg/filesystem/response"
@@ -53,6 +53,17 @@ func (fs *FileSystem) AddFile(ctx context.Context, parent *model.Folder, file fs
 	}
 
 	uploadInfo := file.Info()
+	if len(uploadInfo.Checksums) > 0 {
+		metadata := make(map[string]string, len(uploadInfo.Metadata)+len(uploadInfo.Checksums))
+		for k, v := range uploadInfo.Metadata {
+			metadata[k] = v
+		}
+		for k, v := range uploadInfo.Checksums {
+			metadata[k] = v
+		}
+		uploadInfo.Metadata = metadata
+	}
+
 	newFile := model.File{
 		Name:               uploadInfo.FileName,
 		SourceName:         uploadInfo.SavePath,
@@ -62,6 +73,7 @@ func (fs *FileSystem) AddFile(ctx context.Context, parent *model.Folder, file fs
 		PolicyID:           fs.Policy.ID,
 		MetadataSerialized: uploadInfo.Metadata,
 		UploadSessionID:    uploadInfo.UploadSessionID,
+		Hash:               uploadInfo.Hash,
 	}
 
 	err = newFile.Create()
@@ -74,6 +86,7 @@ func (fs *FileSystem) AddFile(ctx context.Context, parent *model.Folder, file fs
 	}
 
 	fs.User.Storage += newFile.Size
+	fs.changeFolderUsage("+", map[uint]model.FolderUsage{parent.ID: {Size: newFile.Size, Files: 1}})
 	return &newFile, nil
 }
 
@@ -127,6 +140,7 @@ func (fs *FileSystem) Preview(ctx context.Context, id uint, isText bool) (*respo
 	if err != nil {
 		return nil, err
 	}
+	fs.touchFile(&fs.FileTarget[0])
 	return &response.ContentResponse{
 		Redirect: true,
 		URL:      previewURL,
@@ -142,12 +156,24 @@ func (fs *FileSystem) GetDownloadContent(ctx context.Context, id uint) (response
 	if err != nil {
 		return nil, err
 	}
+	fs.touchFile(&fs.FileTarget[0])
 
 	// 返回限速处理后的文件流
 	return fs.withSpeedLimit(rs), nil
 
 }
 
+// touchFile 记录文件被下载或预览的时间
+func (fs *FileSystem) touchFile(file *model.File) {
+	if conf.SystemConfig.Mode == "slave" {
+		return
+	}
+
+	if err := file.UpdateAccessTime(time.Now()); err != nil {
+		util.Log().Debug("Failed to update access time of file %q: %s", file.Name, err)
+	}
+}
+
 // GetContent 获取文件内容，path为虚拟路径
 func (fs *FileSystem) GetContent(ctx context.Context, id uint) (response.RSCloser, error) {
 	err := fs.resetFileIDIfNotExist(ctx, id)
@@ -159,6 +185,10 @@ func (fs *FileSystem) GetContent(ctx context.Context, id uint) (response.RSClose
 	// 获取文件流
 	rs, err := fs.Handler.Get(ctx, fs.FileTarget[0].SourceName)
 	if err != nil {
+		// 主存储策略读取失败时尝试读取副本
+		if replicaRs, replicaErr := fs.getReplicaContent(ctx, fs.Policy, &fs.FileTarget[0]); replicaErr == nil {
+			return replicaRs, nil
+		}
 		return nil, ErrIO.WithError(err)
 	}
 
@@ -178,13 +208,17 @@ func (fs *FileSystem) deleteGroupedFile(ctx context.Context, files map[uint][]*m
 		sourceNamesAll := make([]string, 0, len(toBeDeletedFiles))
 		uploadSessions := make([]*serializer.UploadSession, 0, len(toBeDeletedFiles))
 
+		sourceNames := make(map[string]bool, len(toBeDeletedFiles))
 		for i := 0; i < len(toBeDeletedFiles); i++ {
-			sourceNamesAll = append(sourceNamesAll, toBeDeletedFiles[i].SourceName)
+			// 多个文件引用同一物理文件时只删除一次
+			if !sourceNames[toBeDeletedFiles[i].SourceName] {
+				sourceNames[toBeDeletedFiles[i].SourceName] = true
+				sourceNamesAll = append(sourceNamesAll, toBeDeletedFiles[i].SourceName)
+			}
 
 			if toBeDeletedFiles[i].UploadSessionID != nil {
-				if session, ok := cache.Get(UploadSessionCachePrefix + *toBeDeletedFiles[i].UploadSessionID); ok {
-					uploadSession := session.(serializer.UploadSession)
-					uploadSessions = append(uploadSessions, &uploadSession)
+				if uploadSession, ok := GetUploadSession(*toBeDeletedFiles[i].UploadSessionID); ok {
+					uploadSessions = append(uploadSessions, uploadSession)
 				}
 			}
 
@@ -208,7 +242,7 @@ func (fs *FileSystem) deleteGroupedFile(ctx context.Context, files map[uint][]*m
 				util.Log().Warning("Failed to cancel upload session for %q: %s", upSession.Name, err)
 			}
 
-			cache.Deletes([]string{upSession.Key}, UploadSessionCachePrefix)
+			DeleteUploadSessions(upSession.Key)
 		}
 
 		// 执行删除
@@ -217,6 +251,9 @@ func (fs *FileSystem) deleteGroupedFile(ctx context.Context, files map[uint][]*m
 
 		// Exclude failed results related to thumb file
 		failed[policyID] = util.SliceDifference(failedFile, thumbs)
+
+		// 删除副本存储策略上的副本
+		fs.deleteReplicas(ctx, fs.Policy, util.SliceDifference(sourceNamesAll, failed[policyID]))
 	}
 
 	return failed
@@ -259,6 +296,7 @@ func (fs *FileSystem) GetDownloadURL(ctx context.Context, id uint, timeout strin
 	if err != nil {
 		return "", err
 	}
+	fs.touchFile(fileTarget)
 
 	return source, nil
 }
@@ -302,6 +340,10 @@ func (fs *FileSystem) SignURL(ctx context.Context, file *model.File, ttl int64,
 	// 生成外链地址
 	source, err := fs.Handler.Source(ctx, fs.FileTarget[0].SourceName, ttl, isDownload, fs.User.Group.SpeedLimit)
 	if err != nil {
+		// 主存储策略无法签名时尝试签名副本
+		if replicaSource, replicaErr := fs.signReplicaURL(ctx, fs.Policy, &fs.FileTarget[0], ttl, isDownload); replicaErr == nil {
+			return replicaSource, nil
+		}
 		return "", serializer.NewError(serializer.CodeNotSet, "Failed to get source link", err)
 	}
 
@@ -381,6 +423,20 @@ func (fs *FileSystem) Search(ctx context.Context, keywords ...interface{}) ([]se
 	}
 
 	files, _ := model.GetFilesByKeywords(fs.User.ID, parents, keywords...)
+
+	// 合并用户自定义元数据匹配的文件
+	metaFiles, _ := model.GetFilesByUserMetadata(fs.User.ID, parents, keywords...)
+	found := make(map[uint]bool, len(files))
+	for _, file := range files {
+		found[file.ID] = true
+	}
+	for _, file := range metaFiles {
+		if !found[file.ID] {
+			found[file.ID] = true
+			files = append(files, file)
+		}
+	}
+
 	fs.SetTargetFile(&files)
 
 	return fs.listObjects(ctx, "/", files, nil, nil), nil
@@ -662,8 +662,22 @@ func TestFileSystem_Search(t *testing.T) {
 	fs.User.ID = 1
 
 	mock.ExpectQuery("SELECT(.+)").WithArgs(1, "k1", "k2").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("SELECT(.+)metadata like(.+)").WithArgs(`%"user.%`, "%k1%", "%k2%", 1).WillReturnRows(sqlmock.NewRows([]string{"id"}))
 	res, err := fs.Search(ctx, "k1", "k2")
 	asserts.NoError(mock.ExpectationsWereMet())
 	asserts.NoError(err)
 	asserts.Len(res, 1)
+
+	// 合并用户元数据匹配的文件
+	mock.ExpectQuery("SELECT(.+)").WithArgs(1, "%alpha%").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("SELECT(.+)metadata like(.+)").WithArgs(`%"user.%`, "%alpha%", 1).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "metadata"}).
+			AddRow(1, `{"user.project":"alpha"}`).
+			AddRow(2, `{"user.project":"Alpha-2"}`).
+			AddRow(3, `{"user.project":"beta","thumb_status":"alpha"}`),
+	)
+	res, err = fs.Search(ctx, "%alpha%")
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	asserts.Len(res, 2)
 }
@@ -1,5 +1,11 @@
 package fsctx
 
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
 type key int
 
 const (
@@ -41,4 +47,29 @@ const (
 	WebDAVCtx
 	// WebDAV反代Url
 	WebDAVProxyUrlCtx
+	// ProgressFuncCtx 进度回调
+	ProgressFuncCtx
+	// RangeCtx 读取文件的字节范围
+	RangeCtx
+	// ArchivePasswordCtx 压缩文件密码
+	ArchivePasswordCtx
 )
+
+// Range 读取文件的字节范围
+type Range struct {
+	Offset int64
+	Length int64
+}
+
+// RangeHeader 返回上下文中字节范围对应的 Range 请求头及期望的响应状态码，
+// 未指定范围时请求头为空
+func RangeHeader(ctx context.Context) (http.Header, int) {
+	r, ok := ctx.Value(RangeCtx).(Range)
+	if !ok || r.Length <= 0 {
+		return nil, http.StatusOK
+	}
+
+	return http.Header{
+		"Range": {fmt.Sprintf("bytes=%d-%d", r.Offset, r.Offset+r.Length-1)},
+	}, http.StatusPartialContent
+}
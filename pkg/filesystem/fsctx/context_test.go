@@ -0,0 +1,24 @@
+package fsctx
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRangeHeader(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 未指定范围
+	header, status := RangeHeader(context.Background())
+	asserts.Nil(header)
+	asserts.Equal(http.StatusOK, status)
+
+	// 指定范围
+	ctx := context.WithValue(context.Background(), RangeCtx, Range{Offset: 10, Length: 5})
+	header, status = RangeHeader(ctx)
+	asserts.Equal("bytes=10-14", header.Get("Range"))
+	asserts.Equal(http.StatusPartialContent, status)
+}
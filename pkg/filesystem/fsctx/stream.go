@@ -29,6 +29,9 @@ type UploadTaskInfo struct {
 	AppendStart     uint64
 	Model           interface{}
 	Src             string
+	Hash            string
+	HashState       []byte
+	Checksums       map[string]string
 }
 
 // Get mimetype of uploaded file, if it's not defined, detect it from file name
@@ -67,6 +70,9 @@ type FileStream struct {
 	AppendStart     uint64
 	Model           interface{}
 	Src             string
+	Hash            string            // 文件内容的 SHA-256 摘要，由文件系统在上传时计算或由客户端提供
+	HashState       []byte            // 分片上传时，已上传部分的摘要计算状态
+	Checksums       map[string]string // SHA-256 以外的校验和，键为文件元数据中的键
 }
 
 func (file *FileStream) Read(p []byte) (n int, err error) {
@@ -111,6 +117,9 @@ func (file *FileStream) Info() *UploadTaskInfo {
 		AppendStart:     file.AppendStart,
 		Model:           file.Model,
 		Src:             file.Src,
+		Hash:            file.Hash,
+		HashState:       file.HashState,
+		Checksums:       file.Checksums,
 	}
 }
 
@@ -0,0 +1,356 @@
+package filesystem
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"strconv"
+	"strings"
+
+	model "github.com/Jaylenwa/Vfoy/models"
+	"github.com/Jaylenwa/Vfoy/pkg/cache"
+	"github.com/Jaylenwa/Vfoy/pkg/filesystem/fsctx"
+	"github.com/Jaylenwa/Vfoy/pkg/util"
+)
+
+/* ================
+	 文件内容摘要
+   ================
+*/
+
+// checksumAlgorithms 上传时计算的校验和算法及其在文件元数据中的键，SHA-256 记录在 File.Hash 中
+var checksumAlgorithms = []struct {
+	key string
+	new func() hash.Hash
+}{
+	{"", sha256.New},
+	{model.ChecksumMD5MetadataKey, md5.New},
+	{model.ChecksumSHA1MetadataKey, sha1.New},
+}
+
+// hashingStream 在上传过程中计算文件内容的摘要
+type hashingStream struct {
+	file   *fsctx.FileStream
+	reader io.ReadCloser
+	seeker io.Seeker
+	hashes []hash.Hash
+	read   uint64
+	valid  bool
+}
+
+// newHashingStream 接管文件流的读取以计算摘要，分片上传时从 HashState 继续计算，
+// 缺少之前分片的状态时不计算摘要
+func newHashingStream(file *fsctx.FileStream) *hashingStream {
+	stream := &hashingStream{file: file, hashes: newChecksumHashes(), valid: file.File != nil}
+	if file.AppendStart > 0 {
+		stream.valid = stream.valid && unmarshalHashState(stream.hashes, file.HashState)
+	}
+
+	if !stream.valid {
+		file.Hash = ""
+		file.HashState = nil
+		file.Checksums = nil
+		return stream
+	}
+
+	stream.reader = file.File
+	file.File = stream
+	if file.Seekable() {
+		stream.seeker = file.Seeker
+		file.Seeker = stream
+	}
+
+	return stream
+}
+
+func (s *hashingStream) Read(p []byte) (int, error) {
+	n, err := s.reader.Read(p)
+	if n > 0 {
+		for _, h := range s.hashes {
+			h.Write(p[:n])
+		}
+		s.read += uint64(n)
+	}
+	return n, err
+}
+
+func (s *hashingStream) Close() error {
+	return s.reader.Close()
+}
+
+// Seek 回到起始位置时重新计算摘要，其他位置的跳转会使摘要失效
+func (s *hashingStream) Seek(offset int64, whence int) (int64, error) {
+	pos, err := s.seeker.Seek(offset, whence)
+	if err == nil && pos == 0 && s.file.AppendStart == 0 {
+		for _, h := range s.hashes {
+			h.Reset()
+		}
+		s.read = 0
+	} else {
+		s.valid = false
+	}
+	return pos, err
+}
+
+// finish 完成摘要计算并写回文件流，读取的数据与文件大小不符时摘要无效
+func (s *hashingStream) finish() {
+	if s.reader != nil {
+		s.file.File = s.reader
+	}
+	if s.seeker != nil {
+		s.file.Seeker = s.seeker
+	}
+
+	if !s.valid || s.read != s.file.Size {
+		s.file.Hash = ""
+		s.file.HashState = nil
+		s.file.Checksums = nil
+		return
+	}
+
+	s.file.Hash, s.file.Checksums = sumChecksums(s.hashes)
+	s.file.HashState = marshalHashState(s.hashes)
+}
+
+func newChecksumHashes() []hash.Hash {
+	hashes := make([]hash.Hash, len(checksumAlgorithms))
+	for i, algorithm := range checksumAlgorithms {
+		hashes[i] = algorithm.new()
+	}
+	return hashes
+}
+
+// sumChecksums 返回 SHA-256 摘要及需要记录在元数据中的其他校验和
+func sumChecksums(hashes []hash.Hash) (string, map[string]string) {
+	checksums := make(map[string]string, len(hashes)-1)
+	for i, algorithm := range checksumAlgorithms[1:] {
+		checksums[algorithm.key] = hex.EncodeToString(hashes[i+1].Sum(nil))
+	}
+
+	return hex.EncodeToString(hashes[0].Sum(nil)), checksums
+}
+
+// marshalHashState 序列化各算法的计算状态，每段状态前附带长度
+func marshalHashState(hashes []hash.Hash) []byte {
+	var state []byte
+	for _, h := range hashes {
+		marshaler, ok := h.(encoding.BinaryMarshaler)
+		if !ok {
+			return nil
+		}
+
+		data, err := marshaler.MarshalBinary()
+		if err != nil {
+			return nil
+		}
+
+		length := make([]byte, 4)
+		binary.BigEndian.PutUint32(length, uint32(len(data)))
+		state = append(append(state, length...), data...)
+	}
+
+	return state
+}
+
+func unmarshalHashState(hashes []hash.Hash, state []byte) bool {
+	for _, h := range hashes {
+		unmarshaler, ok := h.(encoding.BinaryUnmarshaler)
+		if !ok || len(state) < 4 {
+			return false
+		}
+
+		length := binary.BigEndian.Uint32(state)
+		if uint64(len(state)-4) < uint64(length) {
+			return false
+		}
+
+		if unmarshaler.UnmarshalBinary(state[4:4+length]) != nil {
+			return false
+		}
+		state = state[4+length:]
+	}
+
+	return len(state) == 0
+}
+
+// ChunkHashState 取得分片上传占位文件中记录的摘要状态，仅在记录的偏移与分片起始位置一致时有效
+func ChunkHashState(file *model.File, offset uint64) []byte {
+	if offset == 0 || file.MetadataSerialized[model.HashOffsetMetadataKey] != strconv.FormatUint(offset, 10) {
+		return nil
+	}
+
+	state, err := base64.StdEncoding.DecodeString(file.MetadataSerialized[model.HashStateMetadataKey])
+	if err != nil {
+		return nil
+	}
+
+	return state
+}
+
+// HookSaveChunkHash 分片上传完成后保存摘要计算状态，最后一个分片完成后保存文件摘要
+func HookSaveChunkHash(isLastChunk bool) Hook {
+	return func(ctx context.Context, fs *FileSystem, fileHeader fsctx.FileHeader) error {
+		fileInfo := fileHeader.Info()
+		fileModel := fileInfo.Model.(*model.File)
+		if isLastChunk {
+			return fileModel.UpdateHash(fileInfo.Hash, fileInfo.Checksums)
+		}
+
+		if fileInfo.Hash == "" {
+			return nil
+		}
+
+		return fileModel.UpdateMetadata(map[string]string{
+			model.HashStateMetadataKey:  base64.StdEncoding.EncodeToString(fileInfo.HashState),
+			model.HashOffsetMetadataKey: strconv.FormatUint(fileInfo.AppendStart+fileInfo.Size, 10),
+		})
+	}
+}
+
+// ParseChecksums 解析客户端提供的校验和，格式为以空格分隔的 "算法:值"，
+// 如 OC-Checksum 请求头中的 "SHA1:2fd4e1c6..."
+func ParseChecksums(value string) map[string]string {
+	checksums := make(map[string]string)
+	for _, field := range strings.Fields(value) {
+		algorithm, sum, ok := strings.Cut(field, ":")
+		if !ok {
+			continue
+		}
+
+		algorithm = strings.ToLower(strings.ReplaceAll(algorithm, "-", ""))
+		checksums[algorithm] = strings.ToLower(sum)
+	}
+
+	return checksums
+}
+
+// HookVerifyChecksum 校验上传内容与客户端提供的校验和是否一致，
+// 服务端未能计算的算法将被忽略
+func HookVerifyChecksum(expected map[string]string) Hook {
+	return func(ctx context.Context, fs *FileSystem, fileHeader fsctx.FileHeader) error {
+		fileInfo := fileHeader.Info()
+		if fileInfo.Hash == "" {
+			return nil
+		}
+
+		actual := model.File{Hash: fileInfo.Hash, MetadataSerialized: fileInfo.Checksums}
+		for algorithm, sum := range actual.Checksums() {
+			if want, ok := expected[algorithm]; ok && want != sum {
+				util.Log().Debug("Checksum %s mismatch for %q, expected %s, got %s.", algorithm, fileInfo.FileName, want, sum)
+				return ErrChecksumMismatch
+			}
+		}
+
+		return nil
+	}
+}
+
+// ComputeChecksums 读取文件内容并计算校验和
+func (fs *FileSystem) ComputeChecksums(ctx context.Context, file *model.File) error {
+	fs.Policy = file.GetPolicy()
+	if err := fs.DispatchHandler(); err != nil {
+		return err
+	}
+
+	rs, err := fs.Handler.Get(ctx, file.SourceName)
+	if err != nil {
+		return ErrIO.WithError(err)
+	}
+	defer rs.Close()
+
+	hashes := newChecksumHashes()
+	writers := make([]io.Writer, len(hashes))
+	for i, h := range hashes {
+		writers[i] = h
+	}
+
+	read, err := io.Copy(io.MultiWriter(writers...), rs)
+	if err != nil {
+		return ErrIO.WithError(err)
+	}
+
+	if uint64(read) != file.Size {
+		return ErrIO.WithError(fmt.Errorf("read %d bytes, expected %d", read, file.Size))
+	}
+
+	return file.UpdateHash(sumChecksums(hashes))
+}
+
+type checksumRequest struct {
+	user   model.User
+	fileID uint
+}
+
+// checksumQueue 等待计算校验和的已有文件，未启动时不计算
+var checksumQueue chan checksumRequest
+
+// InitChecksumWorker 启动在后台计算已有文件校验和的队列
+func InitChecksumWorker() {
+	workers := model.GetIntSetting("checksum_max_task_count", 1)
+	if workers <= 0 {
+		workers = 1
+	}
+
+	checksumQueue = make(chan checksumRequest, 1000)
+	for i := 0; i < workers; i++ {
+		go func() {
+			for req := range checksumQueue {
+				handleChecksumRequest(req)
+			}
+		}()
+	}
+
+	util.Log().Debug("Initialize checksum queue with: WorkerNum = %d", workers)
+}
+
+// ScheduleChecksum 将尚未计算校验和的已有文件加入后台计算队列，
+// 同一文件在一段时间内只加入一次，队列已满时忽略
+func ScheduleChecksum(user *model.User, file *model.File) {
+	if checksumQueue == nil || file.UploadSessionID != nil || len(file.Checksums()) == len(checksumAlgorithms) {
+		return
+	}
+
+	key := fmt.Sprintf("checksum_scheduled_%d", file.ID)
+	if _, ok := cache.Get(key); ok {
+		return
+	}
+
+	select {
+	case checksumQueue <- checksumRequest{user: *user, fileID: file.ID}:
+		_ = cache.Set(key, true, 3600)
+	default:
+		util.Log().Warning("Checksum queue is full, skip computing checksums of file %q.", file.Name)
+	}
+}
+
+func handleChecksumRequest(req checksumRequest) {
+	fs, err := NewFileSystem(&req.user)
+	if err != nil {
+		util.Log().Warning("Failed to initialize filesystem for checksum: %s", err)
+		return
+	}
+	defer fs.Recycle()
+
+	// 入队后文件可能已被删除或覆盖，以当前记录为准
+	files, err := model.GetFilesByIDs([]uint{req.fileID}, req.user.ID)
+	if err != nil || len(files) == 0 {
+		return
+	}
+
+	file := &files[0]
+	if len(file.Checksums()) == len(checksumAlgorithms) || file.UploadSessionID != nil {
+		return
+	}
+
+	if err := fs.ComputeChecksums(context.Background(), file); err != nil {
+		util.Log().Warning("Failed to compute checksums of file %q: %s", file.Name, err)
+	}
+}
@@ -0,0 +1,232 @@
+package filesystem
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/Jaylenwa/Vfoy/models"
+	"github.com/Jaylenwa/Vfoy/pkg/cache"
+	"github.com/Jaylenwa/Vfoy/pkg/filesystem/fsctx"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func sha256Hex(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestHashingStream(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 完整读取
+	{
+		file := &fsctx.FileStream{File: ioutil.NopCloser(strings.NewReader("content")), Size: 7}
+		hashing := newHashingStream(file)
+		_, err := io.Copy(ioutil.Discard, file)
+		asserts.NoError(err)
+		hashing.finish()
+		asserts.Equal(sha256Hex("content"), file.Hash)
+		asserts.Equal("9a0364b9e99bb480dd25e1f0284c8555", file.Checksums[model.ChecksumMD5MetadataKey])
+		asserts.Equal("040f06fd774092478d450774f5ba30c5da78acc8", file.Checksums[model.ChecksumSHA1MetadataKey])
+		asserts.NotEmpty(file.HashState)
+	}
+
+	// 读取不完整
+	{
+		file := &fsctx.FileStream{File: ioutil.NopCloser(strings.NewReader("content")), Size: 10}
+		hashing := newHashingStream(file)
+		io.Copy(ioutil.Discard, file)
+		hashing.finish()
+		asserts.Empty(file.Hash)
+	}
+
+	// 分片续算
+	{
+		first := &fsctx.FileStream{File: ioutil.NopCloser(strings.NewReader("con")), Size: 3}
+		hashing := newHashingStream(first)
+		io.Copy(ioutil.Discard, first)
+		hashing.finish()
+
+		second := &fsctx.FileStream{
+			File:        ioutil.NopCloser(strings.NewReader("tent")),
+			Size:        4,
+			AppendStart: 3,
+			HashState:   first.HashState,
+		}
+		hashing = newHashingStream(second)
+		io.Copy(ioutil.Discard, second)
+		hashing.finish()
+		asserts.Equal(sha256Hex("content"), second.Hash)
+		asserts.Equal("9a0364b9e99bb480dd25e1f0284c8555", second.Checksums[model.ChecksumMD5MetadataKey])
+	}
+
+	// 分片状态损坏
+	{
+		file := &fsctx.FileStream{File: ioutil.NopCloser(strings.NewReader("tent")), Size: 4, AppendStart: 3, HashState: []byte("state")}
+		hashing := newHashingStream(file)
+		io.Copy(ioutil.Discard, file)
+		hashing.finish()
+		asserts.Empty(file.Hash)
+		asserts.Nil(file.Checksums)
+	}
+
+	// 分片缺少之前的状态
+	{
+		file := &fsctx.FileStream{File: ioutil.NopCloser(strings.NewReader("tent")), Size: 4, AppendStart: 3}
+		hashing := newHashingStream(file)
+		io.Copy(ioutil.Discard, file)
+		hashing.finish()
+		asserts.Empty(file.Hash)
+	}
+
+	// 回到起始位置重新计算
+	{
+		reader := strings.NewReader("content")
+		file := &fsctx.FileStream{File: ioutil.NopCloser(reader), Seeker: reader, Size: 7}
+		hashing := newHashingStream(file)
+		io.CopyN(ioutil.Discard, file, 3)
+		_, err := file.Seek(0, io.SeekStart)
+		asserts.NoError(err)
+		io.Copy(ioutil.Discard, file)
+		hashing.finish()
+		asserts.Equal(sha256Hex("content"), file.Hash)
+		asserts.Equal(reader, file.Seeker)
+	}
+}
+
+func TestChunkHashState(t *testing.T) {
+	asserts := assert.New(t)
+	file := &model.File{MetadataSerialized: map[string]string{
+		model.HashStateMetadataKey:  "c3RhdGU=",
+		model.HashOffsetMetadataKey: "10",
+	}}
+
+	asserts.Equal([]byte("state"), ChunkHashState(file, 10))
+	asserts.Nil(ChunkHashState(file, 0))
+	asserts.Nil(ChunkHashState(file, 20))
+}
+
+func TestHookSaveChunkHash(t *testing.T) {
+	asserts := assert.New(t)
+	fs := &FileSystem{}
+
+	// 中间分片保存状态
+	{
+		file := &model.File{Model: gorm.Model{ID: 1}}
+		stream := &fsctx.FileStream{Model: file, Hash: "hash", HashState: []byte("state"), AppendStart: 5, Size: 5}
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		asserts.NoError(HookSaveChunkHash(false)(context.Background(), fs, stream))
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal("10", file.MetadataSerialized[model.HashOffsetMetadataKey])
+	}
+
+	// 中间分片未能计算摘要
+	{
+		file := &model.File{Model: gorm.Model{ID: 1}}
+		stream := &fsctx.FileStream{Model: file}
+		asserts.NoError(HookSaveChunkHash(false)(context.Background(), fs, stream))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 最后一个分片
+	{
+		file := &model.File{Model: gorm.Model{ID: 1}}
+		stream := &fsctx.FileStream{Model: file, Hash: "hash"}
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)").WithArgs("hash", sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		asserts.NoError(HookSaveChunkHash(true)(context.Background(), fs, stream))
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal("hash", file.Hash)
+	}
+}
+
+func TestParseChecksums(t *testing.T) {
+	asserts := assert.New(t)
+	asserts.Equal(map[string]string{"sha1": "abc", "md5": "def"}, ParseChecksums("SHA1:ABC MD5:def invalid"))
+	asserts.Equal(map[string]string{"sha256": "abc"}, ParseChecksums("SHA-256:abc"))
+	asserts.Empty(ParseChecksums(""))
+}
+
+func TestHookVerifyChecksum(t *testing.T) {
+	asserts := assert.New(t)
+	fs := &FileSystem{}
+	stream := &fsctx.FileStream{
+		Hash:      "hash",
+		Checksums: map[string]string{model.ChecksumMD5MetadataKey: "md5"},
+	}
+
+	// 一致
+	asserts.NoError(HookVerifyChecksum(map[string]string{"md5": "md5", "adler32": "1"})(context.Background(), fs, stream))
+
+	// 不一致
+	asserts.Equal(ErrChecksumMismatch, HookVerifyChecksum(map[string]string{"sha256": "other"})(context.Background(), fs, stream))
+
+	// 服务端未能计算
+	asserts.NoError(HookVerifyChecksum(map[string]string{"sha256": "other"})(context.Background(), fs, &fsctx.FileStream{}))
+}
+
+func TestScheduleChecksum(t *testing.T) {
+	asserts := assert.New(t)
+	user := &model.User{Model: gorm.Model{ID: 1}}
+	file := &model.File{Model: gorm.Model{ID: 1}, Name: "1.txt"}
+
+	// 队列未启动
+	ScheduleChecksum(user, file)
+
+	checksumQueue = make(chan checksumRequest, 1)
+	defer func() { checksumQueue = nil }()
+
+	// 已计算过校验和
+	ScheduleChecksum(user, &model.File{Model: gorm.Model{ID: 2}, Hash: "hash", MetadataSerialized: map[string]string{
+		model.ChecksumMD5MetadataKey:  "md5",
+		model.ChecksumSHA1MetadataKey: "sha1",
+	}})
+	asserts.Len(checksumQueue, 0)
+
+	// 成功
+	ScheduleChecksum(user, file)
+	asserts.Len(checksumQueue, 1)
+	req := <-checksumQueue
+	asserts.EqualValues(1, req.fileID)
+	asserts.EqualValues(1, req.user.ID)
+
+	// 短时间内不重复加入
+	ScheduleChecksum(user, file)
+	asserts.Len(checksumQueue, 0)
+	cache.Deletes([]string{"1"}, "checksum_scheduled_")
+
+	// 上传会话未完成
+	sessionID := "session"
+	ScheduleChecksum(user, &model.File{Model: gorm.Model{ID: 3}, UploadSessionID: &sessionID})
+	asserts.Len(checksumQueue, 0)
+}
+
+func TestHandleChecksumRequest(t *testing.T) {
+	asserts := assert.New(t)
+	user := model.User{Model: gorm.Model{ID: 1}, Policy: model.Policy{Type: "local"}}
+
+	// 入队后文件已被删除
+	mock.ExpectQuery("SELECT(.+)files(.+)").WithArgs(2, 1).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	asserts.NotPanics(func() {
+		handleChecksumRequest(checksumRequest{user: user, fileID: 2})
+	})
+	asserts.NoError(mock.ExpectationsWereMet())
+
+	// 查询失败
+	mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnError(errors.New("error"))
+	asserts.NotPanics(func() {
+		handleChecksumRequest(checksumRequest{user: user, fileID: 2})
+	})
+	asserts.NoError(mock.ExpectationsWereMet())
+}
@@ -9,7 +9,6 @@ import (
 	"time"
 
 	model "github.com/Jaylenwa/Vfoy/models"
-	"github.com/Jaylenwa/Vfoy/pkg/cache"
 	"github.com/Jaylenwa/Vfoy/pkg/cluster"
 	"github.com/Jaylenwa/Vfoy/pkg/filesystem/driver/local"
 	"github.com/Jaylenwa/Vfoy/pkg/filesystem/fsctx"
@@ -139,7 +138,14 @@ func HookClearFileSize(ctx context.Context, fs *FileSystem, file fsctx.FileHeade
 	if !ok {
 		return ErrObjectNotExist
 	}
-	return originFile.UpdateSize(0)
+
+	oldSize := originFile.Size
+	if err := originFile.UpdateSize(0); err != nil {
+		return err
+	}
+
+	fs.changeFileSizeUsage(originFile.FolderID, oldSize, 0)
+	return nil
 }
 
 // HookCancelContext 取消上下文
@@ -170,11 +176,25 @@ func GenericAfterUpdate(ctx context.Context, fs *FileSystem, newFile fsctx.FileH
 
 	newFile.SetModel(&originFile)
 
+	oldSize := originFile.Size
 	err := originFile.UpdateSize(newFile.Info().Size)
 	if err != nil {
 		return err
 	}
+	fs.changeFileSizeUsage(originFile.FolderID, oldSize, originFile.Size)
 
+	// 更新内容摘要，未能计算摘要时清除旧值
+	if info := newFile.Info(); info.Hash != "" || originFile.Hash != "" {
+		if err := originFile.UpdateHash(info.Hash, info.Checksums); err != nil {
+			return err
+		}
+	}
+
+	// 重建全文索引
+	scheduleContentIndex(fs.User, &originFile)
+
+	fs.journal(fileChange(model.ChangeUpdate, &originFile))
+	fs.RecordRecent(&originFile, model.RecentEdit)
 	return nil
 }
 
@@ -220,6 +240,15 @@ func GenericAfterUpload(ctx context.Context, fs *FileSystem, fileHeader fsctx.Fi
 	}
 	fileHeader.SetModel(file)
 
+	// 建立全文索引
+	scheduleContentIndex(fs.User, file)
+
+	// 上传会话中的占位文件在上传完成后记录
+	if file.UploadSessionID == nil {
+		fs.journal(fileChange(model.ChangeCreate, file))
+		fs.RecordRecent(file, model.RecentUpload)
+	}
+
 	return nil
 }
 
@@ -245,7 +274,7 @@ func HookChunkUploaded(ctx context.Context, fs *FileSystem, fileHeader fsctx.Fil
 	fileInfo := fileHeader.Info()
 
 	// 更新文件大小
-	return fileInfo.Model.(*model.File).UpdateSize(fileInfo.AppendStart + fileInfo.Size)
+	return fs.updatePlaceholderSize(fileInfo.Model.(*model.File), fileInfo.AppendStart+fileInfo.Size)
 }
 
 // HookChunkUploadFailed 单个分片上传失败后
@@ -253,7 +282,18 @@ func HookChunkUploadFailed(ctx context.Context, fs *FileSystem, fileHeader fsctx
 	fileInfo := fileHeader.Info()
 
 	// 更新文件大小
-	return fileInfo.Model.(*model.File).UpdateSize(fileInfo.AppendStart)
+	return fs.updatePlaceholderSize(fileInfo.Model.(*model.File), fileInfo.AppendStart)
+}
+
+// updatePlaceholderSize 更新占位文件的大小及目录配额的已用量
+func (fs *FileSystem) updatePlaceholderSize(file *model.File, size uint64) error {
+	oldSize := file.Size
+	if err := file.UpdateSize(size); err != nil {
+		return err
+	}
+
+	fs.changeFileSizeUsage(file.FolderID, oldSize, size)
+	return nil
 }
 
 // HookPopPlaceholderToFile 将占位文件提升为正式文件
@@ -261,14 +301,23 @@ func HookPopPlaceholderToFile(picInfo string) Hook {
 	return func(ctx context.Context, fs *FileSystem, fileHeader fsctx.FileHeader) error {
 		fileInfo := fileHeader.Info()
 		fileModel := fileInfo.Model.(*model.File)
-		return fileModel.PopChunkToFile(fileInfo.LastModified, picInfo)
+		if err := fileModel.PopChunkToFile(fileInfo.LastModified, picInfo); err != nil {
+			return err
+		}
+
+		// 建立全文索引
+		scheduleContentIndex(fs.User, fileModel)
+
+		fs.journal(fileChange(model.ChangeCreate, fileModel))
+		fs.RecordRecent(fileModel, model.RecentUpload)
+		return nil
 	}
 }
 
 // HookChunkUploadFinished 分片上传结束后处理文件
 func HookDeleteUploadSession(id string) Hook {
 	return func(ctx context.Context, fs *FileSystem, fileHeader fsctx.FileHeader) error {
-		cache.Deletes([]string{id}, UploadSessionCachePrefix)
+		DeleteUploadSessions(id)
 		return nil
 	}
 }
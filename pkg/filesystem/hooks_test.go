@@ -595,7 +595,7 @@ func TestHookTruncateFileTo(t *testing.T) {
 
 func TestHookChunkUploaded(t *testing.T) {
 	a := assert.New(t)
-	fs := &FileSystem{}
+	fs := &FileSystem{User: &model.User{}}
 	file := &fsctx.FileStream{
 		AppendStart: 10,
 		Size:        10,
@@ -610,13 +610,14 @@ func TestHookChunkUploaded(t *testing.T) {
 		WithArgs(20, sqlmock.AnyArg()).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
+	mock.ExpectQuery("SELECT(.+)folder_quota(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
 	a.NoError(HookChunkUploaded(context.Background(), fs, file))
 	a.NoError(mock.ExpectationsWereMet())
 }
 
 func TestHookChunkUploadFailed(t *testing.T) {
 	a := assert.New(t)
-	fs := &FileSystem{}
+	fs := &FileSystem{User: &model.User{}}
 	file := &fsctx.FileStream{
 		AppendStart: 10,
 		Size:        10,
@@ -631,6 +632,7 @@ func TestHookChunkUploadFailed(t *testing.T) {
 		WithArgs(10, sqlmock.AnyArg()).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
+	mock.ExpectQuery("SELECT(.+)folder_quota(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
 	a.NoError(HookChunkUploadFailed(context.Background(), fs, file))
 	a.NoError(mock.ExpectationsWereMet())
 }
@@ -680,7 +682,11 @@ func TestHookDeleteUploadSession(t *testing.T) {
 	}
 
 	cache.Set(UploadSessionCachePrefix+"TestHookDeleteUploadSession", "", 0)
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE(.+)upload_sessions(.+)").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
 	a.NoError(HookDeleteUploadSession("TestHookDeleteUploadSession")(context.Background(), fs, file))
+	a.NoError(mock.ExpectationsWereMet())
 	_, ok := cache.Get(UploadSessionCachePrefix + "TestHookDeleteUploadSession")
 	a.False(ok)
 }
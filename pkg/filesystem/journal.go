@@ -0,0 +1,147 @@
+package filesystem
+
+import (
+	model "github.com/Jaylenwa/Vfoy/models"
+	"github.com/Jaylenwa/Vfoy/pkg/conf"
+	"github.com/Jaylenwa/Vfoy/pkg/mq"
+	"github.com/Jaylenwa/Vfoy/pkg/serializer"
+	"github.com/Jaylenwa/Vfoy/pkg/util"
+)
+
+// journal 写入当前用户的变更日志并推送给用户，写入失败不影响文件操作本身
+func (fs *FileSystem) journal(changes ...model.Change) {
+	if len(changes) == 0 || fs.User == nil || fs.User.ID == 0 || conf.SystemConfig.Mode == "slave" {
+		return
+	}
+
+	for i := range changes {
+		changes[i].UserID = fs.User.ID
+	}
+
+	if err := model.RecordChanges(changes); err != nil {
+		util.Log().Warning("Failed to record %d change(s) to journal: %s", len(changes), err)
+		return
+	}
+
+	// 推送给用户的在线客户端，事件ID即变更日志游标
+	mq.PublishUserEvent(fs.User.ID, mq.EventFileChange, serializer.BuildDelta(changes[len(changes)-1].ID, false, changes))
+}
+
+// fileChange 生成文件的变更记录
+func fileChange(changeType string, file *model.File) model.Change {
+	return model.Change{
+		Type:     changeType,
+		ObjectID: file.ID,
+		ParentID: file.FolderID,
+		Name:     file.Name,
+	}
+}
+
+// folderChange 生成目录的变更记录
+func folderChange(changeType string, folder *model.Folder) model.Change {
+	change := model.Change{
+		Type:     changeType,
+		IsFolder: true,
+		ObjectID: folder.ID,
+		Name:     folder.Name,
+	}
+
+	if folder.ParentID != nil {
+		change.ParentID = *folder.ParentID
+	}
+
+	return change
+}
+
+// topLevelChanges 生成被删除对象的变更记录，只记录父目录未被一并删除的顶级对象，忽略上传中的占位文件
+func topLevelChanges(changeType string, folders []model.Folder, files []*model.File) []model.Change {
+	folderIDs := make(map[uint]bool, len(folders))
+	for _, folder := range folders {
+		folderIDs[folder.ID] = true
+	}
+
+	changes := make([]model.Change, 0)
+	for i := range folders {
+		if folders[i].ParentID == nil || !folderIDs[*folders[i].ParentID] {
+			changes = append(changes, folderChange(changeType, &folders[i]))
+		}
+	}
+
+	for _, file := range files {
+		if !folderIDs[file.FolderID] && file.UploadSessionID == nil {
+			changes = append(changes, fileChange(changeType, file))
+		}
+	}
+
+	return changes
+}
+
+// createChanges 生成复制产生的新目录和文件的创建记录
+func createChanges(folders []model.Folder, files []model.File) []model.Change {
+	changes := make([]model.Change, 0, len(folders)+len(files))
+	for i := range folders {
+		changes = append(changes, folderChange(model.ChangeCreate, &folders[i]))
+	}
+
+	for i := range files {
+		changes = append(changes, fileChange(model.ChangeCreate, &files[i]))
+	}
+
+	return changes
+}
+
+// moveChanges 生成被移动对象的变更记录，对象名读取自移动后的记录，WebDAV 移动时可能同时重命名
+func (fs *FileSystem) moveChanges(dirs, files []uint, dstFolder *model.Folder) []model.Change {
+	changes := make([]model.Change, 0, len(dirs)+len(files))
+	names := make(map[uint]string, len(dirs))
+	if len(dirs) > 0 {
+		folders, err := model.GetFoldersByIDs(dirs, fs.User.ID)
+		if err != nil {
+			util.Log().Warning("Failed to get moved folders for journal: %s", err)
+		}
+		for _, folder := range folders {
+			names[folder.ID] = folder.Name
+		}
+	}
+
+	for _, id := range dirs {
+		changes = append(changes, model.Change{
+			Type:     model.ChangeMove,
+			IsFolder: true,
+			ObjectID: id,
+			ParentID: dstFolder.ID,
+			Name:     moveName(names, id, dstFolder),
+		})
+	}
+
+	names = make(map[uint]string, len(files))
+	if len(files) > 0 {
+		movedFiles, err := model.GetFilesByIDs(files, fs.User.ID)
+		if err != nil {
+			util.Log().Warning("Failed to get moved files for journal: %s", err)
+		}
+		for _, file := range movedFiles {
+			names[file.ID] = file.Name
+		}
+	}
+
+	for _, id := range files {
+		changes = append(changes, model.Change{
+			Type:     model.ChangeMove,
+			ObjectID: id,
+			ParentID: dstFolder.ID,
+			Name:     moveName(names, id, dstFolder),
+		})
+	}
+
+	return changes
+}
+
+// moveName 返回被移动对象移动后的名称，记录读取失败时退回到 WebDAV 目标名
+func moveName(names map[uint]string, id uint, dstFolder *model.Folder) string {
+	if name, ok := names[id]; ok {
+		return name
+	}
+
+	return dstFolder.WebdavDstName
+}
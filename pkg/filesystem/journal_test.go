@@ -0,0 +1,115 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/Jaylenwa/Vfoy/models"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSystem_Journal(t *testing.T) {
+	asserts := assert.New(t)
+	fs := &FileSystem{User: &model.User{Model: gorm.Model{ID: 1}}}
+
+	// 匿名用户不记录
+	(&FileSystem{User: &model.User{}}).journal(model.Change{Type: model.ChangeCreate})
+	asserts.NoError(mock.ExpectationsWereMet())
+
+	// 写入失败
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT(.+)changes(.+)").WillReturnError(errors.New("error"))
+	mock.ExpectRollback()
+	fs.journal(model.Change{Type: model.ChangeCreate})
+	asserts.NoError(mock.ExpectationsWereMet())
+
+	// 成功
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT(.+)changes(.+)").
+		WithArgs(sqlmock.AnyArg(), 1, model.ChangeCreate, false, 2, 3, "a.txt").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	fs.journal(fileChange(model.ChangeCreate, &model.File{Model: gorm.Model{ID: 2}, FolderID: 3, Name: "a.txt"}))
+	asserts.NoError(mock.ExpectationsWereMet())
+}
+
+func TestTopLevelChanges(t *testing.T) {
+	asserts := assert.New(t)
+	root, parent := uint(1), uint(2)
+	session := "session"
+	folders := []model.Folder{
+		{Model: gorm.Model{ID: 2}, ParentID: &root, Name: "dir"},
+		{Model: gorm.Model{ID: 3}, ParentID: &parent, Name: "sub"},
+	}
+	files := []*model.File{
+		{Model: gorm.Model{ID: 1}, FolderID: 3},
+		{Model: gorm.Model{ID: 2}, FolderID: 1, Name: "a.txt"},
+		{Model: gorm.Model{ID: 3}, FolderID: 1, UploadSessionID: &session},
+	}
+
+	changes := topLevelChanges(model.ChangeDelete, folders, files)
+	asserts.Len(changes, 2)
+	asserts.True(changes[0].IsFolder)
+	asserts.EqualValues(2, changes[0].ObjectID)
+	asserts.EqualValues(1, changes[0].ParentID)
+	asserts.False(changes[1].IsFolder)
+	asserts.EqualValues(2, changes[1].ObjectID)
+	asserts.Equal("a.txt", changes[1].Name)
+}
+
+func TestCreateChanges(t *testing.T) {
+	asserts := assert.New(t)
+	parent := uint(1)
+	folders := []model.Folder{{Model: gorm.Model{ID: 5}, ParentID: &parent, Name: "dir"}}
+	files := []model.File{{Model: gorm.Model{ID: 6}, FolderID: 5, Name: "a.txt"}}
+
+	changes := createChanges(folders, files)
+	asserts.Len(changes, 2)
+	asserts.Equal(model.ChangeCreate, changes[0].Type)
+	asserts.True(changes[0].IsFolder)
+	asserts.EqualValues(5, changes[0].ObjectID)
+	asserts.Equal(model.ChangeCreate, changes[1].Type)
+	asserts.EqualValues(5, changes[1].ParentID)
+	asserts.Equal("a.txt", changes[1].Name)
+}
+
+func TestFileSystem_MoveChanges(t *testing.T) {
+	asserts := assert.New(t)
+	fs := &FileSystem{User: &model.User{Model: gorm.Model{ID: 1}}}
+	dst := &model.Folder{Model: gorm.Model{ID: 3}}
+
+	mock.ExpectQuery("SELECT(.+)folders(.+)").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(2, "dir"))
+	mock.ExpectQuery("SELECT(.+)files(.+)").
+		WillReturnError(errors.New("error"))
+	dst.WebdavDstName = "fallback"
+	changes := fs.moveChanges([]uint{2}, []uint{4}, dst)
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.Len(changes, 2)
+	asserts.Equal("dir", changes[0].Name)
+	asserts.EqualValues(3, changes[0].ParentID)
+	asserts.Equal("fallback", changes[1].Name)
+}
+
+func TestFileSystem_RenameJournal(t *testing.T) {
+	asserts := assert.New(t)
+	fs := &FileSystem{User: &model.User{Model: gorm.Model{ID: 1}}, Policy: &model.Policy{}}
+	ctx := context.Background()
+
+	mock.ExpectQuery("SELECT(.+)folders(.+)").
+		WithArgs(2, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "parent_id"}).AddRow(2, "old", 1))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)folders(.+)SET(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT(.+)changes(.+)").
+		WithArgs(sqlmock.AnyArg(), 1, model.ChangeRename, true, 2, 1, "new").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	asserts.NoError(fs.Rename(ctx, []uint{2}, nil, "new"))
+	asserts.NoError(mock.ExpectationsWereMet())
+}
@@ -0,0 +1,294 @@
+package lock
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Jaylenwa/Vfoy/pkg/cache"
+)
+
+// 加锁的途径
+const (
+	AppWOPI   = "wopi"
+	AppWebDAV = "webdav"
+	AppWeb    = "web"
+)
+
+const (
+	// lockKeyPrefix 文件锁在缓存中的键前缀
+	lockKeyPrefix = "file_lock_"
+	// userLocksKeyPrefix 用户持有的文件锁索引在缓存中的键前缀
+	userLocksKeyPrefix = "file_lock_user_"
+	// MaxTTL 锁的最长有效期，永不过期的锁也会在此之后失效
+	MaxTTL = 24 * time.Hour
+)
+
+var (
+	// ErrLocked 文件已被其他持有者锁定
+	ErrLocked = errors.New("file is locked")
+	// ErrNoSuchLock 文件未被锁定或锁标识不匹配
+	ErrNoSuchLock = errors.New("no such lock")
+	// ErrNotOwner 只能解除自己持有的锁
+	ErrNotOwner = errors.New("lock is not held by current user")
+)
+
+func init() {
+	gob.Register(Lock{})
+	gob.Register([]uint{})
+}
+
+// Lock 文件锁，WOPI、WebDAV 与网页编辑共享
+type Lock struct {
+	FileID    uint
+	UserID    uint   // 持有锁的用户
+	Token     string // 锁标识，WOPI 中为 X-WOPI-Lock，WebDAV 中为锁令牌
+	App       string // 加锁的途径
+	Owner     string // 持有者的描述
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// Expired 锁是否已过期
+func (l *Lock) Expired(now time.Time) bool {
+	return !now.Before(l.ExpiresAt)
+}
+
+func lockKey(fileID uint) string {
+	return fmt.Sprintf("%s%d", lockKeyPrefix, fileID)
+}
+
+func userLocksKey(uid uint) string {
+	return fmt.Sprintf("%s%d", userLocksKeyPrefix, uid)
+}
+
+// Get 获取文件上有效的锁
+func Get(fileID uint) (*Lock, bool) {
+	return get(fileID, time.Now())
+}
+
+func get(fileID uint, now time.Time) (*Lock, bool) {
+	value, ok := cache.Get(lockKey(fileID))
+	if !ok {
+		return nil, false
+	}
+
+	return valid(value, now)
+}
+
+// valid 从缓存值中取得未过期的锁
+func valid(value interface{}, now time.Time) (*Lock, bool) {
+	l, ok := value.(Lock)
+	if !ok || l.Expired(now) {
+		return nil, false
+	}
+
+	return &l, true
+}
+
+// update 原子地检查并修改文件上的锁，多个实例共享缓存时也不会交错。
+// fn 根据当前有效的锁返回要写入的新锁，返回 nil 时解除锁定
+func update(fileID uint, ttl time.Duration, fn func(current *Lock, now time.Time) (*Lock, error)) error {
+	if ttl <= 0 || ttl > MaxTTL {
+		ttl = MaxTTL
+	}
+
+	return cache.Update(lockKey(fileID), func(value interface{}, ok bool) (interface{}, int, error) {
+		now := time.Now()
+		current, _ := valid(value, now)
+		next, err := fn(current, now)
+		if err != nil || next == nil {
+			return nil, 0, err
+		}
+
+		next.ExpiresAt = now.Add(ttl)
+		return *next, int(ttl.Seconds()), nil
+	})
+}
+
+// Acquire 为文件加锁，文件已被相同标识的锁锁定时刷新有效期。
+// 已被其他锁锁定时返回 ErrLocked 及当前的锁
+func Acquire(l Lock, ttl time.Duration) (*Lock, error) {
+	var res *Lock
+	err := update(l.FileID, ttl, func(current *Lock, now time.Time) (*Lock, error) {
+		if current != nil && current.Token != l.Token {
+			res = current
+			return nil, ErrLocked
+		}
+
+		res = &l
+		res.CreatedAt = now
+		if current != nil {
+			res.CreatedAt = current.CreatedAt
+		}
+		return res, nil
+	})
+	if err != nil {
+		return res, err
+	}
+
+	return res, addUserLock(res.UserID, res.FileID)
+}
+
+// Refresh 刷新文件锁的有效期，锁标识不匹配时返回 ErrNoSuchLock 及当前的锁
+func Refresh(fileID uint, token string, ttl time.Duration) (*Lock, error) {
+	var res *Lock
+	err := update(fileID, ttl, func(current *Lock, now time.Time) (*Lock, error) {
+		res = current
+		if current == nil || current.Token != token {
+			return nil, ErrNoSuchLock
+		}
+
+		return res, nil
+	})
+
+	return res, err
+}
+
+// Replace 将标识为 oldToken 的锁替换为新锁，锁标识不匹配时返回 ErrNoSuchLock 及当前的锁
+func Replace(oldToken string, l Lock, ttl time.Duration) (*Lock, error) {
+	var res *Lock
+	err := update(l.FileID, ttl, func(current *Lock, now time.Time) (*Lock, error) {
+		if current == nil || current.Token != oldToken {
+			res = current
+			return nil, ErrNoSuchLock
+		}
+
+		res = &l
+		res.CreatedAt = now
+		return res, nil
+	})
+	if err != nil {
+		return res, err
+	}
+
+	return res, addUserLock(res.UserID, res.FileID)
+}
+
+// Release 解除文件锁，锁标识不匹配时返回 ErrNoSuchLock 及当前的锁
+func Release(fileID uint, token string) (*Lock, error) {
+	var res *Lock
+	err := update(fileID, 0, func(current *Lock, now time.Time) (*Lock, error) {
+		res = current
+		if current == nil || current.Token != token {
+			return nil, ErrNoSuchLock
+		}
+
+		return nil, nil
+	})
+	if err != nil {
+		return res, err
+	}
+
+	return res, removeUserLock(res.UserID, fileID)
+}
+
+// Break 强制解除用户自己持有的文件锁
+func Break(uid, fileID uint) error {
+	err := update(fileID, 0, func(current *Lock, now time.Time) (*Lock, error) {
+		if current == nil {
+			return nil, ErrNoSuchLock
+		}
+
+		if current.UserID != uid {
+			return nil, ErrNotOwner
+		}
+
+		return nil, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return removeUserLock(uid, fileID)
+}
+
+// Check 检查是否可以修改文件，文件未被锁定或持有 tokens 中的任一锁标识时返回 nil，
+// 否则返回 ErrLocked 及当前的锁
+func Check(fileID uint, tokens ...string) (*Lock, error) {
+	current, ok := Get(fileID)
+	if !ok {
+		return nil, nil
+	}
+
+	for _, token := range tokens {
+		if token != "" && token == current.Token {
+			return current, nil
+		}
+	}
+
+	return current, ErrLocked
+}
+
+// ListByUser 列出用户持有的有效文件锁
+func ListByUser(uid uint) []Lock {
+	now := time.Now()
+	locks := make([]Lock, 0)
+	for _, id := range userLockIDs(uid) {
+		if l, ok := get(id, now); ok && l.UserID == uid {
+			locks = append(locks, *l)
+		}
+	}
+
+	return locks
+}
+
+func userLockIDs(uid uint) []uint {
+	if value, ok := cache.Get(userLocksKey(uid)); ok {
+		if ids, ok := value.([]uint); ok {
+			return ids
+		}
+	}
+
+	return nil
+}
+
+// addUserLock 将文件加入持有者的锁索引
+func addUserLock(uid, fileID uint) error {
+	return updateUserLocks(uid, func(ids []uint) []uint {
+		for _, id := range ids {
+			if id == fileID {
+				return ids
+			}
+		}
+
+		return append(ids, fileID)
+	})
+}
+
+// removeUserLock 将文件从持有者的锁索引中移除
+func removeUserLock(uid, fileID uint) error {
+	return updateUserLocks(uid, func(ids []uint) []uint {
+		remain := make([]uint, 0, len(ids))
+		for _, id := range ids {
+			if id != fileID {
+				remain = append(remain, id)
+			}
+		}
+
+		return remain
+	})
+}
+
+// updateUserLocks 原子地更新用户持有的锁索引，同时清理已失效的锁
+func updateUserLocks(uid uint, fn func(ids []uint) []uint) error {
+	return cache.Update(userLocksKey(uid), func(value interface{}, ok bool) (interface{}, int, error) {
+		ids, _ := value.([]uint)
+		ids = fn(append([]uint{}, ids...))
+
+		now := time.Now()
+		valid := make([]uint, 0, len(ids))
+		for _, id := range ids {
+			if _, ok := get(id, now); ok {
+				valid = append(valid, id)
+			}
+		}
+
+		if len(valid) == 0 {
+			return nil, 0, nil
+		}
+
+		return valid, int(MaxTTL.Seconds()), nil
+	})
+}
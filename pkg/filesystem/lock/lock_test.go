@@ -0,0 +1,146 @@
+package lock
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Jaylenwa/Vfoy/pkg/cache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcquire(t *testing.T) {
+	a := assert.New(t)
+	cache.Store = cache.NewMemoStore()
+
+	// 首次加锁
+	l, err := Acquire(Lock{FileID: 1, UserID: 1, Token: "a", App: AppWOPI}, time.Minute)
+	a.NoError(err)
+	a.Equal("a", l.Token)
+	a.False(l.CreatedAt.IsZero())
+
+	// 相同标识刷新
+	l, err = Acquire(Lock{FileID: 1, UserID: 1, Token: "a", App: AppWOPI}, time.Hour)
+	a.NoError(err)
+	a.True(l.ExpiresAt.After(time.Now().Add(time.Minute)))
+
+	// 其他标识加锁失败，返回当前的锁
+	l, err = Acquire(Lock{FileID: 1, UserID: 2, Token: "b", App: AppWebDAV}, time.Minute)
+	a.ErrorIs(err, ErrLocked)
+	a.Equal("a", l.Token)
+
+	// 超出最长有效期
+	l, err = Acquire(Lock{FileID: 2, UserID: 1, Token: "c"}, 0)
+	a.NoError(err)
+	a.WithinDuration(time.Now().Add(MaxTTL), l.ExpiresAt, time.Second)
+}
+
+func TestAcquire_Concurrent(t *testing.T) {
+	a := assert.New(t)
+	cache.Store = cache.NewMemoStore()
+
+	// 并发加锁时只有一个持有者成功
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		acquired int
+	)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := Acquire(Lock{FileID: 1, UserID: 1, Token: fmt.Sprint(i)}, time.Minute); err == nil {
+				mu.Lock()
+				acquired++
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+	a.Equal(1, acquired)
+}
+
+func TestRefreshAndRelease(t *testing.T) {
+	a := assert.New(t)
+	cache.Store = cache.NewMemoStore()
+
+	// 未加锁
+	_, err := Refresh(1, "a", time.Minute)
+	a.ErrorIs(err, ErrNoSuchLock)
+	_, err = Release(1, "a")
+	a.ErrorIs(err, ErrNoSuchLock)
+
+	_, err = Acquire(Lock{FileID: 1, UserID: 1, Token: "a"}, time.Minute)
+	a.NoError(err)
+
+	// 标识不匹配
+	l, err := Refresh(1, "b", time.Minute)
+	a.ErrorIs(err, ErrNoSuchLock)
+	a.Equal("a", l.Token)
+	l, err = Release(1, "b")
+	a.ErrorIs(err, ErrNoSuchLock)
+	a.Equal("a", l.Token)
+
+	// 成功
+	_, err = Refresh(1, "a", time.Hour)
+	a.NoError(err)
+
+	// 替换锁
+	_, err = Replace("b", Lock{FileID: 1, UserID: 1, Token: "c"}, time.Minute)
+	a.ErrorIs(err, ErrNoSuchLock)
+	l, err = Replace("a", Lock{FileID: 1, UserID: 1, Token: "b"}, time.Minute)
+	a.NoError(err)
+	a.Equal("b", l.Token)
+	a.Len(ListByUser(1), 1)
+
+	_, err = Release(1, "b")
+	a.NoError(err)
+	_, ok := Get(1)
+	a.False(ok)
+	a.Empty(ListByUser(1))
+}
+
+func TestCheck(t *testing.T) {
+	a := assert.New(t)
+	cache.Store = cache.NewMemoStore()
+
+	// 未加锁
+	l, err := Check(1)
+	a.NoError(err)
+	a.Nil(l)
+
+	_, err = Acquire(Lock{FileID: 1, UserID: 1, Token: "a"}, time.Minute)
+	a.NoError(err)
+
+	_, err = Check(1)
+	a.ErrorIs(err, ErrLocked)
+	_, err = Check(1, "", "b")
+	a.ErrorIs(err, ErrLocked)
+	_, err = Check(1, "b", "a")
+	a.NoError(err)
+}
+
+func TestListByUserAndBreak(t *testing.T) {
+	a := assert.New(t)
+	cache.Store = cache.NewMemoStore()
+
+	_, err := Acquire(Lock{FileID: 1, UserID: 1, Token: "a"}, time.Minute)
+	a.NoError(err)
+	_, err = Acquire(Lock{FileID: 2, UserID: 1, Token: "b"}, time.Minute)
+	a.NoError(err)
+	_, err = Acquire(Lock{FileID: 3, UserID: 2, Token: "c"}, time.Minute)
+	a.NoError(err)
+
+	a.Len(ListByUser(1), 2)
+	a.Len(ListByUser(2), 1)
+
+	// 不能解除他人的锁
+	a.ErrorIs(Break(1, 3), ErrNotOwner)
+	a.ErrorIs(Break(1, 4), ErrNoSuchLock)
+
+	a.NoError(Break(1, 1))
+	locks := ListByUser(1)
+	a.Len(locks, 1)
+	a.EqualValues(2, locks[0].FileID)
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"path"
 	"strings"
+	"time"
 
 	model "github.com/Jaylenwa/Vfoy/models"
 	"github.com/Jaylenwa/Vfoy/pkg/filesystem/fsctx"
@@ -36,6 +37,9 @@ func (fs *FileSystem) Rename(ctx context.Context, dir, file []uint, new string)
 		if err != nil {
 			return ErrFileExisted
 		}
+
+		fileObject[0].Name = new
+		fs.journal(fileChange(model.ChangeRename, &fileObject[0]))
 		return nil
 	}
 
@@ -49,6 +53,9 @@ func (fs *FileSystem) Rename(ctx context.Context, dir, file []uint, new string)
 		if err != nil {
 			return ErrFileExisted
 		}
+
+		folderObject[0].Name = new
+		fs.journal(folderChange(model.ChangeRename, &folderObject[0]))
 		return nil
 	}
 
@@ -69,32 +76,71 @@ func (fs *FileSystem) Copy(ctx context.Context, dirs, files []uint, src, dst str
 	// 记录复制的文件的总容量
 	var newUsedStorage uint64
 
+	// 检查目标目录的配额
+	quotas, err := model.GetFolderQuotasByOwner(fs.User.ID)
+	if err != nil {
+		return ErrDBListObjects.WithError(err)
+	}
+
+	dstQuotas, err := fs.quotasOf(quotas, dstFolder.ID)
+	if err != nil {
+		return ErrDBListObjects.WithError(err)
+	}
+
+	var usage model.FolderUsage
+	if len(dstQuotas) > 0 {
+		usage, err = objectsUsage(fs.User.ID, dirs, files, true)
+		if err != nil {
+			return err
+		}
+
+		for i := range dstQuotas {
+			if !dstQuotas[i].Allows(usage) {
+				return ErrFolderQuotaExceeded
+			}
+		}
+	}
+
 	// 设置webdav目标名
 	if dstName, ok := ctx.Value(fsctx.WebdavDstName).(string); ok {
 		dstFolder.WebdavDstName = dstName
 	}
 
 	// 复制目录
+	var (
+		copiedFolders []model.Folder
+		copiedFiles   []model.File
+	)
 	if len(dirs) > 0 {
-		subFileSizes, err := srcFolder.CopyFolderTo(dirs[0], dstFolder)
+		subFileSizes, folders, subFiles, err := srcFolder.CopyFolderTo(dirs[0], dstFolder)
 		if err != nil {
 			return ErrObjectNotExist.WithError(err)
 		}
 		newUsedStorage += subFileSizes
+		copiedFolders = append(copiedFolders, folders...)
+		copiedFiles = append(copiedFiles, subFiles...)
 	}
 
 	// 复制文件
 	if len(files) > 0 {
-		subFileSizes, err := srcFolder.MoveOrCopyFileTo(files, dstFolder, true)
+		subFileSizes, subFiles, err := srcFolder.MoveOrCopyFileTo(files, dstFolder, true)
 		if err != nil {
 			return ErrObjectNotExist.WithError(err)
 		}
 		newUsedStorage += subFileSizes
+		copiedFiles = append(copiedFiles, subFiles...)
 	}
 
 	// 扣除容量
 	fs.User.IncreaseStorageWithoutCheck(newUsedStorage)
 
+	// 更新目标目录配额的已用量
+	if err := model.ChangeFolderQuotaUsage(quotaFolderIDs(dstQuotas), "+", usage); err != nil {
+		util.Log().Warning("Failed to update usage of folder quotas: %s", err)
+	}
+
+	fs.journal(createChanges(copiedFolders, copiedFiles)...)
+
 	return nil
 }
 
@@ -108,38 +154,59 @@ func (fs *FileSystem) Move(ctx context.Context, dirs, files []uint, src, dst str
 		return ErrPathNotExist
 	}
 
+	// 检查新进入的目录配额
+	addedQuotas, removedQuotas, err := fs.quotaDiff(srcFolder.ID, dstFolder.ID)
+	if err != nil {
+		return ErrDBListObjects.WithError(err)
+	}
+
+	var usage model.FolderUsage
+	if len(addedQuotas) > 0 || len(removedQuotas) > 0 {
+		usage, err = objectsUsage(fs.User.ID, dirs, files, false)
+		if err != nil {
+			return err
+		}
+
+		for i := range addedQuotas {
+			if !addedQuotas[i].Allows(usage) {
+				return ErrFolderQuotaExceeded
+			}
+		}
+	}
+
 	// 设置webdav目标名
 	if dstName, ok := ctx.Value(fsctx.WebdavDstName).(string); ok {
 		dstFolder.WebdavDstName = dstName
 	}
 
 	// 处理目录及子文件移动
-	err := srcFolder.MoveFolderTo(dirs, dstFolder)
+	err = srcFolder.MoveFolderTo(dirs, dstFolder)
 	if err != nil {
 		return ErrFileExisted.WithError(err)
 	}
 
 	// 处理文件移动
-	_, err = srcFolder.MoveOrCopyFileTo(files, dstFolder, false)
+	_, _, err = srcFolder.MoveOrCopyFileTo(files, dstFolder, false)
 	if err != nil {
 		return ErrFileExisted.WithError(err)
 	}
 
-	// 移动文件
+	// 更新目录配额的已用量
+	if err := model.ChangeFolderQuotaUsage(quotaFolderIDs(addedQuotas), "+", usage); err != nil {
+		util.Log().Warning("Failed to update usage of folder quotas: %s", err)
+	}
+	if err := model.ChangeFolderQuotaUsage(quotaFolderIDs(removedQuotas), "-", usage); err != nil {
+		util.Log().Warning("Failed to update usage of folder quotas: %s", err)
+	}
+
+	fs.journal(fs.moveChanges(dirs, files, dstFolder)...)
 
-	return err
+	return nil
 }
 
 // Delete 递归删除对象, force 为 true 时强制删除文件记录，忽略物理删除是否成功;
 // unlink 为 true 时只删除虚拟文件系统的文件记录，不删除物理文件。
 func (fs *FileSystem) Delete(ctx context.Context, dirs, files []uint, force, unlink bool) error {
-	// 已删除的文件ID
-	var deletedFiles = make([]*model.File, 0, len(fs.FileTarget))
-	// 删除失败的文件的父目录ID
-
-	// 所有文件的ID
-	var allFiles = make([]*model.File, 0, len(fs.FileTarget))
-
 	// 列出要删除的目录
 	if len(dirs) > 0 {
 		err := fs.ListDeleteDirs(ctx, dirs)
@@ -156,12 +223,29 @@ func (fs *FileSystem) Delete(ctx context.Context, dirs, files []uint, force, unl
 		}
 	}
 
+	return fs.deleteTargets(ctx, force, unlink)
+}
+
+// deleteTargets 删除当前设定的目标目录和文件
+func (fs *FileSystem) deleteTargets(ctx context.Context, force, unlink bool) error {
+	// 已删除的文件ID
+	var deletedFiles = make([]*model.File, 0, len(fs.FileTarget))
+
+	// 所有文件的ID
+	var allFiles = make([]*model.File, 0, len(fs.FileTarget))
+
 	// 去除待删除文件中包含软连接的部分
 	filesToBeDelete, err := model.RemoveFilesWithSoftLinks(fs.FileTarget)
 	if err != nil {
 		return ErrDBListObjects.WithError(err)
 	}
 
+	// 去除物理文件仍被历史版本引用的部分
+	filesToBeDelete, err = model.RemoveFilesReferencedByVersions(filesToBeDelete, nil)
+	if err != nil {
+		return ErrDBListObjects.WithError(err)
+	}
+
 	// 根据存储策略将文件分组
 	policyGroup := fs.GroupFileByPolicy(ctx, filesToBeDelete)
 
@@ -193,6 +277,15 @@ func (fs *FileSystem) Delete(ctx context.Context, dirs, files []uint, force, unl
 		return ErrDBDeleteObjects.WithError(err)
 	}
 
+	// 归还目录配额，回收站中的文件已在移入时归还
+	usages := make(map[uint]model.FolderUsage)
+	for _, file := range deletedFiles {
+		if file.DeletedAt == nil {
+			usages[file.FolderID] = usages[file.FolderID].Add(model.FolderUsage{Size: file.Size, Files: 1})
+		}
+	}
+	fs.changeFolderUsage("-", usages)
+
 	// 删除文件记录对应的分享记录
 	// TODO 先取消分享再删除文件
 	deletedFileIDs := make([]uint, len(deletedFiles))
@@ -202,7 +295,24 @@ func (fs *FileSystem) Delete(ctx context.Context, dirs, files []uint, force, unl
 
 	model.DeleteShareBySourceIDs(deletedFileIDs, false)
 
+	// 删除文件的全文索引
+	if err := model.DeleteSearchIndexByFileIDs(deletedFileIDs); err != nil {
+		util.Log().Warning("Failed to delete content index of deleted files: %s", err)
+	}
+
+	// 删除文件的收藏和最近文件记录
+	if err := model.DeleteStarsByObjects(false, deletedFileIDs); err != nil {
+		util.Log().Warning("Failed to delete stars of deleted files: %s", err)
+	}
+	if err := model.DeleteRecentFilesByFileIDs(deletedFileIDs); err != nil {
+		util.Log().Warning("Failed to delete recent records of deleted files: %s", err)
+	}
+
+	// 删除文件的历史版本
+	fs.deleteVersionsOfFiles(ctx, deletedFiles)
+
 	// 如果文件全部删除成功，继续删除目录
+	deletedFolders := make([]model.Folder, 0)
 	if len(deletedFiles) == len(allFiles) {
 		var allFolderIDs = make([]uint, 0, len(fs.DirTarget))
 		for _, value := range fs.DirTarget {
@@ -213,10 +323,23 @@ func (fs *FileSystem) Delete(ctx context.Context, dirs, files []uint, force, unl
 			return ErrDBDeleteObjects.WithError(err)
 		}
 
+		// 删除目录上的配额
+		if err := model.DeleteFolderQuotasByFolderIDs(allFolderIDs); err != nil {
+			util.Log().Warning("Failed to delete quotas of deleted folders: %s", err)
+		}
+
+		// 删除目录的收藏
+		if err := model.DeleteStarsByObjects(true, allFolderIDs); err != nil {
+			util.Log().Warning("Failed to delete stars of deleted folders: %s", err)
+		}
+
 		// 删除目录记录对应的分享记录
 		model.DeleteShareBySourceIDs(allFolderIDs, true)
+		deletedFolders = fs.DirTarget
 	}
 
+	fs.journal(topLevelChanges(model.ChangeDelete, deletedFolders, deletedFiles)...)
+
 	if notDeleted := len(fs.FileTarget) - len(deletedFiles); notDeleted > 0 {
 		return serializer.NewError(
 			serializer.CodeNotFullySuccess,
@@ -288,7 +411,9 @@ func (fs *FileSystem) List(ctx context.Context, dirPath string, pathProcessor fu
 	// 获取子文件
 	childFiles, _ = folder.GetChildFiles()
 
-	return fs.listObjects(ctx, parentPath, childFiles, childFolders, pathProcessor), nil
+	objects := fs.listObjects(ctx, parentPath, childFiles, childFolders, pathProcessor)
+	attachFolderQuotas(objects, childFolders)
+	return objects, nil
 }
 
 // ListPhysical 列出存储策略中的外部目录
@@ -347,7 +472,7 @@ func (fs *FileSystem) listObjects(ctx context.Context, parent string, files []mo
 			}
 		}
 
-		objects = append(objects, serializer.Object{
+		newFolder := serializer.Object{
 			ID:         hashid.HashID(subFolder.ID, hashid.FolderID),
 			Name:       subFolder.Name,
 			Path:       processedPath,
@@ -355,7 +480,12 @@ func (fs *FileSystem) listObjects(ctx context.Context, parent string, files []mo
 			Type:       "dir",
 			Date:       subFolder.UpdatedAt,
 			CreateDate: subFolder.CreatedAt,
-		})
+		}
+		// 用户元数据不对分享访问者公开
+		if shareKey == "" {
+			newFolder.Metadata = listMetadata(subFolder.MetadataSerialized)
+		}
+		objects = append(objects, newFolder)
 	}
 
 	for _, file := range files {
@@ -381,6 +511,8 @@ func (fs *FileSystem) listObjects(ctx context.Context, parent string, files []mo
 			}
 			if shareKey != "" {
 				newFile.Key = shareKey
+			} else {
+				newFile.Metadata = listMetadata(file.MetadataSerialized)
 			}
 			objects = append(objects, newFile)
 		}
@@ -389,6 +521,15 @@ func (fs *FileSystem) listObjects(ctx context.Context, parent string, files []mo
 	return objects
 }
 
+// listMetadata 返回列表中展示的用户元数据，没有时为 nil
+func listMetadata(metadata map[string]string) map[string]string {
+	if res := model.UserMetadata(metadata); len(res) > 0 {
+		return res
+	}
+
+	return nil
+}
+
 // CreateDirectory 根据给定的完整创建目录，支持递归创建。如果目录已存在，则直接
 // 返回已存在的目录。
 func (fs *FileSystem) CreateDirectory(ctx context.Context, fullPath string) (*model.Folder, error) {
@@ -437,12 +578,18 @@ func (fs *FileSystem) CreateDirectory(ctx context.Context, fullPath string) (*mo
 		ParentID: &parent.ID,
 		OwnerID:  fs.User.ID,
 	}
+	start := time.Now()
 	_, err := newFolder.Create()
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to create folder: %w", err)
 	}
 
+	// 已存在的目录会被直接返回，其创建时间早于本次调用
+	if !newFolder.CreatedAt.Before(start) {
+		fs.journal(folderChange(model.ChangeCreate, &newFolder))
+	}
+
 	return &newFolder, nil
 }
 
@@ -456,17 +603,50 @@ func (fs *FileSystem) SaveTo(ctx context.Context, path string) error {
 
 	var (
 		totalSize uint64
+		usage     model.FolderUsage
 		err       error
 	)
 
+	// 检查目标目录的配额
+	quotas, err := model.GetFolderQuotasByOwner(fs.User.ID)
+	if err != nil {
+		return ErrDBListObjects.WithError(err)
+	}
+
+	dstQuotas, err := fs.quotasOf(quotas, folder.ID)
+	if err != nil {
+		return ErrDBListObjects.WithError(err)
+	}
+
+	if len(dstQuotas) > 0 {
+		if len(fs.DirTarget) > 0 {
+			usage, err = objectsUsage(fs.DirTarget[0].OwnerID, []uint{fs.DirTarget[0].ID}, nil, true)
+		} else {
+			usage, err = objectsUsage(fs.FileTarget[0].UserID, nil, []uint{fs.FileTarget[0].ID}, true)
+		}
+		if err != nil {
+			return err
+		}
+
+		for i := range dstQuotas {
+			if !dstQuotas[i].Allows(usage) {
+				return ErrFolderQuotaExceeded
+			}
+		}
+	}
+
+	var (
+		copiedFolders []model.Folder
+		copiedFiles   []model.File
+	)
 	if len(fs.DirTarget) > 0 {
-		totalSize, err = fs.DirTarget[0].CopyFolderTo(fs.DirTarget[0].ID, folder)
+		totalSize, copiedFolders, copiedFiles, err = fs.DirTarget[0].CopyFolderTo(fs.DirTarget[0].ID, folder)
 	} else {
 		parent := model.Folder{
 			OwnerID: fs.FileTarget[0].UserID,
 		}
 		parent.ID = fs.FileTarget[0].FolderID
-		totalSize, err = parent.MoveOrCopyFileTo([]uint{fs.FileTarget[0].ID}, folder, true)
+		totalSize, copiedFiles, err = parent.MoveOrCopyFileTo([]uint{fs.FileTarget[0].ID}, folder, true)
 	}
 
 	// 扣除用户容量
@@ -475,5 +655,12 @@ func (fs *FileSystem) SaveTo(ctx context.Context, path string) error {
 		return ErrFileExisted.WithError(err)
 	}
 
+	// 更新目标目录配额的已用量
+	if err := model.ChangeFolderQuotaUsage(quotaFolderIDs(dstQuotas), "+", usage); err != nil {
+		util.Log().Warning("Failed to update usage of folder quotas: %s", err)
+	}
+
+	fs.journal(createChanges(copiedFolders, copiedFiles)...)
+
 	return nil
 }
@@ -478,6 +478,9 @@ func TestFileSystem_Delete(t *testing.T) {
 			WillReturnRows(sqlmock.NewRows([]string{"id", "policy_id", "source_name"}))
 		mock.ExpectQuery("SELECT(.+)files(.+)").
 			WillReturnRows(sqlmock.NewRows([]string{"id", "policy_id", "source_name"}))
+		// 查询引用物理文件的历史版本
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "policy_id", "source_name"}))
 		// 查询上传策略
 		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id", "type"}).AddRow(365, "local"))
 		// 删除文件记录
@@ -487,16 +490,47 @@ func TestFileSystem_Delete(t *testing.T) {
 		mock.ExpectExec("DELETE(.+)").
 			WillReturnResult(sqlmock.NewResult(0, 1))
 		mock.ExpectCommit()
+		// 归还目录配额
+		mock.ExpectQuery("SELECT(.+)folder_quota(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
 		// 删除对应分享
 		mock.ExpectBegin()
 		mock.ExpectExec("UPDATE(.+)shares").
 			WillReturnResult(sqlmock.NewResult(0, 3))
 		mock.ExpectCommit()
+		// 删除全文索引
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)search_ind(.+)").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("DELETE(.+)search_terms(.+)").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		// 删除收藏和最近文件记录
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)stars(.+)").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)recent_files(.+)").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+		// 查询文件的历史版本
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "file_id"}))
 		// 删除目录
 		mock.ExpectBegin()
 		mock.ExpectExec("DELETE(.+)").
 			WillReturnResult(sqlmock.NewResult(0, 3))
 		mock.ExpectCommit()
+		// 删除目录配额
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)folder_quota(.+)").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+		// 删除目录的收藏
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)stars(.+)").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
 		// 删除对应分享
 		mock.ExpectBegin()
 		mock.ExpectExec("UPDATE(.+)shares").
@@ -535,6 +569,9 @@ func TestFileSystem_Delete(t *testing.T) {
 			WillReturnRows(sqlmock.NewRows([]string{"id", "policy_id", "source_name"}))
 		mock.ExpectQuery("SELECT(.+)files(.+)").
 			WillReturnRows(sqlmock.NewRows([]string{"id", "policy_id", "source_name"}))
+		// 查询引用物理文件的历史版本
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "policy_id", "source_name"}))
 		// 查询上传策略
 		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id", "type"}).AddRow(602, "local"))
 		// 删除文件记录
@@ -544,16 +581,47 @@ func TestFileSystem_Delete(t *testing.T) {
 		mock.ExpectExec("DELETE(.+)").
 			WillReturnResult(sqlmock.NewResult(0, 1))
 		mock.ExpectCommit()
+		// 归还目录配额
+		mock.ExpectQuery("SELECT(.+)folder_quota(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
 		// 删除对应分享
 		mock.ExpectBegin()
 		mock.ExpectExec("UPDATE(.+)shares").
 			WillReturnResult(sqlmock.NewResult(0, 3))
 		mock.ExpectCommit()
+		// 删除全文索引
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)search_ind(.+)").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("DELETE(.+)search_terms(.+)").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		// 删除收藏和最近文件记录
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)stars(.+)").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)recent_files(.+)").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+		// 查询文件的历史版本
+		mock.ExpectQuery("SELECT(.+)file_versions(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "file_id"}))
 		// 删除目录
 		mock.ExpectBegin()
 		mock.ExpectExec("DELETE(.+)").
 			WillReturnResult(sqlmock.NewResult(0, 3))
 		mock.ExpectCommit()
+		// 删除目录配额
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)folder_quota(.+)").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+		// 删除目录的收藏
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)stars(.+)").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
 		// 删除对应分享
 		mock.ExpectBegin()
 		mock.ExpectExec("UPDATE(.+)shares").
@@ -0,0 +1,64 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+
+	model "github.com/Jaylenwa/Vfoy/models"
+	"github.com/Jaylenwa/Vfoy/pkg/serializer"
+)
+
+/* ================
+	 用户自定义元数据
+   ================
+*/
+
+// UpdateMetadata 设置和删除文件或目录的用户自定义元数据，返回更新后的用户元数据
+func (fs *FileSystem) UpdateMetadata(ctx context.Context, id uint, isFolder bool, set map[string]string, remove []string) (map[string]string, error) {
+	var (
+		metadata map[string]string
+		change   model.Change
+	)
+
+	if isFolder {
+		folders, err := model.GetFoldersByIDs([]uint{id}, fs.User.ID)
+		if err != nil || len(folders) == 0 {
+			return nil, ErrObjectNotExist
+		}
+
+		err = folders[0].UpdateUserMetadata(set, remove)
+		if err != nil {
+			return nil, metadataErr(err)
+		}
+
+		metadata = folders[0].MetadataSerialized
+		change = folderChange(model.ChangeUpdate, &folders[0])
+	} else {
+		files, err := model.GetFilesByIDs([]uint{id}, fs.User.ID)
+		if err != nil || len(files) == 0 || files[0].UploadSessionID != nil {
+			return nil, ErrObjectNotExist
+		}
+
+		err = files[0].UpdateUserMetadata(set, remove)
+		if err != nil {
+			return nil, metadataErr(err)
+		}
+
+		metadata = files[0].MetadataSerialized
+		change = fileChange(model.ChangeUpdate, &files[0])
+	}
+
+	fs.journal(change)
+	return model.UserMetadata(metadata), nil
+}
+
+// metadataErr 区分非法的元数据与数据库错误
+func metadataErr(err error) error {
+	if errors.Is(err, model.ErrInvalidMetadataKey) ||
+		errors.Is(err, model.ErrMetadataValueTooLong) ||
+		errors.Is(err, model.ErrTooManyUserMetadata) {
+		return ErrInvalidMetadata.WithError(err)
+	}
+
+	return serializer.NewError(serializer.CodeDBError, "Failed to update metadata", err)
+}
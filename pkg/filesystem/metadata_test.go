@@ -0,0 +1,63 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/Jaylenwa/Vfoy/models"
+	"github.com/Jaylenwa/Vfoy/pkg/conf"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSystem_UpdateMetadata(t *testing.T) {
+	a := assert.New(t)
+	ctx := context.Background()
+	fs := &FileSystem{User: &model.User{}}
+	fs.User.ID = 1
+	conf.SystemConfig.Mode = "slave"
+	defer func() { conf.SystemConfig.Mode = "master" }()
+
+	// 文件不存在
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		_, err := fs.UpdateMetadata(ctx, 1, false, map[string]string{"k": "v"}, nil)
+		a.NoError(mock.ExpectationsWereMet())
+		a.ErrorIs(err, ErrObjectNotExist)
+	}
+
+	// 非法的键
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		_, err := fs.UpdateMetadata(ctx, 1, false, map[string]string{"bad key": "v"}, nil)
+		a.NoError(mock.ExpectationsWereMet())
+		a.ErrorIs(err, ErrInvalidMetadata)
+	}
+
+	// 数据库错误
+	{
+		mock.ExpectQuery("SELECT(.+)folders(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)folders(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		_, err := fs.UpdateMetadata(ctx, 2, true, map[string]string{"k": "v"}, nil)
+		a.NoError(mock.ExpectationsWereMet())
+		a.Error(err)
+		a.NotErrorIs(err, ErrInvalidMetadata)
+	}
+
+	// 成功
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnRows(
+			sqlmock.NewRows([]string{"id", "metadata"}).AddRow(1, `{"thumb_status":"not_available","user.old":"1"}`),
+		)
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		res, err := fs.UpdateMetadata(ctx, 1, false, map[string]string{"k": "v"}, []string{"old"})
+		a.NoError(mock.ExpectationsWereMet())
+		a.NoError(err)
+		a.Equal(map[string]string{"k": "v"}, res)
+	}
+}
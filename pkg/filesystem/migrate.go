@@ -0,0 +1,158 @@
+package filesystem
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path"
+
+	model "github.com/Jaylenwa/Vfoy/models"
+	"github.com/Jaylenwa/Vfoy/pkg/filesystem/driver"
+	"github.com/Jaylenwa/Vfoy/pkg/filesystem/fsctx"
+	"github.com/Jaylenwa/Vfoy/pkg/util"
+)
+
+/* ================
+	 存储策略迁移
+   ================
+*/
+
+// MigrateFile 将文件的物理文件复制到 dst 存储策略，校验内容一致后更新所有引用此物理文件的
+// 记录，再删除原物理文件。virtualPath 为文件所在目录的路径，用于生成新的存储路径
+func (fs *FileSystem) MigrateFile(ctx context.Context, file *model.File, dst *model.Policy, virtualPath string) error {
+	src := file.GetPolicy()
+	if src.ID == dst.ID {
+		return nil
+	}
+
+	srcHandler, err := fs.handlerOf(src)
+	if err != nil {
+		return err
+	}
+
+	dstHandler, err := fs.handlerOf(dst)
+	if err != nil {
+		return err
+	}
+
+	savePath := path.Join(
+		dst.GeneratePath(file.UserID, virtualPath),
+		dst.GenerateFileName(file.UserID, file.Name),
+	)
+
+	rs, err := srcHandler.Get(ctx, file.SourceName)
+	if err != nil {
+		return ErrIO.WithError(err)
+	}
+
+	stream := &fsctx.FileStream{
+		File:        rs,
+		Seeker:      rs,
+		Size:        file.Size,
+		Name:        file.Name,
+		VirtualPath: virtualPath,
+		SavePath:    savePath,
+	}
+	hashing := newHashingStream(stream)
+	err = dstHandler.Put(ctx, stream)
+	hashing.finish()
+	if err != nil {
+		return ErrIO.WithError(err)
+	}
+
+	// 校验迁移后的文件，失败时清理已上传的文件
+	if err := verifyCopiedFile(ctx, srcHandler, dstHandler, savePath, file, stream.Hash); err != nil {
+		if _, deleteErr := dstHandler.Delete(ctx, []string{savePath}); deleteErr != nil {
+			util.Log().Warning("Failed to delete migrated object %q: %s", savePath, deleteErr)
+		}
+		return err
+	}
+
+	if err := model.MigrateFileObject(src.ID, file.SourceName, dst.ID, savePath); err != nil {
+		if _, deleteErr := dstHandler.Delete(ctx, []string{savePath}); deleteErr != nil {
+			util.Log().Warning("Failed to delete migrated object %q: %s", savePath, deleteErr)
+		}
+		return ErrInsertFileRecord.WithError(err)
+	}
+
+	// 旧存储策略上的物理文件及缩略图已不再被引用
+	oldObjects := []string{file.SourceName}
+	if file.MetadataSerialized[model.ThumbSidecarMetadataKey] == "true" {
+		oldObjects = append(oldObjects, file.ThumbFile())
+	}
+	if failed, err := srcHandler.Delete(ctx, oldObjects); err != nil {
+		util.Log().Warning("Failed to delete migrated source objects %v: %s", failed, err)
+	}
+	fs.deleteReplicas(ctx, src, []string{file.SourceName})
+
+	if file.MetadataSerialized[model.ThumbStatusMetadataKey] == model.ThumbStatusExist {
+		if err := updateThumbStatus(file, model.ThumbStatusNotExist); err != nil {
+			util.Log().Debug("Failed to reset thumb status of file %d: %s", file.ID, err)
+		}
+	}
+
+	file.PolicyID = dst.ID
+	file.Policy = *dst
+	file.SourceName = savePath
+	return nil
+}
+
+// handlerOf 返回存储策略对应的适配器
+func (fs *FileSystem) handlerOf(policy *model.Policy) (driver.Handler, error) {
+	fs.Policy = policy
+	if err := fs.DispatchHandler(); err != nil {
+		return nil, err
+	}
+
+	return fs.Handler, nil
+}
+
+// verifyCopiedFile 检查复制到 dst 的文件与原文件的大小及摘要一致，hash 为上传时计算的原文件摘要，
+// 适配器分段读取导致无法计算时重新读取原文件
+func verifyCopiedFile(ctx context.Context, src, dst driver.Handler, savePath string, file *model.File, hash string) error {
+	var err error
+	if hash == "" {
+		if hash, err = objectHash(ctx, src, file.SourceName, file.Size); err != nil {
+			return err
+		}
+	}
+
+	// 原文件内容与上传时记录的摘要不符
+	if file.Hash != "" && file.Hash != hash {
+		return ErrChecksumMismatch
+	}
+
+	migrated, err := objectHash(ctx, dst, savePath, file.Size)
+	if err != nil {
+		return err
+	}
+
+	if migrated != hash {
+		return ErrChecksumMismatch
+	}
+
+	return nil
+}
+
+// objectHash 读取物理文件并计算 SHA-256 摘要，读取的大小与 size 不符时返回错误
+func objectHash(ctx context.Context, handler driver.Handler, source string, size uint64) (string, error) {
+	rs, err := handler.Get(ctx, source)
+	if err != nil {
+		return "", ErrIO.WithError(err)
+	}
+	defer rs.Close()
+
+	h := sha256.New()
+	read, err := io.Copy(h, rs)
+	if err != nil {
+		return "", ErrIO.WithError(err)
+	}
+
+	if uint64(read) != size {
+		return "", ErrIO.WithError(fmt.Errorf("read %d bytes from %q, expected %d", read, source, size))
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
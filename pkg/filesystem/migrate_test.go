@@ -0,0 +1,102 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/Jaylenwa/Vfoy/models"
+	"github.com/Jaylenwa/Vfoy/pkg/cache"
+	"github.com/Jaylenwa/Vfoy/pkg/util"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSystem_MigrateFile(t *testing.T) {
+	asserts := assert.New(t)
+	cache.Set("setting_thumb_file_suffix", "._thumb", 0)
+	fs := &FileSystem{User: &model.User{Model: gorm.Model{ID: 1}}}
+	ctx := context.Background()
+	src := model.Policy{Model: gorm.Model{ID: 1}, Type: "local"}
+	dst := &model.Policy{Model: gorm.Model{ID: 2}, Type: "local", DirNameRule: "tests/migrated/{uid}{path}"}
+
+	newFile := func(content string) *model.File {
+		f, err := util.CreatNestedFile(util.RelativePath("tests/migrate/1.txt"))
+		asserts.NoError(err)
+		f.WriteString(content)
+		f.Close()
+		return &model.File{
+			Model:      gorm.Model{ID: 1},
+			Name:       "1.txt",
+			UserID:     1,
+			Size:       uint64(len(content)),
+			SourceName: "tests/migrate/1.txt",
+			PolicyID:   1,
+			Policy:     src,
+		}
+	}
+
+	// 已在目标存储策略
+	{
+		file := &model.File{PolicyID: 2, Policy: *dst}
+		asserts.NoError(fs.MigrateFile(ctx, file, dst, "/"))
+	}
+
+	// 原文件不存在
+	{
+		file := &model.File{Name: "1.txt", SourceName: "tests/migrate/not_exist.txt", PolicyID: 1, Policy: src}
+		asserts.ErrorIs(fs.MigrateFile(ctx, file, dst, "/"), ErrIO)
+	}
+
+	// 原文件内容与记录的摘要不符
+	{
+		file := newFile("content")
+		file.Hash = "mismatch"
+		err := fs.MigrateFile(ctx, file, dst, "/dir")
+		asserts.ErrorIs(err, ErrChecksumMismatch)
+		asserts.True(util.Exists(util.RelativePath("tests/migrate/1.txt")))
+		asserts.False(util.Exists(util.RelativePath("tests/migrated/1/dir/1.txt")))
+	}
+
+	// 更新记录失败
+	{
+		file := newFile("content")
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		err := fs.MigrateFile(ctx, file, dst, "/dir")
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.ErrorIs(err, ErrInsertFileRecord)
+		asserts.True(util.Exists(util.RelativePath("tests/migrate/1.txt")))
+		asserts.False(util.Exists(util.RelativePath("tests/migrated/1/dir/1.txt")))
+	}
+
+	// 成功
+	{
+		file := newFile("content")
+		file.Hash = sha256Hex("content")
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)").
+			WithArgs(2, "tests/migrated/1/dir/1.txt", 1, "tests/migrate/1.txt").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE(.+)file_versions(.+)").
+			WithArgs(2, "tests/migrated/1/dir/1.txt", 1, "tests/migrate/1.txt").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+		err := fs.MigrateFile(ctx, file, dst, "/dir")
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.EqualValues(2, file.PolicyID)
+		asserts.Equal("tests/migrated/1/dir/1.txt", file.SourceName)
+		asserts.False(util.Exists(util.RelativePath("tests/migrate/1.txt")))
+
+		content, err := os.ReadFile(util.RelativePath("tests/migrated/1/dir/1.txt"))
+		asserts.NoError(err)
+		asserts.Equal("content", string(content))
+	}
+
+	os.RemoveAll(util.RelativePath("tests/migrate"))
+	os.RemoveAll(util.RelativePath("tests/migrated"))
+}
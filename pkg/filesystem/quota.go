@@ -0,0 +1,367 @@
+package filesystem
+
+import (
+	"context"
+	"path"
+
+	model "github.com/Jaylenwa/Vfoy/models"
+	"github.com/Jaylenwa/Vfoy/pkg/filesystem/fsctx"
+	"github.com/Jaylenwa/Vfoy/pkg/serializer"
+	"github.com/Jaylenwa/Vfoy/pkg/util"
+)
+
+/* ================
+	 目录配额
+   ================
+*/
+
+// SetFolderQuota 设定目录的容量和文件数上限，并重新统计目录的已用量；
+// 两个上限均为 0 时取消目录配额，此时返回 nil
+func (fs *FileSystem) SetFolderQuota(ctx context.Context, folder *model.Folder, maxSize, maxFiles uint64) (*model.FolderQuota, error) {
+	if maxSize == 0 && maxFiles == 0 {
+		if err := model.DeleteFolderQuotasByFolderIDs([]uint{folder.ID}); err != nil {
+			return nil, ErrDBDeleteObjects.WithError(err)
+		}
+		return nil, nil
+	}
+
+	usage, err := objectsUsage(folder.OwnerID, []uint{folder.ID}, nil, false)
+	if err != nil {
+		return nil, err
+	}
+
+	quota, err := model.GetFolderQuotaByFolderID(folder.ID)
+	if err != nil {
+		quota = &model.FolderQuota{
+			FolderID:  folder.ID,
+			OwnerID:   folder.OwnerID,
+			MaxSize:   maxSize,
+			MaxFiles:  maxFiles,
+			UsedSize:  usage.Size,
+			UsedFiles: usage.Files,
+		}
+		if err := quota.Create(); err != nil {
+			return nil, ErrInsertFileRecord.WithError(err)
+		}
+		return quota, nil
+	}
+
+	if err := quota.Update(maxSize, maxFiles, usage); err != nil {
+		return nil, ErrInsertFileRecord.WithError(err)
+	}
+
+	return quota, nil
+}
+
+// CheckFolderQuota 检查向目录中增加 usage 后，目录自身及其上级目录的配额是否足够
+func (fs *FileSystem) CheckFolderQuota(folderID uint, usage model.FolderUsage) error {
+	if usage.IsZero() {
+		return nil
+	}
+
+	quotas, err := model.GetFolderQuotasByOwner(fs.User.ID)
+	if err != nil {
+		return ErrDBListObjects.WithError(err)
+	}
+
+	return fs.checkQuotas(quotas, folderID, usage)
+}
+
+// FolderCapacity 返回目录中可用和已用的容量。默认为用户容量，
+// 目录自身或上级目录设定了容量配额且剩余更少时，以该配额为准
+func (fs *FileSystem) FolderCapacity(folderID uint) (available, used uint64, err error) {
+	available, used = fs.User.GetRemainingCapacity(), fs.User.Storage
+
+	quotas, err := model.GetFolderQuotasByOwner(fs.User.ID)
+	if err != nil {
+		return 0, 0, ErrDBListObjects.WithError(err)
+	}
+
+	affected, err := fs.quotasOf(quotas, folderID)
+	if err != nil {
+		return 0, 0, ErrDBListObjects.WithError(err)
+	}
+
+	for _, quota := range affected {
+		if quota.MaxSize == 0 {
+			continue
+		}
+
+		var remain uint64
+		if quota.MaxSize > quota.UsedSize {
+			remain = quota.MaxSize - quota.UsedSize
+		}
+
+		if remain < available {
+			available, used = remain, quota.UsedSize
+		}
+	}
+
+	return available, used, nil
+}
+
+func (fs *FileSystem) checkQuotas(quotas []model.FolderQuota, folderID uint, usage model.FolderUsage) error {
+	if len(quotas) == 0 {
+		return nil
+	}
+
+	affected, err := fs.quotasOf(quotas, folderID)
+	if err != nil {
+		return ErrDBListObjects.WithError(err)
+	}
+
+	for i := range affected {
+		if !affected[i].Allows(usage) {
+			return ErrFolderQuotaExceeded
+		}
+	}
+
+	return nil
+}
+
+// quotasOf 从用户的全部配额中筛选出设定在目录自身及其上级目录上的配额
+func (fs *FileSystem) quotasOf(quotas []model.FolderQuota, folderID uint) ([]model.FolderQuota, error) {
+	if len(quotas) == 0 {
+		return nil, nil
+	}
+
+	ancestors, err := model.GetAncestorFolderIDs(folderID, fs.User.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	affected := make([]model.FolderQuota, 0, len(quotas))
+	for _, quota := range quotas {
+		if util.ContainsUint(ancestors, quota.FolderID) {
+			affected = append(affected, quota)
+		}
+	}
+
+	return affected, nil
+}
+
+// changeFolderUsage 按文件所在目录增减其自身及上级目录配额的已用量，
+// 对象的增删已经完成，失败时只记录日志
+func (fs *FileSystem) changeFolderUsage(operator string, usages map[uint]model.FolderUsage) {
+	if len(usages) == 0 {
+		return
+	}
+
+	quotas, err := model.GetFolderQuotasByOwner(fs.User.ID)
+	if err != nil {
+		util.Log().Warning("Failed to list folder quotas: %s", err)
+		return
+	}
+
+	if len(quotas) == 0 {
+		return
+	}
+
+	// 按配额所在目录汇总变化量
+	changes := make(map[uint]model.FolderUsage)
+	for folderID, usage := range usages {
+		if usage.IsZero() {
+			continue
+		}
+
+		affected, err := fs.quotasOf(quotas, folderID)
+		if err != nil {
+			util.Log().Warning("Failed to trace quotas of folder %d: %s", folderID, err)
+			continue
+		}
+
+		for _, quota := range affected {
+			changes[quota.FolderID] = changes[quota.FolderID].Add(usage)
+		}
+	}
+
+	for folderID, usage := range changes {
+		if err := model.ChangeFolderQuotaUsage([]uint{folderID}, operator, usage); err != nil {
+			util.Log().Warning("Failed to update usage of folder quota %d: %s", folderID, err)
+		}
+	}
+}
+
+// changeFileSizeUsage 文件大小变化后更新目录配额的已用量
+func (fs *FileSystem) changeFileSizeUsage(folderID uint, oldSize, newSize uint64) {
+	if newSize > oldSize {
+		fs.changeFolderUsage("+", map[uint]model.FolderUsage{folderID: {Size: newSize - oldSize}})
+	} else if newSize < oldSize {
+		fs.changeFolderUsage("-", map[uint]model.FolderUsage{folderID: {Size: oldSize - newSize}})
+	}
+}
+
+// quotaDiff 返回对象从 src 移动至 dst 时新增和离开的配额
+func (fs *FileSystem) quotaDiff(src, dst uint) (added, removed []model.FolderQuota, err error) {
+	quotas, err := model.GetFolderQuotasByOwner(fs.User.ID)
+	if err != nil || len(quotas) == 0 {
+		return nil, nil, err
+	}
+
+	srcQuotas, err := fs.quotasOf(quotas, src)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dstQuotas, err := fs.quotasOf(quotas, dst)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	contains := func(quotas []model.FolderQuota, quota model.FolderQuota) bool {
+		for _, q := range quotas {
+			if q.ID == quota.ID {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, quota := range dstQuotas {
+		if !contains(srcQuotas, quota) {
+			added = append(added, quota)
+		}
+	}
+
+	for _, quota := range srcQuotas {
+		if !contains(dstQuotas, quota) {
+			removed = append(removed, quota)
+		}
+	}
+
+	return added, removed, nil
+}
+
+// objectsUsage 统计目录（包含子目录）及文件的总大小和文件数，copyOnly 为 true 时跳过无法复制的文件
+func objectsUsage(uid uint, dirs, files []uint, copyOnly bool) (model.FolderUsage, error) {
+	var (
+		usage   model.FolderUsage
+		targets []model.File
+	)
+
+	if len(dirs) > 0 {
+		folders, err := model.GetRecursiveChildFolder(dirs, uid, true)
+		if err != nil {
+			return usage, ErrDBListObjects.WithError(err)
+		}
+
+		subFiles, err := model.GetChildFilesOfFolders(&folders)
+		if err != nil {
+			return usage, ErrDBListObjects.WithError(err)
+		}
+		targets = append(targets, subFiles...)
+	}
+
+	if len(files) > 0 {
+		fileObjects, err := model.GetFilesByIDs(files, uid)
+		if err != nil {
+			return usage, ErrDBListObjects.WithError(err)
+		}
+		targets = append(targets, fileObjects...)
+	}
+
+	for _, file := range targets {
+		if copyOnly && !file.CanCopy() {
+			continue
+		}
+
+		usage.Size += file.Size
+		usage.Files++
+	}
+
+	return usage, nil
+}
+
+func quotaFolderIDs(quotas []model.FolderQuota) []uint {
+	ids := make([]uint, len(quotas))
+	for i, quota := range quotas {
+		ids[i] = quota.FolderID
+	}
+	return ids
+}
+
+// closestExistingFolder 查找路径对应的目录，目录不存在时返回最近的已存在的上级目录
+func (fs *FileSystem) closestExistingFolder(dirPath string) (*model.Folder, bool) {
+	for {
+		if exist, folder := fs.IsPathExist(dirPath); exist {
+			return folder, true
+		}
+
+		parent := path.Dir(dirPath)
+		if parent == dirPath {
+			return nil, false
+		}
+		dirPath = parent
+	}
+}
+
+// attachFolderQuotas 为列出的子目录附加其配额信息，objects 中目录需排在最前且与 folders 顺序一致
+func attachFolderQuotas(objects []serializer.Object, folders []model.Folder) {
+	if len(folders) == 0 {
+		return
+	}
+
+	ids := make([]uint, len(folders))
+	for i, folder := range folders {
+		ids[i] = folder.ID
+	}
+
+	quotas, err := model.GetFolderQuotasByFolderIDs(ids)
+	if err != nil {
+		util.Log().Debug("Failed to list folder quotas: %s", err)
+		return
+	}
+
+	quotaByFolder := make(map[uint]*model.FolderQuota, len(quotas))
+	for i := range quotas {
+		quotaByFolder[quotas[i].FolderID] = &quotas[i]
+	}
+
+	for i, folder := range folders {
+		if i < len(objects) {
+			objects[i].Quota = serializer.BuildFolderQuota(quotaByFolder[folder.ID])
+		}
+	}
+}
+
+// HookValidateFolderQuota 验证上传目标目录及其上级目录的配额，
+// 文件记录已经存在（如分片上传）时只计算新增的容量
+func HookValidateFolderQuota(ctx context.Context, fs *FileSystem, file fsctx.FileHeader) error {
+	quotas, err := model.GetFolderQuotasByOwner(fs.User.ID)
+	if err != nil {
+		return ErrDBListObjects.WithError(err)
+	}
+
+	if len(quotas) == 0 {
+		return nil
+	}
+
+	fileInfo := file.Info()
+	usage := model.FolderUsage{Size: fileInfo.Size, Files: 1}
+	if placeholder, ok := fileInfo.Model.(*model.File); ok && placeholder != nil {
+		usage.Files = 0
+		return fs.checkQuotas(quotas, placeholder.FolderID, usage)
+	}
+
+	folder, ok := fs.closestExistingFolder(fileInfo.VirtualPath)
+	if !ok {
+		return ErrPathNotExist
+	}
+
+	return fs.checkQuotas(quotas, folder.ID, usage)
+}
+
+// HookValidateFolderQuotaDiff 根据原有文件和新文件的大小验证目录配额
+func HookValidateFolderQuotaDiff(ctx context.Context, fs *FileSystem, newFile fsctx.FileHeader) error {
+	originFile, ok := ctx.Value(fsctx.FileModelCtx).(model.File)
+	if !ok {
+		return ErrObjectNotExist
+	}
+
+	newFileSize := newFile.Info().Size
+	if newFileSize > originFile.Size {
+		return fs.CheckFolderQuota(originFile.FolderID, model.FolderUsage{Size: newFileSize - originFile.Size})
+	}
+
+	return nil
+}
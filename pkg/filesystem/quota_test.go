@@ -0,0 +1,215 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/Jaylenwa/Vfoy/models"
+	"github.com/Jaylenwa/Vfoy/pkg/filesystem/fsctx"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSystem_SetFolderQuota(t *testing.T) {
+	asserts := assert.New(t)
+	fs := &FileSystem{User: &model.User{Model: gorm.Model{ID: 1}}}
+	ctx := context.Background()
+	folder := &model.Folder{Model: gorm.Model{ID: 2}, OwnerID: 1}
+
+	// 取消配额
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)folder_quota(.+)").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		quota, err := fs.SetFolderQuota(ctx, folder, 0, 0)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Nil(quota)
+	}
+
+	// 新建配额
+	{
+		mock.ExpectQuery("SELECT(.+)folders(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "size"}).AddRow(1, 10).AddRow(2, 20))
+		mock.ExpectQuery("SELECT(.+)folder_quota(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)folder_quota(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		quota, err := fs.SetFolderQuota(ctx, folder, 100, 0)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.EqualValues(100, quota.MaxSize)
+		asserts.EqualValues(30, quota.UsedSize)
+		asserts.EqualValues(2, quota.UsedFiles)
+	}
+
+	// 更新已有配额
+	{
+		mock.ExpectQuery("SELECT(.+)folders(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnRows(sqlmock.NewRows([]string{"id", "size"}))
+		mock.ExpectQuery("SELECT(.+)folder_quota(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "folder_id", "max_size"}).AddRow(1, 2, 100))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)folder_quota(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		quota, err := fs.SetFolderQuota(ctx, folder, 0, 5)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.EqualValues(0, quota.MaxSize)
+		asserts.EqualValues(5, quota.MaxFiles)
+	}
+}
+
+func TestFileSystem_CheckFolderQuota(t *testing.T) {
+	asserts := assert.New(t)
+	fs := &FileSystem{User: &model.User{Model: gorm.Model{ID: 1}}}
+
+	// 用量为空
+	{
+		asserts.NoError(fs.CheckFolderQuota(3, model.FolderUsage{}))
+	}
+
+	// 未设定配额
+	{
+		mock.ExpectQuery("SELECT(.+)folder_quota(.+)").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		asserts.NoError(fs.CheckFolderQuota(3, model.FolderUsage{Size: 10}))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 上级目录配额不足
+	{
+		mock.ExpectQuery("SELECT(.+)folder_quota(.+)").WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "folder_id", "max_size", "used_size"}).AddRow(1, 2, 10, 5))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").WithArgs(3, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "parent_id"}).AddRow(3, 2))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").WithArgs(2, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "parent_id"}).AddRow(2, nil))
+		err := fs.CheckFolderQuota(3, model.FolderUsage{Size: 6})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal(ErrFolderQuotaExceeded, err)
+	}
+
+	// 配额不在上级目录上
+	{
+		mock.ExpectQuery("SELECT(.+)folder_quota(.+)").WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "folder_id", "max_size", "used_size"}).AddRow(1, 4, 10, 5))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").WithArgs(3, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "parent_id"}).AddRow(3, nil))
+		err := fs.CheckFolderQuota(3, model.FolderUsage{Size: 6})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+	}
+}
+
+func TestHookValidateFolderQuota(t *testing.T) {
+	asserts := assert.New(t)
+	fs := &FileSystem{User: &model.User{Model: gorm.Model{ID: 1}}}
+	ctx := context.Background()
+
+	// 未设定配额
+	{
+		mock.ExpectQuery("SELECT(.+)folder_quota(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		asserts.NoError(HookValidateFolderQuota(ctx, fs, &fsctx.FileStream{Size: 10, VirtualPath: "/"}))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 已有占位文件，只计算容量
+	{
+		file := &fsctx.FileStream{Size: 10, Model: &model.File{FolderID: 2}}
+		mock.ExpectQuery("SELECT(.+)folder_quota(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "folder_id", "max_files", "used_files"}).AddRow(1, 2, 1, 1))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").WithArgs(2, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "parent_id"}).AddRow(2, nil))
+		asserts.NoError(HookValidateFolderQuota(ctx, fs, file))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 文件数超出
+	{
+		file := &fsctx.FileStream{Size: 10, VirtualPath: "/"}
+		mock.ExpectQuery("SELECT(.+)folder_quota(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "folder_id", "max_files", "used_files"}).AddRow(1, 2, 1, 1))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "parent_id"}).AddRow(2, nil))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").WithArgs(2, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "parent_id"}).AddRow(2, nil))
+		asserts.Equal(ErrFolderQuotaExceeded, HookValidateFolderQuota(ctx, fs, file))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestHookValidateFolderQuotaDiff(t *testing.T) {
+	asserts := assert.New(t)
+	fs := &FileSystem{User: &model.User{Model: gorm.Model{ID: 1}}}
+	file := model.File{Size: 10, FolderID: 2}
+	ctx := context.WithValue(context.Background(), fsctx.FileModelCtx, file)
+
+	// 无需验证
+	{
+		asserts.NoError(HookValidateFolderQuotaDiff(ctx, fs, &fsctx.FileStream{Size: 10}))
+	}
+
+	// 需要验证
+	{
+		mock.ExpectQuery("SELECT(.+)folder_quota(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "folder_id", "max_size", "used_size"}).AddRow(1, 2, 15, 10))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").WithArgs(2, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "parent_id"}).AddRow(2, nil))
+		asserts.Equal(ErrFolderQuotaExceeded, HookValidateFolderQuotaDiff(ctx, fs, &fsctx.FileStream{Size: 16}))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 文件不存在
+	{
+		asserts.Equal(ErrObjectNotExist, HookValidateFolderQuotaDiff(context.Background(), fs, &fsctx.FileStream{Size: 16}))
+	}
+}
+
+func TestFileSystem_FolderCapacity(t *testing.T) {
+	asserts := assert.New(t)
+	fs := &FileSystem{User: &model.User{
+		Model:   gorm.Model{ID: 1},
+		Storage: 20,
+		Group:   model.Group{MaxStorage: 100},
+	}}
+
+	// 未设定配额
+	{
+		mock.ExpectQuery("SELECT(.+)folder_quota(.+)").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		available, used, err := fs.FolderCapacity(3)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.EqualValues(80, available)
+		asserts.EqualValues(20, used)
+	}
+
+	// 上级目录配额剩余更少
+	{
+		mock.ExpectQuery("SELECT(.+)folder_quota(.+)").WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "folder_id", "max_size", "used_size"}).
+				AddRow(1, 2, 10, 5).
+				AddRow(2, 3, 0, 1))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").WithArgs(3, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "parent_id"}).AddRow(3, 2))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").WithArgs(2, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "parent_id"}).AddRow(2, nil))
+		available, used, err := fs.FolderCapacity(3)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.EqualValues(5, available)
+		asserts.EqualValues(5, used)
+	}
+
+	// 查询失败
+	{
+		mock.ExpectQuery("SELECT(.+)folder_quota(.+)").WillReturnError(errors.New("error"))
+		_, _, err := fs.FolderCapacity(3)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+	}
+}
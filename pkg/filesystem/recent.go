@@ -0,0 +1,63 @@
+package filesystem
+
+import (
+	"context"
+	"time"
+
+	model "github.com/Jaylenwa/Vfoy/models"
+	"github.com/Jaylenwa/Vfoy/pkg/conf"
+	"github.com/Jaylenwa/Vfoy/pkg/serializer"
+	"github.com/Jaylenwa/Vfoy/pkg/util"
+)
+
+// RecordRecent 将文件记入当前用户的最近文件，只记录用户自己的文件，
+// 分享访问者等其他用户的访问会被忽略，记录失败不影响文件操作本身
+func (fs *FileSystem) RecordRecent(file *model.File, action string) {
+	if fs.User == nil || fs.User.ID == 0 || file.UserID != fs.User.ID || file.UploadSessionID != nil ||
+		conf.SystemConfig.Mode == "slave" {
+		return
+	}
+
+	if err := model.RecordRecentFile(fs.User.ID, file.ID, action, time.Now()); err != nil {
+		util.Log().Debug("Failed to record recent file %q: %s", file.Name, err)
+	}
+}
+
+// ListRecent 列出最近访问或修改的文件，最近的在前
+func (fs *FileSystem) ListRecent(ctx context.Context, limit int) ([]serializer.Object, error) {
+	recents, err := model.ListRecentFiles(fs.User.ID, limit)
+	if err != nil {
+		return nil, ErrDBListObjects.WithError(err)
+	}
+
+	if len(recents) == 0 {
+		return []serializer.Object{}, nil
+	}
+
+	ids := make([]uint, len(recents))
+	for i, recent := range recents {
+		ids[i] = recent.FileID
+	}
+
+	files, err := model.GetFilesByIDs(ids, fs.User.ID)
+	if err != nil {
+		return nil, ErrDBListObjects.WithError(err)
+	}
+
+	filesByID := make(map[uint]model.File, len(files))
+	for _, file := range files {
+		filesByID[file.ID] = file
+	}
+
+	resolver := newPathResolver(fs.User.ID)
+	objects := make([]serializer.Object, 0, len(recents))
+	for _, recent := range recents {
+		if file, ok := filesByID[recent.FileID]; ok {
+			if parent, ok := resolver.fullPath(file.FolderID); ok {
+				objects = append(objects, fs.listObjects(ctx, parent, []model.File{file}, nil, nil)...)
+			}
+		}
+	}
+
+	return objects, nil
+}
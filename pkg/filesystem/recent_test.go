@@ -0,0 +1,65 @@
+package filesystem
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/Jaylenwa/Vfoy/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSystem_RecordRecent(t *testing.T) {
+	a := assert.New(t)
+	fs := &FileSystem{User: &model.User{}}
+	fs.User.ID = 1
+
+	// 其他用户的文件不记录
+	fs.RecordRecent(&model.File{UserID: 2}, model.RecentPreview)
+	a.NoError(mock.ExpectationsWereMet())
+
+	file := &model.File{UserID: 1}
+	file.ID = 3
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)recent_files(.+)").WithArgs(sqlmock.AnyArg(), model.RecentDownload, 1, 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	fs.RecordRecent(file, model.RecentDownload)
+	a.NoError(mock.ExpectationsWereMet())
+}
+
+func TestFileSystem_ListRecent(t *testing.T) {
+	a := assert.New(t)
+	ctx := context.Background()
+	fs := &FileSystem{User: &model.User{}}
+	fs.User.ID = 1
+
+	// 没有记录
+	{
+		mock.ExpectQuery("SELECT(.+)recent_files(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		objects, err := fs.ListRecent(ctx, 10)
+		a.NoError(mock.ExpectationsWereMet())
+		a.NoError(err)
+		a.Empty(objects)
+	}
+
+	// 按最近访问排列，跳过已删除的文件
+	{
+		mock.ExpectQuery("SELECT(.+)recent_files(.+)").WillReturnRows(
+			sqlmock.NewRows([]string{"id", "file_id"}).AddRow(3, 12).AddRow(2, 11).AddRow(1, 10),
+		)
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnRows(
+			sqlmock.NewRows([]string{"id", "name", "folder_id"}).AddRow(10, "a.txt", 1).AddRow(12, "c.txt", 1),
+		)
+		mock.ExpectQuery("SELECT(.+)folders(.+)").WithArgs(1, 1).WillReturnRows(
+			sqlmock.NewRows([]string{"id", "name", "owner_id"}).AddRow(1, "/", 1),
+		)
+		objects, err := fs.ListRecent(ctx, 10)
+		a.NoError(mock.ExpectationsWereMet())
+		a.NoError(err)
+		a.Len(objects, 2)
+		a.Equal("c.txt", objects[0].Name)
+		a.Equal("a.txt", objects[1].Name)
+		a.Equal("/", objects[1].Path)
+	}
+}
@@ -0,0 +1,305 @@
+package filesystem
+
+import (
+	"context"
+	"path"
+
+	model "github.com/Jaylenwa/Vfoy/models"
+	"github.com/Jaylenwa/Vfoy/pkg/filesystem/driver"
+	"github.com/Jaylenwa/Vfoy/pkg/filesystem/fsctx"
+	"github.com/Jaylenwa/Vfoy/pkg/filesystem/response"
+	"github.com/Jaylenwa/Vfoy/pkg/util"
+)
+
+/* ================
+	 存储策略副本
+   ================
+*/
+
+type replicaRequest struct {
+	user        model.User
+	file        model.File
+	virtualPath string
+}
+
+// replicaQueue 等待复制到副本存储策略的文件，未启动时不复制
+var replicaQueue chan replicaRequest
+
+// InitReplicator 启动在后台复制副本的任务队列
+func InitReplicator() {
+	workers := model.GetIntSetting("replica_max_task_count", 1)
+	if workers <= 0 {
+		workers = 1
+	}
+
+	replicaQueue = make(chan replicaRequest, 1000)
+	for i := 0; i < workers; i++ {
+		go func() {
+			for req := range replicaQueue {
+				handleReplicaRequest(req)
+			}
+		}()
+	}
+
+	util.Log().Debug("Initialize replica queue with: WorkerNum = %d", workers)
+}
+
+// HookReplicateFile 将上传完成的文件加入副本复制队列
+func HookReplicateFile(ctx context.Context, fs *FileSystem, fileHeader fsctx.FileHeader) error {
+	if fs.Policy == nil || fs.Policy.OptionsSerialized.ReplicaPolicyID == 0 {
+		return nil
+	}
+
+	info := fileHeader.Info()
+	file, ok := info.Model.(*model.File)
+	if !ok || file.UploadSessionID != nil || replicaQueue == nil {
+		return nil
+	}
+
+	req := replicaRequest{user: *fs.User, file: *file, virtualPath: info.VirtualPath}
+	if req.file.PolicyID == fs.Policy.ID {
+		req.file.Policy = *fs.Policy
+	}
+
+	select {
+	case replicaQueue <- req:
+	default:
+		util.Log().Warning("Replica queue is full, skip replicating file %q.", file.Name)
+	}
+
+	return nil
+}
+
+func handleReplicaRequest(req replicaRequest) {
+	fs, err := NewFileSystem(&req.user)
+	if err != nil {
+		util.Log().Warning("Failed to initialize filesystem for replication: %s", err)
+		return
+	}
+	defer fs.Recycle()
+
+	if err := fs.ReplicateFile(context.Background(), &req.file, req.virtualPath); err != nil {
+		util.Log().Warning("Failed to replicate file %q: %s", req.file.Name, err)
+	}
+}
+
+// ReplicateFile 将文件的物理文件复制到所在存储策略的副本存储策略，已有副本时覆盖原副本。
+// virtualPath 为文件所在目录的路径，用于生成副本的存储路径
+func (fs *FileSystem) ReplicateFile(ctx context.Context, file *model.File, virtualPath string) error {
+	src := file.GetPolicy()
+	dst := replicaPolicyOf(src)
+	if dst == nil {
+		return nil
+	}
+
+	replica, err := model.GetReplica(src.ID, file.SourceName)
+	if err != nil {
+		replica = &model.Replica{PolicyID: src.ID, SourceName: file.SourceName}
+	}
+
+	if replica.UpToDate(file, dst.ID) {
+		return nil
+	}
+
+	// 副本存储策略已更换，删除原副本
+	if replica.ID > 0 && replica.ReplicaPolicyID != dst.ID {
+		fs.deleteReplicaObjects(ctx, []model.Replica{*replica})
+		replica.ReplicaSource = ""
+	}
+
+	if replica.ReplicaSource == "" {
+		replica.ReplicaPolicyID = dst.ID
+		replica.ReplicaSource = path.Join(
+			dst.GeneratePath(file.UserID, virtualPath),
+			dst.GenerateFileName(file.UserID, file.Name),
+		)
+	}
+
+	srcHandler, err := fs.handlerOf(src)
+	if err != nil {
+		return err
+	}
+
+	dstHandler, err := fs.handlerOf(dst)
+	if err != nil {
+		return err
+	}
+
+	rs, err := srcHandler.Get(ctx, file.SourceName)
+	if err != nil {
+		return ErrIO.WithError(err)
+	}
+
+	stream := &fsctx.FileStream{
+		File:        rs,
+		Seeker:      rs,
+		Size:        file.Size,
+		Name:        file.Name,
+		VirtualPath: virtualPath,
+		SavePath:    replica.ReplicaSource,
+		Mode:        fsctx.Overwrite,
+	}
+	hashing := newHashingStream(stream)
+	err = dstHandler.Put(ctx, stream)
+	hashing.finish()
+	if err != nil {
+		return ErrIO.WithError(err)
+	}
+
+	if err := verifyCopiedFile(ctx, srcHandler, dstHandler, replica.ReplicaSource, file, stream.Hash); err != nil {
+		if _, deleteErr := dstHandler.Delete(ctx, []string{replica.ReplicaSource}); deleteErr != nil {
+			util.Log().Warning("Failed to delete replica object %q: %s", replica.ReplicaSource, deleteErr)
+		}
+		return err
+	}
+
+	replica.Size = file.Size
+	replica.Hash = file.Hash
+	if err := replica.Save(); err != nil {
+		if _, deleteErr := dstHandler.Delete(ctx, []string{replica.ReplicaSource}); deleteErr != nil {
+			util.Log().Warning("Failed to delete replica object %q: %s", replica.ReplicaSource, deleteErr)
+		}
+		return ErrInsertFileRecord.WithError(err)
+	}
+
+	return nil
+}
+
+// replicaPolicyOf 返回存储策略的副本存储策略，未设定时返回 nil
+func replicaPolicyOf(policy *model.Policy) *model.Policy {
+	id := policy.OptionsSerialized.ReplicaPolicyID
+	if id == 0 || id == policy.ID {
+		return nil
+	}
+
+	replica, err := model.GetPolicyByID(id)
+	if err != nil {
+		util.Log().Warning("Replica policy %d of policy %q not exist: %s", id, policy.Name, err)
+		return nil
+	}
+
+	return &replica
+}
+
+// replicaHandler 返回副本存储策略的适配器，不改变当前文件系统的存储策略
+func (fs *FileSystem) replicaHandler(policy *model.Policy) (driver.Handler, error) {
+	replicaFs := &FileSystem{User: fs.User, Policy: policy}
+	if err := replicaFs.DispatchHandler(); err != nil {
+		return nil, err
+	}
+
+	return replicaFs.Handler, nil
+}
+
+// replicaOf 查找 policy 上文件内容的可用副本，副本缺失或已过期时返回 ErrReplicaNotExist
+func (fs *FileSystem) replicaOf(policy *model.Policy, file *model.File) (*model.Replica, driver.Handler, error) {
+	if policy == nil || policy.OptionsSerialized.ReplicaPolicyID == 0 {
+		return nil, nil, ErrReplicaNotExist
+	}
+
+	replica, err := model.GetReplica(policy.ID, file.SourceName)
+	if err != nil {
+		return nil, nil, ErrReplicaNotExist.WithError(err)
+	}
+
+	if replica.Size != file.Size || (file.Hash != "" && replica.Hash != file.Hash) {
+		return nil, nil, ErrReplicaNotExist
+	}
+
+	replicaPolicy, err := model.GetPolicyByID(replica.ReplicaPolicyID)
+	if err != nil {
+		return nil, nil, ErrReplicaNotExist.WithError(err)
+	}
+
+	handler, err := fs.replicaHandler(&replicaPolicy)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return replica, handler, nil
+}
+
+// getReplicaContent 从副本读取文件内容
+func (fs *FileSystem) getReplicaContent(ctx context.Context, policy *model.Policy, file *model.File) (response.RSCloser, error) {
+	replica, handler, err := fs.replicaOf(policy, file)
+	if err != nil {
+		return nil, err
+	}
+
+	rs, err := handler.Get(ctx, replica.ReplicaSource)
+	if err != nil {
+		return nil, ErrIO.WithError(err)
+	}
+
+	util.Log().Debug("Primary object %q is unavailable, read from replica %q.", file.SourceName, replica.ReplicaSource)
+	return rs, nil
+}
+
+// signReplicaURL 为副本签名 URL
+func (fs *FileSystem) signReplicaURL(ctx context.Context, policy *model.Policy, file *model.File, ttl int64, isDownload bool) (string, error) {
+	replica, handler, err := fs.replicaOf(policy, file)
+	if err != nil {
+		return "", err
+	}
+
+	return handler.Source(ctx, replica.ReplicaSource, ttl, isDownload, fs.User.Group.SpeedLimit)
+}
+
+// deleteReplicas 删除 policy 上已删除的物理文件的副本
+func (fs *FileSystem) deleteReplicas(ctx context.Context, policy *model.Policy, sources []string) {
+	if policy.OptionsSerialized.ReplicaPolicyID == 0 || len(sources) == 0 {
+		return
+	}
+
+	replicas, err := model.GetReplicasBySources(policy.ID, sources)
+	if err != nil {
+		util.Log().Warning("Failed to list replicas of policy %q: %s", policy.Name, err)
+		return
+	}
+
+	deleted := fs.deleteReplicaObjects(ctx, replicas)
+	if err := model.DeleteReplicas(deleted); err != nil {
+		util.Log().Warning("Failed to delete replica records: %s", err)
+	}
+}
+
+// deleteReplicaObjects 删除副本的物理文件，返回删除成功的副本
+func (fs *FileSystem) deleteReplicaObjects(ctx context.Context, replicas []model.Replica) []model.Replica {
+	grouped := make(map[uint][]model.Replica)
+	for _, replica := range replicas {
+		grouped[replica.ReplicaPolicyID] = append(grouped[replica.ReplicaPolicyID], replica)
+	}
+
+	deleted := make([]model.Replica, 0, len(replicas))
+	for policyID, group := range grouped {
+		policy, err := model.GetPolicyByID(policyID)
+		if err != nil {
+			util.Log().Warning("Replica policy %d not exist: %s", policyID, err)
+			continue
+		}
+
+		handler, err := fs.replicaHandler(&policy)
+		if err != nil {
+			util.Log().Warning("Failed to dispatch handler of replica policy %q: %s", policy.Name, err)
+			continue
+		}
+
+		sources := make([]string, len(group))
+		for i, replica := range group {
+			sources[i] = replica.ReplicaSource
+		}
+
+		failed, err := handler.Delete(ctx, sources)
+		if err != nil {
+			util.Log().Warning("Failed to delete %d replica object(s) of policy %q: %s", len(failed), policy.Name, err)
+		}
+
+		for _, replica := range group {
+			if !util.ContainsString(failed, replica.ReplicaSource) {
+				deleted = append(deleted, replica)
+			}
+		}
+	}
+
+	return deleted
+}
@@ -0,0 +1,206 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/Jaylenwa/Vfoy/models"
+	"github.com/Jaylenwa/Vfoy/pkg/cache"
+	"github.com/Jaylenwa/Vfoy/pkg/filesystem/fsctx"
+	"github.com/Jaylenwa/Vfoy/pkg/util"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func replicaTestPolicies() (model.Policy, model.Policy) {
+	src := model.Policy{Model: gorm.Model{ID: 1}, Type: "local"}
+	src.OptionsSerialized.ReplicaPolicyID = 2
+	dst := model.Policy{
+		Model:        gorm.Model{ID: 2},
+		Type:         "local",
+		DirNameRule:  "tests/replica/{uid}{path}",
+		FileNameRule: "{originname}",
+	}
+	cache.Set("policy_2", dst, 0)
+	return src, dst
+}
+
+func TestFileSystem_ReplicateFile(t *testing.T) {
+	asserts := assert.New(t)
+	fs := &FileSystem{User: &model.User{Model: gorm.Model{ID: 1}}}
+	ctx := context.Background()
+	src, _ := replicaTestPolicies()
+
+	f, err := util.CreatNestedFile(util.RelativePath("tests/replica_src/1.txt"))
+	asserts.NoError(err)
+	f.WriteString("content")
+	f.Close()
+	file := &model.File{
+		Model:      gorm.Model{ID: 1},
+		Name:       "1.txt",
+		UserID:     1,
+		Size:       7,
+		SourceName: "tests/replica_src/1.txt",
+		PolicyID:   1,
+		Policy:     src,
+		Hash:       sha256Hex("content"),
+	}
+
+	// 未设定副本存储策略
+	{
+		file := &model.File{Policy: model.Policy{Model: gorm.Model{ID: 1}}}
+		asserts.NoError(fs.ReplicateFile(ctx, file, "/"))
+	}
+
+	// 副本已是最新
+	{
+		mock.ExpectQuery("SELECT(.+)replicas(.+)").
+			WithArgs(1, "tests/replica_src/1.txt").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "replica_policy_id", "size", "hash"}).
+				AddRow(1, 2, 7, sha256Hex("content")))
+		asserts.NoError(fs.ReplicateFile(ctx, file, "/dir"))
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.False(util.Exists(util.RelativePath("tests/replica/1/dir/1.txt")))
+	}
+
+	// 保存记录失败
+	{
+		mock.ExpectQuery("SELECT(.+)replicas(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)replicas(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		err := fs.ReplicateFile(ctx, file, "/dir")
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.ErrorIs(err, ErrInsertFileRecord)
+		asserts.False(util.Exists(util.RelativePath("tests/replica/1/dir/1.txt")))
+	}
+
+	// 成功
+	{
+		mock.ExpectQuery("SELECT(.+)replicas(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)replicas(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		asserts.NoError(fs.ReplicateFile(ctx, file, "/dir"))
+		asserts.NoError(mock.ExpectationsWereMet())
+
+		content, err := os.ReadFile(util.RelativePath("tests/replica/1/dir/1.txt"))
+		asserts.NoError(err)
+		asserts.Equal("content", string(content))
+		asserts.True(util.Exists(util.RelativePath("tests/replica_src/1.txt")))
+	}
+
+	os.RemoveAll(util.RelativePath("tests/replica_src"))
+	os.RemoveAll(util.RelativePath("tests/replica"))
+}
+
+func TestFileSystem_GetContentFromReplica(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	src, _ := replicaTestPolicies()
+
+	f, err := util.CreatNestedFile(util.RelativePath("tests/replica/1/1.txt"))
+	asserts.NoError(err)
+	f.WriteString("content")
+	f.Close()
+	file := model.File{
+		Model:      gorm.Model{ID: 1},
+		Name:       "1.txt",
+		Size:       7,
+		SourceName: "tests/replica_src/not_exist.txt",
+		PolicyID:   1,
+		Policy:     src,
+	}
+
+	// 副本已过期
+	{
+		fs := &FileSystem{User: &model.User{Model: gorm.Model{ID: 1}}, FileTarget: []model.File{file}}
+		mock.ExpectQuery("SELECT(.+)replicas(.+)").
+			WithArgs(1, "tests/replica_src/not_exist.txt").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "replica_policy_id", "replica_source", "size"}).
+				AddRow(1, 2, "tests/replica/1/1.txt", 6))
+		rs, err := fs.GetContent(ctx, 1)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.ErrorIs(err, ErrIO)
+		asserts.Nil(rs)
+	}
+
+	// 主存储策略读取失败，读取副本
+	{
+		fs := &FileSystem{User: &model.User{Model: gorm.Model{ID: 1}}, FileTarget: []model.File{file}}
+		mock.ExpectQuery("SELECT(.+)replicas(.+)").
+			WithArgs(1, "tests/replica_src/not_exist.txt").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "replica_policy_id", "replica_source", "size"}).
+				AddRow(1, 2, "tests/replica/1/1.txt", 7))
+		rs, err := fs.GetContent(ctx, 1)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		content, _ := io.ReadAll(rs)
+		rs.Close()
+		asserts.Equal("content", string(content))
+		asserts.EqualValues(1, fs.Policy.ID)
+	}
+
+	os.RemoveAll(util.RelativePath("tests/replica"))
+}
+
+func TestFileSystem_DeleteReplicas(t *testing.T) {
+	asserts := assert.New(t)
+	fs := &FileSystem{User: &model.User{Model: gorm.Model{ID: 1}}}
+	ctx := context.Background()
+	src, _ := replicaTestPolicies()
+
+	// 未设定副本存储策略
+	fs.deleteReplicas(ctx, &model.Policy{}, []string{"1.txt"})
+
+	f, err := util.CreatNestedFile(util.RelativePath("tests/replica/1/1.txt"))
+	asserts.NoError(err)
+	f.Close()
+
+	mock.ExpectQuery("SELECT(.+)replicas(.+)").
+		WithArgs(1, "1.txt").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "replica_policy_id", "replica_source"}).
+			AddRow(5, 2, "tests/replica/1/1.txt"))
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE(.+)replicas(.+)").WithArgs(5).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	fs.deleteReplicas(ctx, &src, []string{"1.txt"})
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.False(util.Exists(util.RelativePath("tests/replica/1/1.txt")))
+
+	os.RemoveAll(util.RelativePath("tests/replica"))
+}
+
+func TestHookReplicateFile(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	src, _ := replicaTestPolicies()
+	fs := &FileSystem{User: &model.User{Model: gorm.Model{ID: 1}}, Policy: &src}
+	file := &model.File{Name: "1.txt", PolicyID: 1}
+
+	// 队列未启动
+	asserts.NoError(HookReplicateFile(ctx, fs, &fsctx.FileStream{Model: file}))
+
+	replicaQueue = make(chan replicaRequest, 1)
+	defer func() { replicaQueue = nil }()
+
+	// 未设定副本存储策略
+	asserts.NoError(HookReplicateFile(ctx, &FileSystem{Policy: &model.Policy{}}, &fsctx.FileStream{Model: file}))
+	asserts.Len(replicaQueue, 0)
+
+	// 成功
+	asserts.NoError(HookReplicateFile(ctx, fs, &fsctx.FileStream{Model: file, VirtualPath: "/dir"}))
+	asserts.Len(replicaQueue, 1)
+	req := <-replicaQueue
+	asserts.Equal("/dir", req.virtualPath)
+	asserts.EqualValues(1, req.file.Policy.ID)
+
+	// 上传会话未完成
+	sessionID := "session"
+	asserts.NoError(HookReplicateFile(ctx, fs, &fsctx.FileStream{Model: &model.File{UploadSessionID: &sessionID}}))
+	asserts.Len(replicaQueue, 0)
+}
@@ -0,0 +1,365 @@
+package filesystem
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	model "github.com/Jaylenwa/Vfoy/models"
+	"github.com/Jaylenwa/Vfoy/pkg/search"
+	"github.com/Jaylenwa/Vfoy/pkg/serializer"
+	"github.com/Jaylenwa/Vfoy/pkg/util"
+)
+
+/* ================
+	 全文索引与搜索
+   ================
+*/
+
+const (
+	// searchContentMaxLength 索引记录中保存的文本的最大字节数，用于生成摘要
+	searchContentMaxLength = 60000
+	// contentSearchMaxHits 全文搜索最多返回的结果数
+	contentSearchMaxHits = 100
+	// maxTagQueryDepth 搜索语句中引用标签的最大嵌套层数
+	maxTagQueryDepth = 3
+)
+
+type indexRequest struct {
+	user model.User
+	file model.File
+}
+
+// indexQueue 等待建立全文索引的文件，未启动时不建立索引
+var indexQueue chan indexRequest
+
+// InitContentIndexer 启动在后台建立全文索引的任务队列
+func InitContentIndexer() {
+	workers := model.GetIntSetting("search_index_max_task_count", 1)
+	if workers <= 0 {
+		workers = 1
+	}
+
+	indexQueue = make(chan indexRequest, 1000)
+	for i := 0; i < workers; i++ {
+		go func() {
+			for req := range indexQueue {
+				handleIndexRequest(req)
+			}
+		}()
+	}
+
+	util.Log().Debug("Initialize content index queue with: WorkerNum = %d", workers)
+}
+
+// scheduleContentIndex 将文件加入全文索引队列，队列已满时忽略
+func scheduleContentIndex(user *model.User, file *model.File) {
+	if indexQueue == nil || file.UploadSessionID != nil || !search.Supported(file.Name) {
+		return
+	}
+
+	select {
+	case indexQueue <- indexRequest{user: *user, file: *file}:
+	default:
+		util.Log().Warning("Content index queue is full, skip indexing file %q.", file.Name)
+	}
+}
+
+func handleIndexRequest(req indexRequest) {
+	if !model.IsTrueVal(model.GetSettingByName("search_index_enabled")) {
+		return
+	}
+
+	fs, err := NewFileSystem(&req.user)
+	if err != nil {
+		util.Log().Warning("Failed to initialize filesystem for content index: %s", err)
+		return
+	}
+	defer fs.Recycle()
+
+	if err := fs.IndexFileContent(context.Background(), &req.file); err != nil {
+		util.Log().Debug("Failed to index content of file %q: %s", req.file.Name, err)
+	}
+}
+
+// IndexFileContent 提取文件的文本内容并更新全文索引，不支持的文件将删除已有索引
+func (fs *FileSystem) IndexFileContent(ctx context.Context, file *model.File) error {
+	if !search.Supported(file.Name) {
+		return model.DeleteSearchIndexByFileIDs([]uint{file.ID})
+	}
+
+	fs.Policy = file.GetPolicy()
+	if err := fs.DispatchHandler(); err != nil {
+		return err
+	}
+
+	rs, err := fs.Handler.Get(ctx, file.SourceName)
+	if err != nil {
+		return ErrIO.WithError(err)
+	}
+	defer rs.Close()
+
+	maxSize := model.GetIntSetting("search_index_max_src_size", 10485760)
+	text, err := search.Extract(file.Name, rs, int64(maxSize))
+	if err != nil {
+		return fmt.Errorf("failed to extract text: %w", err)
+	}
+
+	index := &model.SearchIndex{
+		FileID:  file.ID,
+		UserID:  file.UserID,
+		Content: search.Truncate(text, searchContentMaxLength),
+	}
+	return index.Save(search.Tokenize(text))
+}
+
+// SearchContent 在文件内容中搜索关键字，按相关度返回结果及命中的摘要
+func (fs *FileSystem) SearchContent(ctx context.Context, query string) ([]serializer.Object, error) {
+	hits, err := model.SearchFileContent(fs.User.ID, search.QueryTerms(query))
+	if err != nil {
+		return nil, fmt.Errorf("failed to search content index: %w", err)
+	}
+
+	// 如果限定了根目录，则只在这个根目录下搜索
+	var scope map[uint]bool
+	if fs.Root != nil {
+		allFolders, err := model.GetRecursiveChildFolder([]uint{fs.Root.ID}, fs.User.ID, true)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list all folders: %w", err)
+		}
+
+		scope = make(map[uint]bool, len(allFolders))
+		for _, folder := range allFolders {
+			scope[folder.ID] = true
+		}
+	}
+
+	ids := make([]uint, 0, len(hits))
+	for _, hit := range hits {
+		ids = append(ids, hit.FileID)
+	}
+
+	files := make([]model.File, 0)
+	if len(ids) > 0 {
+		files, err = model.GetFilesByIDs(ids, fs.User.ID)
+		if err != nil {
+			return nil, ErrDBListObjects.WithError(err)
+		}
+	}
+
+	filesByID := make(map[uint]model.File, len(files))
+	for _, file := range files {
+		filesByID[file.ID] = file
+	}
+
+	// 按相关度排列结果
+	ranked := make([]model.File, 0, contentSearchMaxHits)
+	rankedIDs := make([]uint, 0, contentSearchMaxHits)
+	for _, hit := range hits {
+		file, ok := filesByID[hit.FileID]
+		if !ok || file.UploadSessionID != nil || (scope != nil && !scope[file.FolderID]) {
+			continue
+		}
+
+		ranked = append(ranked, file)
+		rankedIDs = append(rankedIDs, file.ID)
+		if len(ranked) >= contentSearchMaxHits {
+			break
+		}
+	}
+
+	snippets := make(map[uint]string, len(ranked))
+	if len(rankedIDs) > 0 {
+		indexes, err := model.GetSearchIndexByFileIDs(rankedIDs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get content index: %w", err)
+		}
+
+		for _, index := range indexes {
+			snippets[index.FileID] = search.Snippet(index.Content, query)
+		}
+	}
+
+	fs.SetTargetFile(&ranked)
+	objects := fs.listObjects(ctx, "/", ranked, nil, nil)
+	for i := range objects {
+		objects[i].Snippet = snippets[ranked[i].ID]
+	}
+
+	return objects, nil
+}
+
+// SearchQuery 按结构化搜索语句搜索目录和文件，cond 中需预先设定分页和排序，
+// 返回当前页的对象及符合条件的总数
+func (fs *FileSystem) SearchQuery(ctx context.Context, query *search.Query, cond *model.ObjectSearchCondition) ([]serializer.Object, int, error) {
+	return fs.searchObjects(ctx, cond, func(scopes *[]*model.Folder) error {
+		return fs.applyQuery(cond, query, scopes, 0)
+	})
+}
+
+// SearchTag 按文件分类标签的表达式搜索，cond 中需预先设定分页和排序
+func (fs *FileSystem) SearchTag(ctx context.Context, tag *model.Tag, cond *model.ObjectSearchCondition) ([]serializer.Object, int, error) {
+	return fs.searchObjects(ctx, cond, func(scopes *[]*model.Folder) error {
+		return fs.applyTag(cond, tag, scopes, 0)
+	})
+}
+
+func (fs *FileSystem) searchObjects(ctx context.Context, cond *model.ObjectSearchCondition, apply func(scopes *[]*model.Folder) error) ([]serializer.Object, int, error) {
+	scopes := make([]*model.Folder, 0, 1)
+	if fs.Root != nil {
+		scopes = append(scopes, fs.Root)
+	}
+
+	if err := apply(&scopes); err != nil {
+		return nil, 0, err
+	}
+
+	if len(scopes) > 0 {
+		parents, err := searchScope(fs.User.ID, scopes)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if len(parents) == 0 {
+			return []serializer.Object{}, 0, nil
+		}
+		cond.Parents = parents
+	}
+
+	folders, files, total, err := model.SearchObjects(fs.User.ID, cond)
+	if err != nil {
+		return nil, 0, ErrDBListObjects.WithError(err)
+	}
+
+	fs.SetTargetFile(&files)
+	return fs.listObjects(ctx, "/", files, folders, nil), total, nil
+}
+
+// searchScope 列出同时位于所有限定目录之下的目录ID
+func searchScope(uid uint, scopes []*model.Folder) ([]uint, error) {
+	var parents []uint
+	for i, scope := range scopes {
+		allFolders, err := model.GetRecursiveChildFolder([]uint{scope.ID}, uid, true)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list all folders: %w", err)
+		}
+
+		if i == 0 {
+			parents = make([]uint, 0, len(allFolders))
+			for _, folder := range allFolders {
+				parents = append(parents, folder.ID)
+			}
+			continue
+		}
+
+		inScope := make(map[uint]bool, len(allFolders))
+		for _, folder := range allFolders {
+			inScope[folder.ID] = true
+		}
+
+		filtered := parents[:0]
+		for _, id := range parents {
+			if inScope[id] {
+				filtered = append(filtered, id)
+			}
+		}
+		parents = filtered
+	}
+
+	return parents, nil
+}
+
+// applyQuery 将搜索语句合并至查询条件
+func (fs *FileSystem) applyQuery(cond *model.ObjectSearchCondition, query *search.Query, scopes *[]*model.Folder, depth int) error {
+	for _, name := range query.Names {
+		cond.NameGroups = append(cond.NameGroups, []string{"%" + model.EscapeLike(name) + "%"})
+	}
+
+	for _, exts := range query.ExtGroups {
+		group := make([]string, len(exts))
+		for i, ext := range exts {
+			group[i] = "%." + model.EscapeLike(ext)
+		}
+		cond.NameGroups = append(cond.NameGroups, group)
+	}
+
+	for _, filter := range query.Metadata {
+		cond.MetadataPatterns = append(cond.MetadataPatterns, model.MetadataSearchPattern(filter.Key, filter.Value))
+	}
+
+	if query.FilesOnly() {
+		cond.FilesOnly = true
+	}
+	if query.Type == search.ObjectTypeFolder {
+		cond.FoldersOnly = true
+	}
+
+	if query.MinSize != nil && (cond.MinSize == nil || *cond.MinSize < *query.MinSize) {
+		cond.MinSize = query.MinSize
+	}
+	if query.MaxSize != nil && (cond.MaxSize == nil || *cond.MaxSize > *query.MaxSize) {
+		cond.MaxSize = query.MaxSize
+	}
+
+	cond.ModifiedAfter = laterTime(cond.ModifiedAfter, query.Modified.After)
+	cond.ModifiedBefore = earlierTime(cond.ModifiedBefore, query.Modified.Before)
+	cond.CreatedAfter = laterTime(cond.CreatedAfter, query.Created.After)
+	cond.CreatedBefore = earlierTime(cond.CreatedBefore, query.Created.Before)
+
+	if query.In != "" {
+		exist, folder := fs.IsPathExist(query.In)
+		if !exist {
+			return ErrPathNotExist
+		}
+		*scopes = append(*scopes, folder)
+	}
+
+	for _, name := range query.Tags {
+		tag, err := model.GetFileTagByName(name, fs.User.ID)
+		if err != nil {
+			return ErrTagNotExist.WithError(err)
+		}
+
+		if err := fs.applyTag(cond, tag, scopes, depth+1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// applyTag 将文件分类标签的表达式合并至查询条件，
+// 旧版标签的表达式为按行分隔的文件名匹配模式
+func (fs *FileSystem) applyTag(cond *model.ObjectSearchCondition, tag *model.Tag, scopes *[]*model.Folder, depth int) error {
+	if !tag.IsQuery {
+		cond.FilesOnly = true
+		cond.NameGroups = append(cond.NameGroups, strings.Split(model.EscapeLikeEscapeChar(tag.Expression), "\n"))
+		return nil
+	}
+
+	if depth > maxTagQueryDepth {
+		return fmt.Errorf("%w: tags are nested too deeply", search.ErrInvalidQuery)
+	}
+
+	query, err := search.ParseQuery(tag.Expression)
+	if err != nil {
+		return err
+	}
+
+	return fs.applyQuery(cond, query, scopes, depth)
+}
+
+func laterTime(a, b time.Time) time.Time {
+	if a.IsZero() || b.After(a) {
+		return b
+	}
+	return a
+}
+
+func earlierTime(a, b time.Time) time.Time {
+	if a.IsZero() || (!b.IsZero() && b.Before(a)) {
+		return b
+	}
+	return a
+}
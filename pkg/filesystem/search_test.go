@@ -0,0 +1,105 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/Jaylenwa/Vfoy/models"
+	"github.com/Jaylenwa/Vfoy/pkg/search"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSystem_SearchQuery(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	fs := &FileSystem{
+		User: &model.User{},
+	}
+	fs.User.ID = 1
+
+	// 引用旧版标签
+	{
+		query, err := search.ParseQuery("report ext:pdf tag:work")
+		asserts.NoError(err)
+		mock.ExpectQuery("SELECT(.+)tags(.+)").WithArgs(1, model.FileTagType, "work").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "expression"}).AddRow(1, "%.doc\n%.pdf"))
+		mock.ExpectQuery("SELECT count(.+)files(.+)").
+			WithArgs(1, "%report%", "%.pdf", "%.doc", "%.pdf").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "report.pdf"))
+		res, total, err := fs.SearchQuery(ctx, query, &model.ObjectSearchCondition{Limit: 10})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Equal(1, total)
+		asserts.Len(res, 1)
+	}
+
+	// 关键字中的通配符按字面匹配，旧版标签的通配符保留原义
+	{
+		query, err := search.ParseQuery(`"50%_off" tag:sale`)
+		asserts.NoError(err)
+		mock.ExpectQuery("SELECT(.+)tags(.+)").WithArgs(1, model.FileTagType, "sale").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "expression"}).AddRow(1, "%!%"))
+		mock.ExpectQuery("SELECT count(.+)files(.+)name like (.+) escape (.+)").
+			WithArgs(1, "%50!%!_off%", "%!!%").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		_, total, err := fs.SearchQuery(ctx, query, &model.ObjectSearchCondition{Limit: 10})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Equal(0, total)
+	}
+
+	// 标签不存在
+	{
+		query, err := search.ParseQuery("tag:work")
+		asserts.NoError(err)
+		mock.ExpectQuery("SELECT(.+)tags(.+)").WillReturnError(errors.New("not found"))
+		_, _, err = fs.SearchQuery(ctx, query, &model.ObjectSearchCondition{Limit: 10})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.ErrorIs(err, ErrTagNotExist)
+	}
+}
+
+func TestFileSystem_SearchTag(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	fs := &FileSystem{
+		User: &model.User{},
+	}
+	fs.User.ID = 1
+
+	// 结构化搜索语句
+	{
+		tag := &model.Tag{IsQuery: true, Expression: "type:folder name:2026"}
+		mock.ExpectQuery("SELECT count(.+)folders(.+)").WithArgs(1, "%2026%").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "2026"))
+		res, total, err := fs.SearchTag(ctx, tag, &model.ObjectSearchCondition{Limit: 10})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Equal(1, total)
+		asserts.Len(res, 1)
+		asserts.Equal("dir", res[0].Type)
+	}
+
+	// 标签循环引用
+	{
+		tag := &model.Tag{IsQuery: true, Expression: "tag:loop"}
+		for i := 0; i < maxTagQueryDepth+1; i++ {
+			mock.ExpectQuery("SELECT(.+)tags(.+)").
+				WillReturnRows(sqlmock.NewRows([]string{"id", "is_query", "expression"}).AddRow(1, true, "tag:loop"))
+		}
+		_, _, err := fs.SearchTag(ctx, tag, &model.ObjectSearchCondition{Limit: 10})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.ErrorIs(err, search.ErrInvalidQuery)
+	}
+
+	// 语句无效
+	{
+		tag := &model.Tag{IsQuery: true, Expression: "size>abc"}
+		_, _, err := fs.SearchTag(ctx, tag, &model.ObjectSearchCondition{Limit: 10})
+		asserts.ErrorIs(err, search.ErrInvalidQuery)
+	}
+}
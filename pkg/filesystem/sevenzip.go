@@ -0,0 +1,173 @@
+package filesystem
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/bodgit/sevenzip"
+	"github.com/mholt/archiver/v4"
+)
+
+// sevenZipHeader 7z 文件头
+var sevenZipHeader = []byte("7z\xBC\xAF\x27\x1C")
+
+// sevenZipAESCoder 7z AES-256 + SHA-256 编码器 ID
+var sevenZipAESCoder = []byte{0x06, 0xF1, 0x07, 0x01}
+
+const (
+	// sevenZipMaxHeaderSize 检查加密时最多读取的 7z 头部大小
+	sevenZipMaxHeaderSize = 4 << 20
+	// sevenZipVerifySize 校验密码时最多读取的文件大小
+	sevenZipVerifySize = 16 << 20
+)
+
+func init() {
+	archiver.RegisterFormat(SevenZip{})
+}
+
+// SevenZip 7z 格式，仅用于解压，压缩任务使用 sevenZipArchiveWriter
+type SevenZip struct {
+	// 解压加密文件使用的密码
+	Password string
+}
+
+// Name 格式名称
+func (SevenZip) Name() string { return ".7z" }
+
+// Match 根据文件头识别 7z 格式，忽略文件名
+func (z SevenZip) Match(filename string, stream io.Reader) (archiver.MatchResult, error) {
+	var mr archiver.MatchResult
+
+	buf := make([]byte, len(sevenZipHeader))
+	if _, err := io.ReadFull(stream, buf); err != nil {
+		return mr, nil
+	}
+
+	mr.ByStream = bytes.Equal(buf, sevenZipHeader)
+	return mr, nil
+}
+
+// Archive 不支持通过 archiver 创建 7z 文件
+func (SevenZip) Archive(ctx context.Context, output io.Writer, files []archiver.File) error {
+	return errors.New("creating 7z archive is not supported")
+}
+
+// Extract 解压 7z 文件，sourceArchive 需支持随机读取
+func (z SevenZip) Extract(ctx context.Context, sourceArchive io.Reader, pathsInArchive []string, handleFile archiver.FileHandler) error {
+	sra, ok := sourceArchive.(interface {
+		io.ReaderAt
+		io.Seeker
+	})
+	if !ok {
+		return errors.New("input type must be an io.ReaderAt and io.Seeker because of 7z format constraints")
+	}
+
+	size, err := sra.Seek(0, io.SeekEnd)
+	if err != nil {
+		return fmt.Errorf("determining stream size: %w", err)
+	}
+
+	if z.Password == "" && sevenZipEncrypted(sra) {
+		return ErrArchiveEncrypted
+	}
+
+	zr, err := openSevenZip(sra, size, z.Password)
+	if err != nil {
+		return err
+	}
+
+	for i, f := range zr.File {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		f := f
+		file := archiver.File{
+			FileInfo:      f.FileInfo(),
+			Header:        f.FileHeader,
+			NameInArchive: f.Name,
+			Open:          func() (io.ReadCloser, error) { return f.Open() },
+		}
+
+		if err := handleFile(ctx, file); err != nil {
+			return fmt.Errorf("handling file %d: %s: %w", i, f.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// openSevenZip 使用密码打开 7z 文件，密码错误时返回 ErrArchivePassword
+func openSevenZip(r io.ReaderAt, size int64, password string) (*sevenzip.Reader, error) {
+	zr, err := sevenzip.NewReaderWithPassword(r, size, password)
+	if err != nil {
+		// 文件头加密时无法区分密码错误与文件损坏，以是否使用了 AES 编码器判断
+		if sevenZipEncrypted(r) {
+			if password == "" {
+				return nil, ErrArchiveEncrypted
+			}
+
+			return nil, ErrArchivePassword
+		}
+
+		return nil, err
+	}
+
+	if password != "" && !verifySevenZipPassword(zr) {
+		return nil, ErrArchivePassword
+	}
+
+	return zr, nil
+}
+
+// sevenZipEncrypted 7z 文件头中是否使用了 AES 编码器
+func sevenZipEncrypted(r io.ReaderAt) bool {
+	start := make([]byte, 32)
+	if _, err := r.ReadAt(start, 0); err != nil {
+		return false
+	}
+
+	offset := binary.LittleEndian.Uint64(start[12:20])
+	size := binary.LittleEndian.Uint64(start[20:28])
+	if size == 0 || size > sevenZipMaxHeaderSize {
+		return false
+	}
+
+	header := make([]byte, size)
+	if _, err := r.ReadAt(header, 32+int64(offset)); err != nil {
+		return false
+	}
+
+	return bytes.Contains(header, sevenZipAESCoder)
+}
+
+// verifySevenZipPassword 读取第一个非空文件校验密码，文件头未加密时密码错误只能在读取内容时发现
+func verifySevenZipPassword(zr *sevenzip.Reader) bool {
+	for _, f := range zr.File {
+		info := f.FileInfo()
+		if !info.Mode().IsRegular() || info.Size() == 0 {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return false
+		}
+		defer rc.Close()
+
+		// 完整读取时会校验 CRC
+		if info.Size() <= sevenZipVerifySize {
+			_, err = io.Copy(io.Discard, rc)
+		} else {
+			_, err = io.CopyN(io.Discard, rc, sevenZipVerifySize)
+		}
+
+		return err == nil
+	}
+
+	return true
+}
@@ -0,0 +1,39 @@
+package filesystem
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mholt/archiver/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSevenZip_Match(t *testing.T) {
+	asserts := assert.New(t)
+
+	res, err := SevenZip{}.Match("1.zip", strings.NewReader("7z\xBC\xAF\x27\x1C\x00\x04"))
+	asserts.NoError(err)
+	asserts.True(res.Matched())
+
+	// 不依据扩展名识别
+	res, err = SevenZip{}.Match("1.7z", strings.NewReader("PK\x03\x04"))
+	asserts.NoError(err)
+	asserts.False(res.Matched())
+
+	format, _, err := archiver.Identify("", strings.NewReader("7z\xBC\xAF\x27\x1C\x00\x04"))
+	asserts.NoError(err)
+	asserts.IsType(SevenZip{}, format)
+}
+
+func TestSevenZip_Extract(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 不支持随机读取
+	err := SevenZip{}.Extract(context.Background(), strings.NewReader(""), nil, nil)
+	asserts.Error(err)
+
+	// 无效的文件
+	err = SevenZip{}.Extract(context.Background(), strings.NewReader("7z\xBC\xAF\x27\x1C"), nil, nil)
+	asserts.Error(err)
+}
@@ -0,0 +1,347 @@
+package filesystem
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"hash/crc32"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/Jaylenwa/Vfoy/pkg/util"
+	"github.com/ulikunitz/xz/lzma"
+)
+
+/* ================
+	 7z 格式写入
+   ================
+*/
+
+// 7z 文件头中的属性 ID
+const (
+	sevenZipIDEnd              = 0x00
+	sevenZipIDHeader           = 0x01
+	sevenZipIDMainStreamsInfo  = 0x04
+	sevenZipIDFilesInfo        = 0x05
+	sevenZipIDPackInfo         = 0x06
+	sevenZipIDUnpackInfo       = 0x07
+	sevenZipIDSubStreamsInfo   = 0x08
+	sevenZipIDSize             = 0x09
+	sevenZipIDCRC              = 0x0A
+	sevenZipIDFolder           = 0x0B
+	sevenZipIDCodersUnpackSize = 0x0C
+	sevenZipIDNumUnpackStream  = 0x0D
+	sevenZipIDEmptyStream      = 0x0E
+	sevenZipIDEmptyFile        = 0x0F
+	sevenZipIDName             = 0x11
+	sevenZipIDMTime            = 0x14
+)
+
+const (
+	// sevenZipSignatureHeaderLen 文件开头的签名头长度，其中记录了文件头的位置
+	sevenZipSignatureHeaderLen = 32
+	// sevenZipLZMA2 LZMA2 编码器 ID
+	sevenZipLZMA2 = 0x21
+	// sevenZipDefaultLevel 未指定压缩级别时使用的级别
+	sevenZipDefaultLevel = 5
+	// filetimeUnixEpoch Unix 纪元对应的 Windows FILETIME，单位为 100 纳秒
+	filetimeUnixEpoch = 116444736000000000
+)
+
+// sevenZipEntry 已写入的文件，大小为实际写入的长度
+type sevenZipEntry struct {
+	name     string
+	size     uint64
+	crc      uint32
+	modified time.Time
+}
+
+// sevenZipArchiveWriter 7z 格式写入器，所有文件以 LZMA2 压缩为一个固实数据流，
+// 结束时在末尾写入文件头，并回到开头写入签名头，因此需要可 Seek 的输出
+type sevenZipArchiveWriter struct {
+	w       io.WriteSeeker
+	packed  *countingWriter
+	lzma    *lzma.Writer2
+	dictCap int
+	entries []sevenZipEntry
+	current *sevenZipEntryWriter
+}
+
+// sevenZipEntryWriter 计算当前文件的长度与 CRC
+type sevenZipEntryWriter struct {
+	w    io.Writer
+	size uint64
+	crc  hash.Hash32
+}
+
+func (w *sevenZipEntryWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.size += uint64(n)
+	w.crc.Write(p[:n])
+	return n, err
+}
+
+// countingWriter 记录写入的字节数
+type countingWriter struct {
+	w io.Writer
+	n uint64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += uint64(n)
+	return n, err
+}
+
+// sevenZipDictCap 压缩级别对应的字典大小，级别 1 为 64 KiB，每级扩大 4 倍，最大 64 MiB
+func sevenZipDictCap(level int) int {
+	if level == 0 {
+		level = sevenZipDefaultLevel
+	}
+
+	shift := 16 + 2*(level-1)
+	if shift > 26 {
+		shift = 26
+	}
+
+	return 1 << shift
+}
+
+func newSevenZipArchiveWriter(writer io.Writer, level int) (*sevenZipArchiveWriter, error) {
+	ws, ok := writer.(io.WriteSeeker)
+	if !ok {
+		return nil, ErrUnsupportedArchive.WithError(errors.New("7z output must be seekable"))
+	}
+
+	// 为签名头预留位置
+	if _, err := ws.Write(make([]byte, sevenZipSignatureHeaderLen)); err != nil {
+		return nil, err
+	}
+
+	return &sevenZipArchiveWriter{
+		w:       ws,
+		packed:  &countingWriter{w: ws},
+		dictCap: sevenZipDictCap(level),
+	}, nil
+}
+
+// finish 记录当前文件的实际长度与 CRC
+func (w *sevenZipArchiveWriter) finish() {
+	if w.current == nil {
+		return
+	}
+
+	entry := &w.entries[len(w.entries)-1]
+	entry.size = w.current.size
+	entry.crc = w.current.crc.Sum32()
+	w.current = nil
+}
+
+func (w *sevenZipArchiveWriter) Create(name string, size uint64, modified time.Time) (io.Writer, error) {
+	w.finish()
+
+	// 写入第一个文件时才开始数据流，空压缩文件中只有签名头
+	if w.lzma == nil {
+		lw, err := lzma.Writer2Config{DictCap: w.dictCap}.NewWriter2(w.packed)
+		if err != nil {
+			return nil, err
+		}
+		w.lzma = lw
+	}
+
+	w.entries = append(w.entries, sevenZipEntry{
+		name:     strings.TrimPrefix(util.FormSlash(name), "/"),
+		modified: modified,
+	})
+	w.current = &sevenZipEntryWriter{w: w.lzma, crc: crc32.NewIEEE()}
+	return w.current, nil
+}
+
+func (w *sevenZipArchiveWriter) Close() error {
+	w.finish()
+	if w.lzma != nil {
+		if err := w.lzma.Close(); err != nil {
+			return err
+		}
+	}
+
+	var header []byte
+	if len(w.entries) > 0 {
+		header = w.header()
+	}
+
+	if _, err := w.w.Write(header); err != nil {
+		return err
+	}
+
+	// 签名头记录文件头相对于签名头末尾的偏移、长度与 CRC，空压缩文件中均为 0
+	signature := make([]byte, sevenZipSignatureHeaderLen)
+	copy(signature, sevenZipHeader)
+	signature[7] = 4
+	binary.LittleEndian.PutUint64(signature[12:], w.packed.n)
+	binary.LittleEndian.PutUint64(signature[20:], uint64(len(header)))
+	binary.LittleEndian.PutUint32(signature[28:], crc32.ChecksumIEEE(header))
+	binary.LittleEndian.PutUint32(signature[8:], crc32.ChecksumIEEE(signature[12:]))
+
+	if _, err := w.w.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := w.w.Write(signature); err != nil {
+		return err
+	}
+
+	_, err := w.w.Seek(0, io.SeekEnd)
+	return err
+}
+
+// header 生成未压缩的文件头，长度为 0 的文件不占用数据流。
+// 空文件排在最前，兼容按文件序号读取空文件标记的解压实现
+func (w *sevenZipArchiveWriter) header() []byte {
+	var (
+		buf        = &bytes.Buffer{}
+		empties    []sevenZipEntry
+		streams    []sevenZipEntry
+		unpackSize uint64
+	)
+
+	for _, entry := range w.entries {
+		if entry.size == 0 {
+			empties = append(empties, entry)
+			continue
+		}
+
+		streams = append(streams, entry)
+		unpackSize += entry.size
+	}
+
+	entries := append(empties, streams...)
+
+	buf.WriteByte(sevenZipIDHeader)
+
+	if len(streams) > 0 {
+		buf.WriteByte(sevenZipIDMainStreamsInfo)
+
+		buf.WriteByte(sevenZipIDPackInfo)
+		write7zNumber(buf, 0)
+		write7zNumber(buf, 1)
+		buf.WriteByte(sevenZipIDSize)
+		write7zNumber(buf, w.packed.n)
+		buf.WriteByte(sevenZipIDEnd)
+
+		// 单个目录，仅包含一个 LZMA2 编码器，属性为字典大小
+		buf.WriteByte(sevenZipIDUnpackInfo)
+		buf.WriteByte(sevenZipIDFolder)
+		write7zNumber(buf, 1)
+		buf.WriteByte(0)
+		write7zNumber(buf, 1)
+		buf.WriteByte(0x20 | 1)
+		buf.WriteByte(sevenZipLZMA2)
+		write7zNumber(buf, 1)
+		buf.WriteByte(lzma.EncodeDictCap(int64(w.dictCap)))
+		buf.WriteByte(sevenZipIDCodersUnpackSize)
+		write7zNumber(buf, unpackSize)
+		buf.WriteByte(sevenZipIDEnd)
+
+		buf.WriteByte(sevenZipIDSubStreamsInfo)
+		buf.WriteByte(sevenZipIDNumUnpackStream)
+		write7zNumber(buf, uint64(len(streams)))
+		if len(streams) > 1 {
+			buf.WriteByte(sevenZipIDSize)
+			for _, entry := range streams[:len(streams)-1] {
+				write7zNumber(buf, entry.size)
+			}
+		}
+		buf.WriteByte(sevenZipIDCRC)
+		buf.WriteByte(1)
+		for _, entry := range streams {
+			binary.Write(buf, binary.LittleEndian, entry.crc)
+		}
+		buf.WriteByte(sevenZipIDEnd)
+
+		buf.WriteByte(sevenZipIDEnd)
+	}
+
+	buf.WriteByte(sevenZipIDFilesInfo)
+	write7zNumber(buf, uint64(len(entries)))
+
+	if len(empties) > 0 {
+		emptyStream := make([]bool, len(entries))
+		emptyFile := make([]bool, len(empties))
+		for i := range empties {
+			emptyStream[i] = true
+			// 不占用数据流的条目都是空文件而非目录
+			emptyFile[i] = true
+		}
+
+		write7zProperty(buf, sevenZipIDEmptyStream, bitVector(emptyStream))
+		write7zProperty(buf, sevenZipIDEmptyFile, bitVector(emptyFile))
+	}
+
+	names := &bytes.Buffer{}
+	names.WriteByte(0)
+	for _, entry := range entries {
+		for _, c := range utf16.Encode([]rune(entry.name)) {
+			binary.Write(names, binary.LittleEndian, c)
+		}
+		binary.Write(names, binary.LittleEndian, uint16(0))
+	}
+	write7zProperty(buf, sevenZipIDName, names.Bytes())
+
+	times := &bytes.Buffer{}
+	times.Write([]byte{1, 0})
+	for _, entry := range entries {
+		binary.Write(times, binary.LittleEndian, uint64(entry.modified.UnixNano()/100+filetimeUnixEpoch))
+	}
+	write7zProperty(buf, sevenZipIDMTime, times.Bytes())
+
+	buf.WriteByte(sevenZipIDEnd)
+	buf.WriteByte(sevenZipIDEnd)
+
+	return buf.Bytes()
+}
+
+// write7zProperty 写入属性 ID、长度及内容
+func write7zProperty(buf *bytes.Buffer, id byte, data []byte) {
+	buf.WriteByte(id)
+	write7zNumber(buf, uint64(len(data)))
+	buf.Write(data)
+}
+
+// write7zNumber 写入 7z 的变长整数，首字节高位中 1 的个数为后续字节数
+func write7zNumber(buf *bytes.Buffer, value uint64) {
+	var (
+		first byte
+		mask  byte = 0x80
+		i     int
+	)
+
+	for i = 0; i < 8; i++ {
+		if value < uint64(1)<<(7*(i+1)) {
+			first |= byte(value >> (8 * i))
+			break
+		}
+		first |= mask
+		mask >>= 1
+	}
+
+	buf.WriteByte(first)
+	for ; i > 0; i-- {
+		buf.WriteByte(byte(value))
+		value >>= 8
+	}
+}
+
+// bitVector 将布尔数组按高位在前打包为字节
+func bitVector(bits []bool) []byte {
+	res := make([]byte, (len(bits)+7)/8)
+	for i, bit := range bits {
+		if bit {
+			res[i/8] |= 0x80 >> (i % 8)
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,144 @@
+package filesystem
+
+import (
+	"context"
+	"path"
+
+	model "github.com/Jaylenwa/Vfoy/models"
+	"github.com/Jaylenwa/Vfoy/pkg/serializer"
+)
+
+/* ================
+	 收藏与最近文件
+   ================
+*/
+
+// Star 收藏目录和文件，只能收藏自己的对象
+func (fs *FileSystem) Star(ctx context.Context, dirs, files []uint) error {
+	return fs.setStarred(dirs, files, true)
+}
+
+// Unstar 取消收藏目录和文件
+func (fs *FileSystem) Unstar(ctx context.Context, dirs, files []uint) error {
+	return fs.setStarred(dirs, files, false)
+}
+
+func (fs *FileSystem) setStarred(dirs, files []uint, starred bool) error {
+	update := model.UnstarObjects
+	if starred {
+		update = model.StarObjects
+		if len(dirs) > 0 {
+			folders, err := model.GetFoldersByIDs(dirs, fs.User.ID)
+			if err != nil || len(folders) != len(dirs) {
+				return ErrObjectNotExist
+			}
+		}
+
+		if len(files) > 0 {
+			fileObjects, err := model.GetFilesByIDs(files, fs.User.ID)
+			if err != nil || len(fileObjects) != len(files) {
+				return ErrObjectNotExist
+			}
+		}
+	}
+
+	if err := update(fs.User.ID, true, dirs); err != nil {
+		return serializer.NewError(serializer.CodeDBError, "Failed to update starred objects", err)
+	}
+
+	if err := update(fs.User.ID, false, files); err != nil {
+		return serializer.NewError(serializer.CodeDBError, "Failed to update starred objects", err)
+	}
+
+	return nil
+}
+
+// ListStarred 列出收藏的目录和文件，已在回收站中的对象不会列出
+func (fs *FileSystem) ListStarred(ctx context.Context) ([]serializer.Object, error) {
+	stars, err := model.ListStars(fs.User.ID)
+	if err != nil {
+		return nil, ErrDBListObjects.WithError(err)
+	}
+
+	folderIDs := make([]uint, 0)
+	fileIDs := make([]uint, 0)
+	for _, star := range stars {
+		if star.IsFolder {
+			folderIDs = append(folderIDs, star.ObjectID)
+		} else {
+			fileIDs = append(fileIDs, star.ObjectID)
+		}
+	}
+
+	folders := make([]model.Folder, 0)
+	if len(folderIDs) > 0 {
+		if folders, err = model.GetFoldersByIDs(folderIDs, fs.User.ID); err != nil {
+			return nil, ErrDBListObjects.WithError(err)
+		}
+	}
+
+	files := make([]model.File, 0)
+	if len(fileIDs) > 0 {
+		if files, err = model.GetFilesByIDs(fileIDs, fs.User.ID); err != nil {
+			return nil, ErrDBListObjects.WithError(err)
+		}
+	}
+
+	// 按收藏的先后排列
+	foldersByID := make(map[uint]model.Folder, len(folders))
+	for _, folder := range folders {
+		foldersByID[folder.ID] = folder
+	}
+
+	filesByID := make(map[uint]model.File, len(files))
+	for _, file := range files {
+		filesByID[file.ID] = file
+	}
+
+	resolver := newPathResolver(fs.User.ID)
+	objects := make([]serializer.Object, 0, len(stars))
+	for _, star := range stars {
+		if star.IsFolder {
+			if folder, ok := foldersByID[star.ObjectID]; ok && folder.ParentID != nil {
+				if parent, ok := resolver.fullPath(*folder.ParentID); ok {
+					objects = append(objects, fs.listObjects(ctx, parent, nil, []model.Folder{folder}, nil)...)
+				}
+			}
+			continue
+		}
+
+		if file, ok := filesByID[star.ObjectID]; ok {
+			if parent, ok := resolver.fullPath(file.FolderID); ok {
+				objects = append(objects, fs.listObjects(ctx, parent, []model.File{file}, nil, nil)...)
+			}
+		}
+	}
+
+	return objects, nil
+}
+
+// pathResolver 查找并缓存目录的完整路径
+type pathResolver struct {
+	uid   uint
+	paths map[uint]string
+}
+
+func newPathResolver(uid uint) *pathResolver {
+	return &pathResolver{uid: uid, paths: make(map[uint]string)}
+}
+
+// fullPath 返回目录的完整路径，目录或其上级目录已不存在时返回 false
+func (r *pathResolver) fullPath(id uint) (string, bool) {
+	if p, ok := r.paths[id]; ok {
+		return p, p != ""
+	}
+
+	folders, err := model.GetFoldersByIDs([]uint{id}, r.uid)
+	if err != nil || len(folders) == 0 || folders[0].TraceRoot() != nil {
+		r.paths[id] = ""
+		return "", false
+	}
+
+	r.paths[id] = path.Join(folders[0].Position, folders[0].Name)
+	return r.paths[id], true
+}
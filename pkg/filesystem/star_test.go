@@ -0,0 +1,88 @@
+package filesystem
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/Jaylenwa/Vfoy/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSystem_Star(t *testing.T) {
+	a := assert.New(t)
+	ctx := context.Background()
+	fs := &FileSystem{User: &model.User{}}
+	fs.User.ID = 1
+
+	// 对象不存在
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		a.ErrorIs(fs.Star(ctx, nil, []uint{2}), ErrObjectNotExist)
+		a.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 成功
+	{
+		mock.ExpectQuery("SELECT(.+)folders(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+		mock.ExpectQuery("SELECT(.+)stars(.+)").WithArgs(1, true, 3).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)stars(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		a.NoError(fs.Star(ctx, []uint{3}, nil))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 取消收藏无需检查对象
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE(.+)stars(.+)").WithArgs(1, false, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		a.NoError(fs.Unstar(ctx, nil, []uint{2}))
+		a.NoError(mock.ExpectationsWereMet())
+	}
+}
+
+func TestFileSystem_ListStarred(t *testing.T) {
+	a := assert.New(t)
+	ctx := context.Background()
+	fs := &FileSystem{User: &model.User{}}
+	fs.User.ID = 1
+
+	mock.ExpectQuery("SELECT(.+)stars(.+)").WillReturnRows(
+		sqlmock.NewRows([]string{"id", "is_folder", "object_id"}).
+			AddRow(4, false, 10).
+			AddRow(3, true, 20).
+			AddRow(2, false, 11). // 已删除
+			AddRow(1, false, 12), // 父目录已在回收站中
+	)
+	mock.ExpectQuery("SELECT(.+)folders(.+)").WillReturnRows(
+		sqlmock.NewRows([]string{"id", "name", "parent_id"}).AddRow(20, "docs", 1),
+	)
+	mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnRows(
+		sqlmock.NewRows([]string{"id", "name", "folder_id"}).AddRow(10, "a.txt", 20).AddRow(12, "b.txt", 30),
+	)
+	// 文件 a.txt 的父目录 /docs
+	mock.ExpectQuery("SELECT(.+)folders(.+)").WithArgs(20, 1).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "name", "parent_id", "owner_id"}).AddRow(20, "docs", 1, 1),
+	)
+	mock.ExpectQuery("SELECT(.+)folders(.+)").WithArgs(1, 1).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "name", "owner_id"}).AddRow(1, "/", 1),
+	)
+	// 目录 docs 的父目录 /
+	mock.ExpectQuery("SELECT(.+)folders(.+)").WithArgs(1, 1).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "name", "owner_id"}).AddRow(1, "/", 1),
+	)
+	// 文件 b.txt 的父目录不存在
+	mock.ExpectQuery("SELECT(.+)folders(.+)").WithArgs(30, 1).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	objects, err := fs.ListStarred(ctx)
+	a.NoError(mock.ExpectationsWereMet())
+	a.NoError(err)
+	a.Len(objects, 2)
+	a.Equal("a.txt", objects[0].Name)
+	a.Equal("/docs", objects[0].Path)
+	a.Equal("docs", objects[1].Name)
+	a.Equal("dir", objects[1].Type)
+	a.Equal("/", objects[1].Path)
+}
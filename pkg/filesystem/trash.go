@@ -0,0 +1,346 @@
+package filesystem
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	model "github.com/Jaylenwa/Vfoy/models"
+	"github.com/Jaylenwa/Vfoy/pkg/util"
+)
+
+/* =================
+	 回收站
+   =================
+*/
+
+const (
+	// RestoreConflictFail 恢复时遇到同名对象则失败
+	RestoreConflictFail = "fail"
+	// RestoreConflictRename 恢复时遇到同名对象则自动重命名
+	RestoreConflictRename = "rename"
+	// RestoreConflictOverwrite 恢复时遇到同名对象则将其移入回收站
+	RestoreConflictOverwrite = "overwrite"
+)
+
+// Remove 删除用户选中的对象，用户组设定了回收站保留天数时移入回收站，
+// force 或 unlink 为 true 时直接删除
+func (fs *FileSystem) Remove(ctx context.Context, dirs, files []uint, force, unlink bool) error {
+	if retention := fs.User.Group.OptionsSerialized.TrashRetention; retention > 0 && !force && !unlink {
+		return fs.Trash(ctx, dirs, files, retention)
+	}
+
+	return fs.Delete(ctx, dirs, files, force, unlink)
+}
+
+// Trash 将对象移入回收站，retention 为保留的天数。上传中的占位文件会被直接删除。
+func (fs *FileSystem) Trash(ctx context.Context, dirs, files []uint, retention int) error {
+	expires := time.Now().Add(time.Duration(retention) * 24 * time.Hour)
+
+	if len(dirs) > 0 {
+		folders, err := model.GetFoldersByIDs(dirs, fs.User.ID)
+		if err != nil {
+			return ErrDBListObjects.WithError(err)
+		}
+
+		for _, folder := range folders {
+			if folder.ParentID == nil {
+				return ErrRootProtected
+			}
+
+			if err := fs.trashFolder(&folder, expires); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(files) > 0 {
+		targets, err := model.GetFilesByIDs(files, fs.User.ID)
+		if err != nil {
+			return ErrDBListObjects.WithError(err)
+		}
+
+		placeholders := make([]uint, 0)
+		for _, file := range targets {
+			if file.UploadSessionID != nil {
+				placeholders = append(placeholders, file.ID)
+				continue
+			}
+
+			if err := fs.trashFile(&file, expires); err != nil {
+				return err
+			}
+		}
+
+		if len(placeholders) > 0 {
+			return fs.Delete(ctx, nil, placeholders, false, false)
+		}
+	}
+
+	return nil
+}
+
+func (fs *FileSystem) trashFolder(folder *model.Folder, expires time.Time) error {
+	subFolders, err := model.GetRecursiveChildFolder([]uint{folder.ID}, fs.User.ID, true)
+	if err != nil {
+		return ErrDBListObjects.WithError(err)
+	}
+
+	subFiles, err := model.GetChildFilesOfFolders(&subFolders)
+	if err != nil {
+		return ErrDBListObjects.WithError(err)
+	}
+
+	if err := folder.TraceRoot(); err != nil {
+		return ErrObjectNotExist.WithError(err)
+	}
+
+	folderIDs := make([]uint, len(subFolders))
+	for i, f := range subFolders {
+		folderIDs[i] = f.ID
+	}
+
+	var size uint64
+	fileIDs := make([]uint, len(subFiles))
+	for i, f := range subFiles {
+		fileIDs[i] = f.ID
+		size += f.Size
+	}
+
+	trash := &model.Trash{
+		UserID:       fs.User.ID,
+		ObjectID:     folder.ID,
+		IsFolder:     true,
+		Name:         folder.Name,
+		Key:          trashKey(),
+		OriginalPath: folder.Position,
+		ParentID:     *folder.ParentID,
+		Size:         size,
+		ExpiredAt:    &expires,
+	}
+
+	if err := trash.Create(folderIDs, fileIDs); err != nil {
+		return ErrDBDeleteObjects.WithError(err)
+	}
+
+	// 回收站中的对象不占用目录配额
+	fs.changeFolderUsage("-", map[uint]model.FolderUsage{
+		*folder.ParentID: {Size: size, Files: uint64(len(subFiles))},
+	})
+
+	fs.journal(folderChange(model.ChangeDelete, folder))
+
+	return nil
+}
+
+func (fs *FileSystem) trashFile(file *model.File, expires time.Time) error {
+	parents, err := model.GetFoldersByIDs([]uint{file.FolderID}, fs.User.ID)
+	if err != nil || len(parents) == 0 {
+		return ErrObjectNotExist.WithError(err)
+	}
+
+	parent := parents[0]
+	if err := parent.TraceRoot(); err != nil {
+		return ErrObjectNotExist.WithError(err)
+	}
+
+	trash := &model.Trash{
+		UserID:       fs.User.ID,
+		ObjectID:     file.ID,
+		Name:         file.Name,
+		Key:          trashKey(),
+		OriginalPath: path.Join(parent.Position, parent.Name),
+		ParentID:     parent.ID,
+		Size:         file.Size,
+		ExpiredAt:    &expires,
+	}
+
+	if err := trash.Create(nil, []uint{file.ID}); err != nil {
+		return ErrDBDeleteObjects.WithError(err)
+	}
+
+	// 回收站中的对象不占用目录配额
+	fs.changeFolderUsage("-", map[uint]model.FolderUsage{parent.ID: {Size: file.Size, Files: 1}})
+
+	fs.journal(fileChange(model.ChangeDelete, file))
+
+	return nil
+}
+
+// listTrashedObjects 列出回收站记录包含的目录和文件，已被其他记录包含的子对象会被排除
+func (fs *FileSystem) listTrashedObjects(trash *model.Trash) ([]model.Folder, []model.File, error) {
+	if !trash.IsFolder {
+		file, err := model.GetTrashedFileByID(trash.ObjectID, fs.User.ID)
+		if err != nil {
+			return nil, nil, ErrObjectNotExist
+		}
+		return []model.Folder{}, []model.File{*file}, nil
+	}
+
+	excludeFolders, excludeFiles := model.GetTrashRootIDs(fs.User.ID)
+	for i, id := range excludeFolders {
+		if id == trash.ObjectID {
+			excludeFolders = append(excludeFolders[:i], excludeFolders[i+1:]...)
+			break
+		}
+	}
+
+	folders, err := model.GetTrashedFolderTree(trash.ObjectID, fs.User.ID, excludeFolders)
+	if err != nil {
+		return nil, nil, ErrDBListObjects.WithError(err)
+	}
+
+	if len(folders) == 0 {
+		return nil, nil, ErrObjectNotExist
+	}
+
+	folderIDs := make([]uint, len(folders))
+	for i, f := range folders {
+		folderIDs[i] = f.ID
+	}
+
+	files, err := model.GetTrashedFiles(folderIDs, fs.User.ID, excludeFiles)
+	if err != nil {
+		return nil, nil, ErrDBListObjects.WithError(err)
+	}
+
+	return folders, files, nil
+}
+
+// RestoreTrash 从回收站恢复对象到原始位置，conflict 指定遇到同名对象时的处理方式。
+// 原始目录不存在时会按原路径重新创建。
+func (fs *FileSystem) RestoreTrash(ctx context.Context, items []model.Trash, conflict string) error {
+	for i := range items {
+		item := &items[i]
+		folders, files, err := fs.listTrashedObjects(item)
+		if err != nil {
+			return err
+		}
+
+		// 找到原始父目录
+		var parent *model.Folder
+		if parents, err := model.GetFoldersByIDs([]uint{item.ParentID}, fs.User.ID); err == nil && len(parents) > 0 {
+			parent = &parents[0]
+		} else {
+			parent, err = fs.CreateDirectory(ctx, item.OriginalPath)
+			if err != nil {
+				return err
+			}
+		}
+
+		name, err := fs.resolveRestoreConflict(ctx, parent, item.Name, conflict)
+		if err != nil {
+			return err
+		}
+
+		folderIDs := make([]uint, len(folders))
+		for i, f := range folders {
+			folderIDs[i] = f.ID
+		}
+
+		var usage model.FolderUsage
+		fileIDs := make([]uint, len(files))
+		for i, f := range files {
+			fileIDs[i] = f.ID
+			usage.Size += f.Size
+			usage.Files++
+		}
+
+		if err := fs.CheckFolderQuota(parent.ID, usage); err != nil {
+			return err
+		}
+
+		if err := item.Restore(name, parent.ID, folderIDs, fileIDs); err != nil {
+			return ErrDBListObjects.WithError(err)
+		}
+
+		fs.changeFolderUsage("+", map[uint]model.FolderUsage{parent.ID: usage})
+
+		fs.journal(model.Change{
+			Type:     model.ChangeCreate,
+			IsFolder: item.IsFolder,
+			ObjectID: item.ObjectID,
+			ParentID: parent.ID,
+			Name:     name,
+		})
+	}
+
+	return nil
+}
+
+// resolveRestoreConflict 处理恢复目标目录中的同名对象，返回最终使用的对象名
+func (fs *FileSystem) resolveRestoreConflict(ctx context.Context, parent *model.Folder, name, conflict string) (string, error) {
+	isExist := func(name string) (bool, *model.Folder, *model.File) {
+		if folder, err := parent.GetChild(name); err == nil {
+			return true, folder, nil
+		}
+		if ok, file := fs.IsChildFileExist(parent, name); ok {
+			return true, nil, file
+		}
+		return false, nil, nil
+	}
+
+	exist, folder, file := isExist(name)
+	if !exist {
+		return name, nil
+	}
+
+	switch conflict {
+	case RestoreConflictRename:
+		ext := path.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		for i := 1; ; i++ {
+			candidate := fmt.Sprintf("%s (%d)%s", base, i, ext)
+			if exist, _, _ := isExist(candidate); !exist {
+				return candidate, nil
+			}
+		}
+	case RestoreConflictOverwrite:
+		var dirs, files []uint
+		if folder != nil {
+			dirs = []uint{folder.ID}
+		} else {
+			files = []uint{file.ID}
+		}
+
+		err := fs.Remove(ctx, dirs, files, false, false)
+		fs.CleanTargets()
+		return name, err
+	default:
+		return "", ErrFileExisted
+	}
+}
+
+// PurgeTrash 彻底删除回收站中的对象，并释放其占用的容量
+func (fs *FileSystem) PurgeTrash(ctx context.Context, items []model.Trash) error {
+	purged := make([]uint, 0, len(items))
+	defer func() {
+		if len(purged) > 0 {
+			model.DeleteTrashByIDs(purged)
+		}
+	}()
+
+	for i := range items {
+		folders, files, err := fs.listTrashedObjects(&items[i])
+		if err != nil && err != ErrObjectNotExist {
+			return err
+		}
+
+		fs.CleanTargets()
+		fs.SetTargetDir(&folders)
+		fs.SetTargetFile(&files)
+		if err := fs.deleteTargets(ctx, false, false); err != nil {
+			return err
+		}
+
+		purged = append(purged, items[i].ID)
+	}
+
+	return nil
+}
+
+func trashKey() string {
+	return fmt.Sprintf(".trash_%d_%s", time.Now().UnixNano(), util.RandStringRunes(8))
+}
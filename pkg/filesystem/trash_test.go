@@ -0,0 +1,127 @@
+package filesystem
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/Jaylenwa/Vfoy/models"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSystem_Remove(t *testing.T) {
+	asserts := assert.New(t)
+	fs := &FileSystem{User: &model.User{Model: gorm.Model{ID: 1}}}
+	ctx := context.Background()
+
+	// 未启用回收站，直接删除
+	{
+		mock.ExpectQuery("SELECT(.+)folders(.+)").WithArgs(1, 2).WillReturnError(errors.New("error"))
+		err := fs.Remove(ctx, []uint{2}, nil, false, false)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+	}
+
+	// 启用回收站，移入回收站
+	fs.User.Group.OptionsSerialized.TrashRetention = 7
+	{
+		mock.ExpectQuery("SELECT(.+)folders(.+)").WithArgs(2, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "parent_id"}).AddRow(2, nil))
+		err := fs.Remove(ctx, []uint{2}, nil, false, false)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal(ErrRootProtected, err)
+	}
+
+	// 仅解除关联时直接删除
+	{
+		fs.CleanTargets()
+		mock.ExpectQuery("SELECT(.+)folders(.+)").WithArgs(1, 2).WillReturnError(errors.New("error"))
+		err := fs.Remove(ctx, []uint{2}, nil, false, true)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+	}
+}
+
+func TestFileSystem_Trash(t *testing.T) {
+	asserts := assert.New(t)
+	fs := &FileSystem{User: &model.User{Model: gorm.Model{ID: 1}}}
+	ctx := context.Background()
+
+	// 根目录受保护
+	{
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "parent_id"}).AddRow(1, nil))
+		err := fs.Trash(ctx, []uint{1}, nil, 7)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal(ErrRootProtected, err)
+	}
+
+	// 移入文件
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "folder_id", "size"}).AddRow(2, "1.txt", 1, 10))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "parent_id"}).AddRow(1, "/", nil))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE(.+)files(.+)deleted_at").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("INSERT(.+)trashes").
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 1, 2, false, "1.txt", sqlmock.AnyArg(), "/", 1, 10, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		mock.ExpectQuery("SELECT(.+)folder_quota(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		err := fs.Trash(ctx, nil, []uint{2}, 7)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+	}
+}
+
+func TestFileSystem_RestoreTrash(t *testing.T) {
+	asserts := assert.New(t)
+	fs := &FileSystem{User: &model.User{Model: gorm.Model{ID: 1}}}
+	ctx := context.Background()
+	items := []model.Trash{{ObjectID: 2, Name: "1.txt", ParentID: 1, OriginalPath: "/"}}
+
+	// 存在同名文件
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(2, "key"))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "/"))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(3, "1.txt"))
+		err := fs.RestoreTrash(ctx, items, RestoreConflictFail)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal(ErrFileExisted, err)
+	}
+
+	// 自动重命名
+	{
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(2, "key"))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "/"))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(3, "1.txt"))
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectQuery("SELECT(.+)folder_quota(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)files(.+)deleted_at").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE(.+)files(.+)").WithArgs(1, "1 (1).txt", 2).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("DELETE(.+)trashes").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		mock.ExpectQuery("SELECT(.+)folder_quota(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		err := fs.RestoreTrash(ctx, items, RestoreConflictRename)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+	}
+}
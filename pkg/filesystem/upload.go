@@ -7,7 +7,6 @@ import (
 	"time"
 
 	model "github.com/Jaylenwa/Vfoy/models"
-	"github.com/Jaylenwa/Vfoy/pkg/cache"
 	"github.com/Jaylenwa/Vfoy/pkg/filesystem/fsctx"
 	"github.com/Jaylenwa/Vfoy/pkg/request"
 	"github.com/Jaylenwa/Vfoy/pkg/serializer"
@@ -54,7 +53,10 @@ func (fs *FileSystem) Upload(ctx context.Context, file *fsctx.FileStream) (err e
 		// 处理客户端未完成上传时，关闭连接
 		go fs.CancelUpload(ctx, savePath, file)
 
+		// 计算文件内容摘要
+		hashing := newHashingStream(file)
 		err = fs.Handler.Put(ctx, file)
+		hashing.finish()
 		if err != nil {
 			fs.Trigger(ctx, "AfterUploadFailed", file)
 			return err
@@ -131,6 +133,17 @@ func (fs *FileSystem) CreateUploadSession(ctx context.Context, file *fsctx.FileS
 	// 获取相关有效期设置
 	callBackSessionTTL := model.GetIntSetting("upload_session_timeout", 86400)
 
+	// 用户在存储策略下已有相同内容的文件时直接引用，无需传输数据
+	if file.Hash != "" && file.Size > 0 {
+		if blob, err := model.GetFileByHash(file.Hash, file.Size, fs.Policy.ID, fs.User.ID); err == nil {
+			return fs.instantUpload(ctx, file, blob)
+		}
+	}
+
+	// 客户端提供的摘要未经校验，不能写入占位文件，仅用于上传完成后校验
+	expectedHash := file.Hash
+	file.Hash = ""
+
 	callbackKey := uuid.Must(uuid.NewV4()).String()
 	fileSize := file.Size
 
@@ -142,6 +155,7 @@ func (fs *FileSystem) CreateUploadSession(ctx context.Context, file *fsctx.FileS
 
 	fs.Use("BeforeUpload", HookValidateFile)
 	fs.Use("BeforeUpload", HookValidateCapacity)
+	fs.Use("BeforeUpload", HookValidateFolderQuota)
 
 	// 验证文件规格
 	if err := fs.Upload(ctx, file); err != nil {
@@ -158,6 +172,7 @@ func (fs *FileSystem) CreateUploadSession(ctx context.Context, file *fsctx.FileS
 		SavePath:       file.SavePath,
 		LastModified:   file.LastModified,
 		CallbackSecret: util.RandStringRunes(32),
+		Hash:           expectedHash,
 	}
 
 	// 获取上传凭证
@@ -177,11 +192,7 @@ func (fs *FileSystem) CreateUploadSession(ctx context.Context, file *fsctx.FileS
 	}
 
 	// 创建回调会话
-	err = cache.Set(
-		UploadSessionCachePrefix+callbackKey,
-		*uploadSession,
-		callBackSessionTTL,
-	)
+	err = SaveUploadSession(uploadSession, callBackSessionTTL)
 	if err != nil {
 		return nil, err
 	}
@@ -192,6 +203,28 @@ func (fs *FileSystem) CreateUploadSession(ctx context.Context, file *fsctx.FileS
 	return credential, nil
 }
 
+// instantUpload 创建引用已有物理文件的文件记录，完成秒传
+func (fs *FileSystem) instantUpload(ctx context.Context, file *fsctx.FileStream, blob *model.File) (*serializer.UploadCredential, error) {
+	file.Mode = fsctx.Nop
+	file.SavePath = blob.SourceName
+	file.Checksums = make(map[string]string)
+	for _, key := range []string{model.ChecksumMD5MetadataKey, model.ChecksumSHA1MetadataKey} {
+		if sum := blob.MetadataSerialized[key]; sum != "" {
+			file.Checksums[key] = sum
+		}
+	}
+
+	fs.Use("BeforeUpload", HookValidateFile)
+	fs.Use("BeforeUpload", HookValidateCapacity)
+	fs.Use("BeforeUpload", HookValidateFolderQuota)
+	fs.Use("AfterUpload", GenericAfterUpload)
+	if err := fs.Upload(ctx, file); err != nil {
+		return nil, err
+	}
+
+	return &serializer.UploadCredential{Instant: true}, nil
+}
+
 // UploadFromStream 从文件流上传文件
 func (fs *FileSystem) UploadFromStream(ctx context.Context, file *fsctx.FileStream, resetPolicy bool) error {
 	if resetPolicy {
@@ -208,8 +241,10 @@ func (fs *FileSystem) UploadFromStream(ctx context.Context, file *fsctx.FileStre
 	if fs.Hooks == nil {
 		fs.Use("BeforeUpload", HookValidateFile)
 		fs.Use("BeforeUpload", HookValidateCapacity)
+		fs.Use("BeforeUpload", HookValidateFolderQuota)
 		fs.Use("AfterUploadCanceled", HookDeleteTempFile)
 		fs.Use("AfterUpload", GenericAfterUpload)
+		fs.Use("AfterUpload", HookReplicateFile)
 		fs.Use("AfterValidateFailed", HookDeleteTempFile)
 	}
 	fs.Lock.Unlock()
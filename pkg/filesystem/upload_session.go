@@ -0,0 +1,137 @@
+package filesystem
+
+import (
+	"time"
+
+	model "github.com/Jaylenwa/Vfoy/models"
+	"github.com/Jaylenwa/Vfoy/pkg/cache"
+	"github.com/Jaylenwa/Vfoy/pkg/conf"
+	"github.com/Jaylenwa/Vfoy/pkg/serializer"
+	"github.com/Jaylenwa/Vfoy/pkg/util"
+)
+
+/* ==========================
+     上传会话的持久化与恢复
+   ==========================
+*/
+
+// SaveUploadSession 保存上传会话到缓存，并写入数据库以便重启后恢复
+func SaveUploadSession(session *serializer.UploadSession, ttl int) error {
+	if err := cache.Set(UploadSessionCachePrefix+session.Key, *session, ttl); err != nil {
+		return err
+	}
+
+	record := &model.UploadSession{
+		SessionID:      session.Key,
+		UserID:         session.UID,
+		VirtualPath:    session.VirtualPath,
+		Name:           session.Name,
+		Size:           session.Size,
+		SavePath:       session.SavePath,
+		LastModified:   session.LastModified,
+		PolicyID:       session.Policy.ID,
+		Callback:       session.Callback,
+		CallbackSecret: session.CallbackSecret,
+		UploadURL:      session.UploadURL,
+		UploadID:       session.UploadID,
+		Credential:     session.Credential,
+		Hash:           session.Hash,
+		ExpiredAt:      time.Now().Add(time.Duration(ttl) * time.Second),
+	}
+
+	return record.Create()
+}
+
+// GetUploadSession 查找上传会话，缓存中不存在时从数据库恢复
+func GetUploadSession(id string) (*serializer.UploadSession, bool) {
+	if raw, ok := cache.Get(UploadSessionCachePrefix + id); ok {
+		session := raw.(serializer.UploadSession)
+		return &session, true
+	}
+
+	record, err := model.GetUploadSessionByID(id)
+	if err != nil {
+		return nil, false
+	}
+
+	session, err := restoreUploadSession(record)
+	if err != nil {
+		util.Log().Warning("Failed to restore upload session %q: %s", id, err)
+		return nil, false
+	}
+
+	return session, true
+}
+
+// DeleteUploadSessions 从缓存和数据库中删除上传会话
+func DeleteUploadSessions(ids ...string) {
+	cache.Deletes(ids, UploadSessionCachePrefix)
+
+	// 从机不使用数据库
+	if conf.SystemConfig.Mode == "slave" {
+		return
+	}
+
+	if err := model.DeleteUploadSessionsByIDs(ids); err != nil {
+		util.Log().Warning("Failed to delete upload session records: %s", err)
+	}
+}
+
+// RestoreUploadSessions 启动时将数据库中未过期的上传会话恢复到缓存
+func RestoreUploadSessions() {
+	records, err := model.GetActiveUploadSessions()
+	if err != nil {
+		util.Log().Warning("Failed to list upload sessions: %s", err)
+		return
+	}
+
+	restored := 0
+	for i := range records {
+		if _, ok := cache.Get(UploadSessionCachePrefix + records[i].SessionID); ok {
+			continue
+		}
+
+		if _, err := restoreUploadSession(&records[i]); err != nil {
+			util.Log().Warning("Failed to restore upload session %q: %s", records[i].SessionID, err)
+			continue
+		}
+
+		restored++
+	}
+
+	if restored > 0 {
+		util.Log().Info("Restored %d upload sessions.", restored)
+	}
+}
+
+// restoreUploadSession 根据数据库记录重建上传会话，并以剩余的有效期写入缓存
+func restoreUploadSession(record *model.UploadSession) (*serializer.UploadSession, error) {
+	policy, err := model.GetPolicyByID(record.PolicyID)
+	if err != nil {
+		return nil, err
+	}
+
+	session := &serializer.UploadSession{
+		Key:            record.SessionID,
+		UID:            record.UserID,
+		VirtualPath:    record.VirtualPath,
+		Name:           record.Name,
+		Size:           record.Size,
+		SavePath:       record.SavePath,
+		LastModified:   record.LastModified,
+		Policy:         policy,
+		Callback:       record.Callback,
+		CallbackSecret: record.CallbackSecret,
+		UploadURL:      record.UploadURL,
+		UploadID:       record.UploadID,
+		Credential:     record.Credential,
+		Hash:           record.Hash,
+	}
+
+	ttl := int(time.Until(record.ExpiredAt).Seconds())
+	if ttl <= 0 {
+		return nil, ErrUploadSessionExpired
+	}
+
+	return session, cache.Set(UploadSessionCachePrefix+session.Key, *session, ttl)
+}
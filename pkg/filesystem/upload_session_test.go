@@ -0,0 +1,68 @@
+package filesystem
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/Jaylenwa/Vfoy/pkg/cache"
+	"github.com/Jaylenwa/Vfoy/pkg/serializer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSaveUploadSession(t *testing.T) {
+	a := assert.New(t)
+	session := &serializer.UploadSession{Key: "TestSaveUploadSession", UID: 1, Size: 10}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT(.+)upload_sessions(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	a.NoError(SaveUploadSession(session, 60))
+	a.NoError(mock.ExpectationsWereMet())
+
+	res, ok := GetUploadSession("TestSaveUploadSession")
+	a.True(ok)
+	a.EqualValues(10, res.Size)
+}
+
+func TestGetUploadSession(t *testing.T) {
+	a := assert.New(t)
+	cache.Deletes([]string{"TestGetUploadSession"}, UploadSessionCachePrefix)
+
+	// 缓存与数据库中均不存在
+	mock.ExpectQuery("SELECT(.+)upload_sessions(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	_, ok := GetUploadSession("TestGetUploadSession")
+	a.False(ok)
+	a.NoError(mock.ExpectationsWereMet())
+
+	// 从数据库恢复
+	mock.ExpectQuery("SELECT(.+)upload_sessions(.+)").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "session_id", "user_id", "size", "policy_id", "expired_at"}).
+			AddRow(1, "TestGetUploadSession", 1, 10, 100, time.Now().Add(time.Minute)))
+	mock.ExpectQuery("SELECT(.+)policies(.+)").WillReturnRows(sqlmock.NewRows([]string{"id", "type"}).AddRow(100, "local"))
+	session, ok := GetUploadSession("TestGetUploadSession")
+	a.NoError(mock.ExpectationsWereMet())
+	a.True(ok)
+	a.EqualValues(1, session.UID)
+	a.Equal("local", session.Policy.Type)
+
+	// 已写入缓存
+	_, ok = cache.Get(UploadSessionCachePrefix + "TestGetUploadSession")
+	a.True(ok)
+}
+
+func TestRestoreUploadSessions(t *testing.T) {
+	a := assert.New(t)
+	cache.Set(UploadSessionCachePrefix+"TestRestoreUploadSessions1", serializer.UploadSession{}, 0)
+
+	mock.ExpectQuery("SELECT(.+)upload_sessions(.+)").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "session_id", "policy_id", "expired_at"}).
+			AddRow(1, "TestRestoreUploadSessions1", 101, time.Now().Add(time.Minute)).
+			AddRow(2, "TestRestoreUploadSessions2", 101, time.Now().Add(time.Minute)))
+	mock.ExpectQuery("SELECT(.+)policies(.+)").WillReturnRows(sqlmock.NewRows([]string{"id", "type"}).AddRow(101, "local"))
+	RestoreUploadSessions()
+	a.NoError(mock.ExpectationsWereMet())
+
+	_, ok := cache.Get(UploadSessionCachePrefix + "TestRestoreUploadSessions2")
+	a.True(ok)
+}
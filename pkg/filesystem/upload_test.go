@@ -175,6 +175,8 @@ func TestFileSystem_GetUploadToken(t *testing.T) {
 		testHandler := new(FileHeaderMock)
 		testHandler.On("Token", testMock.Anything, int64(10), testMock.Anything, testMock.Anything).Return(&serializer.UploadCredential{Credential: "test"}, nil)
 		fs.Handler = testHandler
+		mock.ExpectQuery("SELECT(.+)folder_quota(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectQuery("SELECT(.+)folder_quota(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
 		mock.ExpectQuery("SELECT(.+)").
 			WithArgs(1).
 			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}).AddRow(1, 1))
@@ -183,6 +185,10 @@ func TestFileSystem_GetUploadToken(t *testing.T) {
 		mock.ExpectExec("INSERT(.+)files(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectExec("UPDATE(.+)storage(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectCommit()
+		mock.ExpectQuery("SELECT(.+)folder_quota(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)upload_sessions(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
 		res, err := fs.CreateUploadSession(ctx, &fsctx.FileStream{
 			Size:        0,
 			Name:        "file",
@@ -203,6 +209,8 @@ func TestFileSystem_GetUploadToken(t *testing.T) {
 		testHandler := new(FileHeaderMock)
 		testHandler.On("Token", testMock.Anything, int64(10), testMock.Anything, testMock.Anything).Return(&serializer.UploadCredential{}, errors.New("error"))
 		fs.Handler = testHandler
+		mock.ExpectQuery("SELECT(.+)folder_quota(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectQuery("SELECT(.+)folder_quota(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
 		mock.ExpectQuery("SELECT(.+)").
 			WithArgs(1).
 			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}).AddRow(1, 1))
@@ -211,6 +219,7 @@ func TestFileSystem_GetUploadToken(t *testing.T) {
 		mock.ExpectExec("INSERT(.+)files(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectExec("UPDATE(.+)storage(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectCommit()
+		mock.ExpectQuery("SELECT(.+)folder_quota(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
 		_, err := fs.CreateUploadSession(ctx, &fsctx.FileStream{
 			Size:        0,
 			Name:        "file",
@@ -220,6 +229,25 @@ func TestFileSystem_GetUploadToken(t *testing.T) {
 		testHandler.AssertExpectations(t)
 		asserts.Error(err)
 	}
+
+	// 秒传仅查找用户自己的文件
+	{
+		fs := FileSystem{
+			User:   &model.User{Model: gorm.Model{ID: 1}},
+			Policy: &model.Policy{Model: gorm.Model{ID: 3}},
+		}
+		mock.ExpectQuery("SELECT(.+)files(.+)user_id = \\?(.+)").
+			WithArgs("hash", 5, 3, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		_, err := fs.CreateUploadSession(ctx, &fsctx.FileStream{
+			Size:        5,
+			Name:        "file",
+			VirtualPath: "/",
+			Hash:        "hash",
+		})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+	}
 }
 
 func TestFileSystem_UploadFromStream(t *testing.T) {
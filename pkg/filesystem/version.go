@@ -0,0 +1,208 @@
+package filesystem
+
+import (
+	"context"
+
+	model "github.com/Jaylenwa/Vfoy/models"
+	"github.com/Jaylenwa/Vfoy/pkg/filesystem/fsctx"
+	"github.com/Jaylenwa/Vfoy/pkg/util"
+)
+
+/* =================
+	 文件历史版本
+   =================
+*/
+
+// VersioningEnabled 当前用户组是否启用了文件历史版本
+func (fs *FileSystem) VersioningEnabled() bool {
+	return fs.User.Group.OptionsSerialized.MaxFileVersions > 0
+}
+
+// UseVersionedUpdate 为覆盖已有文件的上传配置钩子，新内容写入新的物理路径，原始内容
+// 保存为历史版本。无法为新内容分配不同路径时返回 false，此时不做任何更改。
+func (fs *FileSystem) UseVersionedUpdate(ctx context.Context, originFile *model.File, file *fsctx.FileStream) bool {
+	previous := *originFile
+	savePath := fs.GenerateSavePath(ctx, file)
+	if savePath == previous.SourceName {
+		return false
+	}
+
+	originFile.SourceName = savePath
+	file.Mode &= ^fsctx.Overwrite
+
+	fs.Use("BeforeUpload", HookResetPolicy)
+	fs.Use("BeforeUpload", HookValidateFile)
+	fs.Use("BeforeUpload", HookValidateCapacity)
+	fs.Use("BeforeUpload", HookValidateFolderQuotaDiff)
+	fs.Use("AfterUploadCanceled", HookDeleteTempFile)
+	fs.Use("AfterValidateFailed", HookDeleteTempFile)
+	fs.Use("AfterUpload", HookUpdateSourceName)
+	fs.Use("AfterUpload", GenericAfterUpdate)
+	fs.Use("AfterUpload", HookSaveVersion(&previous))
+	return true
+}
+
+// HookSaveVersion 将文件被覆盖前的内容保存为历史版本
+func HookSaveVersion(previous *model.File) Hook {
+	return func(ctx context.Context, fs *FileSystem, file fsctx.FileHeader) error {
+		version := &model.FileVersion{
+			FileID:     previous.ID,
+			UserID:     previous.UserID,
+			AuthorID:   fs.User.ID,
+			SourceName: previous.SourceName,
+			PolicyID:   previous.PolicyID,
+			Size:       previous.Size,
+		}
+
+		if err := version.Create(); err != nil {
+			return err
+		}
+
+		fs.pruneVersions(ctx, previous)
+		return nil
+	}
+}
+
+// pruneVersions 按照用户组限制删除最旧的历史版本
+func (fs *FileSystem) pruneVersions(ctx context.Context, file *model.File) {
+	versions, err := model.GetFileVersions(file.ID, file.UserID)
+	if err != nil {
+		util.Log().Warning("Failed to list versions of file %q: %s", file.Name, err)
+		return
+	}
+
+	maxVersions := fs.User.Group.OptionsSerialized.MaxFileVersions
+	maxSize := fs.User.Group.OptionsSerialized.MaxVersionSize
+
+	// 版本按时间倒序排列，保留最新的版本直到达到限制
+	var total uint64
+	toBeDeleted := make([]model.FileVersion, 0)
+	for i, version := range versions {
+		total += version.Size
+		if (maxVersions > 0 && i >= maxVersions) || (maxSize > 0 && total > maxSize) {
+			toBeDeleted = append(toBeDeleted, version)
+		}
+	}
+
+	if err := fs.deleteVersions(ctx, file, toBeDeleted); err != nil {
+		util.Log().Warning("Failed to prune versions of file %q: %s", file.Name, err)
+	}
+}
+
+// deleteVersions 删除历史版本的物理文件和记录
+func (fs *FileSystem) deleteVersions(ctx context.Context, file *model.File, versions []model.FileVersion) error {
+	if len(versions) == 0 {
+		return nil
+	}
+
+	if err := fs.deleteVersionSources(ctx, file, versions); err != nil {
+		return err
+	}
+
+	return model.DeleteFileVersions(versions)
+}
+
+// deleteVersionSources 删除历史版本的物理文件，仍被其他文件或版本引用的物理文件会被保留
+func (fs *FileSystem) deleteVersionSources(ctx context.Context, file *model.File, versions []model.FileVersion) error {
+	ids := make([]uint, len(versions))
+	sources := make([]model.File, len(versions))
+	for i, version := range versions {
+		ids[i] = version.ID
+		sources[i] = version.AsFile(file)
+		sources[i].ID = 0
+	}
+
+	sources, err := model.RemoveFilesWithSoftLinks(sources)
+	if err != nil {
+		return err
+	}
+
+	sources, err = model.RemoveFilesReferencedByVersions(sources, ids)
+	if err != nil {
+		return err
+	}
+
+	failed := fs.deleteGroupedFile(ctx, fs.GroupFileByPolicy(ctx, sources))
+	for policy, names := range failed {
+		if len(names) > 0 {
+			util.Log().Warning("Failed to delete %d version file(s) of policy %d.", len(names), policy)
+		}
+	}
+
+	return nil
+}
+
+// deleteVersionsOfFiles 删除已删除文件的全部历史版本
+func (fs *FileSystem) deleteVersionsOfFiles(ctx context.Context, files []*model.File) {
+	if len(files) == 0 {
+		return
+	}
+
+	ids := make([]uint, len(files))
+	for i, file := range files {
+		ids[i] = file.ID
+	}
+
+	versions, err := model.GetFileVersionsByFileIDs(ids)
+	if err != nil {
+		util.Log().Warning("Failed to list versions of deleted files: %s", err)
+		return
+	}
+
+	for _, file := range files {
+		fileVersions := make([]model.FileVersion, 0)
+		for _, version := range versions {
+			if version.FileID == file.ID {
+				fileVersions = append(fileVersions, version)
+			}
+		}
+
+		if err := fs.deleteVersions(ctx, file, fileVersions); err != nil {
+			util.Log().Warning("Failed to delete versions of file %q: %s", file.Name, err)
+		}
+	}
+}
+
+// RestoreVersion 将文件恢复为给定的历史版本，启用历史版本时当前内容会被保存为新版本
+func (fs *FileSystem) RestoreVersion(ctx context.Context, file *model.File, version *model.FileVersion) error {
+	var current *model.FileVersion
+	if fs.VersioningEnabled() {
+		current = &model.FileVersion{
+			FileID:     file.ID,
+			UserID:     file.UserID,
+			AuthorID:   fs.User.ID,
+			SourceName: file.SourceName,
+			PolicyID:   file.PolicyID,
+			Size:       file.Size,
+		}
+	} else if version.Size > file.Size && fs.User.GetRemainingCapacity() < version.Size-file.Size {
+		return ErrInsufficientCapacity
+	}
+
+	if version.Size > file.Size {
+		if err := fs.CheckFolderQuota(file.FolderID, model.FolderUsage{Size: version.Size - file.Size}); err != nil {
+			return err
+		}
+	}
+
+	previous := *file
+	if err := file.RestoreVersion(version, current); err != nil {
+		return ErrDBListObjects.WithError(err)
+	}
+	fs.changeFileSizeUsage(file.FolderID, previous.Size, file.Size)
+	fs.journal(fileChange(model.ChangeUpdate, file))
+
+	// 未保留当前内容时，删除其物理文件
+	if current == nil {
+		if err := fs.deleteVersionSources(ctx, &previous, []model.FileVersion{{
+			SourceName: previous.SourceName,
+			PolicyID:   previous.PolicyID,
+		}}); err != nil {
+			util.Log().Warning("Failed to delete replaced content of file %q: %s", previous.Name, err)
+		}
+		return nil
+	}
+
+	fs.pruneVersions(ctx, file)
+	return nil
+}
@@ -0,0 +1,71 @@
+package filesystem
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/Jaylenwa/Vfoy/models"
+	"github.com/Jaylenwa/Vfoy/pkg/filesystem/fsctx"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSystem_UseVersionedUpdate(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+
+	// 新路径与原路径相同
+	{
+		fs := &FileSystem{
+			User:   &model.User{Model: gorm.Model{ID: 1}},
+			Policy: &model.Policy{DirNameRule: "/", FileNameRule: "{originname}"},
+		}
+		file := &model.File{SourceName: "/1.txt"}
+		stream := &fsctx.FileStream{Name: "1.txt", Mode: fsctx.Overwrite}
+		asserts.False(fs.UseVersionedUpdate(ctx, file, stream))
+		asserts.Equal("/1.txt", file.SourceName)
+		asserts.Empty(fs.Hooks)
+	}
+
+	// 写入新路径
+	{
+		fs := &FileSystem{
+			User:   &model.User{Model: gorm.Model{ID: 1}},
+			Policy: &model.Policy{DirNameRule: "/", FileNameRule: "{randomkey8}_{originname}"},
+		}
+		file := &model.File{SourceName: "1.txt"}
+		stream := &fsctx.FileStream{Name: "1.txt", Mode: fsctx.Overwrite}
+		asserts.True(fs.UseVersionedUpdate(ctx, file, stream))
+		asserts.NotEqual("1.txt", file.SourceName)
+		asserts.Zero(stream.Mode & fsctx.Overwrite)
+		asserts.Len(fs.Hooks["AfterUpload"], 3)
+		asserts.Len(fs.Hooks["AfterUploadCanceled"], 1)
+	}
+}
+
+func TestFileSystem_PruneVersions(t *testing.T) {
+	asserts := assert.New(t)
+	fs := &FileSystem{User: &model.User{
+		Model: gorm.Model{ID: 1},
+		Group: model.Group{OptionsSerialized: model.GroupOption{MaxFileVersions: 1}},
+	}}
+	file := &model.File{Model: gorm.Model{ID: 1}, UserID: 1}
+
+	mock.ExpectQuery("SELECT(.+)file_versions(.+)").WillReturnRows(
+		sqlmock.NewRows([]string{"id", "user_id", "source_name", "policy_id", "size"}).
+			AddRow(3, 1, "3.txt", 1, 3).
+			AddRow(2, 1, "2.txt", 1, 2),
+	)
+	// 查询软链接
+	mock.ExpectQuery("SELECT(.+)files(.+)").WithArgs("2.txt", 1, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "source_name", "policy_id"}).AddRow(5, "2.txt", 1))
+	// 被其他文件引用，无需物理删除
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE(.+)file_versions").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("UPDATE(.+)users(.+)").WithArgs(2, sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	fs.pruneVersions(context.Background(), file)
+	asserts.NoError(mock.ExpectationsWereMet())
+}
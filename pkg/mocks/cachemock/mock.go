@@ -1,6 +1,9 @@
 package cachemock
 
-import "github.com/stretchr/testify/mock"
+import (
+	"github.com/Jaylenwa/Vfoy/pkg/cache"
+	"github.com/stretchr/testify/mock"
+)
 
 type CacheClientMock struct {
 	mock.Mock
@@ -28,6 +31,10 @@ func (c CacheClientMock) Delete(keys []string, prefix string) error {
 	return c.Called(keys, prefix).Error(0)
 }
 
+func (c *CacheClientMock) Update(key string, fn cache.UpdateFunc) error {
+	return c.Called(key, fn).Error(0)
+}
+
 func (c CacheClientMock) Persist(path string) error {
 	return c.Called(path).Error(0)
 }
@@ -0,0 +1,36 @@
+package mq
+
+import (
+	"strconv"
+)
+
+// 推送给用户的事件类型
+const (
+	// EventFileChange 文件系统变更，正文为 serializer.Delta
+	EventFileChange = "change"
+	// EventTaskUpdate 任务状态变更，正文为 serializer.TaskEvent
+	EventTaskUpdate = "task"
+	// EventDownloadUpdate 离线下载状态变更，正文为 serializer.DownloadEvent
+	EventDownloadUpdate = "download"
+)
+
+// userTopicPrefix 用户事件消息主题的前缀
+const userTopicPrefix = "user_event_"
+
+// UserTopic 返回用户事件的消息主题
+func UserTopic(uid uint) string {
+	return userTopicPrefix + strconv.FormatUint(uint64(uid), 10)
+}
+
+// PublishUserEvent 向用户的所有在线客户端推送事件
+func PublishUserEvent(uid uint, event string, content interface{}) {
+	if uid == 0 {
+		return
+	}
+
+	GlobalMQ.Publish(UserTopic(uid), Message{
+		TriggeredBy: UserTopic(uid),
+		Event:       event,
+		Content:     content,
+	})
+}
@@ -0,0 +1,128 @@
+package mq
+
+import (
+	"bytes"
+	"encoding/gob"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Jaylenwa/Vfoy/pkg/conf"
+	"github.com/Jaylenwa/Vfoy/pkg/util"
+	"github.com/gin-gonic/gin"
+	"github.com/gomodule/redigo/redis"
+)
+
+// redisChannelPrefix 消息在 Redis 中发布的频道前缀
+const redisChannelPrefix = "vfoy_mq_"
+
+// redisRetryInterval Redis 订阅连接断开后重连的间隔
+const redisRetryInterval = 5 * time.Second
+
+// Init 初始化消息队列，配置了 Redis 时通过 Redis 在多个主机实例间广播消息
+func Init() {
+	if conf.RedisConfig.Server != "" && gin.Mode() != gin.TestMode {
+		GlobalMQ = NewRedisMQ(
+			conf.RedisConfig.Network,
+			conf.RedisConfig.Server,
+			conf.RedisConfig.User,
+			conf.RedisConfig.Password,
+			conf.RedisConfig.DB,
+		)
+	}
+}
+
+// redisMQ 通过 Redis 发布消息，并将收到的消息分发给本实例的订阅者
+type redisMQ struct {
+	*inMemoryMQ
+	pool *redis.Pool
+}
+
+// NewRedisMQ 创建基于 Redis 发布订阅的消息队列
+func NewRedisMQ(network, address, user, password, database string) MQ {
+	mq := newRedisMQ(&redis.Pool{
+		MaxIdle:     10,
+		IdleTimeout: 240 * time.Second,
+		Dial: func() (redis.Conn, error) {
+			db, err := strconv.Atoi(database)
+			if err != nil {
+				return nil, err
+			}
+
+			return redis.Dial(
+				network,
+				address,
+				redis.DialDatabase(db),
+				redis.DialUsername(user),
+				redis.DialPassword(password),
+			)
+		},
+	})
+
+	go mq.receive()
+	return mq
+}
+
+func newRedisMQ(pool *redis.Pool) *redisMQ {
+	return &redisMQ{
+		inMemoryMQ: NewMQ().(*inMemoryMQ),
+		pool:       pool,
+	}
+}
+
+// Publish 发布消息，无法编码或发布到 Redis 的消息只分发给本实例的订阅者
+func (r *redisMQ) Publish(topic string, message Message) {
+	var buffer bytes.Buffer
+	if err := gob.NewEncoder(&buffer).Encode(message); err != nil {
+		util.Log().Debug("Failed to encode message of topic %q, publish locally: %s", topic, err)
+		r.inMemoryMQ.Publish(topic, message)
+		return
+	}
+
+	conn := r.pool.Get()
+	defer conn.Close()
+	if _, err := conn.Do("PUBLISH", redisChannelPrefix+topic, buffer.Bytes()); err != nil {
+		util.Log().Warning("Failed to publish message to Redis, publish locally: %s", err)
+		r.inMemoryMQ.Publish(topic, message)
+	}
+}
+
+// receive 订阅 Redis 中的消息，连接断开后自动重连
+func (r *redisMQ) receive() {
+	for {
+		if err := r.subscribe(); err != nil {
+			util.Log().Warning("Redis message subscription interrupted, retry in %s: %s", redisRetryInterval, err)
+		}
+		time.Sleep(redisRetryInterval)
+	}
+}
+
+func (r *redisMQ) subscribe() error {
+	conn := r.pool.Get()
+	defer conn.Close()
+
+	psc := redis.PubSubConn{Conn: conn}
+	if err := psc.PSubscribe(redisChannelPrefix + "*"); err != nil {
+		return err
+	}
+
+	for {
+		switch v := psc.Receive().(type) {
+		case redis.Message:
+			r.dispatch(v.Channel, v.Data)
+		case error:
+			return v
+		}
+	}
+}
+
+// dispatch 将 Redis 频道中收到的消息分发给本实例的订阅者
+func (r *redisMQ) dispatch(channel string, data []byte) {
+	var message Message
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&message); err != nil {
+		util.Log().Warning("Failed to decode message from Redis channel %q: %s", channel, err)
+		return
+	}
+
+	r.inMemoryMQ.Publish(strings.TrimPrefix(channel, redisChannelPrefix), message)
+}
@@ -0,0 +1,109 @@
+package mq
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/rafaeljusto/redigomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedisMQ_Publish(t *testing.T) {
+	asserts := assert.New(t)
+	conn := redigomock.NewConn()
+	mq := newRedisMQ(&redis.Pool{
+		Dial:    func() (redis.Conn, error) { return conn, nil },
+		MaxIdle: 10,
+	})
+
+	// 发布到 Redis
+	{
+		cmd := conn.GenericCommand("PUBLISH").Expect(int64(1))
+		notifier := mq.Subscribe("TestRedisMQ_Publish", 1)
+		mq.Publish("TestRedisMQ_Publish", Message{Event: "event"})
+		asserts.Equal(1, conn.Stats(cmd))
+
+		// 由订阅循环分发，不直接分发给本实例
+		select {
+		case <-notifier:
+			asserts.Fail("message should not be delivered locally")
+		case <-time.After(50 * time.Millisecond):
+		}
+		mq.Unsubscribe("TestRedisMQ_Publish", notifier)
+	}
+
+	// 发布失败时分发给本实例
+	{
+		conn.Clear()
+		conn.GenericCommand("PUBLISH").ExpectError(errors.New("error"))
+		notifier := mq.Subscribe("TestRedisMQ_Publish", 1)
+		mq.Publish("TestRedisMQ_Publish", Message{Event: "event"})
+		select {
+		case msg := <-notifier:
+			asserts.Equal("event", msg.Event)
+		case <-time.After(time.Second):
+			asserts.Fail("message not delivered")
+		}
+		mq.Unsubscribe("TestRedisMQ_Publish", notifier)
+	}
+
+	// 无法编码的消息只分发给本实例
+	{
+		conn.Clear()
+		cmd := conn.GenericCommand("PUBLISH").Expect(int64(1))
+		notifier := mq.Subscribe("TestRedisMQ_Publish", 1)
+		mq.Publish("TestRedisMQ_Publish", Message{Event: "event", Content: struct{ A int }{1}})
+		asserts.Equal(0, conn.Stats(cmd))
+		select {
+		case msg := <-notifier:
+			asserts.Equal("event", msg.Event)
+		case <-time.After(time.Second):
+			asserts.Fail("message not delivered")
+		}
+	}
+}
+
+func TestRedisMQ_Dispatch(t *testing.T) {
+	asserts := assert.New(t)
+	mq := newRedisMQ(&redis.Pool{})
+	notifier := mq.Subscribe("TestRedisMQ_Dispatch", 1)
+
+	// 无法解码
+	mq.dispatch(redisChannelPrefix+"TestRedisMQ_Dispatch", []byte("invalid"))
+	asserts.Len(notifier, 0)
+
+	// 成功
+	var buffer bytes.Buffer
+	asserts.NoError(gob.NewEncoder(&buffer).Encode(Message{Event: "event", Content: "content"}))
+	mq.dispatch(redisChannelPrefix+"TestRedisMQ_Dispatch", buffer.Bytes())
+	select {
+	case msg := <-notifier:
+		asserts.Equal("event", msg.Event)
+		asserts.Equal("content", msg.Content)
+	case <-time.After(time.Second):
+		asserts.Fail("message not delivered")
+	}
+}
+
+func TestPublishUserEvent(t *testing.T) {
+	asserts := assert.New(t)
+	notifier := GlobalMQ.Subscribe(UserTopic(1), 1)
+	defer GlobalMQ.Unsubscribe(UserTopic(1), notifier)
+
+	// 匿名用户
+	PublishUserEvent(0, EventTaskUpdate, nil)
+
+	PublishUserEvent(1, EventTaskUpdate, "content")
+	select {
+	case msg := <-notifier:
+		asserts.Equal(EventTaskUpdate, msg.Event)
+		asserts.Equal("content", msg.Content)
+	case <-time.After(time.Second):
+		asserts.Fail("message not delivered")
+	}
+	asserts.NotEqual(UserTopic(1), UserTopic(2))
+}
@@ -0,0 +1,77 @@
+package search
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+)
+
+// extractDocx 提取 Word 文档正文，段落之间以换行分隔
+func extractDocx(content []byte) (string, error) {
+	archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return "", err
+	}
+
+	for _, f := range archive.File {
+		if f.Name != "word/document.xml" {
+			continue
+		}
+
+		document, err := f.Open()
+		if err != nil {
+			return "", err
+		}
+		defer document.Close()
+
+		return extractDocxXML(document)
+	}
+
+	return "", errors.New("word/document.xml not found")
+}
+
+func extractDocxXML(r io.Reader) (string, error) {
+	var (
+		res    strings.Builder
+		inText bool
+	)
+
+	decoder := xml.NewDecoder(r)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return res.String(), err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				res.WriteString(" ")
+			case "br":
+				res.WriteString("\n")
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				res.WriteString("\n")
+			}
+		case xml.CharData:
+			if inText {
+				res.Write(t)
+			}
+		}
+	}
+
+	return res.String(), nil
+}
@@ -0,0 +1,71 @@
+package search
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	ErrUnsupportedType = errors.New("unsupported file type")
+	ErrBinaryContent   = errors.New("file content is not text")
+)
+
+// textExts 按纯文本读取的扩展名
+var textExts = map[string]bool{
+	"txt": true, "md": true, "markdown": true, "csv": true, "tsv": true, "log": true,
+	"json": true, "xml": true, "html": true, "htm": true, "yaml": true, "yml": true,
+	"toml": true, "ini": true, "conf": true, "cfg": true, "tex": true, "rst": true,
+	"go": true, "py": true, "js": true, "ts": true, "jsx": true, "tsx": true, "vue": true,
+	"java": true, "kt": true, "scala": true, "c": true, "h": true, "cpp": true, "hpp": true,
+	"cc": true, "cs": true, "rb": true, "php": true, "rs": true, "swift": true, "lua": true,
+	"pl": true, "r": true, "sh": true, "bat": true, "ps1": true, "sql": true, "css": true,
+	"scss": true, "less": true, "proto": true,
+}
+
+func ext(name string) string {
+	return strings.ToLower(strings.TrimPrefix(filepath.Ext(name), "."))
+}
+
+// Supported 判断是否支持提取此文件的文本内容
+func Supported(name string) bool {
+	e := ext(name)
+	return textExts[e] || e == "pdf" || e == "docx"
+}
+
+// Extract 提取文件中的文本内容，最多读取 maxSize 字节
+func Extract(name string, r io.Reader, maxSize int64) (string, error) {
+	content, err := io.ReadAll(io.LimitReader(r, maxSize))
+	if err != nil {
+		return "", err
+	}
+
+	switch e := ext(name); {
+	case textExts[e]:
+		return extractText(content)
+	case e == "pdf":
+		return extractPDF(content)
+	case e == "docx":
+		return extractDocx(content)
+	default:
+		return "", ErrUnsupportedType
+	}
+}
+
+// extractText 读取纯文本，包含空字符的内容视为二进制文件
+func extractText(content []byte) (string, error) {
+	if bytes.IndexByte(content, 0) >= 0 {
+		return "", ErrBinaryContent
+	}
+
+	// 去除 UTF-8 BOM，截断时可能残留的不完整字符会被替换
+	content = bytes.TrimPrefix(content, []byte("\xef\xbb\xbf"))
+	if !utf8.Valid(content) {
+		return strings.ToValidUTF8(string(content), " "), nil
+	}
+
+	return string(content), nil
+}
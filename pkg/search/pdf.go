@@ -0,0 +1,188 @@
+package search
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// pdfStream 匹配 PDF 中的流对象及其字典
+var pdfStream = regexp.MustCompile(`(?s)<<(.*?)>>\s*stream\r?\n`)
+
+// extractPDF 提取 PDF 内容流中以文本操作符绘制的字符串。
+// 仅支持未压缩或 FlateDecode 压缩的内容流及单字节编码的字体，
+// 无法处理的内容将被忽略
+func extractPDF(content []byte) (string, error) {
+	if !bytes.HasPrefix(content, []byte("%PDF")) {
+		return "", errors.New("invalid pdf header")
+	}
+
+	var res strings.Builder
+	for _, loc := range pdfStream.FindAllSubmatchIndex(content, -1) {
+		dict := content[loc[2]:loc[3]]
+		start := loc[1]
+		end := bytes.Index(content[start:], []byte("endstream"))
+		if end < 0 {
+			break
+		}
+
+		data := content[start : start+end]
+		if bytes.Contains(dict, []byte("/Subtype/Image")) || bytes.Contains(dict, []byte("/Subtype /Image")) {
+			continue
+		}
+
+		if bytes.Contains(dict, []byte("/FlateDecode")) {
+			reader, err := zlib.NewReader(bytes.NewReader(data))
+			if err != nil {
+				continue
+			}
+
+			// 截断的流仍可能包含可用的内容
+			data, _ = io.ReadAll(reader)
+			reader.Close()
+		} else if bytes.Contains(dict, []byte("/Filter")) {
+			continue
+		}
+
+		extractPDFText(data, &res)
+	}
+
+	return res.String(), nil
+}
+
+// extractPDFText 解析内容流中 BT/ET 之间的 Tj、TJ、' 和 " 操作符的字符串操作数
+func extractPDFText(data []byte, res *strings.Builder) {
+	inText := false
+	for i := 0; i < len(data); i++ {
+		switch c := data[i]; {
+		case c == '(' && inText:
+			var str []byte
+			str, i = readPDFString(data, i)
+			res.Write(str)
+		case c == '<' && inText && i+1 < len(data) && data[i+1] != '<':
+			var str []byte
+			str, i = readPDFHexString(data, i)
+			res.Write(str)
+		case isPDFKeyword(data, i, "BT"):
+			inText = true
+			i++
+		case isPDFKeyword(data, i, "ET"):
+			inText = false
+			res.WriteString("\n")
+			i++
+		case inText && (isPDFKeyword(data, i, "Td") || isPDFKeyword(data, i, "TD") || isPDFKeyword(data, i, "T*")):
+			res.WriteString(" ")
+			i++
+		}
+	}
+}
+
+// isPDFKeyword 判断 data[i:] 是否为独立的操作符 keyword
+func isPDFKeyword(data []byte, i int, keyword string) bool {
+	if !bytes.HasPrefix(data[i:], []byte(keyword)) {
+		return false
+	}
+
+	isDelimiter := func(c byte) bool {
+		return strings.IndexByte(" \t\r\n\f()<>[]/%", c) >= 0
+	}
+
+	return (i == 0 || isDelimiter(data[i-1])) && (i+len(keyword) == len(data) || isDelimiter(data[i+len(keyword)]))
+}
+
+// readPDFString 读取以 data[start] 处的 '(' 开头的字面字符串，返回内容及结尾 ')' 的位置
+func readPDFString(data []byte, start int) ([]byte, int) {
+	var str []byte
+	depth := 0
+	for i := start; i < len(data); i++ {
+		c := data[i]
+		switch c {
+		case '\\':
+			if i+1 >= len(data) {
+				return str, i
+			}
+			i++
+			switch e := data[i]; e {
+			case 'n', 'r', 't':
+				str = append(str, ' ')
+			case 'b', 'f':
+			case '0', '1', '2', '3', '4', '5', '6', '7':
+				// 最多三位的八进制转义
+				v := 0
+				j := i
+				for ; j < len(data) && j < i+3 && data[j] >= '0' && data[j] <= '7'; j++ {
+					v = v*8 + int(data[j]-'0')
+				}
+				str = appendPDFByte(str, byte(v))
+				i = j - 1
+			default:
+				str = append(str, e)
+			}
+		case '(':
+			if depth > 0 {
+				str = append(str, c)
+			}
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return str, i
+			}
+			str = append(str, c)
+		default:
+			str = appendPDFByte(str, c)
+		}
+	}
+
+	return str, len(data)
+}
+
+// readPDFHexString 读取以 data[start] 处的 '<' 开头的十六进制字符串
+func readPDFHexString(data []byte, start int) ([]byte, int) {
+	end := bytes.IndexByte(data[start:], '>')
+	if end < 0 {
+		return nil, len(data)
+	}
+
+	var (
+		str  []byte
+		high = -1
+	)
+	for _, c := range data[start+1 : start+end] {
+		var v int
+		switch {
+		case c >= '0' && c <= '9':
+			v = int(c - '0')
+		case c >= 'a' && c <= 'f':
+			v = int(c-'a') + 10
+		case c >= 'A' && c <= 'F':
+			v = int(c-'A') + 10
+		default:
+			continue
+		}
+
+		if high < 0 {
+			high = v
+		} else {
+			str = appendPDFByte(str, byte(high<<4|v))
+			high = -1
+		}
+	}
+
+	return str, start + end
+}
+
+// appendPDFByte 按 Latin-1 解释单字节字符，控制字符视为空格
+func appendPDFByte(str []byte, c byte) []byte {
+	if c < 0x20 {
+		return append(str, ' ')
+	}
+	if c < 0x80 {
+		return append(str, c)
+	}
+
+	return append(str, string(rune(c))...)
+}
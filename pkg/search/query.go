@@ -0,0 +1,418 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ErrInvalidQuery 搜索语句无法解析
+var ErrInvalidQuery = errors.New("invalid search query")
+
+const (
+	// ObjectTypeFile 只搜索文件
+	ObjectTypeFile = "file"
+	// ObjectTypeFolder 只搜索目录
+	ObjectTypeFolder = "folder"
+)
+
+// Categories 文件分类及其包含的扩展名
+var Categories = map[string][]string{
+	"image": {"bmp", "iff", "png", "gif", "jpg", "jpeg", "psd", "svg", "webp"},
+	"video": {"mp4", "flv", "avi", "wmv", "mkv", "rm", "rmvb", "mov", "ogv"},
+	"audio": {"mp3", "flac", "ape", "wav", "acc", "ogg", "midi", "mid"},
+	"doc":   {"txt", "md", "pdf", "doc", "docx", "ppt", "pptx", "xls", "xlsx", "pub"},
+}
+
+// TimeRange 时间范围 [After, Before)，零值表示不限
+type TimeRange struct {
+	After  time.Time
+	Before time.Time
+}
+
+// IsZero 时间范围是否不限
+func (r TimeRange) IsZero() bool {
+	return r.After.IsZero() && r.Before.IsZero()
+}
+
+// Query 解析后的结构化搜索语句，各条件之间为“与”的关系
+type Query struct {
+	// Names 名称中需包含的关键字
+	Names []string
+	// ExtGroups 扩展名条件，同组内满足其一即可
+	ExtGroups [][]string
+	// Tags 引用的文件分类标签名
+	Tags []string
+	// In 限定搜索的目录
+	In string
+	// Type 限定对象类型，为空时同时搜索文件和目录
+	Type string
+	// MinSize、MaxSize 文件大小的闭区间，nil 表示不限
+	MinSize *uint64
+	MaxSize *uint64
+	// Modified、Created 修改时间、创建时间范围
+	Modified TimeRange
+	Created  TimeRange
+	// Metadata 用户自定义元数据条件
+	Metadata []MetadataFilter
+}
+
+// MetadataFilter 用户自定义元数据条件，Value 为空时只要求存在该键
+type MetadataFilter struct {
+	Key   string
+	Value string
+}
+
+// FilesOnly 是否包含只对文件有效的条件
+func (q *Query) FilesOnly() bool {
+	return q.Type == ObjectTypeFile || len(q.ExtGroups) > 0 || q.MinSize != nil || q.MaxSize != nil
+}
+
+// ParseQuery 解析搜索语句，例如
+//
+//	ext:pdf size>10MB modified:2026-01..2026-06 tag:work in:/Projects name:"report" meta:project=alpha
+//
+// 不带过滤器前缀的词视为名称关键字
+func ParseQuery(q string) (*Query, error) {
+	tokens, err := splitQuery(q)
+	if err != nil {
+		return nil, err
+	}
+
+	query := &Query{}
+	for _, token := range tokens {
+		if err := query.apply(token); err != nil {
+			return nil, err
+		}
+	}
+
+	return query, nil
+}
+
+// queryToken 搜索语句中的一个词，key 为空时为名称关键字
+type queryToken struct {
+	key   string
+	op    string
+	value string
+}
+
+// splitQuery 按空白分割搜索语句，双引号内的空白不分割
+func splitQuery(q string) ([]queryToken, error) {
+	var (
+		tokens  []queryToken
+		current strings.Builder
+		quoted  bool
+		touched bool
+	)
+
+	flush := func() {
+		if touched {
+			tokens = append(tokens, parseToken(current.String()))
+		}
+		current.Reset()
+		touched = false
+	}
+
+	for _, c := range q {
+		switch {
+		case c == '"':
+			quoted = !quoted
+			touched = true
+		case unicode.IsSpace(c) && !quoted:
+			flush()
+		default:
+			current.WriteRune(c)
+			touched = true
+		}
+	}
+
+	if quoted {
+		return nil, fmt.Errorf("%w: unterminated quote", ErrInvalidQuery)
+	}
+
+	flush()
+	return tokens, nil
+}
+
+// parseToken 拆分过滤器名、操作符和值，没有字母开头的过滤器名时整体视为关键字
+func parseToken(token string) queryToken {
+	i := strings.IndexAny(token, ":<>=")
+	if i <= 0 {
+		return queryToken{value: token}
+	}
+
+	for _, c := range token[:i] {
+		if c > unicode.MaxASCII || !unicode.IsLetter(c) {
+			return queryToken{value: token}
+		}
+	}
+
+	op := token[i : i+1]
+	if (op == ">" || op == "<") && strings.HasPrefix(token[i+1:], "=") {
+		op += "="
+	}
+
+	return queryToken{
+		key:   strings.ToLower(token[:i]),
+		op:    op,
+		value: token[i+len(op):],
+	}
+}
+
+func (q *Query) apply(token queryToken) error {
+	if token.key == "" {
+		if token.value != "" {
+			q.Names = append(q.Names, token.value)
+		}
+		return nil
+	}
+
+	isCompare := token.op != ":" && token.op != "="
+	switch token.key {
+	case "size", "modified", "created":
+	default:
+		if isCompare {
+			return fmt.Errorf("%w: filter %q does not support operator %q", ErrInvalidQuery, token.key, token.op)
+		}
+	}
+
+	if token.value == "" {
+		return fmt.Errorf("%w: empty value for filter %q", ErrInvalidQuery, token.key)
+	}
+
+	switch token.key {
+	case "name":
+		q.Names = append(q.Names, token.value)
+	case "ext":
+		exts := make([]string, 0)
+		for _, e := range strings.Split(token.value, ",") {
+			if e = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(e), ".")); e != "" {
+				exts = append(exts, e)
+			}
+		}
+		if len(exts) == 0 {
+			return fmt.Errorf("%w: empty value for filter %q", ErrInvalidQuery, token.key)
+		}
+		q.ExtGroups = append(q.ExtGroups, exts)
+	case "tag":
+		q.Tags = append(q.Tags, token.value)
+	case "in":
+		q.In = token.value
+	case "type":
+		return q.applyType(strings.ToLower(token.value))
+	case "size":
+		return q.applySize(token.op, token.value)
+	case "modified":
+		return applyTimeRange(&q.Modified, token.op, token.value)
+	case "created":
+		return applyTimeRange(&q.Created, token.op, token.value)
+	case "meta":
+		filter := MetadataFilter{Key: token.value}
+		if i := strings.Index(token.value, "="); i >= 0 {
+			filter = MetadataFilter{Key: token.value[:i], Value: token.value[i+1:]}
+		}
+		if filter.Key == "" {
+			return fmt.Errorf("%w: empty metadata key", ErrInvalidQuery)
+		}
+		q.Metadata = append(q.Metadata, filter)
+	default:
+		return fmt.Errorf("%w: unknown filter %q", ErrInvalidQuery, token.key)
+	}
+
+	return nil
+}
+
+func (q *Query) applyType(value string) error {
+	objectType := value
+	if exts, ok := Categories[value]; ok {
+		objectType = ObjectTypeFile
+		q.ExtGroups = append(q.ExtGroups, exts)
+	} else if value != ObjectTypeFile && value != ObjectTypeFolder {
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidQuery, value)
+	}
+
+	if q.Type != "" && q.Type != objectType {
+		return fmt.Errorf("%w: conflicting type filters", ErrInvalidQuery)
+	}
+
+	q.Type = objectType
+	return nil
+}
+
+func (q *Query) applySize(op, value string) error {
+	if op == ":" || op == "=" {
+		if from, to, isRange := splitRange(value); isRange {
+			if from != "" {
+				if err := q.applySize(">=", from); err != nil {
+					return err
+				}
+			}
+			if to != "" {
+				return q.applySize("<=", to)
+			}
+			return nil
+		}
+	}
+
+	size, err := ParseSize(value)
+	if err != nil {
+		return err
+	}
+
+	setMin := func(v uint64) {
+		if q.MinSize == nil || *q.MinSize < v {
+			q.MinSize = &v
+		}
+	}
+	setMax := func(v uint64) {
+		if q.MaxSize == nil || *q.MaxSize > v {
+			q.MaxSize = &v
+		}
+	}
+
+	switch op {
+	case ">":
+		setMin(size + 1)
+	case ">=":
+		setMin(size)
+	case "<":
+		if size == 0 {
+			return fmt.Errorf("%w: size cannot be less than 0", ErrInvalidQuery)
+		}
+		setMax(size - 1)
+	case "<=":
+		setMax(size)
+	default:
+		setMin(size)
+		setMax(size)
+	}
+
+	return nil
+}
+
+// sizeUnits 文件大小单位
+var sizeUnits = map[string]uint64{
+	"": 1, "b": 1,
+	"k": 1 << 10, "kb": 1 << 10,
+	"m": 1 << 20, "mb": 1 << 20,
+	"g": 1 << 30, "gb": 1 << 30,
+	"t": 1 << 40, "tb": 1 << 40,
+}
+
+// ParseSize 解析带单位的文件大小，如 512、10KB、1.5G，单位按 1024 进制换算
+func ParseSize(value string) (uint64, error) {
+	i := strings.IndexFunc(value, func(c rune) bool {
+		return !unicode.IsDigit(c) && c != '.'
+	})
+	if i < 0 {
+		i = len(value)
+	}
+
+	unit, ok := sizeUnits[strings.ToLower(value[i:])]
+	number, err := strconv.ParseFloat(value[:i], 64)
+	if !ok || err != nil || number < 0 {
+		return 0, fmt.Errorf("%w: invalid size %q", ErrInvalidQuery, value)
+	}
+
+	// float64(math.MaxUint64) 即 2^64，不小于该值时转换结果未定义
+	size := number * float64(unit)
+	if size >= math.MaxUint64 {
+		return 0, fmt.Errorf("%w: size %q is too large", ErrInvalidQuery, value)
+	}
+
+	return uint64(size), nil
+}
+
+func applyTimeRange(r *TimeRange, op, value string) error {
+	setAfter := func(t time.Time) {
+		if r.After.IsZero() || r.After.Before(t) {
+			r.After = t
+		}
+	}
+	setBefore := func(t time.Time) {
+		if r.Before.IsZero() || r.Before.After(t) {
+			r.Before = t
+		}
+	}
+
+	if op == ":" || op == "=" {
+		from, to, isRange := splitRange(value)
+		if !isRange {
+			from, to = value, value
+		}
+
+		if from != "" {
+			start, _, err := ParseDate(from)
+			if err != nil {
+				return err
+			}
+			setAfter(start)
+		}
+
+		if to != "" {
+			_, end, err := ParseDate(to)
+			if err != nil {
+				return err
+			}
+			setBefore(end)
+		}
+
+		return nil
+	}
+
+	start, end, err := ParseDate(value)
+	if err != nil {
+		return err
+	}
+
+	switch op {
+	case ">":
+		setAfter(end)
+	case ">=":
+		setAfter(start)
+	case "<":
+		setBefore(start)
+	case "<=":
+		setBefore(end)
+	}
+
+	return nil
+}
+
+// dateLayouts 支持的日期格式，精度依次为日、月、年
+var dateLayouts = []string{"2006-01-02", "2006-01", "2006"}
+
+// ParseDate 解析日期，返回其所表示时间段的起止时间 [start, end)
+func ParseDate(value string) (time.Time, time.Time, error) {
+	for i, layout := range dateLayouts {
+		start, err := time.ParseInLocation(layout, value, time.Local)
+		if err != nil {
+			continue
+		}
+
+		switch i {
+		case 0:
+			return start, start.AddDate(0, 0, 1), nil
+		case 1:
+			return start, start.AddDate(0, 1, 0), nil
+		default:
+			return start, start.AddDate(1, 0, 0), nil
+		}
+	}
+
+	return time.Time{}, time.Time{}, fmt.Errorf("%w: invalid date %q", ErrInvalidQuery, value)
+}
+
+// splitRange 拆分 a..b 形式的范围，两端均可省略
+func splitRange(value string) (string, string, bool) {
+	i := strings.Index(value, "..")
+	if i < 0 {
+		return "", "", false
+	}
+
+	return value[:i], value[i+2:], true
+}
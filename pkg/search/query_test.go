@@ -0,0 +1,143 @@
+package search
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseQuery(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 完整语句
+	{
+		q, err := ParseQuery(`ext:pdf,.DOCX size>10MB modified:2026-01..2026-06 tag:work in:/Projects name:"annual report" draft`)
+		asserts.NoError(err)
+		asserts.Equal([]string{"annual report", "draft"}, q.Names)
+		asserts.Equal([][]string{{"pdf", "docx"}}, q.ExtGroups)
+		asserts.Equal([]string{"work"}, q.Tags)
+		asserts.Equal("/Projects", q.In)
+		asserts.EqualValues(10<<20+1, *q.MinSize)
+		asserts.Nil(q.MaxSize)
+		asserts.Equal(time.Date(2026, 1, 1, 0, 0, 0, 0, time.Local), q.Modified.After)
+		asserts.Equal(time.Date(2026, 7, 1, 0, 0, 0, 0, time.Local), q.Modified.Before)
+		asserts.True(q.Created.IsZero())
+		asserts.True(q.FilesOnly())
+	}
+
+	// 空语句
+	{
+		q, err := ParseQuery("  ")
+		asserts.NoError(err)
+		asserts.Empty(q.Names)
+		asserts.False(q.FilesOnly())
+	}
+
+	// 带空格的目录
+	{
+		q, err := ParseQuery(`in:"/My Files/2026" type:folder`)
+		asserts.NoError(err)
+		asserts.Equal("/My Files/2026", q.In)
+		asserts.Equal(ObjectTypeFolder, q.Type)
+		asserts.False(q.FilesOnly())
+	}
+
+	// 分类
+	{
+		q, err := ParseQuery("type:image")
+		asserts.NoError(err)
+		asserts.Equal(ObjectTypeFile, q.Type)
+		asserts.Equal([][]string{Categories["image"]}, q.ExtGroups)
+	}
+
+	// 大小范围取交集
+	{
+		q, err := ParseQuery("size:1k..1m size<=512k size>=2k")
+		asserts.NoError(err)
+		asserts.EqualValues(2048, *q.MinSize)
+		asserts.EqualValues(512<<10, *q.MaxSize)
+	}
+
+	// 单个日期及比较
+	{
+		q, err := ParseQuery("created:2026-03-05 modified>2025 modified<=2026-02")
+		asserts.NoError(err)
+		asserts.Equal(time.Date(2026, 3, 5, 0, 0, 0, 0, time.Local), q.Created.After)
+		asserts.Equal(time.Date(2026, 3, 6, 0, 0, 0, 0, time.Local), q.Created.Before)
+		asserts.Equal(time.Date(2026, 1, 1, 0, 0, 0, 0, time.Local), q.Modified.After)
+		asserts.Equal(time.Date(2026, 3, 1, 0, 0, 0, 0, time.Local), q.Modified.Before)
+	}
+
+	// 开放区间
+	{
+		q, err := ParseQuery("modified:..2026-06")
+		asserts.NoError(err)
+		asserts.True(q.Modified.After.IsZero())
+		asserts.Equal(time.Date(2026, 7, 1, 0, 0, 0, 0, time.Local), q.Modified.Before)
+	}
+
+	// 自定义元数据
+	{
+		q, err := ParseQuery(`meta:project=alpha meta:"owner=Jane Doe" meta:reviewed`)
+		asserts.NoError(err)
+		asserts.Equal([]MetadataFilter{
+			{Key: "project", Value: "alpha"},
+			{Key: "owner", Value: "Jane Doe"},
+			{Key: "reviewed"},
+		}, q.Metadata)
+		asserts.False(q.FilesOnly())
+	}
+
+	// 非过滤器形式的冒号视为关键字
+	{
+		q, err := ParseQuery("10:30")
+		asserts.NoError(err)
+		asserts.Equal([]string{"10:30"}, q.Names)
+	}
+
+	// 错误
+	for _, input := range []string{
+		`name:"unterminated`,
+		"foo:bar",
+		"ext>pdf",
+		"size>ten",
+		"size<0",
+		"modified:2026-13",
+		"type:unknown",
+		"type:folder type:image",
+		"tag:",
+		"meta:=alpha",
+	} {
+		_, err := ParseQuery(input)
+		asserts.True(errors.Is(err, ErrInvalidQuery), input)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	asserts := assert.New(t)
+
+	for input, expected := range map[string]uint64{
+		"512":   512,
+		"10KB":  10 << 10,
+		"1.5g":  3 << 29,
+		"2TB":   2 << 40,
+		"100b":  100,
+		"0.5MB": 1 << 19,
+	} {
+		size, err := ParseSize(input)
+		asserts.NoError(err, input)
+		asserts.Equal(expected, size, input)
+	}
+
+	_, err := ParseSize("10PB")
+	asserts.Error(err)
+	_, err = ParseSize("16777216TB")
+	asserts.ErrorIs(err, ErrInvalidQuery)
+	size, err := ParseSize("16777215TB")
+	asserts.NoError(err)
+	asserts.EqualValues(uint64(16777215)<<40, size)
+	_, err = ParseSize("")
+	asserts.Error(err)
+}
@@ -0,0 +1,150 @@
+package search
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenize(t *testing.T) {
+	asserts := assert.New(t)
+
+	terms := Tokenize("Hello, hello world! 全文搜索")
+	asserts.Equal(2, terms["hello"])
+	asserts.Equal(1, terms["world"])
+	asserts.Equal(1, terms["全文"])
+	asserts.Equal(1, terms["文搜"])
+	asserts.Equal(1, terms["搜索"])
+	asserts.Len(terms, 5)
+
+	// 单个汉字
+	asserts.Equal(map[string]int{"字": 1, "a": 1}, Tokenize("字 a"))
+
+	// 超出数量限制时保留高频词
+	var text strings.Builder
+	for i := 0; i < MaxTermsPerFile+10; i++ {
+		text.WriteString("w" + strconv.Itoa(i) + " ")
+	}
+	text.WriteString("frequent frequent frequent")
+	terms = Tokenize(text.String())
+	asserts.Len(terms, MaxTermsPerFile)
+	asserts.Equal(3, terms["frequent"])
+}
+
+func TestQueryTerms(t *testing.T) {
+	asserts := assert.New(t)
+	asserts.Equal([]string{"report", "2026"}, QueryTerms("Report 2026 report"))
+	asserts.Equal([]string{"全文", "文搜", "搜索"}, QueryTerms("全文搜索"))
+	asserts.Empty(QueryTerms(" ,. "))
+}
+
+func TestSupported(t *testing.T) {
+	asserts := assert.New(t)
+	asserts.True(Supported("a.TXT"))
+	asserts.True(Supported("main.go"))
+	asserts.True(Supported("a.pdf"))
+	asserts.True(Supported("a.docx"))
+	asserts.False(Supported("a.doc"))
+	asserts.False(Supported("a.png"))
+}
+
+func TestExtract(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 纯文本
+	{
+		text, err := Extract("a.md", strings.NewReader("\xef\xbb\xbf# Title"), 1024)
+		asserts.NoError(err)
+		asserts.Equal("# Title", text)
+	}
+
+	// 超出大小限制
+	{
+		text, err := Extract("a.txt", strings.NewReader("1234567890"), 4)
+		asserts.NoError(err)
+		asserts.Equal("1234", text)
+	}
+
+	// 二进制内容
+	{
+		_, err := Extract("a.txt", bytes.NewReader([]byte{'a', 0, 'b'}), 1024)
+		asserts.Equal(ErrBinaryContent, err)
+	}
+
+	// 不支持的类型
+	{
+		_, err := Extract("a.png", strings.NewReader(""), 1024)
+		asserts.Equal(ErrUnsupportedType, err)
+	}
+
+	// docx
+	{
+		var buf bytes.Buffer
+		archive := zip.NewWriter(&buf)
+		w, _ := archive.Create("word/document.xml")
+		w.Write([]byte(`<?xml version="1.0"?><w:document xmlns:w="w"><w:body>` +
+			`<w:p><w:r><w:t>Hello</w:t></w:r><w:r><w:tab/><w:t>world</w:t></w:r></w:p>` +
+			`<w:p><w:r><w:t>第二段</w:t></w:r></w:p></w:body></w:document>`))
+		archive.Close()
+
+		text, err := Extract("a.docx", &buf, 1<<20)
+		asserts.NoError(err)
+		asserts.Equal("Hello world\n第二段\n", text)
+	}
+
+	// 无效的 docx
+	{
+		_, err := Extract("a.docx", strings.NewReader("invalid"), 1024)
+		asserts.Error(err)
+	}
+
+	// pdf
+	{
+		var compressed bytes.Buffer
+		w := zlib.NewWriter(&compressed)
+		w.Write([]byte("BT /F1 12 Tf 72 712 Td [(Hel) -20 (lo)] TJ 0 -14 Td (w\\157rld\\)) Tj <21> Tj ET"))
+		w.Close()
+
+		var pdf bytes.Buffer
+		pdf.WriteString("%PDF-1.4\n1 0 obj\n<< /Length 10 >>\nstream\nBT (plain) Tj ET\nendstream\nendobj\n")
+		pdf.WriteString("2 0 obj\n<< /Length 0 /Filter /FlateDecode >>\nstream\n")
+		pdf.Write(compressed.Bytes())
+		pdf.WriteString("\nendstream\nendobj\n%%EOF")
+
+		text, err := Extract("a.pdf", &pdf, 1<<20)
+		asserts.NoError(err)
+		asserts.Equal("plain\n Hello world)!\n", text)
+	}
+
+	// 无效的 pdf
+	{
+		_, err := Extract("a.pdf", strings.NewReader("invalid"), 1024)
+		asserts.Error(err)
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	asserts := assert.New(t)
+
+	asserts.Equal("a <mark>Quick</mark> &lt;fox&gt;", Snippet("a Quick <fox>", "quick"))
+	asserts.Equal("全文<mark>搜索</mark>", Snippet("全文搜索", "搜索"))
+	asserts.Equal("no match", Snippet("no match", "other"))
+
+	long := strings.Repeat("a ", 100) + "target" + strings.Repeat(" b", 100)
+	res := Snippet(long, "target")
+	asserts.True(strings.HasPrefix(res, "…"))
+	asserts.True(strings.HasSuffix(res, "…"))
+	asserts.Contains(res, "<mark>target</mark>")
+}
+
+func TestTruncate(t *testing.T) {
+	asserts := assert.New(t)
+	asserts.Equal("abc", Truncate("abc", 10))
+	asserts.Equal("全", Truncate("全文", 4))
+	asserts.Equal("", Truncate("全文", 2))
+}
@@ -0,0 +1,120 @@
+package search
+
+import (
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// snippetBefore 摘要中命中位置之前保留的字符数
+	snippetBefore = 40
+	// snippetLength 摘要的最大字符数
+	snippetLength = 160
+)
+
+// Snippet 截取内容中首个命中关键字附近的片段，转义 HTML 后以 <mark> 标记命中的关键字
+func Snippet(content, query string) string {
+	keywords := strings.Fields(strings.ToLower(query))
+	runes := []rune(content)
+	lower := []rune(strings.ToLower(content))
+	if len(lower) != len(runes) {
+		// 大小写转换改变了字符数时不做大小写无关匹配
+		lower = runes
+	}
+
+	// 查找首个命中位置
+	first := -1
+	for _, keyword := range keywords {
+		if pos := indexRunes(lower, []rune(keyword), 0); pos >= 0 && (first < 0 || pos < first) {
+			first = pos
+		}
+	}
+
+	start := 0
+	if first > snippetBefore {
+		start = first - snippetBefore
+	}
+	end := start + snippetLength
+	if end > len(runes) {
+		end = len(runes)
+	}
+
+	// 标记命中区间
+	marked := make([]bool, end-start)
+	for _, keyword := range keywords {
+		k := []rune(keyword)
+		for pos := indexRunes(lower[:end], k, start); pos >= 0; pos = indexRunes(lower[:end], k, pos+len(k)) {
+			for i := pos; i < pos+len(k) && i < end; i++ {
+				marked[i-start] = true
+			}
+		}
+	}
+
+	var res strings.Builder
+	if start > 0 {
+		res.WriteString("…")
+	}
+
+	inMark := false
+	for i, r := range runes[start:end] {
+		if marked[i] != inMark {
+			if inMark {
+				res.WriteString("</mark>")
+			} else {
+				res.WriteString("<mark>")
+			}
+			inMark = marked[i]
+		}
+
+		if unicode.IsSpace(r) {
+			r = ' '
+		}
+		res.WriteString(html.EscapeString(string(r)))
+	}
+	if inMark {
+		res.WriteString("</mark>")
+	}
+
+	if end < len(runes) {
+		res.WriteString("…")
+	}
+
+	return res.String()
+}
+
+// indexRunes 从 from 开始查找 sub 在 s 中的位置
+func indexRunes(s, sub []rune, from int) int {
+	if len(sub) == 0 {
+		return -1
+	}
+
+	for i := from; i+len(sub) <= len(s); i++ {
+		match := true
+		for j := range sub {
+			if s[i+j] != sub[j] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Truncate 将内容截断至不超过 size 字节，不截断多字节字符
+func Truncate(content string, size int) string {
+	if len(content) <= size {
+		return content
+	}
+
+	for size > 0 && !utf8.RuneStart(content[size]) {
+		size--
+	}
+
+	return content[:size]
+}
@@ -0,0 +1,103 @@
+package search
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	// maxTermLength 索引词的最大长度，超出的词不被索引
+	maxTermLength = 64
+	// MaxTermsPerFile 单个文件最多索引的词数，按出现频率保留
+	MaxTermsPerFile = 2000
+)
+
+// isCJK 判断字符是否属于不以空格分词的中日韩文字
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// eachTerm 将文本切分为索引词：拉丁文字等按非字母数字字符分隔并转为小写，
+// 中日韩文字按相邻两字切分
+func eachTerm(text string, fn func(term string)) {
+	var word, cjk []rune
+
+	flushWord := func() {
+		if len(word) > 0 && len(word) <= maxTermLength {
+			fn(strings.ToLower(string(word)))
+		}
+		word = word[:0]
+	}
+
+	flushCJK := func() {
+		if len(cjk) == 1 {
+			fn(string(cjk))
+		}
+		for i := 0; i+1 < len(cjk); i++ {
+			fn(string(cjk[i : i+2]))
+		}
+		cjk = cjk[:0]
+	}
+
+	for _, r := range text {
+		switch {
+		case isCJK(r):
+			flushWord()
+			cjk = append(cjk, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushCJK()
+			word = append(word, r)
+		default:
+			flushWord()
+			flushCJK()
+		}
+	}
+
+	flushWord()
+	flushCJK()
+}
+
+// Tokenize 统计文本中各索引词的出现次数，最多保留 MaxTermsPerFile 个最常出现的词
+func Tokenize(text string) map[string]int {
+	terms := make(map[string]int)
+	eachTerm(text, func(term string) {
+		terms[term]++
+	})
+
+	if len(terms) <= MaxTermsPerFile {
+		return terms
+	}
+
+	sorted := make([]string, 0, len(terms))
+	for term := range terms {
+		sorted = append(sorted, term)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if terms[sorted[i]] != terms[sorted[j]] {
+			return terms[sorted[i]] > terms[sorted[j]]
+		}
+		return sorted[i] < sorted[j]
+	})
+
+	res := make(map[string]int, MaxTermsPerFile)
+	for _, term := range sorted[:MaxTermsPerFile] {
+		res[term] = terms[term]
+	}
+
+	return res
+}
+
+// QueryTerms 将搜索关键字切分为去重后的索引词
+func QueryTerms(query string) []string {
+	seen := make(map[string]bool)
+	terms := make([]string, 0)
+	eachTerm(query, func(term string) {
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	})
+
+	return terms
+}
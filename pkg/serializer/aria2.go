@@ -1,6 +1,7 @@
 package serializer
 
 import (
+	"encoding/gob"
 	"path"
 	"time"
 
@@ -8,6 +9,17 @@ import (
 	"github.com/Jaylenwa/Vfoy/pkg/aria2/rpc"
 )
 
+func init() {
+	gob.Register(DownloadEvent{})
+}
+
+// DownloadEvent 推送给用户的离线下载状态变更
+type DownloadEvent struct {
+	GID    string `json:"gid"`
+	Status int    `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
 // DownloadListResponse 下载列表响应条目
 type DownloadListResponse struct {
 	UpdateTime     time.Time      `json:"update"`
@@ -193,6 +193,12 @@ const (
 	CodeDisabledSharePreview = 40070
 	// 签名无效
 	CodeInvalidSign = 40071
+	// 目录配额不足
+	CodeFolderQuotaExceeded = 40072
+	// 生命周期规则不存在
+	CodeLifecycleRuleNotFound = 40073
+	// 文件已被锁定
+	CodeFileLocked = 40074
 	// CodeDBError 数据库操作失败
 	CodeDBError = 50001
 	// CodeEncryptError 加密失败
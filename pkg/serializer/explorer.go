@@ -10,6 +10,7 @@ import (
 
 func init() {
 	gob.Register(ObjectProps{})
+	gob.Register(Delta{})
 }
 
 // ObjectProps 文件、目录对象的详细属性信息
@@ -22,7 +23,10 @@ type ObjectProps struct {
 	ChildFileNum   int       `json:"child_file_num"`
 	Path           string    `json:"path"`
 
-	QueryDate time.Time `json:"query_date"`
+	Checksums map[string]string `json:"checksums,omitempty"`
+	Metadata  map[string]string `json:"metadata"`
+	Quota     *FolderQuota      `json:"quota,omitempty"`
+	QueryDate time.Time         `json:"query_date"`
 }
 
 // ObjectList 文件、目录列表
@@ -30,20 +34,66 @@ type ObjectList struct {
 	Parent  string         `json:"parent,omitempty"`
 	Objects []Object       `json:"objects"`
 	Policy  *PolicySummary `json:"policy,omitempty"`
+	Quota   *FolderQuota   `json:"quota,omitempty"`
 }
 
 // Object 文件或者目录
 type Object struct {
-	ID            string    `json:"id"`
-	Name          string    `json:"name"`
-	Path          string    `json:"path"`
-	Thumb         bool      `json:"thumb"`
-	Size          uint64    `json:"size"`
-	Type          string    `json:"type"`
-	Date          time.Time `json:"date"`
-	CreateDate    time.Time `json:"create_date"`
-	Key           string    `json:"key,omitempty"`
-	SourceEnabled bool      `json:"source_enabled"`
+	ID            string       `json:"id"`
+	Name          string       `json:"name"`
+	Path          string       `json:"path"`
+	Thumb         bool         `json:"thumb"`
+	Size          uint64       `json:"size"`
+	Type          string       `json:"type"`
+	Date          time.Time    `json:"date"`
+	CreateDate    time.Time    `json:"create_date"`
+	Key           string       `json:"key,omitempty"`
+	SourceEnabled bool         `json:"source_enabled"`
+	Snippet       string       `json:"snippet,omitempty"` // 全文搜索命中的内容摘要
+	Quota         *FolderQuota `json:"quota,omitempty"`   // 目录配额
+
+	Metadata map[string]string `json:"metadata,omitempty"` // 用户自定义元数据
+}
+
+// FolderQuota 目录配额及已用量，上限为 0 表示不限
+type FolderQuota struct {
+	MaxSize   uint64 `json:"max_size"`
+	MaxFiles  uint64 `json:"max_files"`
+	UsedSize  uint64 `json:"used_size"`
+	UsedFiles uint64 `json:"used_files"`
+}
+
+// BuildFolderQuota 构建目录配额响应
+func BuildFolderQuota(quota *model.FolderQuota) *FolderQuota {
+	if quota == nil {
+		return nil
+	}
+
+	return &FolderQuota{
+		MaxSize:   quota.MaxSize,
+		MaxFiles:  quota.MaxFiles,
+		UsedSize:  quota.UsedSize,
+		UsedFiles: quota.UsedFiles,
+	}
+}
+
+// FileLock 用户持有的文件锁
+type FileLock struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	App       string    `json:"app"`
+	Owner     string    `json:"owner"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// ArchiveEntry 压缩文件中的条目
+type ArchiveEntry struct {
+	Name      string    `json:"name"`
+	Size      uint64    `json:"size"`
+	Modified  time.Time `json:"modified"`
+	IsDir     bool      `json:"is_dir"`
+	Encrypted bool      `json:"encrypted"`
 }
 
 // PolicySummary 用于前端组件使用的存储策略概况
@@ -78,6 +128,56 @@ func BuildObjectList(parent uint, objects []Object, policy *model.Policy) Object
 	return res
 }
 
+// Delta 变更增量列表，Reset 为 true 时客户端需重新完整列取目录
+type Delta struct {
+	Cursor  uint     `json:"cursor"`
+	Reset   bool     `json:"reset"`
+	HasMore bool     `json:"has_more"`
+	Changes []Change `json:"changes"`
+}
+
+// Change 文件或目录的变更
+type Change struct {
+	Type   string    `json:"type"`
+	Object string    `json:"object"`
+	ID     string    `json:"id"`
+	Parent string    `json:"parent,omitempty"`
+	Name   string    `json:"name,omitempty"`
+	Date   time.Time `json:"date"`
+}
+
+// BuildDelta 构建变更增量列表响应
+func BuildDelta(cursor uint, hasMore bool, changes []model.Change) Delta {
+	res := Delta{
+		Cursor:  cursor,
+		HasMore: hasMore,
+		Changes: make([]Change, len(changes)),
+	}
+
+	for i, change := range changes {
+		item := Change{
+			Type:   change.Type,
+			Object: "file",
+			ID:     hashid.HashID(change.ObjectID, hashid.FileID),
+			Name:   change.Name,
+			Date:   change.CreatedAt,
+		}
+
+		if change.IsFolder {
+			item.Object = "dir"
+			item.ID = hashid.HashID(change.ObjectID, hashid.FolderID)
+		}
+
+		if change.ParentID > 0 {
+			item.Parent = hashid.HashID(change.ParentID, hashid.FolderID)
+		}
+
+		res.Changes[i] = item
+	}
+
+	return res
+}
+
 // Sources 获取外链的结果响应
 type Sources struct {
 	URL    string `json:"url"`
@@ -14,3 +14,20 @@ func TestBuildObjectList(t *testing.T) {
 	a.NotNil(res.Policy)
 	a.Len(res.Objects, 2)
 }
+
+func TestBuildDelta(t *testing.T) {
+	a := assert.New(t)
+	res := BuildDelta(10, true, []model.Change{
+		{ID: 9, Type: model.ChangeMove, IsFolder: true, ObjectID: 1, ParentID: 2},
+		{ID: 10, Type: model.ChangeDelete, ObjectID: 1},
+	})
+	a.EqualValues(10, res.Cursor)
+	a.True(res.HasMore)
+	a.False(res.Reset)
+	a.Len(res.Changes, 2)
+	a.Equal("dir", res.Changes[0].Object)
+	a.NotEmpty(res.Changes[0].Parent)
+	a.Equal("file", res.Changes[1].Object)
+	a.Empty(res.Changes[1].Parent)
+	a.NotEqual(res.Changes[0].ID, res.Changes[1].ID)
+}
@@ -1,11 +1,16 @@
 package serializer
 
 import (
+	"encoding/gob"
 	"time"
 
 	model "github.com/Jaylenwa/Vfoy/models"
 )
 
+func init() {
+	gob.Register(TaskEvent{})
+}
+
 // SiteConfig 站点全局设置序列
 type SiteConfig struct {
 	SiteName             string   `json:"title"`
@@ -35,6 +40,14 @@ type task struct {
 	Error      string    `json:"error"`
 }
 
+// TaskEvent 推送给用户的任务状态变更
+type TaskEvent struct {
+	ID     uint   `json:"id"`
+	Type   int    `json:"type"`
+	Status int    `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
 // BuildTaskList 构建任务列表响应
 func BuildTaskList(tasks []model.Task, total int) Response {
 	res := make([]task, 0, len(tasks))
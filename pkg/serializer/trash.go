@@ -0,0 +1,45 @@
+package serializer
+
+import (
+	"time"
+
+	model "github.com/Jaylenwa/Vfoy/models"
+	"github.com/Jaylenwa/Vfoy/pkg/hashid"
+)
+
+// TrashItem 回收站中的对象
+type TrashItem struct {
+	ID           string     `json:"id"`
+	Name         string     `json:"name"`
+	Type         string     `json:"type"`
+	Size         uint64     `json:"size"`
+	OriginalPath string     `json:"original_path"`
+	DeletedAt    time.Time  `json:"deleted_at"`
+	ExpiredAt    *time.Time `json:"expired_at,omitempty"`
+}
+
+// BuildTrashList 构建回收站列表响应
+func BuildTrashList(items []model.Trash, total int) Response {
+	res := make([]TrashItem, 0, len(items))
+	for _, item := range items {
+		objectType := "file"
+		if item.IsFolder {
+			objectType = "dir"
+		}
+
+		res = append(res, TrashItem{
+			ID:           hashid.HashID(item.ID, hashid.TrashID),
+			Name:         item.Name,
+			Type:         objectType,
+			Size:         item.Size,
+			OriginalPath: item.OriginalPath,
+			DeletedAt:    item.CreatedAt,
+			ExpiredAt:    item.ExpiredAt,
+		})
+	}
+
+	return Response{Data: map[string]interface{}{
+		"total": total,
+		"items": res,
+	}}
+}
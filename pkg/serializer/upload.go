@@ -31,6 +31,7 @@ type UploadCredential struct {
 	KeyTime     string   `json:"keyTime,omitempty"` // COS用有效期
 	Policy      string   `json:"policy,omitempty"`
 	CompleteURL string   `json:"completeURL,omitempty"`
+	Instant     bool     `json:"instant,omitempty"` // 秒传完成，无需上传数据
 }
 
 // UploadSession 上传会话
@@ -48,6 +49,46 @@ type UploadSession struct {
 	UploadURL      string
 	UploadID       string
 	Credential     string
+	Hash           string // 客户端提供的 SHA-256 摘要，上传完成后用于校验
+}
+
+// UploadSessionStatus 上传会话已接收的分片，用于客户端断点续传
+type UploadSessionStatus struct {
+	SessionID      string `json:"sessionID"`
+	ChunkSize      uint64 `json:"chunkSize"`
+	Size           uint64 `json:"size"`
+	UploadedSize   uint64 `json:"uploadedSize"`
+	UploadedChunks []int  `json:"uploadedChunks"`
+}
+
+// BuildUploadSessionStatus 根据已接收的数据大小计算已完成的分片
+func BuildUploadSessionStatus(session *UploadSession, received uint64) UploadSessionStatus {
+	status := UploadSessionStatus{
+		SessionID:      session.Key,
+		ChunkSize:      session.Policy.OptionsSerialized.ChunkSize,
+		Size:           session.Size,
+		UploadedSize:   received,
+		UploadedChunks: []int{},
+	}
+
+	completed := 0
+	if status.ChunkSize > 0 {
+		completed = int(received / status.ChunkSize)
+	}
+
+	// 最后一个分片可能不足分片大小
+	if received >= session.Size && session.Size > 0 {
+		completed = 1
+		if status.ChunkSize > 0 {
+			completed = int((session.Size + status.ChunkSize - 1) / status.ChunkSize)
+		}
+	}
+
+	for i := 0; i < completed; i++ {
+		status.UploadedChunks = append(status.UploadedChunks, i)
+	}
+
+	return status
 }
 
 // UploadCallback 上传回调正文
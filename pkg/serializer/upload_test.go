@@ -0,0 +1,36 @@
+package serializer
+
+import (
+	"testing"
+
+	model "github.com/Jaylenwa/Vfoy/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildUploadSessionStatus(t *testing.T) {
+	a := assert.New(t)
+	session := &UploadSession{
+		Key:    "1",
+		Size:   25,
+		Policy: model.Policy{OptionsSerialized: model.PolicyOption{ChunkSize: 10}},
+	}
+
+	// 未接收数据
+	status := BuildUploadSessionStatus(session, 0)
+	a.Equal("1", status.SessionID)
+	a.Empty(status.UploadedChunks)
+
+	// 部分分片
+	status = BuildUploadSessionStatus(session, 20)
+	a.Equal([]int{0, 1}, status.UploadedChunks)
+	a.EqualValues(20, status.UploadedSize)
+
+	// 全部接收，最后一个分片不足分片大小
+	status = BuildUploadSessionStatus(session, 25)
+	a.Equal([]int{0, 1, 2}, status.UploadedChunks)
+
+	// 不分片上传
+	session.Policy.OptionsSerialized.ChunkSize = 0
+	a.Empty(BuildUploadSessionStatus(session, 10).UploadedChunks)
+	a.Equal([]int{0}, BuildUploadSessionStatus(session, 25).UploadedChunks)
+}
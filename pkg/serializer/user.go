@@ -53,6 +53,7 @@ type tag struct {
 	Color      string `json:"color"`
 	Type       int    `json:"type"`
 	Expression string `json:"expression"`
+	Query      bool   `json:"query"`
 }
 
 type storage struct {
@@ -145,8 +146,9 @@ func buildTagRes(tags []model.Tag) []tag {
 			Icon:  tags[i].Icon,
 			Color: tags[i].Color,
 			Type:  tags[i].Type,
+			Query: tags[i].IsQuery,
 		}
-		if newTag.Type != 0 {
+		if newTag.Type != 0 || newTag.Query {
 			newTag.Expression = tags[i].Expression
 
 		}
@@ -0,0 +1,31 @@
+package serializer
+
+import (
+	"time"
+
+	model "github.com/Jaylenwa/Vfoy/models"
+	"github.com/Jaylenwa/Vfoy/pkg/hashid"
+)
+
+// FileVersion 文件历史版本
+type FileVersion struct {
+	ID        string    `json:"id"`
+	Size      uint64    `json:"size"`
+	Author    string    `json:"author"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BuildFileVersionList 构建文件历史版本列表响应，authors 为操作者ID到昵称的映射
+func BuildFileVersionList(versions []model.FileVersion, authors map[uint]string) Response {
+	res := make([]FileVersion, 0, len(versions))
+	for _, version := range versions {
+		res = append(res, FileVersion{
+			ID:        hashid.HashID(version.ID, hashid.FileVersionID),
+			Size:      version.Size,
+			Author:    authors[version.AuthorID],
+			CreatedAt: version.CreatedAt,
+		})
+	}
+
+	return Response{Data: res}
+}
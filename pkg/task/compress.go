@@ -25,9 +25,14 @@ type CompressTask struct {
 
 // CompressProps 压缩任务属性
 type CompressProps struct {
-	Dirs  []uint `json:"dirs"`
-	Files []uint `json:"files"`
-	Dst   string `json:"dst"`
+	Dirs     []uint `json:"dirs"`
+	Files    []uint `json:"files"`
+	Dst      string `json:"dst"`
+	Format   string `json:"format,omitempty"` // 压缩文件格式，为空时使用 zip
+	Level    int    `json:"level,omitempty"`  // 压缩级别，为 0 时使用默认级别
+	Password string `json:"-"`                // 加密密码，不写入数据库
+	// 是否需要加密，密码不会持久化，从数据库恢复的加密任务无法继续执行
+	Encrypted bool `json:"encrypted,omitempty"`
 }
 
 // Props 获取任务属性
@@ -86,6 +91,12 @@ func (job *CompressTask) GetError() *JobError {
 
 // Do 开始执行任务
 func (job *CompressTask) Do() {
+	// 从数据库恢复的加密任务已丢失密码，不能退化为明文压缩
+	if job.TaskProps.Encrypted && job.TaskProps.Password == "" {
+		job.SetErrorMsg(ErrCompressPasswordLost.Error())
+		return
+	}
+
 	// 创建文件系统
 	fs, err := filesystem.NewFileSystem(job.User)
 	if err != nil {
@@ -101,7 +112,7 @@ func (job *CompressTask) Do() {
 	zipFilePath := filepath.Join(
 		util.RelativePath(model.GetSettingByName("temp_path")),
 		saveFolder,
-		fmt.Sprintf("archive_%d.zip", time.Now().UnixNano()),
+		fmt.Sprintf("archive_%d.%s", time.Now().UnixNano(), job.format()),
 	)
 	zipFile, err := util.CreatNestedFile(zipFilePath)
 	if err != nil {
@@ -114,7 +125,11 @@ func (job *CompressTask) Do() {
 
 	// 开始压缩
 	ctx := context.Background()
-	err = fs.Compress(ctx, zipFile, job.TaskProps.Dirs, job.TaskProps.Files, false)
+	err = fs.CompressAs(ctx, zipFile, job.TaskProps.Dirs, job.TaskProps.Files, filesystem.CompressOption{
+		Format:   job.format(),
+		Level:    job.TaskProps.Level,
+		Password: job.TaskProps.Password,
+	})
 	if err != nil {
 		job.SetErrorMsg(err.Error())
 		return
@@ -135,14 +150,27 @@ func (job *CompressTask) Do() {
 	job.removeZipFile()
 }
 
+// format 压缩文件格式
+func (job *CompressTask) format() string {
+	if job.TaskProps.Format == "" {
+		return filesystem.CompressFormatZip
+	}
+
+	return job.TaskProps.Format
+}
+
 // NewCompressTask 新建压缩任务
-func NewCompressTask(user *model.User, dst string, dirs, files []uint) (Job, error) {
+func NewCompressTask(user *model.User, dst string, dirs, files []uint, format string, level int, password string) (Job, error) {
 	newTask := &CompressTask{
 		User: user,
 		TaskProps: CompressProps{
-			Dirs:  dirs,
-			Files: files,
-			Dst:   dst,
+			Dirs:      dirs,
+			Files:     files,
+			Dst:       dst,
+			Format:    format,
+			Level:     level,
+			Password:  password,
+			Encrypted: password != "",
 		},
 	}
 
@@ -146,6 +146,19 @@ func TestCompressTask_Do(t *testing.T) {
 		asserts.NotEmpty(task.GetError().Msg)
 		asserts.True(util.IsEmpty(util.RelativePath("test/compress")))
 	}
+
+	// 恢复的加密任务已丢失密码
+	{
+		task.TaskProps.Encrypted = true
+		task.TaskProps.Password = ""
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1,
+			1))
+		mock.ExpectCommit()
+		task.Do()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal(ErrCompressPasswordLost.Error(), task.GetError().Msg)
+	}
 }
 
 func TestNewCompressTask(t *testing.T) {
@@ -156,7 +169,7 @@ func TestNewCompressTask(t *testing.T) {
 		mock.ExpectBegin()
 		mock.ExpectExec("INSERT(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectCommit()
-		job, err := NewCompressTask(&model.User{}, "/", []uint{12}, []uint{})
+		job, err := NewCompressTask(&model.User{}, "/", []uint{12}, []uint{}, "", 0, "")
 		asserts.NoError(mock.ExpectationsWereMet())
 		asserts.NotNil(job)
 		asserts.NoError(err)
@@ -167,7 +180,7 @@ func TestNewCompressTask(t *testing.T) {
 		mock.ExpectBegin()
 		mock.ExpectExec("INSERT(.+)").WillReturnError(errors.New("error"))
 		mock.ExpectRollback()
-		job, err := NewCompressTask(&model.User{}, "/", []uint{12}, []uint{})
+		job, err := NewCompressTask(&model.User{}, "/", []uint{12}, []uint{}, "", 0, "")
 		asserts.NoError(mock.ExpectationsWereMet())
 		asserts.Nil(job)
 		asserts.Error(err)
@@ -186,6 +199,16 @@ func TestNewCompressTaskFromModel(t *testing.T) {
 		asserts.NotNil(job)
 	}
 
+	// 加密标记被持久化
+	{
+		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		job, err := NewCompressTaskFromModel(&model.Task{Props: `{"encrypted":true}`})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.True(job.(*CompressTask).TaskProps.Encrypted)
+		asserts.Empty(job.(*CompressTask).TaskProps.Password)
+	}
+
 	// JSON解析失败
 	{
 		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
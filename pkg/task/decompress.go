@@ -3,9 +3,13 @@ package task
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"time"
 
 	model "github.com/Jaylenwa/Vfoy/models"
 	"github.com/Jaylenwa/Vfoy/pkg/filesystem"
+	"github.com/Jaylenwa/Vfoy/pkg/filesystem/fsctx"
+	"github.com/Jaylenwa/Vfoy/pkg/serializer"
 )
 
 // DecompressTask 文件压缩任务
@@ -23,6 +27,14 @@ type DecompressProps struct {
 	Src      string `json:"src"`
 	Dst      string `json:"dst"`
 	Encoding string `json:"encoding"`
+	// 仅解压指定的条目
+	Entries []string `json:"entries,omitempty"`
+	// 压缩文件密码，不写入数据库，从数据库恢复的任务需重新提交
+	Password string `json:"-"`
+
+	// 解压进度
+	Files int    `json:"files,omitempty"`
+	Size  uint64 `json:"size,omitempty"`
 }
 
 // Props 获取任务属性
@@ -83,7 +95,36 @@ func (job *DecompressTask) Do() {
 
 	job.TaskModel.SetProgress(DecompressingProgress)
 
-	err = fs.Decompress(context.Background(), job.TaskProps.Src, job.TaskProps.Dst, job.TaskProps.Encoding)
+	// 记录已解压的文件数量和大小，每秒最多更新一次
+	var lastUpdate time.Time
+	ctx := context.WithValue(context.Background(), fsctx.ProgressFuncCtx, filesystem.DecompressProgressFunc(
+		func(files int, size uint64) {
+			job.TaskProps.Files = files
+			job.TaskProps.Size = size
+			if time.Since(lastUpdate) >= time.Second {
+				lastUpdate = time.Now()
+				job.TaskModel.SetProps(job.Props())
+			}
+		},
+	))
+	if job.TaskProps.Password != "" {
+		ctx = context.WithValue(ctx, fsctx.ArchivePasswordCtx, job.TaskProps.Password)
+	}
+
+	if len(job.TaskProps.Entries) > 0 {
+		err = fs.ExtractEntries(ctx, job.TaskProps.Src, job.TaskProps.Entries, job.TaskProps.Dst, job.TaskProps.Encoding)
+	} else {
+		err = fs.Decompress(ctx, job.TaskProps.Src, job.TaskProps.Dst, job.TaskProps.Encoding)
+	}
+	if job.TaskProps.Files > 0 {
+		job.TaskModel.SetProps(job.Props())
+	}
+
+	if errors.Is(err, filesystem.ErrArchivePassword) {
+		job.SetError(&JobError{Msg: "Incorrect archive password.", Error: err.Error(), Code: serializer.CodeIncorrectPassword})
+		return
+	}
+
 	if err != nil {
 		job.SetErrorMsg("Failed to decompress file.", err)
 		return
@@ -91,14 +132,16 @@ func (job *DecompressTask) Do() {
 
 }
 
-// NewDecompressTask 新建压缩任务
-func NewDecompressTask(user *model.User, src, dst, encoding string) (Job, error) {
+// NewDecompressTask 新建压缩任务，指定 entries 时仅解压其中的条目
+func NewDecompressTask(user *model.User, src, dst, encoding, password string, entries ...string) (Job, error) {
 	newTask := &DecompressTask{
 		User: user,
 		TaskProps: DecompressProps{
 			Src:      src,
 			Dst:      dst,
 			Encoding: encoding,
+			Entries:  entries,
+			Password: password,
 		},
 	}
 
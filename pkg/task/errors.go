@@ -5,4 +5,6 @@ import "errors"
 var (
 	// ErrUnknownTaskType 未知任务类型
 	ErrUnknownTaskType = errors.New("unknown task type")
+	// ErrCompressPasswordLost 加密压缩任务恢复后密码已丢失
+	ErrCompressPasswordLost = errors.New("archive password is not persisted, please submit the encrypted compress task again")
 )
@@ -0,0 +1,148 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+
+	model "github.com/Jaylenwa/Vfoy/models"
+	"github.com/Jaylenwa/Vfoy/pkg/filesystem"
+	"github.com/Jaylenwa/Vfoy/pkg/search"
+	"github.com/Jaylenwa/Vfoy/pkg/util"
+)
+
+// indexBatchSize 重建索引时每批处理的文件数
+const indexBatchSize = 100
+
+// IndexTask 重建全文索引任务
+type IndexTask struct {
+	User      *model.User
+	TaskModel *model.Task
+	TaskProps IndexProps
+	Err       *JobError
+}
+
+// IndexProps 重建全文索引任务属性
+type IndexProps struct {
+	// 已处理的最后一个文件 ID，用于恢复任务
+	LastFileID uint `json:"last_file_id"`
+}
+
+// Props 获取任务属性
+func (job *IndexTask) Props() string {
+	res, _ := json.Marshal(job.TaskProps)
+	return string(res)
+}
+
+// Type 获取任务状态
+func (job *IndexTask) Type() int {
+	return IndexTaskType
+}
+
+// Creator 获取创建者ID
+func (job *IndexTask) Creator() uint {
+	return job.User.ID
+}
+
+// Model 获取任务的数据库模型
+func (job *IndexTask) Model() *model.Task {
+	return job.TaskModel
+}
+
+// SetStatus 设定状态
+func (job *IndexTask) SetStatus(status int) {
+	job.TaskModel.SetStatus(status)
+}
+
+// SetError 设定任务失败信息
+func (job *IndexTask) SetError(err *JobError) {
+	job.Err = err
+	res, _ := json.Marshal(job.Err)
+	job.TaskModel.SetError(string(res))
+}
+
+// SetErrorMsg 设定任务失败信息
+func (job *IndexTask) SetErrorMsg(msg string, err error) {
+	jobErr := &JobError{Msg: msg}
+	if err != nil {
+		jobErr.Error = err.Error()
+	}
+	job.SetError(jobErr)
+}
+
+// GetError 返回任务失败信息
+func (job *IndexTask) GetError() *JobError {
+	return job.Err
+}
+
+// Do 开始执行任务
+func (job *IndexTask) Do() {
+	fs, err := filesystem.NewFileSystem(job.User)
+	if err != nil {
+		job.SetErrorMsg("Failed to initialize filesystem.", err)
+		return
+	}
+	defer fs.Recycle()
+
+	job.TaskModel.SetProgress(ListingProgress)
+	ctx := context.Background()
+	for {
+		files, err := model.GetIndexableFiles(job.User.ID, job.TaskProps.LastFileID, indexBatchSize)
+		if err != nil {
+			job.SetErrorMsg("Failed to list files.", err)
+			return
+		}
+
+		if len(files) == 0 {
+			return
+		}
+
+		job.TaskModel.SetProgress(InsertingProgress)
+		for i := range files {
+			if !search.Supported(files[i].Name) {
+				continue
+			}
+
+			if err := fs.IndexFileContent(ctx, &files[i]); err != nil {
+				util.Log().Debug("Failed to index content of file %q: %s", files[i].Name, err)
+			}
+		}
+
+		// 记录进度，以便任务中断后继续
+		job.TaskProps.LastFileID = files[len(files)-1].ID
+		job.TaskModel.SetProps(job.Props())
+	}
+}
+
+// NewIndexTask 新建重建全文索引任务
+func NewIndexTask(user *model.User) (Job, error) {
+	newTask := &IndexTask{
+		User: user,
+	}
+
+	record, err := Record(newTask)
+	if err != nil {
+		return nil, err
+	}
+	newTask.TaskModel = record
+
+	return newTask, nil
+}
+
+// NewIndexTaskFromModel 从数据库记录中恢复重建全文索引任务
+func NewIndexTaskFromModel(task *model.Task) (Job, error) {
+	user, err := model.GetActiveUserByID(task.UserID)
+	if err != nil {
+		return nil, err
+	}
+	newTask := &IndexTask{
+		User:      &user,
+		TaskModel: task,
+	}
+
+	err = json.Unmarshal([]byte(task.Props), &newTask.TaskProps)
+	if err != nil {
+		return nil, err
+	}
+
+	return newTask, nil
+}
@@ -17,6 +17,14 @@ const (
 	ImportTaskType
 	// RecycleTaskType 回收任务
 	RecycleTaskType
+	// IndexTaskType 重建全文索引任务
+	IndexTaskType
+	// MigrateTaskType 存储策略迁移任务
+	MigrateTaskType
+	// ReplicateTaskType 副本补全任务
+	ReplicateTaskType
+	// LifecycleTaskType 生命周期规则执行任务
+	LifecycleTaskType
 )
 
 // 任务状态
@@ -67,6 +75,7 @@ type Job interface {
 type JobError struct {
 	Msg   string `json:"msg,omitempty"`
 	Error string `json:"error,omitempty"`
+	Code  int    `json:"code,omitempty"`
 }
 
 // Record 将任务记录到数据库中
@@ -117,6 +126,14 @@ func GetJobFromModel(task *model.Task) (Job, error) {
 		return NewImportTaskFromModel(task)
 	case RecycleTaskType:
 		return NewRecycleTaskFromModel(task)
+	case IndexTaskType:
+		return NewIndexTaskFromModel(task)
+	case MigrateTaskType:
+		return NewMigrateTaskFromModel(task)
+	case ReplicateTaskType:
+		return NewReplicateTaskFromModel(task)
+	case LifecycleTaskType:
+		return NewLifecycleTaskFromModel(task)
 	default:
 		return nil, ErrUnknownTaskType
 	}
@@ -0,0 +1,264 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	model "github.com/Jaylenwa/Vfoy/models"
+	"github.com/Jaylenwa/Vfoy/pkg/filesystem"
+	"github.com/Jaylenwa/Vfoy/pkg/util"
+)
+
+// lifecycleBatchSize 执行生命周期规则时每批处理的文件数
+const lifecycleBatchSize = 100
+
+// LifecycleTask 生命周期规则执行任务
+type LifecycleTask struct {
+	User      *model.User
+	TaskModel *model.Task
+	TaskProps LifecycleProps
+	Err       *JobError
+
+	// 目录ID与路径的对应
+	folderPaths map[uint]string
+}
+
+// LifecycleProps 生命周期规则执行任务属性
+type LifecycleProps struct {
+	RuleID uint `json:"rule_id"`
+
+	// 已处理的最后一个文件 ID，用于恢复任务
+	LastFileID uint `json:"last_file_id"`
+	Processed  int  `json:"processed"`
+	FileFailures
+}
+
+// Props 获取任务属性
+func (job *LifecycleTask) Props() string {
+	res, _ := json.Marshal(job.TaskProps)
+	return string(res)
+}
+
+// Type 获取任务状态
+func (job *LifecycleTask) Type() int {
+	return LifecycleTaskType
+}
+
+// Creator 获取创建者ID
+func (job *LifecycleTask) Creator() uint {
+	return job.User.ID
+}
+
+// Model 获取任务的数据库模型
+func (job *LifecycleTask) Model() *model.Task {
+	return job.TaskModel
+}
+
+// SetStatus 设定状态
+func (job *LifecycleTask) SetStatus(status int) {
+	job.TaskModel.SetStatus(status)
+}
+
+// SetError 设定任务失败信息
+func (job *LifecycleTask) SetError(err *JobError) {
+	job.Err = err
+	res, _ := json.Marshal(job.Err)
+	job.TaskModel.SetError(string(res))
+}
+
+// SetErrorMsg 设定任务失败信息
+func (job *LifecycleTask) SetErrorMsg(msg string, err error) {
+	jobErr := &JobError{Msg: msg}
+	if err != nil {
+		jobErr.Error = err.Error()
+	}
+	job.SetError(jobErr)
+}
+
+// GetError 返回任务失败信息
+func (job *LifecycleTask) GetError() *JobError {
+	return job.Err
+}
+
+// Do 开始执行任务
+func (job *LifecycleTask) Do() {
+	rule, err := model.GetLifecycleRuleByID(job.TaskProps.RuleID)
+	if err != nil {
+		job.SetErrorMsg("Lifecycle rule not exist.", err)
+		return
+	}
+
+	var dst model.Policy
+	if rule.Action == model.LifecycleActionTransition {
+		if dst, err = model.GetPolicyByID(rule.DstPolicyID); err != nil {
+			job.SetErrorMsg("Policy not exist.", err)
+			return
+		}
+	}
+
+	folderIDs, err := rule.FolderIDs()
+	if err != nil {
+		job.SetErrorMsg("Failed to list folders.", err)
+		return
+	}
+
+	fs, err := filesystem.NewFileSystem(job.User)
+	if err != nil {
+		job.SetErrorMsg("Failed to initialize filesystem.", err)
+		return
+	}
+	defer fs.Recycle()
+
+	job.TaskModel.SetProgress(ListingProgress)
+	ctx := context.Background()
+	now := time.Now()
+	for {
+		files, err := model.GetLifecycleMatches(rule, folderIDs, now, job.TaskProps.LastFileID, lifecycleBatchSize)
+		if err != nil {
+			job.SetErrorMsg("Failed to list files.", err)
+			return
+		}
+
+		if len(files) == 0 {
+			break
+		}
+
+		job.TaskModel.SetProgress(TransferringProgress)
+		switch rule.Action {
+		case model.LifecycleActionTransition:
+			for i := range files {
+				job.record(&files[i], migrateFile(ctx, fs, &files[i], &dst, job.folderPath(&files[i])))
+			}
+		case model.LifecycleActionDelete:
+			job.delete(ctx, files)
+		default:
+			job.SetErrorMsg(fmt.Sprintf("Unknown lifecycle action %q.", rule.Action), nil)
+			return
+		}
+
+		// 记录进度，以便任务中断后继续
+		job.TaskProps.LastFileID = files[len(files)-1].ID
+		job.TaskModel.SetProps(job.Props())
+	}
+
+	if job.TaskProps.Failed > 0 {
+		job.SetErrorMsg(
+			fmt.Sprintf("Failed to process %d file(s).", job.TaskProps.Failed),
+			job.TaskProps.FailureErr(),
+		)
+	}
+}
+
+// delete 使用文件所有者的文件系统删除文件
+func (job *LifecycleTask) delete(ctx context.Context, files []model.File) {
+	owners := make(map[uint][]*model.File)
+	for i := range files {
+		owners[files[i].UserID] = append(owners[files[i].UserID], &files[i])
+	}
+
+	for uid, owned := range owners {
+		ids := make([]uint, len(owned))
+		for i, file := range owned {
+			ids[i] = file.ID
+		}
+
+		err := job.deleteOwned(ctx, uid, ids)
+		for _, file := range owned {
+			job.record(file, err)
+		}
+	}
+}
+
+func (job *LifecycleTask) deleteOwned(ctx context.Context, uid uint, ids []uint) error {
+	user, err := model.GetUserByID(uid)
+	if err != nil {
+		return err
+	}
+
+	fs, err := filesystem.NewFileSystem(&user)
+	if err != nil {
+		return err
+	}
+	defer fs.Recycle()
+
+	return fs.Delete(ctx, []uint{}, ids, false, false)
+}
+
+// record 记录文件的处理结果
+func (job *LifecycleTask) record(file *model.File, err error) {
+	if err == nil {
+		job.TaskProps.Processed++
+		return
+	}
+
+	util.Log().Warning("Failed to apply lifecycle rule to file %q: %s", file.Name, err)
+	job.TaskProps.AddFailure(file, err)
+}
+
+// folderPath 返回文件所在目录的路径
+func (job *LifecycleTask) folderPath(file *model.File) string {
+	if job.folderPaths == nil {
+		job.folderPaths = make(map[uint]string)
+	}
+
+	return folderPath(job.folderPaths, file.FolderID)
+}
+
+// RunningLifecycleRules 返回有尚未结束的执行任务的规则ID
+func RunningLifecycleRules() map[uint]bool {
+	res := make(map[uint]bool)
+	for _, t := range model.GetTasksByStatus(Queued, Processing) {
+		if t.Type != LifecycleTaskType {
+			continue
+		}
+
+		var props LifecycleProps
+		if err := json.Unmarshal([]byte(t.Props), &props); err == nil {
+			res[props.RuleID] = true
+		}
+	}
+
+	return res
+}
+
+// NewLifecycleTask 新建生命周期规则执行任务
+func NewLifecycleTask(user *model.User, ruleID uint) (Job, error) {
+	newTask := &LifecycleTask{
+		User:      user,
+		TaskProps: LifecycleProps{RuleID: ruleID},
+	}
+
+	record, err := Record(newTask)
+	if err != nil {
+		return nil, err
+	}
+	newTask.TaskModel = record
+
+	return newTask, nil
+}
+
+// NewLifecycleTaskFromModel 从数据库记录中恢复生命周期规则执行任务，定时任务创建的记录没有创建者
+func NewLifecycleTaskFromModel(task *model.Task) (Job, error) {
+	user := model.NewAnonymousUser()
+	if task.UserID > 0 {
+		creator, err := model.GetActiveUserByID(task.UserID)
+		if err != nil {
+			return nil, err
+		}
+		user = &creator
+	}
+
+	newTask := &LifecycleTask{
+		User:      user,
+		TaskModel: task,
+	}
+
+	err := json.Unmarshal([]byte(task.Props), &newTask.TaskProps)
+	if err != nil {
+		return nil, err
+	}
+
+	return newTask, nil
+}
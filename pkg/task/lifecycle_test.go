@@ -0,0 +1,120 @@
+package task
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/Jaylenwa/Vfoy/models"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLifecycleTask_Props(t *testing.T) {
+	asserts := assert.New(t)
+	task := &LifecycleTask{
+		User: &model.User{},
+	}
+	asserts.NotEmpty(task.Props())
+	asserts.Equal(LifecycleTaskType, task.Type())
+	asserts.EqualValues(0, task.Creator())
+	asserts.Nil(task.Model())
+}
+
+func TestLifecycleTask_Do(t *testing.T) {
+	asserts := assert.New(t)
+	task := &LifecycleTask{
+		User: &model.User{Policy: model.Policy{Type: "local"}},
+		TaskModel: &model.Task{
+			Model: gorm.Model{ID: 1},
+		},
+		TaskProps: LifecycleProps{RuleID: 2},
+	}
+
+	// 规则不存在
+	{
+		mock.ExpectQuery("SELECT(.+)lifecycle_rules(.+)").WillReturnError(errors.New("not found"))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		task.Do()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal("Lifecycle rule not exist.", task.GetError().Msg)
+		task.Err = nil
+	}
+
+	// 删除文件失败
+	{
+		mock.ExpectQuery("SELECT(.+)lifecycle_rules(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "action", "min_age"}).AddRow(2, model.LifecycleActionDelete, 30))
+		// 设定listing状态
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		// 列出匹配的文件
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "user_id"}).
+				AddRow(5, "a.txt", 7).
+				AddRow(6, "b.txt", 7))
+		// 设定transferring状态
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		// 查找文件所有者
+		mock.ExpectQuery("SELECT(.+)users(.+)").WillReturnError(errors.New("not found"))
+		// 记录进度
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		// 没有更多文件
+		mock.ExpectQuery("SELECT(.+)files(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		// 设定失败信息
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		task.Do()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal("Failed to process 2 file(s).", task.GetError().Msg)
+		asserts.EqualValues(6, task.TaskProps.LastFileID)
+		asserts.Equal(2, task.TaskProps.Failed)
+		asserts.Len(task.TaskProps.Errors, 2)
+	}
+}
+
+func TestRunningLifecycleRules(t *testing.T) {
+	asserts := assert.New(t)
+
+	mock.ExpectQuery("SELECT(.+)tasks(.+)").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "type", "props"}).
+			AddRow(1, LifecycleTaskType, `{"rule_id":3}`).
+			AddRow(2, MigrateTaskType, `{"rule_id":4}`).
+			AddRow(3, LifecycleTaskType, `?`))
+	res := RunningLifecycleRules()
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.Equal(map[uint]bool{3: true}, res)
+}
+
+func TestNewLifecycleTaskFromModel(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 定时任务创建
+	{
+		mock.ExpectQuery("SELECT(.+)groups(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+		job, err := NewLifecycleTaskFromModel(&model.Task{Props: `{"rule_id":2,"last_file_id":10}`})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.EqualValues(2, job.(*LifecycleTask).TaskProps.RuleID)
+		asserts.EqualValues(10, job.(*LifecycleTask).TaskProps.LastFileID)
+		asserts.EqualValues(0, job.Creator())
+	}
+
+	// 创建者不存在
+	{
+		mock.ExpectQuery("SELECT(.+)groups(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+		mock.ExpectQuery("SELECT(.+)users(.+)").WillReturnError(errors.New("error"))
+		job, err := NewLifecycleTaskFromModel(&model.Task{UserID: 1, Props: `{"rule_id":2}`})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+		asserts.Nil(job)
+	}
+}
@@ -0,0 +1,294 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	model "github.com/Jaylenwa/Vfoy/models"
+	"github.com/Jaylenwa/Vfoy/pkg/filesystem"
+	"github.com/Jaylenwa/Vfoy/pkg/util"
+)
+
+// migrateBatchSize 迁移时每批处理的文件数
+const migrateBatchSize = 100
+
+// maxTaskErrors 任务失败信息中最多记录的错误数
+const maxTaskErrors = 10
+
+// FileFailures 批量处理文件的任务中失败的文件数及部分失败原因
+type FileFailures struct {
+	Failed int      `json:"failed"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+// AddFailure 记录文件处理失败，最多保留 maxTaskErrors 条失败原因
+func (f *FileFailures) AddFailure(file *model.File, err error) {
+	f.Failed++
+	if len(f.Errors) < maxTaskErrors {
+		f.Errors = append(f.Errors, fmt.Sprintf("%s: %s", file.Name, err))
+	}
+}
+
+// FailureErr 合并记录的失败原因，没有失败时返回 nil
+func (f *FileFailures) FailureErr() error {
+	if f.Failed == 0 {
+		return nil
+	}
+
+	return errors.New(strings.Join(f.Errors, "\n"))
+}
+
+// MigrateTask 存储策略迁移任务
+type MigrateTask struct {
+	User      *model.User
+	TaskModel *model.Task
+	TaskProps MigrateProps
+	Err       *JobError
+
+	// 目录ID与路径的对应
+	folderPaths map[uint]string
+}
+
+// MigrateProps 存储策略迁移任务属性
+type MigrateProps struct {
+	DstPolicyID uint `json:"dst_policy_id"` // 目标存储策略ID
+	UserID      uint `json:"user_id"`       // 限定用户
+	GroupID     uint `json:"group_id"`      // 限定用户组
+	FolderID    uint `json:"folder_id"`     // 限定目录，包含子目录
+	PolicyID    uint `json:"policy_id"`     // 限定原存储策略
+
+	// 已处理的最后一个文件 ID，用于恢复任务
+	LastFileID uint `json:"last_file_id"`
+	Migrated   int  `json:"migrated"`
+	FileFailures
+}
+
+// Props 获取任务属性
+func (job *MigrateTask) Props() string {
+	res, _ := json.Marshal(job.TaskProps)
+	return string(res)
+}
+
+// Type 获取任务状态
+func (job *MigrateTask) Type() int {
+	return MigrateTaskType
+}
+
+// Creator 获取创建者ID
+func (job *MigrateTask) Creator() uint {
+	return job.User.ID
+}
+
+// Model 获取任务的数据库模型
+func (job *MigrateTask) Model() *model.Task {
+	return job.TaskModel
+}
+
+// SetStatus 设定状态
+func (job *MigrateTask) SetStatus(status int) {
+	job.TaskModel.SetStatus(status)
+}
+
+// SetError 设定任务失败信息
+func (job *MigrateTask) SetError(err *JobError) {
+	job.Err = err
+	res, _ := json.Marshal(job.Err)
+	job.TaskModel.SetError(string(res))
+}
+
+// SetErrorMsg 设定任务失败信息
+func (job *MigrateTask) SetErrorMsg(msg string, err error) {
+	jobErr := &JobError{Msg: msg}
+	if err != nil {
+		jobErr.Error = err.Error()
+	}
+	job.SetError(jobErr)
+}
+
+// GetError 返回任务失败信息
+func (job *MigrateTask) GetError() *JobError {
+	return job.Err
+}
+
+// Do 开始执行任务
+func (job *MigrateTask) Do() {
+	dst, err := model.GetPolicyByID(job.TaskProps.DstPolicyID)
+	if err != nil {
+		job.SetErrorMsg("Policy not exist.", err)
+		return
+	}
+
+	scope, err := job.scope()
+	if err != nil {
+		job.SetErrorMsg("Failed to list folders.", err)
+		return
+	}
+
+	fs, err := filesystem.NewFileSystem(job.User)
+	if err != nil {
+		job.SetErrorMsg("Failed to initialize filesystem.", err)
+		return
+	}
+	defer fs.Recycle()
+
+	job.TaskModel.SetProgress(ListingProgress)
+	ctx := context.Background()
+	for {
+		files, err := model.GetFilesToMigrate(dst.ID, scope, job.TaskProps.LastFileID, migrateBatchSize)
+		if err != nil {
+			job.SetErrorMsg("Failed to list files.", err)
+			return
+		}
+
+		if len(files) == 0 {
+			break
+		}
+
+		job.TaskModel.SetProgress(TransferringProgress)
+		for i := range files {
+			if err := migrateFile(ctx, fs, &files[i], &dst, job.folderPath(&files[i])); err != nil {
+				util.Log().Warning("Failed to migrate file %q: %s", files[i].Name, err)
+				job.TaskProps.AddFailure(&files[i], err)
+			} else {
+				job.TaskProps.Migrated++
+			}
+
+			// 记录进度，以便任务中断后继续
+			job.TaskProps.LastFileID = files[i].ID
+			job.TaskModel.SetProps(job.Props())
+		}
+	}
+
+	if job.TaskProps.Failed > 0 {
+		job.SetErrorMsg(
+			fmt.Sprintf("Failed to migrate %d file(s).", job.TaskProps.Failed),
+			job.TaskProps.FailureErr(),
+		)
+	}
+}
+
+// migrateFile 将文件及其历史版本迁移到 dst 存储策略，virtualPath 为文件所在目录的路径
+func migrateFile(ctx context.Context, fs *filesystem.FileSystem, file *model.File, dst *model.Policy, virtualPath string) error {
+	if err := fs.MigrateFile(ctx, file, dst, virtualPath); err != nil {
+		return err
+	}
+
+	versions, err := model.GetFileVersionsByFileIDs([]uint{file.ID})
+	if err != nil {
+		return err
+	}
+
+	for _, version := range versions {
+		if version.PolicyID == dst.ID {
+			continue
+		}
+
+		// 历史版本的内容摘要未记录，迁移时只比对读取的内容
+		object := version.AsFile(file)
+		object.Hash = ""
+		object.Policy = model.Policy{}
+		if err := fs.MigrateFile(ctx, &object, dst, virtualPath); err != nil {
+			return fmt.Errorf("version %d: %w", version.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// scope 根据任务属性生成迁移范围
+func (job *MigrateTask) scope() (*model.MigrateScope, error) {
+	scope := &model.MigrateScope{
+		UserID:   job.TaskProps.UserID,
+		GroupID:  job.TaskProps.GroupID,
+		PolicyID: job.TaskProps.PolicyID,
+	}
+
+	if job.TaskProps.FolderID > 0 {
+		folder, err := model.GetFolderByID(job.TaskProps.FolderID)
+		if err != nil {
+			return nil, err
+		}
+
+		folders, err := model.GetRecursiveChildFolder([]uint{folder.ID}, folder.OwnerID, true)
+		if err != nil {
+			return nil, err
+		}
+
+		scope.FolderIDs = make([]uint, len(folders))
+		for i, f := range folders {
+			scope.FolderIDs[i] = f.ID
+		}
+	}
+
+	return scope, nil
+}
+
+// folderPath 返回文件所在目录的路径
+func (job *MigrateTask) folderPath(file *model.File) string {
+	if job.folderPaths == nil {
+		job.folderPaths = make(map[uint]string)
+	}
+
+	return folderPath(job.folderPaths, file.FolderID)
+}
+
+// folderPath 返回目录的路径并记录在 cache 中，目录已不存在时返回根目录
+func folderPath(cache map[uint]string, folderID uint) string {
+	if res, ok := cache[folderID]; ok {
+		return res
+	}
+
+	res := "/"
+	if folder, err := model.GetFolderByID(folderID); err == nil {
+		if err := folder.TraceRoot(); err == nil {
+			res = path.Join(folder.Position, folder.Name)
+		}
+	}
+
+	cache[folderID] = res
+	return res
+}
+
+// NewMigrateTask 新建存储策略迁移任务
+func NewMigrateTask(user uint, props MigrateProps) (Job, error) {
+	creator, err := model.GetActiveUserByID(user)
+	if err != nil {
+		return nil, err
+	}
+
+	newTask := &MigrateTask{
+		User:      &creator,
+		TaskProps: props,
+	}
+
+	record, err := Record(newTask)
+	if err != nil {
+		return nil, err
+	}
+	newTask.TaskModel = record
+
+	return newTask, nil
+}
+
+// NewMigrateTaskFromModel 从数据库记录中恢复存储策略迁移任务
+func NewMigrateTaskFromModel(task *model.Task) (Job, error) {
+	user, err := model.GetActiveUserByID(task.UserID)
+	if err != nil {
+		return nil, err
+	}
+	newTask := &MigrateTask{
+		User:      &user,
+		TaskModel: task,
+	}
+
+	err = json.Unmarshal([]byte(task.Props), &newTask.TaskProps)
+	if err != nil {
+		return nil, err
+	}
+
+	return newTask, nil
+}
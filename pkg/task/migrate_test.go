@@ -0,0 +1,114 @@
+package task
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/Jaylenwa/Vfoy/models"
+	"github.com/Jaylenwa/Vfoy/pkg/cache"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrateTask_Props(t *testing.T) {
+	asserts := assert.New(t)
+	task := &MigrateTask{
+		User: &model.User{},
+	}
+	asserts.NotEmpty(task.Props())
+	asserts.Equal(MigrateTaskType, task.Type())
+	asserts.EqualValues(0, task.Creator())
+	asserts.Nil(task.Model())
+}
+
+func TestMigrateTask_Do(t *testing.T) {
+	asserts := assert.New(t)
+	task := &MigrateTask{
+		User: &model.User{Policy: model.Policy{Type: "local"}},
+		TaskModel: &model.Task{
+			Model: gorm.Model{ID: 1},
+		},
+		TaskProps: MigrateProps{DstPolicyID: 82},
+	}
+
+	// 存储策略不存在
+	{
+		cache.Deletes([]string{"82"}, "policy_")
+		mock.ExpectQuery("SELECT(.+)policies(.+)").WillReturnError(errors.New("not found"))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		task.Do()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal("Policy not exist.", task.GetError().Msg)
+		task.Err = nil
+	}
+
+	// 文件迁移失败
+	{
+		cache.Deletes([]string{"81", "82"}, "policy_")
+		mock.ExpectQuery("SELECT(.+)policies(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "type"}).AddRow(82, "local"))
+		// 设定listing状态
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		// 列出待迁移文件
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WithArgs(0, 82).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "policy_id", "source_name", "folder_id"}).
+				AddRow(5, "a.txt", 81, "not_exist.txt", 1))
+		// 设定transferring状态
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		// 查找所在目录
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "/"))
+		// 查找原存储策略
+		mock.ExpectQuery("SELECT(.+)policies(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "type"}).AddRow(81, "local"))
+		// 记录进度
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		// 没有更多文件
+		mock.ExpectQuery("SELECT(.+)files(.+)").
+			WithArgs(5, 82).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		// 设定失败信息
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		task.Do()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal("Failed to migrate 1 file(s).", task.GetError().Msg)
+		asserts.EqualValues(5, task.TaskProps.LastFileID)
+		asserts.Equal(1, task.TaskProps.Failed)
+		asserts.Len(task.TaskProps.Errors, 1)
+	}
+}
+
+func TestNewMigrateTaskFromModel(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 成功
+	{
+		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		job, err := NewMigrateTaskFromModel(&model.Task{Props: `{"dst_policy_id":2,"last_file_id":10}`})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.EqualValues(2, job.(*MigrateTask).TaskProps.DstPolicyID)
+		asserts.EqualValues(10, job.(*MigrateTask).TaskProps.LastFileID)
+	}
+
+	// JSON解析失败
+	{
+		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		job, err := NewMigrateTaskFromModel(&model.Task{Props: "?"})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+		asserts.Nil(job)
+	}
+}
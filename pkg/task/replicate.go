@@ -0,0 +1,213 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	model "github.com/Jaylenwa/Vfoy/models"
+	"github.com/Jaylenwa/Vfoy/pkg/filesystem"
+	"github.com/Jaylenwa/Vfoy/pkg/util"
+)
+
+// replicateBatchSize 补全副本时每批处理的文件数
+const replicateBatchSize = 100
+
+// ReplicateTask 副本补全任务，为设定了副本存储策略的存储策略补全缺失或过期的副本
+type ReplicateTask struct {
+	User      *model.User
+	TaskModel *model.Task
+	TaskProps ReplicateProps
+	Err       *JobError
+
+	// 目录ID与路径的对应
+	folderPaths map[uint]string
+}
+
+// ReplicateProps 副本补全任务属性
+type ReplicateProps struct {
+	PolicyID uint `json:"policy_id"` // 限定存储策略，为 0 时处理全部存储策略
+
+	// 正在处理的存储策略及其已处理的最后一个文件 ID，用于恢复任务
+	CurrentPolicyID uint `json:"current_policy_id"`
+	LastFileID      uint `json:"last_file_id"`
+	Replicated      int  `json:"replicated"`
+	FileFailures
+}
+
+// Props 获取任务属性
+func (job *ReplicateTask) Props() string {
+	res, _ := json.Marshal(job.TaskProps)
+	return string(res)
+}
+
+// Type 获取任务状态
+func (job *ReplicateTask) Type() int {
+	return ReplicateTaskType
+}
+
+// Creator 获取创建者ID
+func (job *ReplicateTask) Creator() uint {
+	return job.User.ID
+}
+
+// Model 获取任务的数据库模型
+func (job *ReplicateTask) Model() *model.Task {
+	return job.TaskModel
+}
+
+// SetStatus 设定状态
+func (job *ReplicateTask) SetStatus(status int) {
+	job.TaskModel.SetStatus(status)
+}
+
+// SetError 设定任务失败信息
+func (job *ReplicateTask) SetError(err *JobError) {
+	job.Err = err
+	res, _ := json.Marshal(job.Err)
+	job.TaskModel.SetError(string(res))
+}
+
+// SetErrorMsg 设定任务失败信息
+func (job *ReplicateTask) SetErrorMsg(msg string, err error) {
+	jobErr := &JobError{Msg: msg}
+	if err != nil {
+		jobErr.Error = err.Error()
+	}
+	job.SetError(jobErr)
+}
+
+// GetError 返回任务失败信息
+func (job *ReplicateTask) GetError() *JobError {
+	return job.Err
+}
+
+// Do 开始执行任务
+func (job *ReplicateTask) Do() {
+	policies, err := model.GetReplicatedPolicies()
+	if err != nil {
+		job.SetErrorMsg("Failed to list policies.", err)
+		return
+	}
+
+	fs, err := filesystem.NewFileSystem(job.User)
+	if err != nil {
+		job.SetErrorMsg("Failed to initialize filesystem.", err)
+		return
+	}
+	defer fs.Recycle()
+
+	ctx := context.Background()
+	for i := range policies {
+		policy := &policies[i]
+		if job.TaskProps.PolicyID > 0 && policy.ID != job.TaskProps.PolicyID {
+			continue
+		}
+
+		// 跳过恢复任务前已处理完成的存储策略
+		if policy.ID < job.TaskProps.CurrentPolicyID {
+			continue
+		}
+
+		if policy.ID != job.TaskProps.CurrentPolicyID {
+			job.TaskProps.CurrentPolicyID = policy.ID
+			job.TaskProps.LastFileID = 0
+		}
+
+		if err := job.replicatePolicy(ctx, fs, policy); err != nil {
+			job.SetErrorMsg("Failed to list files.", err)
+			return
+		}
+	}
+
+	if job.TaskProps.Failed > 0 {
+		job.SetErrorMsg(
+			fmt.Sprintf("Failed to replicate %d file(s).", job.TaskProps.Failed),
+			job.TaskProps.FailureErr(),
+		)
+	}
+}
+
+// replicatePolicy 补全存储策略上缺失的副本
+func (job *ReplicateTask) replicatePolicy(ctx context.Context, fs *filesystem.FileSystem, policy *model.Policy) error {
+	job.TaskModel.SetProgress(ListingProgress)
+	for {
+		files, err := model.GetFilesMissingReplica(
+			policy.ID,
+			policy.OptionsSerialized.ReplicaPolicyID,
+			job.TaskProps.LastFileID,
+			replicateBatchSize,
+		)
+		if err != nil {
+			return err
+		}
+
+		if len(files) == 0 {
+			return nil
+		}
+
+		job.TaskModel.SetProgress(TransferringProgress)
+		for i := range files {
+			files[i].Policy = *policy
+			if err := fs.ReplicateFile(ctx, &files[i], job.folderPath(&files[i])); err != nil {
+				util.Log().Warning("Failed to replicate file %q: %s", files[i].Name, err)
+				job.TaskProps.AddFailure(&files[i], err)
+			} else {
+				job.TaskProps.Replicated++
+			}
+
+			// 记录进度，以便任务中断后继续
+			job.TaskProps.LastFileID = files[i].ID
+			job.TaskModel.SetProps(job.Props())
+		}
+	}
+}
+
+// folderPath 返回文件所在目录的路径
+func (job *ReplicateTask) folderPath(file *model.File) string {
+	if job.folderPaths == nil {
+		job.folderPaths = make(map[uint]string)
+	}
+
+	return folderPath(job.folderPaths, file.FolderID)
+}
+
+// NewReplicateTask 新建副本补全任务，policyID 为 0 时处理全部存储策略
+func NewReplicateTask(user uint, policyID uint) (Job, error) {
+	creator, err := model.GetActiveUserByID(user)
+	if err != nil {
+		return nil, err
+	}
+
+	newTask := &ReplicateTask{
+		User:      &creator,
+		TaskProps: ReplicateProps{PolicyID: policyID},
+	}
+
+	record, err := Record(newTask)
+	if err != nil {
+		return nil, err
+	}
+	newTask.TaskModel = record
+
+	return newTask, nil
+}
+
+// NewReplicateTaskFromModel 从数据库记录中恢复副本补全任务
+func NewReplicateTaskFromModel(task *model.Task) (Job, error) {
+	user, err := model.GetActiveUserByID(task.UserID)
+	if err != nil {
+		return nil, err
+	}
+	newTask := &ReplicateTask{
+		User:      &user,
+		TaskModel: task,
+	}
+
+	err = json.Unmarshal([]byte(task.Props), &newTask.TaskProps)
+	if err != nil {
+		return nil, err
+	}
+
+	return newTask, nil
+}
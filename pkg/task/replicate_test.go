@@ -0,0 +1,117 @@
+package task
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	model "github.com/Jaylenwa/Vfoy/models"
+	"github.com/Jaylenwa/Vfoy/pkg/cache"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplicateTask_Props(t *testing.T) {
+	asserts := assert.New(t)
+	task := &ReplicateTask{
+		User: &model.User{},
+	}
+	asserts.NotEmpty(task.Props())
+	asserts.Equal(ReplicateTaskType, task.Type())
+	asserts.EqualValues(0, task.Creator())
+	asserts.Nil(task.Model())
+}
+
+func TestReplicateTask_Do(t *testing.T) {
+	asserts := assert.New(t)
+	task := &ReplicateTask{
+		User: &model.User{Policy: model.Policy{Type: "local"}},
+		TaskModel: &model.Task{
+			Model: gorm.Model{ID: 1},
+		},
+	}
+
+	// 列出存储策略失败
+	{
+		mock.ExpectQuery("SELECT(.+)policies(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		task.Do()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal("Failed to list policies.", task.GetError().Msg)
+		task.Err = nil
+	}
+
+	// 文件复制失败
+	{
+		cache.Deletes([]string{"4"}, "policy_")
+		mock.ExpectQuery("SELECT(.+)policies(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "type", "options"}).
+				AddRow(1, "local", `{}`).
+				AddRow(3, "local", `{"replica_policy_id":4}`))
+		// 设定listing状态
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		// 列出缺少副本的文件
+		mock.ExpectQuery("SELECT(.+)files(.+)replicas(.+)").
+			WithArgs(0, 3, 4).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "policy_id", "source_name", "folder_id"}).
+				AddRow(5, "a.txt", 3, "not_exist.txt", 1))
+		// 设定transferring状态
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		// 查找所在目录
+		mock.ExpectQuery("SELECT(.+)folders(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "/"))
+		// 查找副本存储策略
+		mock.ExpectQuery("SELECT(.+)policies(.+)").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "type"}).AddRow(4, "local"))
+		// 查找已有副本
+		mock.ExpectQuery("SELECT(.+)replicas(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		// 记录进度
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		// 没有更多文件
+		mock.ExpectQuery("SELECT(.+)files(.+)replicas(.+)").
+			WithArgs(5, 3, 4).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		// 设定失败信息
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		task.Do()
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal("Failed to replicate 1 file(s).", task.GetError().Msg)
+		asserts.EqualValues(3, task.TaskProps.CurrentPolicyID)
+		asserts.EqualValues(5, task.TaskProps.LastFileID)
+		asserts.Equal(1, task.TaskProps.Failed)
+		asserts.Len(task.TaskProps.Errors, 1)
+	}
+}
+
+func TestNewReplicateTaskFromModel(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 成功
+	{
+		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		job, err := NewReplicateTaskFromModel(&model.Task{Props: `{"policy_id":2,"current_policy_id":2,"last_file_id":10}`})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.EqualValues(2, job.(*ReplicateTask).TaskProps.CurrentPolicyID)
+		asserts.EqualValues(10, job.(*ReplicateTask).TaskProps.LastFileID)
+	}
+
+	// JSON解析失败
+	{
+		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		job, err := NewReplicateTaskFromModel(&model.Task{Props: "?"})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+		asserts.Nil(job)
+	}
+}
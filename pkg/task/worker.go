@@ -3,6 +3,8 @@ package task
 import (
 	"fmt"
 
+	"github.com/Jaylenwa/Vfoy/pkg/mq"
+	"github.com/Jaylenwa/Vfoy/pkg/serializer"
 	"github.com/Jaylenwa/Vfoy/pkg/util"
 )
 
@@ -18,14 +20,14 @@ type GeneralWorker struct {
 // Do 执行任务
 func (worker *GeneralWorker) Do(job Job) {
 	util.Log().Debug("Start executing task.")
-	job.SetStatus(Processing)
+	setStatus(job, Processing)
 
 	defer func() {
 		// 致命错误捕获
 		if err := recover(); err != nil {
 			util.Log().Debug("Failed to execute task: %s", err)
 			job.SetError(&JobError{Msg: "Fatal error.", Error: fmt.Sprintf("%s", err)})
-			job.SetStatus(Error)
+			setStatus(job, Error)
 		}
 	}()
 
@@ -35,11 +37,27 @@ func (worker *GeneralWorker) Do(job Job) {
 	// 任务执行失败
 	if err := job.GetError(); err != nil {
 		util.Log().Debug("Failed to execute task.")
-		job.SetStatus(Error)
+		setStatus(job, Error)
 		return
 	}
 
 	util.Log().Debug("Task finished.")
 	// 执行完成
-	job.SetStatus(Complete)
+	setStatus(job, Complete)
+}
+
+// setStatus 设定任务状态，并推送给任务创建者
+func setStatus(job Job, status int) {
+	job.SetStatus(status)
+
+	task := job.Model()
+	if task == nil || job.Creator() == 0 {
+		return
+	}
+
+	event := serializer.TaskEvent{ID: task.ID, Type: job.Type(), Status: status}
+	if err := job.GetError(); err != nil {
+		event.Error = err.Msg
+	}
+	mq.PublishUserEvent(job.Creator(), mq.EventTaskUpdate, event)
 }
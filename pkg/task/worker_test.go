@@ -2,8 +2,13 @@ package task
 
 import (
 	"testing"
+	"time"
 
+	"github.com/DATA-DOG/go-sqlmock"
 	model "github.com/Jaylenwa/Vfoy/models"
+	"github.com/Jaylenwa/Vfoy/pkg/mq"
+	"github.com/Jaylenwa/Vfoy/pkg/serializer"
+	"github.com/jinzhu/gorm"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -14,11 +19,11 @@ type MockJob struct {
 }
 
 func (job *MockJob) Type() int {
-	panic("implement me")
+	return 0
 }
 
 func (job *MockJob) Creator() uint {
-	panic("implement me")
+	return 0
 }
 
 func (job *MockJob) Props() string {
@@ -26,7 +31,7 @@ func (job *MockJob) Props() string {
 }
 
 func (job *MockJob) Model() *model.Task {
-	panic("implement me")
+	return nil
 }
 
 func (job *MockJob) SetStatus(status int) {
@@ -79,3 +84,28 @@ func TestGeneralWorker_Do(t *testing.T) {
 	}
 
 }
+
+func TestSetStatus(t *testing.T) {
+	asserts := assert.New(t)
+	notifier := mq.GlobalMQ.Subscribe(mq.UserTopic(1), 1)
+	defer mq.GlobalMQ.Unsubscribe(mq.UserTopic(1), notifier)
+
+	job := &MigrateTask{
+		User:      &model.User{Model: gorm.Model{ID: 1}},
+		TaskModel: &model.Task{Model: gorm.Model{ID: 2}},
+		Err:       &JobError{Msg: "error"},
+	}
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	setStatus(job, Error)
+	asserts.NoError(mock.ExpectationsWereMet())
+
+	select {
+	case msg := <-notifier:
+		asserts.Equal(mq.EventTaskUpdate, msg.Event)
+		asserts.Equal(serializer.TaskEvent{ID: 2, Type: MigrateTaskType, Status: Error, Error: "error"}, msg.Content)
+	case <-time.After(time.Second):
+		asserts.Fail("event not published")
+	}
+}
@@ -0,0 +1,113 @@
+package tus
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"hash"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// tus 1.0 断点续传协议，见 https://tus.io/protocols/resumable-upload
+
+const (
+	// Version 支持的协议版本
+	Version = "1.0.0"
+	// Extensions 支持的协议扩展
+	Extensions = "creation,termination,checksum,expiration"
+	// ChecksumAlgorithms 支持的校验和算法
+	ChecksumAlgorithms = "md5,sha1,sha256"
+	// ContentType PATCH 请求的内容类型
+	ContentType = "application/offset+octet-stream"
+
+	// StatusChecksumMismatch 校验和不匹配时的响应状态码
+	StatusChecksumMismatch = 460
+)
+
+// 协议使用的请求头和响应头
+const (
+	HeaderResumable         = "Tus-Resumable"
+	HeaderVersion           = "Tus-Version"
+	HeaderExtension         = "Tus-Extension"
+	HeaderChecksumAlgorithm = "Tus-Checksum-Algorithm"
+	HeaderUploadLength      = "Upload-Length"
+	HeaderUploadDeferLength = "Upload-Defer-Length"
+	HeaderUploadOffset      = "Upload-Offset"
+	HeaderUploadMetadata    = "Upload-Metadata"
+	HeaderUploadChecksum    = "Upload-Checksum"
+	HeaderUploadExpires     = "Upload-Expires"
+)
+
+// maxMetadataValueLength 单个元数据值的最大长度
+const maxMetadataValueLength = 4096
+
+var (
+	ErrInvalidMetadata     = errors.New("invalid Upload-Metadata")
+	ErrInvalidChecksum     = errors.New("invalid Upload-Checksum")
+	ErrUnsupportedChecksum = errors.New("unsupported checksum algorithm")
+)
+
+// ParseMetadata 解析 Upload-Metadata 请求头，格式为逗号分隔的 "键 Base64值"，值可省略
+func ParseMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		key, encoded, _ := strings.Cut(pair, " ")
+		if key == "" || strings.ContainsAny(key, " \t") {
+			return nil, ErrInvalidMetadata
+		}
+
+		value, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil || len(value) > maxMetadataValueLength {
+			return nil, ErrInvalidMetadata
+		}
+
+		metadata[key] = string(value)
+	}
+
+	return metadata, nil
+}
+
+// ParseChecksum 解析 Upload-Checksum 请求头，返回对应算法的摘要计算器及期望的摘要
+func ParseChecksum(header string) (hash.Hash, []byte, error) {
+	algorithm, encoded, ok := strings.Cut(strings.TrimSpace(header), " ")
+	if !ok {
+		return nil, nil, ErrInvalidChecksum
+	}
+
+	expected, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, nil, ErrInvalidChecksum
+	}
+
+	var h hash.Hash
+	switch strings.ToLower(algorithm) {
+	case "md5":
+		h = md5.New()
+	case "sha1":
+		h = sha1.New()
+	case "sha256":
+		h = sha256.New()
+	default:
+		return nil, nil, ErrUnsupportedChecksum
+	}
+
+	if len(expected) != h.Size() {
+		return nil, nil, ErrInvalidChecksum
+	}
+
+	return h, expected, nil
+}
+
+// FormatExpires 格式化 Upload-Expires 响应头
+func FormatExpires(t time.Time) string {
+	return t.UTC().Format(http.TimeFormat)
+}
@@ -0,0 +1,53 @@
+package tus
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMetadata(t *testing.T) {
+	a := assert.New(t)
+
+	metadata, err := ParseMetadata("filename dGVzdC50eHQ=, is_confidential,path Lw==")
+	a.NoError(err)
+	a.Equal(map[string]string{"filename": "test.txt", "is_confidential": "", "path": "/"}, metadata)
+
+	metadata, err = ParseMetadata("")
+	a.NoError(err)
+	a.Empty(metadata)
+
+	// 值不是 Base64
+	_, err = ParseMetadata("filename test.txt")
+	a.Equal(ErrInvalidMetadata, err)
+}
+
+func TestParseChecksum(t *testing.T) {
+	a := assert.New(t)
+	sum := sha1.Sum([]byte("hello"))
+
+	h, expected, err := ParseChecksum("sha1 " + base64.StdEncoding.EncodeToString(sum[:]))
+	a.NoError(err)
+	a.Equal(sum[:], expected)
+	h.Write([]byte("hello"))
+	a.Equal(expected, h.Sum(nil))
+
+	// 不支持的算法
+	_, _, err = ParseChecksum("crc32 AAAAAA==")
+	a.Equal(ErrUnsupportedChecksum, err)
+
+	// 格式错误
+	_, _, err = ParseChecksum("sha1")
+	a.Equal(ErrInvalidChecksum, err)
+	_, _, err = ParseChecksum("sha1 AAAAAA==")
+	a.Equal(ErrInvalidChecksum, err)
+}
+
+func TestFormatExpires(t *testing.T) {
+	a := assert.New(t)
+	expires := time.Date(2022, 1, 2, 3, 4, 5, 0, time.FixedZone("CST", 8*3600))
+	a.Equal("Sat, 01 Jan 2022 19:04:05 GMT", FormatExpires(expires))
+}
@@ -0,0 +1,266 @@
+package webdav
+
+import (
+	"encoding/gob"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Jaylenwa/Vfoy/pkg/cache"
+	"github.com/Jaylenwa/Vfoy/pkg/util"
+	"github.com/gofrs/uuid"
+)
+
+// cacheLSKeyPrefix WebDAV 锁表在缓存中的键前缀
+const cacheLSKeyPrefix = "webdav_ls_"
+
+func init() {
+	gob.Register(map[string]cacheLSNode{})
+}
+
+// NewLockSystem 为用户创建 LockSystem，配置 Redis 时锁保存在 Redis 中，
+// 重启后不会丢失且对所有主机节点可见，否则使用进程内的 LockSystem
+func NewLockSystem(uid uint) LockSystem {
+	if _, ok := cache.Store.(*cache.RedisStore); ok {
+		return NewCacheLS(cache.Store, uid)
+	}
+
+	return NewMemLS()
+}
+
+// NewCacheLS 返回保存在缓存中的 LockSystem
+func NewCacheLS(store cache.Driver, uid uint) LockSystem {
+	return &cacheLS{
+		store: store,
+		key:   fmt.Sprintf("%s%d", cacheLSKeyPrefix, uid),
+	}
+}
+
+// cacheLSHeldTimeout 请求持有锁的最长时间，处理请求的节点异常退出后锁在此之后可再次使用
+const cacheLSHeldTimeout = time.Hour
+
+// cacheLS 将用户的全部锁以锁令牌为键保存在缓存中，每次修改都通过缓存的原子更新完成，
+// 多个节点并发加锁、解锁时不会互相覆盖
+type cacheLS struct {
+	store cache.Driver
+	key   string
+}
+
+// cacheLSNode 缓存中保存的锁，Expiry 为零值时永不过期
+type cacheLSNode struct {
+	Details LockDetails
+	Expiry  time.Time
+	// HeldUntil 锁正被某个请求使用，在此之前其他请求不能使用、刷新或解除该锁
+	HeldUntil time.Time
+}
+
+func (n cacheLSNode) expired(now time.Time) bool {
+	return !n.Expiry.IsZero() && !now.Before(n.Expiry)
+}
+
+func (n cacheLSNode) held(now time.Time) bool {
+	return now.Before(n.HeldUntil)
+}
+
+// covers 锁是否覆盖了指定资源
+func (n cacheLSNode) covers(name string) bool {
+	if name == n.Details.Root {
+		return true
+	}
+
+	if n.Details.ZeroDepth {
+		return false
+	}
+
+	return n.Details.Root == "/" || strings.HasPrefix(name, n.Details.Root+"/")
+}
+
+// update 原子地读取并修改锁表，读取时清理过期的锁。fn 返回错误时不做修改
+func (m *cacheLS) update(now time.Time, fn func(nodes map[string]cacheLSNode) error) error {
+	return m.store.Update(m.key, func(value interface{}, ok bool) (interface{}, int, error) {
+		nodes := make(map[string]cacheLSNode)
+		if stored, ok := value.(map[string]cacheLSNode); ok {
+			for token, n := range stored {
+				if !n.expired(now) {
+					nodes[token] = n
+				}
+			}
+		}
+
+		if err := fn(nodes); err != nil {
+			return nil, 0, err
+		}
+
+		if len(nodes) == 0 {
+			return nil, 0, nil
+		}
+
+		return nodes, cacheLSTTL(now, nodes), nil
+	})
+}
+
+// cacheLSTTL 锁表的缓存有效期与最晚过期的锁一致
+func cacheLSTTL(now time.Time, nodes map[string]cacheLSNode) int {
+	ttl := 0
+	for _, n := range nodes {
+		if n.Expiry.IsZero() {
+			return 0
+		}
+
+		if remain := int(n.Expiry.Sub(now)/time.Second) + 1; remain > ttl {
+			ttl = remain
+		}
+	}
+
+	return ttl
+}
+
+func (m *cacheLS) Confirm(now time.Time, name0, name1 string, conditions ...Condition) (func(), error) {
+	var t0, t1 string
+	err := m.update(now, func(nodes map[string]cacheLSNode) error {
+		t0, t1 = "", ""
+		if name0 != "" {
+			if t0 = lookup(nodes, now, slashClean(name0), conditions...); t0 == "" {
+				return ErrConfirmationFailed
+			}
+		}
+		if name1 != "" {
+			if t1 = lookup(nodes, now, slashClean(name1), conditions...); t1 == "" {
+				return ErrConfirmationFailed
+			}
+		}
+
+		// Don't hold the same lock twice.
+		if t1 == t0 {
+			t1 = ""
+		}
+
+		for _, t := range []string{t0, t1} {
+			if t != "" {
+				n := nodes[t]
+				n.HeldUntil = now.Add(cacheLSHeldTimeout)
+				nodes[t] = n
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return func() {
+		err := m.update(time.Now(), func(nodes map[string]cacheLSNode) error {
+			for _, t := range []string{t0, t1} {
+				if n, ok := nodes[t]; ok {
+					n.HeldUntil = time.Time{}
+					nodes[t] = n
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			util.Log().Warning("Failed to release WebDAV lock %q: %s", t0, err)
+		}
+	}, nil
+}
+
+// lookup 返回覆盖指定资源、匹配任一条件且未被持有的锁令牌
+func lookup(nodes map[string]cacheLSNode, now time.Time, name string, conditions ...Condition) string {
+	for _, c := range conditions {
+		n, ok := nodes[c.Token]
+		if !ok || n.held(now) {
+			continue
+		}
+		if n.covers(name) {
+			return c.Token
+		}
+	}
+	return ""
+}
+
+func (m *cacheLS) Create(now time.Time, details LockDetails) (string, error) {
+	details.Root = slashClean(details.Root)
+	token := "opaquelocktoken:" + uuid.Must(uuid.NewV4()).String()
+	n := cacheLSNode{Details: details}
+	if details.Duration >= 0 {
+		n.Expiry = now.Add(details.Duration)
+	}
+
+	err := m.update(now, func(nodes map[string]cacheLSNode) error {
+		if !canCreate(nodes, details.Root, details.ZeroDepth) {
+			return ErrLocked
+		}
+
+		nodes[token] = n
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+func (m *cacheLS) Refresh(now time.Time, token string, duration time.Duration) (LockDetails, error) {
+	var details LockDetails
+	err := m.update(now, func(nodes map[string]cacheLSNode) error {
+		n, ok := nodes[token]
+		if !ok {
+			return ErrNoSuchLock
+		}
+		if n.held(now) {
+			return ErrLocked
+		}
+
+		n.Details.Duration = duration
+		n.Expiry = time.Time{}
+		if duration >= 0 {
+			n.Expiry = now.Add(duration)
+		}
+
+		nodes[token] = n
+		details = n.Details
+		return nil
+	})
+	if err != nil {
+		return LockDetails{}, err
+	}
+
+	return details, nil
+}
+
+func (m *cacheLS) Unlock(now time.Time, token string) error {
+	return m.update(now, func(nodes map[string]cacheLSNode) error {
+		n, ok := nodes[token]
+		if !ok {
+			return ErrNoSuchLock
+		}
+		if n.held(now) {
+			return ErrLocked
+		}
+
+		delete(nodes, token)
+		return nil
+	})
+}
+
+// canCreate 检查是否可以在指定资源上创建锁
+func canCreate(nodes map[string]cacheLSNode, name string, zeroDepth bool) bool {
+	for _, n := range nodes {
+		root := n.Details.Root
+		switch {
+		case root == name:
+			// The target node is already locked.
+			return false
+		case !zeroDepth && (name == "/" || strings.HasPrefix(root, name+"/")):
+			// The requested lock depth is infinite and a descendent of the
+			// target node is locked.
+			return false
+		case n.covers(name):
+			// An ancestor of the target node is locked with infinite depth.
+			return false
+		}
+	}
+
+	return true
+}
@@ -137,17 +137,17 @@ func copyFiles(ctx context.Context, fs *filesystem.FileSystem, src FileInfo, dst
 	return http.StatusNoContent, nil
 }
 
-// 判断目标 文件/夹 是否已经存在，存在则先删除目标文件/夹
+// 判断目标 文件/夹 是否已经存在，存在则先删除目标文件/夹，启用回收站时移入回收站
 func _checkOverwriteFile(ctx context.Context, fs *filesystem.FileSystem, src FileInfo, dst string) error {
 	if src.IsDir() {
 		ok, folder := fs.IsPathExist(dst)
 		if ok {
-			return fs.Delete(ctx, []uint{folder.ID}, []uint{}, false, false)
+			return fs.Remove(ctx, []uint{folder.ID}, []uint{}, false, false)
 		}
 	} else {
 		ok, file := fs.IsFileExist(dst)
 		if ok {
-			return fs.Delete(ctx, []uint{}, []uint{file.ID}, false, false)
+			return fs.Remove(ctx, []uint{}, []uint{file.ID}, false, false)
 		}
 	}
 	return nil
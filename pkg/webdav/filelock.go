@@ -0,0 +1,110 @@
+package webdav
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	model "github.com/Jaylenwa/Vfoy/models"
+	"github.com/Jaylenwa/Vfoy/pkg/filesystem"
+	"github.com/Jaylenwa/Vfoy/pkg/filesystem/lock"
+	"github.com/gofrs/uuid"
+)
+
+// 文件锁由 WebDAV、WOPI 与网页编辑共享，目录及尚不存在的资源上的锁在 LockSystem 中维护
+
+// ifTokens 取得 If 请求头中携带的全部锁令牌
+func ifTokens(r *http.Request) ([]string, bool) {
+	hdr := r.Header.Get("If")
+	if hdr == "" {
+		return nil, true
+	}
+
+	ih, ok := parseIfHeader(hdr)
+	if !ok {
+		return nil, false
+	}
+
+	tokens := make([]string, 0, len(ih.lists))
+	for _, l := range ih.lists {
+		for _, c := range l.conditions {
+			if !c.Not && c.Token != "" {
+				tokens = append(tokens, c.Token)
+			}
+		}
+	}
+
+	return tokens, true
+}
+
+// fileAt 取得路径对应的文件，路径不存在或为目录时返回 nil
+func fileAt(fs *filesystem.FileSystem, reqPath string) *model.File {
+	if ok, file := fs.IsFileExist(reqPath); ok {
+		return file
+	}
+
+	return nil
+}
+
+// checkFileLock 检查路径上的文件是否被其他持有者锁定
+func checkFileLock(fs *filesystem.FileSystem, reqPath string, tokens []string) (int, error) {
+	file := fileAt(fs, reqPath)
+	if file == nil {
+		return 0, nil
+	}
+
+	if _, err := lock.Check(file.ID, tokens...); err != nil {
+		return StatusLocked, ErrLocked
+	}
+
+	return 0, nil
+}
+
+// lockFile 为文件加锁或刷新已有的锁，返回锁的剩余有效期
+func lockFile(fs *filesystem.FileSystem, file *model.File, token, owner string, duration time.Duration, refresh bool) (time.Duration, int, error) {
+	var (
+		l   *lock.Lock
+		err error
+	)
+
+	if refresh {
+		l, err = lock.Refresh(file.ID, token, duration)
+	} else {
+		l, err = lock.Acquire(lock.Lock{
+			FileID: file.ID,
+			UserID: fs.User.ID,
+			Token:  token,
+			App:    lock.AppWebDAV,
+			Owner:  owner,
+		}, duration)
+	}
+
+	switch {
+	case errors.Is(err, lock.ErrLocked):
+		return 0, StatusLocked, ErrLocked
+	case errors.Is(err, lock.ErrNoSuchLock):
+		return 0, http.StatusPreconditionFailed, ErrNoSuchLock
+	case err != nil:
+		return 0, http.StatusInternalServerError, err
+	}
+
+	return time.Until(l.ExpiresAt), 0, nil
+}
+
+// unlockFile 解除文件上的锁
+func unlockFile(file *model.File, token string) (int, error) {
+	_, err := lock.Release(file.ID, token)
+	switch {
+	case errors.Is(err, lock.ErrNoSuchLock):
+		return http.StatusConflict, ErrNoSuchLock
+	case err != nil:
+		return http.StatusInternalServerError, err
+	}
+
+	return http.StatusNoContent, nil
+}
+
+// newLockToken 生成新的锁令牌
+func newLockToken() string {
+	return "opaquelocktoken:" + uuid.Must(uuid.NewV4()).String()
+}
@@ -416,6 +416,9 @@ func (b *byExpiry) Pop() interface{} {
 
 const infiniteTimeout = -1
 
+// tempLockTimeout 检查冲突时创建的临时锁的有效期，避免节点异常退出后遗留永不过期的锁
+const tempLockTimeout = time.Hour
+
 // parseTimeout parses the Timeout HTTP header, as per section 10.7. If s is
 // empty, an infiniteTimeout is returned.
 func parseTimeout(s string) (time.Duration, error) {
@@ -14,10 +14,20 @@ import (
 	"net/http"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	model "github.com/Jaylenwa/Vfoy/models"
 	"github.com/Jaylenwa/Vfoy/pkg/filesystem"
+	"github.com/Jaylenwa/Vfoy/pkg/filesystem/fsctx"
+	"github.com/Jaylenwa/Vfoy/pkg/hashid"
+)
+
+const (
+	// ocNamespace ownCloud 属性的命名空间
+	ocNamespace = "http://owncloud.org/ns"
+	// msNamespace Windows 资源管理器属性的命名空间
+	msNamespace = "urn:schemas-microsoft-com:"
 )
 
 type FileDeadProps struct {
@@ -27,15 +37,32 @@ type FileDeadProps struct {
 // 实现 webdav.DeadPropsHolder 接口，不能在models.file里面定义
 func (file *FileDeadProps) DeadProps() (map[xml.Name]Property, error) {
 	return map[xml.Name]Property{
-		{Space: "http://owncloud.org/ns", Local: "checksums"}: {
+		{Space: ocNamespace, Local: "checksums"}: {
 			XMLName: xml.Name{
-				Space: "http://owncloud.org/ns", Local: "checksums",
+				Space: ocNamespace, Local: "checksums",
 			},
-			InnerXML: []byte("<checksum>" + file.MetadataSerialized[model.ChecksumMetadataKey] + "</checksum>"),
+			InnerXML: []byte("<checksum>" + ocChecksums(file.File) + "</checksum>"),
 		},
 	}, nil
 }
 
+// ocChecksums 以 ownCloud 格式返回文件校验和，服务端尚未计算时返回客户端提供的值
+func ocChecksums(file *model.File) string {
+	checksums := file.Checksums()
+	if len(checksums) == 0 {
+		return file.MetadataSerialized[model.ChecksumMetadataKey]
+	}
+
+	res := make([]string, 0, len(checksums))
+	for _, algorithm := range []string{model.ChecksumSHA1, model.ChecksumMD5, model.ChecksumSHA256} {
+		if sum, ok := checksums[algorithm]; ok {
+			res = append(res, strings.ToUpper(algorithm)+":"+sum)
+		}
+	}
+
+	return strings.Join(res, " ")
+}
+
 func (file *FileDeadProps) Patch(proppatches []Proppatch) ([]Propstat, error) {
 	var (
 		stat Propstat
@@ -45,18 +72,32 @@ func (file *FileDeadProps) Patch(proppatches []Proppatch) ([]Propstat, error) {
 	for _, patch := range proppatches {
 		for _, prop := range patch.Props {
 			stat.Props = append(stat.Props, Property{XMLName: prop.XMLName})
-			if prop.XMLName.Space == "DAV:" && prop.XMLName.Local == "lastmodified" {
-				var modtimeUnix int64
-				modtimeUnix, err = strconv.ParseInt(string(prop.InnerXML), 10, 64)
-				if err == nil {
-					err = model.DB.Model(file.File).UpdateColumn("updated_at", time.Unix(modtimeUnix, 0)).Error
-				}
+			if modtime, ok := patchedModTime(prop); ok {
+				err = model.DB.Model(file.File).UpdateColumn("updated_at", modtime).Error
 			}
 		}
 	}
 	return []Propstat{stat}, err
 }
 
+// patchedModTime 解析客户端通过 PROPPATCH 设定的修改时间，支持 DAV:lastmodified 中的 Unix 时间戳
+// 与 Windows 资源管理器使用的 Win32LastModifiedTime
+func patchedModTime(prop Property) (time.Time, bool) {
+	value := strings.TrimSpace(string(prop.InnerXML))
+	switch prop.XMLName {
+	case xml.Name{Space: "DAV:", Local: "lastmodified"}:
+		if modtimeUnix, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return time.Unix(modtimeUnix, 0), true
+		}
+	case xml.Name{Space: msNamespace, Local: "Win32LastModifiedTime"}:
+		if modtime, err := http.ParseTime(value); err == nil {
+			return modtime, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
 type FolderDeadProps struct {
 	*model.Folder
 }
@@ -74,12 +115,8 @@ func (folder *FolderDeadProps) Patch(proppatches []Proppatch) ([]Propstat, error
 	for _, patch := range proppatches {
 		for _, prop := range patch.Props {
 			stat.Props = append(stat.Props, Property{XMLName: prop.XMLName})
-			if prop.XMLName.Space == "DAV:" && prop.XMLName.Local == "lastmodified" {
-				var modtimeUnix int64
-				modtimeUnix, err = strconv.ParseInt(string(prop.InnerXML), 10, 64)
-				if err == nil {
-					err = model.DB.Model(folder.Folder).UpdateColumn("updated_at", time.Unix(modtimeUnix, 0)).Error
-				}
+			if modtime, ok := patchedModTime(prop); ok {
+				err = model.DB.Model(folder.Folder).UpdateColumn("updated_at", modtime).Error
 			}
 		}
 	}
@@ -182,6 +219,9 @@ var liveProps = map[xml.Name]struct {
 	findFn func(context.Context, *filesystem.FileSystem, LockSystem, string, FileInfo) (string, error)
 	// dir is true if the property applies to directories.
 	dir bool
+	// explicit is true if the property is only returned when requested by
+	// name, i.e. it is excluded from allprop and propname responses.
+	explicit bool
 }{
 	{Space: "DAV:", Local: "resourcetype"}: {
 		findFn: findResourceType,
@@ -207,8 +247,8 @@ var liveProps = map[xml.Name]struct {
 		dir: true,
 	},
 	{Space: "DAV:", Local: "creationdate"}: {
-		findFn: nil,
-		dir:    false,
+		findFn: findCreationDate,
+		dir:    true,
 	},
 	{Space: "DAV:", Local: "getcontentlanguage"}: {
 		findFn: nil,
@@ -234,6 +274,30 @@ var liveProps = map[xml.Name]struct {
 		findFn: findSupportedLock,
 		dir:    true,
 	},
+
+	// RFC 4331 quota properties, which "MUST NOT be returned by allprop".
+	{Space: "DAV:", Local: "quota-available-bytes"}: {
+		findFn:   findQuotaAvailableBytes,
+		dir:      true,
+		explicit: true,
+	},
+	{Space: "DAV:", Local: "quota-used-bytes"}: {
+		findFn:   findQuotaUsedBytes,
+		dir:      true,
+		explicit: true,
+	},
+
+	// ownCloud properties used by Nextcloud-style clients.
+	{Space: ocNamespace, Local: "fileid"}: {
+		findFn:   findOCFileID,
+		dir:      true,
+		explicit: true,
+	},
+	{Space: ocNamespace, Local: "permissions"}: {
+		findFn:   findOCPermissions,
+		dir:      true,
+		explicit: true,
+	},
 }
 
 // TODO(nigeltao) merge props and allprop?
@@ -302,7 +366,7 @@ func propnames(ctx context.Context, fs *filesystem.FileSystem, ls LockSystem, fi
 
 	pnames := make([]xml.Name, 0, len(liveProps)+len(deadProps))
 	for pn, prop := range liveProps {
-		if prop.findFn != nil && (prop.dir || !isDir) {
+		if prop.findFn != nil && !prop.explicit && (prop.dir || !isDir) {
 			pnames = append(pnames, pn)
 		}
 	}
@@ -448,6 +512,97 @@ func findLastModified(ctx context.Context, fs *filesystem.FileSystem, ls LockSys
 	return fi.ModTime().UTC().Format(http.TimeFormat), nil
 }
 
+func findCreationDate(ctx context.Context, fs *filesystem.FileSystem, ls LockSystem, name string, fi FileInfo) (string, error) {
+	var created time.Time
+	switch v := fi.(type) {
+	case *FileDeadProps:
+		created = v.CreatedAt
+	case *model.File:
+		created = v.CreatedAt
+	case *model.Folder:
+		created = v.CreatedAt
+	}
+
+	if created.IsZero() {
+		created = fi.ModTime()
+	}
+
+	return created.UTC().Format(time.RFC3339), nil
+}
+
+// capacityOf 返回资源所在目录可用和已用的容量
+func capacityOf(fs *filesystem.FileSystem, fi FileInfo) (uint64, uint64, error) {
+	switch v := fi.(type) {
+	case *FileDeadProps:
+		return fs.FolderCapacity(v.FolderID)
+	case *model.File:
+		return fs.FolderCapacity(v.FolderID)
+	case *model.Folder:
+		return fs.FolderCapacity(v.ID)
+	}
+
+	return fs.User.GetRemainingCapacity(), fs.User.Storage, nil
+}
+
+func findQuotaAvailableBytes(ctx context.Context, fs *filesystem.FileSystem, ls LockSystem, name string, fi FileInfo) (string, error) {
+	available, _, err := capacityOf(fs, fi)
+	if err != nil {
+		return "", err
+	}
+
+	return strconv.FormatUint(available, 10), nil
+}
+
+func findQuotaUsedBytes(ctx context.Context, fs *filesystem.FileSystem, ls LockSystem, name string, fi FileInfo) (string, error) {
+	_, used, err := capacityOf(fs, fi)
+	if err != nil {
+		return "", err
+	}
+
+	return strconv.FormatUint(used, 10), nil
+}
+
+func findOCFileID(ctx context.Context, fs *filesystem.FileSystem, ls LockSystem, name string, fi FileInfo) (string, error) {
+	switch v := fi.(type) {
+	case *FileDeadProps:
+		return hashid.HashID(v.ID, hashid.FileID), nil
+	case *model.File:
+		return hashid.HashID(v.ID, hashid.FileID), nil
+	case *model.Folder:
+		return hashid.HashID(v.ID, hashid.FolderID), nil
+	}
+
+	return "", nil
+}
+
+// findOCPermissions 以 ownCloud 格式返回当前 WebDAV 账户对资源的权限：
+// R 可分享，G 可读，D 可删除，N 可重命名，V 可移动，W 可写入，C 可创建文件，K 可创建目录
+func findOCPermissions(ctx context.Context, fs *filesystem.FileSystem, ls LockSystem, name string, fi FileInfo) (string, error) {
+	perms := "G"
+	if fs.User.Group.ShareEnabled {
+		perms = "R" + perms
+	}
+
+	if application, ok := ctx.Value(fsctx.WebDAVCtx).(*model.Webdav); ok && application.Readonly {
+		return perms, nil
+	}
+
+	// 根目录不能删除、重命名或移动
+	isRoot := false
+	if folder, ok := fi.(*model.Folder); ok {
+		isRoot = folder.ParentID == nil || (fs.Root != nil && folder.ID == fs.Root.ID)
+	}
+	if !isRoot {
+		perms += "DNV"
+	}
+
+	if fi.IsDir() {
+		return perms + "CK", nil
+	}
+
+	return perms + "W", nil
+}
+
 // ErrNotImplemented should be returned by optional interfaces if they
 // want the original implementation to be used.
 var ErrNotImplemented = errors.New("not implemented")
@@ -518,6 +673,11 @@ type ETager interface {
 }
 
 func findETag(ctx context.Context, fs *filesystem.FileSystem, ls LockSystem, reqPath string, fi FileInfo) (string, error) {
+	// 已计算内容摘要的文件以摘要作为 ETag
+	if file, ok := fi.(*model.File); ok && file.Hash != "" {
+		return `"` + file.Hash + `"`, nil
+	}
+
 	return fmt.Sprintf(`"%x%x"`, fi.ModTime().UnixNano(), fi.GetSize()), nil
 }
 
@@ -71,7 +71,7 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request, fs *filesyst
 		// 检查并新建 LockSystem
 		ls, ok := h.LockSystem[fs.User.ID]
 		if !ok {
-			h.LockSystem[fs.User.ID] = NewMemLS()
+			h.LockSystem[fs.User.ID] = NewLockSystem(fs.User.ID)
 			ls = h.LockSystem[fs.User.ID]
 		}
 		h.Mutex.Unlock()
@@ -113,109 +113,113 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request, fs *filesyst
 
 // OK
 func (h *Handler) lock(now time.Time, root string, fs *filesystem.FileSystem, ls LockSystem) (token string, status int, err error) {
-	//token, err = ls.Create(now, LockDetails{
-	//	Root:      root,
-	//	Duration:  infiniteTimeout,
-	//	ZeroDepth: true,
-	//})
-	//if err != nil {
-	//	if err == ErrLocked {
-	//		return "", StatusLocked, err
-	//	}
-	//	return "", http.StatusInternalServerError, err
-	//}
+	token, err = ls.Create(now, LockDetails{
+		Root:      root,
+		Duration:  tempLockTimeout,
+		ZeroDepth: true,
+	})
+	if err != nil {
+		if err == ErrLocked {
+			return "", StatusLocked, err
+		}
+		return "", http.StatusInternalServerError, err
+	}
+
+	return token, 0, nil
+}
 
-	return fmt.Sprintf("%d", time.Now().Unix()), 0, nil
+// lockSystem 取得用户的 LockSystem
+func (h *Handler) lockSystem(uid uint) (LockSystem, bool) {
+	h.Mutex.Lock()
+	defer h.Mutex.Unlock()
+	ls, ok := h.LockSystem[uid]
+	return ls, ok
 }
 
 // ok
 func (h *Handler) confirmLocks(r *http.Request, src, dst string, fs *filesystem.FileSystem) (release func(), status int, err error) {
+	ls, ok := h.lockSystem(fs.User.ID)
+	if !ok {
+		return nil, http.StatusInternalServerError, errNoLockSystem
+	}
 
-	//hdr := r.Header.Get("If")
-	//h.Mutex.Lock()
-	//ls,ok := h.LockSystem[fs.User.ID]
-	//h.Mutex.Unlock()
-	//if !ok{
-	//	return nil, http.StatusInternalServerError, errNoLockSystem
-	//}
-	//
-	//if hdr == "" {
-	//	// An empty If header means that the client hasn't previously created locks.
-	//	// Even if this client doesn't care about locks, we still need to check that
-	//	// the resources aren't locked by another client, so we create temporary
-	//	// locks that would conflict with another client's locks. These temporary
-	//	// locks are unlocked at the end of the HTTP request.
-	//	now, srcToken, dstToken := time.Now(), "", ""
-	//	if src != "" {
-	//		srcToken, status, err = h.lock(now, src, fs,ls)
-	//		if err != nil {
-	//			return nil, status, err
-	//		}
-	//	}
-	//	if dst != "" {
-	//		dstToken, status, err = h.lock(now, dst, fs,ls)
-	//		if err != nil {
-	//			if srcToken != "" {
-	//				ls.Unlock(now, srcToken)
-	//			}
-	//			return nil, status, err
-	//		}
-	//	}
-	//
-	//	return func() {
-	//		if dstToken != "" {
-	//			ls.Unlock(now, dstToken)
-	//		}
-	//		if srcToken != "" {
-	//			ls.Unlock(now, srcToken)
-	//		}
-	//	}, 0, nil
-	//}
-	//
-	//ih, ok := parseIfHeader(hdr)
-	//if !ok {
-	//	return nil, http.StatusBadRequest, errInvalidIfHeader
-	//}
-	//// ih is a disjunction (OR) of ifLists, so any ifList will do.
-	//for _, l := range ih.lists {
-	//	lsrc := l.resourceTag
-	//	if lsrc == "" {
-	//		lsrc = src
-	//	} else {
-	//		u, err := url.Parse(lsrc)
-	//		if err != nil {
-	//			continue
-	//		}
-	//		//if u.Host != r.Host {
-	//		//	continue
-	//		//}
-	//		lsrc, status, err = h.stripPrefix(u.Path, fs.User.ID)
-	//		if err != nil {
-	//			return nil, status, err
-	//		}
-	//	}
-	//	release, err = ls.Confirm(
-	//		time.Now(),
-	//		lsrc,
-	//		dst,
-	//		l.conditions...,
-	//	)
-	//	if err == ErrConfirmationFailed {
-	//		continue
-	//	}
-	//	if err != nil {
-	//		return nil, http.StatusInternalServerError, err
-	//	}
-	//	return release, 0, nil
-	//}
-	//// Section 10.4.1 says that "If this header is evaluated and all state lists
-	//// fail, then the request must fail with a 412 (Precondition Failed) status."
-	//// We follow the spec even though the cond_put_corrupt_token test case from
-	//// the litmus test warns on seeing a 412 instead of a 423 (Locked).
-	//return nil, http.StatusPreconditionFailed, ErrLocked
+	// 检查源与目标文件上的文件锁
+	tokens, ok := ifTokens(r)
+	if !ok {
+		return nil, http.StatusBadRequest, errInvalidIfHeader
+	}
 
-	return func() {
+	for _, p := range []string{src, dst} {
+		if p == "" {
+			continue
+		}
+		if status, err := checkFileLock(fs, p, tokens); err != nil {
+			return nil, status, err
+		}
+	}
+
+	if hdr := r.Header.Get("If"); hdr != "" {
+		ih, _ := parseIfHeader(hdr)
+		// ih is a disjunction (OR) of ifLists, so any ifList will do.
+		for _, l := range ih.lists {
+			lsrc := l.resourceTag
+			if lsrc == "" {
+				lsrc = src
+			} else {
+				u, err := url.Parse(lsrc)
+				if err != nil {
+					continue
+				}
+				lsrc, status, err = h.stripPrefix(u.Path, fs.User.ID)
+				if err != nil {
+					return nil, status, err
+				}
+			}
+			release, err = ls.Confirm(
+				time.Now(),
+				lsrc,
+				dst,
+				l.conditions...,
+			)
+			if err == ErrConfirmationFailed {
+				continue
+			}
+			if err != nil {
+				return nil, http.StatusInternalServerError, err
+			}
+			return release, 0, nil
+		}
+	}
+
+	// The client holds no lock on these resources (its If header may only
+	// carry file lock tokens). We still need to check that the resources
+	// aren't locked by another client, so we create temporary locks that
+	// would conflict with another client's locks. These temporary locks are
+	// unlocked at the end of the HTTP request.
+	now, srcToken, dstToken := time.Now(), "", ""
+	if src != "" {
+		srcToken, status, err = h.lock(now, src, fs, ls)
+		if err != nil {
+			return nil, status, err
+		}
+	}
+	if dst != "" {
+		dstToken, status, err = h.lock(now, dst, fs, ls)
+		if err != nil {
+			if srcToken != "" {
+				ls.Unlock(now, srcToken)
+			}
+			return nil, status, err
+		}
+	}
 
+	return func() {
+		if dstToken != "" {
+			ls.Unlock(now, dstToken)
+		}
+		if srcToken != "" {
+			ls.Unlock(now, srcToken)
+		}
 	}, 0, nil
 }
 
@@ -337,7 +341,7 @@ func (h *Handler) handleDelete(w http.ResponseWriter, r *http.Request, fs *files
 
 	// 尝试作为文件删除
 	if ok, file := fs.IsFileExist(reqPath); ok {
-		if err := fs.Delete(ctx, []uint{}, []uint{file.ID}, false, false); err != nil {
+		if err := fs.Remove(ctx, []uint{}, []uint{file.ID}, false, false); err != nil {
 			return http.StatusMethodNotAllowed, err
 		}
 		return http.StatusNoContent, nil
@@ -345,7 +349,7 @@ func (h *Handler) handleDelete(w http.ResponseWriter, r *http.Request, fs *files
 
 	// 尝试作为目录删除
 	if ok, folder := fs.IsPathExist(reqPath); ok {
-		if err := fs.Delete(ctx, []uint{folder.ID}, []uint{}, false, false); err != nil {
+		if err := fs.Remove(ctx, []uint{folder.ID}, []uint{}, false, false); err != nil {
 			return http.StatusMethodNotAllowed, err
 		}
 		return http.StatusNoContent, nil
@@ -386,9 +390,18 @@ func (h *Handler) handlePut(w http.ResponseWriter, r *http.Request, fs *filesyst
 		VirtualPath: filePath,
 	}
 
+	// 校验客户端提供的校验和，需在写入文件记录前执行
+	if checksums := filesystem.ParseChecksums(r.Header.Get("OC-Checksum")); len(checksums) > 0 {
+		fs.Use("AfterUpload", filesystem.HookVerifyChecksum(checksums))
+	}
+
 	// 判断文件是否已存在
 	exist, originFile := fs.IsFileExist(reqPath)
-	if exist {
+	if exist && fs.VersioningEnabled() && fs.UseVersionedUpdate(ctx, originFile, &fileData) {
+		// 已存在，启用历史版本时保留原始内容
+		fs.Use("AfterUploadCanceled", filesystem.HookCancelContext)
+		ctx = context.WithValue(ctx, fsctx.FileModelCtx, *originFile)
+	} else if exist {
 		// 已存在，为更新操作
 
 		// 检查此文件是否有软链接
@@ -405,6 +418,7 @@ func (h *Handler) handlePut(w http.ResponseWriter, r *http.Request, fs *filesyst
 		fs.Use("BeforeUpload", filesystem.HookResetPolicy)
 		fs.Use("BeforeUpload", filesystem.HookValidateFile)
 		fs.Use("BeforeUpload", filesystem.HookValidateCapacityDiff)
+		fs.Use("BeforeUpload", filesystem.HookValidateFolderQuotaDiff)
 		fs.Use("AfterUploadCanceled", filesystem.HookCleanFileContent)
 		fs.Use("AfterUploadCanceled", filesystem.HookClearFileSize)
 		fs.Use("AfterUploadCanceled", filesystem.HookCancelContext)
@@ -417,6 +431,7 @@ func (h *Handler) handlePut(w http.ResponseWriter, r *http.Request, fs *filesyst
 		// 给文件系统分配钩子
 		fs.Use("BeforeUpload", filesystem.HookValidateFile)
 		fs.Use("BeforeUpload", filesystem.HookValidateCapacity)
+		fs.Use("BeforeUpload", filesystem.HookValidateFolderQuota)
 		fs.Use("AfterUploadCanceled", filesystem.HookDeleteTempFile)
 		fs.Use("AfterUploadCanceled", filesystem.HookCancelContext)
 		fs.Use("AfterUpload", filesystem.GenericAfterUpload)
@@ -425,6 +440,7 @@ func (h *Handler) handlePut(w http.ResponseWriter, r *http.Request, fs *filesyst
 
 	// rclone 请求
 	fs.Use("AfterUpload", filesystem.NewWebdavAfterUploadHook(r))
+	fs.Use("AfterUpload", filesystem.HookReplicateFile)
 
 	// 执行上传
 	err = fs.Upload(ctx, &fileData)
@@ -437,6 +453,10 @@ func (h *Handler) handlePut(w http.ResponseWriter, r *http.Request, fs *filesyst
 		return http.StatusInternalServerError, err
 	}
 	w.Header().Set("ETag", etag)
+	if _, err := strconv.ParseInt(r.Header.Get("X-OC-Mtime"), 10, 64); err == nil {
+		// 告知客户端已采用其提供的修改时间
+		w.Header().Set("X-OC-MTime", "accepted")
+	}
 	return http.StatusCreated, nil
 }
 
@@ -578,126 +598,138 @@ func (h *Handler) handleLock(w http.ResponseWriter, r *http.Request, fs *filesys
 	if err != nil {
 		return http.StatusBadRequest, err
 	}
+	li, status, err := readLockInfo(r.Body)
+	if err != nil {
+		return status, err
+	}
 
 	reqPath, status, err := h.stripPrefix(r.URL.Path, fs.User.ID)
 	if err != nil {
 		return status, err
 	}
 
-	////ctx := r.Context()
-	//token, ld, now, created := "", LockDetails{}, time.Now(), false
-	//if li == (lockInfo{}) {
-	//	// An empty lockInfo means to refresh the lock.
-	//	ih, ok := parseIfHeader(r.Header.Get("If"))
-	//	if !ok {
-	//		return http.StatusBadRequest, errInvalidIfHeader
-	//	}
-	//	if len(ih.lists) == 1 && len(ih.lists[0].conditions) == 1 {
-	//		token = ih.lists[0].conditions[0].Token
-	//	}
-	//	if token == "" {
-	//		return http.StatusBadRequest, errInvalidLockToken
-	//	}
-	//	ld, err = ls.Refresh(now, token, duration)
-	//	if err != nil {
-	//		if err == ErrNoSuchLock {
-	//			return http.StatusPreconditionFailed, err
-	//		}
-	//		return http.StatusInternalServerError, err
-	//	}
-	//
-	//} else {
-	//	// Section 9.10.3 says that "If no Depth header is submitted on a LOCK request,
-	//	// then the request MUST act as if a "Depth:infinity" had been submitted."
-	//	depth := infiniteDepth
-	//	if hdr := r.Header.Get("Depth"); hdr != "" {
-	//		depth = parseDepth(hdr)
-	//		if depth != 0 && depth != infiniteDepth {
-	//			// Section 9.10.3 says that "Values other than 0 or infinity must not be
-	//			// used with the Depth header on a LOCK method".
-	//			return http.StatusBadRequest, errInvalidDepth
-	//		}
-	//	}
-	//	reqPath, status, err := h.stripPrefix(r.URL.Path, fs.User.ID)
-	//	if err != nil {
-	//		return status, err
-	//	}
-	//	ld = LockDetails{
-	//		Root:      reqPath,
-	//		Duration:  duration,
-	//		OwnerXML:  li.Owner.InnerXML,
-	//		ZeroDepth: depth == 0,
-	//	}
-	//	token, err = ls.Create(now, ld)
-	//	if err != nil {
-	//		if err == ErrLocked {
-	//			return StatusLocked, err
-	//		}
-	//		return http.StatusInternalServerError, err
-	//	}
-	//	defer func() {
-	//		if retErr != nil {
-	//			ls.Unlock(now, token)
-	//		}
-	//	}()
-	//
-	//	// Create the resource if it didn't previously exist.
-	//	//if _, err := h.FileSystem.Stat(ctx, reqPath); err != nil {
-	//	//	f, err := h.FileSystem.OpenFile(ctx, reqPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
-	//	//	if err != nil {
-	//	//		// TODO: detect missing intermediate dirs and return http.StatusConflict?
-	//	//		return http.StatusInternalServerError, err
-	//	//	}
-	//	//	f.Close()
-	//	//	created = true
-	//	//}
-	//
-	//	// http://www.webdav.org/specs/rfc4918.html#HEADER_Lock-Token says that the
-	//	// Lock-Token value is a Coded-URL. We add angle brackets.
-	//	w.Header().Set("Lock-Token", "<"+token+">")
-	//}
-	//
-	//w.Header().Set("Content-Type", "application/xml; charset=utf-8")
-	//if created {
-	//	// This is "w.WriteHeader(http.StatusCreated)" and not "return
-	//	// http.StatusCreated, nil" because we write our own (XML) response to w
-	//	// and Handler.ServeHTTP would otherwise write "Created".
-	//	w.WriteHeader(http.StatusCreated)
-	//}
+	token, ld, now := "", LockDetails{}, time.Now()
+	if li == (lockInfo{}) {
+		// An empty lockInfo means to refresh the lock.
+		ih, ok := parseIfHeader(r.Header.Get("If"))
+		if !ok {
+			return http.StatusBadRequest, errInvalidIfHeader
+		}
+		if len(ih.lists) == 1 && len(ih.lists[0].conditions) == 1 {
+			token = ih.lists[0].conditions[0].Token
+		}
+		if token == "" {
+			return http.StatusBadRequest, errInvalidLockToken
+		}
+		ld, err = ls.Refresh(now, token, duration)
+		if err == ErrNoSuchLock {
+			// 刷新文件锁
+			if file := fileAt(fs, reqPath); file != nil {
+				ld = LockDetails{Root: reqPath, OwnerXML: fs.User.Email, ZeroDepth: true}
+				ld.Duration, status, err = lockFile(fs, file, token, "", duration, true)
+				if err != nil {
+					return status, err
+				}
+			}
+		}
+		if err != nil {
+			if err == ErrNoSuchLock {
+				return http.StatusPreconditionFailed, err
+			}
+			if err == ErrLocked {
+				return StatusLocked, err
+			}
+			return http.StatusInternalServerError, err
+		}
 
-	writeLockInfo(w, fmt.Sprintf("%d", time.Now().UnixNano()), LockDetails{
-		Duration: duration,
-		OwnerXML: fs.User.Email,
-		Root:     reqPath,
-	})
+	} else {
+		// Section 9.10.3 says that "If no Depth header is submitted on a LOCK request,
+		// then the request MUST act as if a "Depth:infinity" had been submitted."
+		depth := infiniteDepth
+		if hdr := r.Header.Get("Depth"); hdr != "" {
+			depth = parseDepth(hdr)
+			if depth != 0 && depth != infiniteDepth {
+				// Section 9.10.3 says that "Values other than 0 or infinity must not be
+				// used with the Depth header on a LOCK method".
+				return http.StatusBadRequest, errInvalidDepth
+			}
+		}
+		ld = LockDetails{
+			Root:      reqPath,
+			Duration:  duration,
+			OwnerXML:  li.Owner.InnerXML,
+			ZeroDepth: depth == 0,
+		}
+
+		if file := fileAt(fs, reqPath); file != nil {
+			// 文件使用共享的文件锁，加锁前确认上级目录未被其他客户端锁定
+			tempToken, status, err := h.lock(now, reqPath, fs, ls)
+			if err != nil {
+				return status, err
+			}
+			ls.Unlock(now, tempToken)
+
+			token = newLockToken()
+			ld.Duration, status, err = lockFile(fs, file, token, ld.OwnerXML, duration, false)
+			if err != nil {
+				return status, err
+			}
+		} else {
+			token, err = ls.Create(now, ld)
+			if err != nil {
+				if err == ErrLocked {
+					return StatusLocked, err
+				}
+				return http.StatusInternalServerError, err
+			}
+		}
+
+		// http://www.webdav.org/specs/rfc4918.html#HEADER_Lock-Token says that the
+		// Lock-Token value is a Coded-URL. We add angle brackets.
+		w.Header().Set("Lock-Token", "<"+token+">")
+	}
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	writeLockInfo(w, token, ld)
 	return 0, nil
 }
 
 // OK
 func (h *Handler) handleUnlock(w http.ResponseWriter, r *http.Request, fs *filesystem.FileSystem, ls LockSystem) (status int, err error) {
 	defer fs.Recycle()
-	return http.StatusNoContent, err
 
-	//// http://www.webdav.org/specs/rfc4918.html#HEADER_Lock-Token says that the
-	//// Lock-Token value is a Coded-URL. We strip its angle brackets.
-	//t := r.Header.Get("Lock-Token")
-	//if len(t) < 2 || t[0] != '<' || t[len(t)-1] != '>' {
-	//	return http.StatusBadRequest, errInvalidLockToken
-	//}
-	//t = t[1 : len(t)-1]
-	//
-	//switch err = ls.Unlock(time.Now(), t); err {
-	//case nil:
-	//	return http.StatusNoContent, err
-	//case ErrForbidden:
-	//	return http.StatusForbidden, err
-	//case ErrLocked:
-	//	return StatusLocked, err
-	//case ErrNoSuchLock:
-	//	return http.StatusConflict, err
-	//default:
-	//	return http.StatusInternalServerError, err
-	//}
+	// http://www.webdav.org/specs/rfc4918.html#HEADER_Lock-Token says that the
+	// Lock-Token value is a Coded-URL. We strip its angle brackets.
+	t := r.Header.Get("Lock-Token")
+	if len(t) < 2 || t[0] != '<' || t[len(t)-1] != '>' {
+		return http.StatusBadRequest, errInvalidLockToken
+	}
+	t = t[1 : len(t)-1]
+
+	err = ls.Unlock(time.Now(), t)
+	if err == ErrNoSuchLock {
+		// 解除文件锁
+		reqPath, status, err := h.stripPrefix(r.URL.Path, fs.User.ID)
+		if err != nil {
+			return status, err
+		}
+		if file := fileAt(fs, reqPath); file != nil {
+			return unlockFile(file, t)
+		}
+	}
+
+	switch err {
+	case nil:
+		return http.StatusNoContent, err
+	case ErrForbidden:
+		return http.StatusForbidden, err
+	case ErrLocked:
+		return StatusLocked, err
+	case ErrNoSuchLock:
+		return http.StatusConflict, err
+	default:
+		return http.StatusInternalServerError, err
+	}
 }
 
 // OK
@@ -52,12 +52,19 @@ const (
 	OverwriteHeader     = wopiHeaderPrefix + "Override"
 	ServerErrorHeader   = wopiHeaderPrefix + "ServerError"
 	RenameRequestHeader = wopiHeaderPrefix + "RequestedName"
+	LockHeader          = wopiHeaderPrefix + "Lock"
+	OldLockHeader       = wopiHeaderPrefix + "OldLock"
+	LockFailureHeader   = wopiHeaderPrefix + "LockFailureReason"
 
 	MethodLock        = "LOCK"
 	MethodUnlock      = "UNLOCK"
 	MethodRefreshLock = "REFRESH_LOCK"
+	MethodGetLock     = "GET_LOCK"
 	MethodRename      = "RENAME_FILE"
 
+	// LockTTL WOPI 文件锁的有效期
+	LockTTL = 30 * time.Minute
+
 	wopiSrcPlaceholder    = "WOPI_SOURCE"
 	wopiSrcParamDefault   = "WOPISrc"
 	languageParamDefault  = "lang"
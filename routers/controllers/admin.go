@@ -13,6 +13,7 @@ import (
 	"github.com/Jaylenwa/Vfoy/pkg/serializer"
 	"github.com/Jaylenwa/Vfoy/pkg/wopi"
 	"github.com/Jaylenwa/Vfoy/service/admin"
+	"github.com/Jaylenwa/Vfoy/service/explorer"
 	"github.com/gin-gonic/gin"
 )
 
@@ -445,6 +446,83 @@ func AdminCreateImportTask(c *gin.Context) {
 	}
 }
 
+// AdminCreateMigrateTask 新建存储策略迁移任务
+func AdminCreateMigrateTask(c *gin.Context) {
+	var service admin.MigrateTaskService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Create(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// AdminCreateReplicateTask 新建副本补全任务
+func AdminCreateReplicateTask(c *gin.Context) {
+	var service admin.ReplicateTaskService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Create(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// AdminListLifecycleRule 列出生命周期规则
+func AdminListLifecycleRule(c *gin.Context) {
+	var service admin.AdminListService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.LifecycleRules()
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// AdminAddLifecycleRule 新建或保存生命周期规则
+func AdminAddLifecycleRule(c *gin.Context) {
+	var service admin.AddLifecycleRuleService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Add()
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// AdminGetLifecycleRule 获取生命周期规则详情
+func AdminGetLifecycleRule(c *gin.Context) {
+	var service admin.LifecycleRuleService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Get()
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// AdminDeleteLifecycleRule 删除生命周期规则
+func AdminDeleteLifecycleRule(c *gin.Context) {
+	var service admin.LifecycleRuleService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Delete()
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// AdminDryRunLifecycleRule 试运行生命周期规则
+func AdminDryRunLifecycleRule(c *gin.Context) {
+	var service admin.LifecycleRuleService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.DryRun()
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
 // AdminListFolders 列出用户或外部文件系统目录
 func AdminListFolders(c *gin.Context) {
 	var service admin.ListFolderService
@@ -456,6 +534,17 @@ func AdminListFolders(c *gin.Context) {
 	}
 }
 
+// AdminSetFolderQuota 设置任意用户目录的配额
+func AdminSetFolderQuota(c *gin.Context) {
+	var service explorer.FolderQuotaService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := admin.SetFolderQuota(c, &service)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
 // AdminListNodes 列出从机节点
 func AdminListNodes(c *gin.Context) {
 	var service admin.AdminListService
@@ -26,3 +26,25 @@ func ListDirectory(c *gin.Context) {
 		c.JSON(200, ErrorResponse(err))
 	}
 }
+
+// ListDelta 列出游标之后的文件系统变更
+func ListDelta(c *gin.Context) {
+	var service explorer.DeltaService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.List(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// SetFolderQuota 设置目录配额
+func SetFolderQuota(c *gin.Context) {
+	var service explorer.FolderQuotaService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Set(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
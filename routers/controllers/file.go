@@ -63,6 +63,39 @@ func Decompress(c *gin.Context) {
 	}
 }
 
+// ListArchiveEntries 列出压缩文件中的条目
+func ListArchiveEntries(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.ArchiveEntryService
+	if err := c.ShouldBindQuery(&service); err == nil {
+		res := service.List(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// GetArchiveEntry 预览或下载压缩文件中的单个条目
+func GetArchiveEntry(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.ArchiveEntryService
+	if err := c.ShouldBindQuery(&service); err == nil {
+		res := service.Stream(ctx, c)
+		// 是否有错误发生
+		if res.Code != 0 {
+			c.JSON(200, res)
+		}
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
 // AnonymousGetContent 匿名获取文件资源
 func AnonymousGetContent(c *gin.Context) {
 	// 创建上下文
@@ -368,6 +401,21 @@ func DeleteUploadSession(c *gin.Context) {
 	}
 }
 
+// GetUploadSessionStatus 查询上传会话已接收的分片
+func GetUploadSessionStatus(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.UploadSessionService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Status(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
 // DeleteAllUploadSession 删除全部上传会话
 func DeleteAllUploadSession(c *gin.Context) {
 	// 创建上下文
@@ -410,6 +458,13 @@ func SearchFile(c *gin.Context) {
 	c.JSON(200, res)
 }
 
+// RebuildSearchIndex 重建全文索引
+func RebuildSearchIndex(c *gin.Context) {
+	var service explorer.SearchIndexService
+	res := service.Rebuild(c, CurrentUser(c))
+	c.JSON(200, res)
+}
+
 // CreateFile 创建空白文件
 func CreateFile(c *gin.Context) {
 	var service explorer.SingleFileService
@@ -420,3 +475,48 @@ func CreateFile(c *gin.Context) {
 		c.JSON(200, ErrorResponse(err))
 	}
 }
+
+// ListFileVersions 列出文件的历史版本
+func ListFileVersions(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.FileIDService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.ListVersions(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// CreateVersionDownloadSession 创建文件历史版本下载会话
+func CreateVersionDownloadSession(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.FileVersionService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.CreateDownloadSession(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// RestoreFileVersion 将文件恢复为历史版本
+func RestoreFileVersion(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.FileVersionService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Restore(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
@@ -82,3 +82,121 @@ func GetProperty(c *gin.Context) {
 		c.JSON(200, ErrorResponse(err))
 	}
 }
+
+// UpdateMetadata 设置和删除文件或目录的用户自定义元数据
+func UpdateMetadata(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.ItemMetadataService
+	service.ID = c.Param("id")
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Update(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// ListStarred 列出收藏的文件和目录
+func ListStarred(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	res := explorer.ListStarred(ctx, c)
+	c.JSON(200, res)
+}
+
+// Star 收藏文件或目录
+func Star(c *gin.Context) {
+	setStarred(c, true)
+}
+
+// Unstar 取消收藏文件或目录
+func Unstar(c *gin.Context) {
+	setStarred(c, false)
+}
+
+func setStarred(c *gin.Context, starred bool) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.ItemIDService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Star(ctx, c, starred)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// ListRecent 列出最近访问或修改的文件
+func ListRecent(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.RecentListService
+	if err := c.ShouldBindQuery(&service); err == nil {
+		res := service.List(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// ListLocks 列出当前用户持有的文件锁
+func ListLocks(c *gin.Context) {
+	res := explorer.ListLocks(c, CurrentUser(c))
+	c.JSON(200, res)
+}
+
+// BreakLock 强制解除当前用户持有的文件锁
+func BreakLock(c *gin.Context) {
+	res := explorer.BreakLock(c, CurrentUser(c))
+	c.JSON(200, res)
+}
+
+// ListTrash 列出回收站内容
+func ListTrash(c *gin.Context) {
+	var service explorer.TrashListService
+	if err := c.ShouldBindQuery(&service); err == nil {
+		res := service.List(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// RestoreTrash 恢复回收站中的对象
+func RestoreTrash(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.TrashRestoreService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Restore(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// PurgeTrash 彻底删除回收站中的对象
+func PurgeTrash(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.TrashPurgeService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Purge(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Jaylenwa/Vfoy/pkg/serializer"
+	"github.com/Jaylenwa/Vfoy/pkg/tus"
+	"github.com/Jaylenwa/Vfoy/service/explorer"
+	"github.com/gin-gonic/gin"
+)
+
+// tusStatus 将业务错误码转换为 tus 协议的 HTTP 状态码
+func tusStatus(code int) int {
+	switch code {
+	case serializer.CodeParamErr, serializer.CodeInvalidContentLength, serializer.CodeFileTypeNotAllowed, serializer.CodeIllegalObjectName:
+		return http.StatusBadRequest
+	case serializer.CodePolicyNotAllowed, serializer.CodeNoPermissionErr:
+		return http.StatusForbidden
+	case serializer.CodeUploadSessionExpired, serializer.CodeNotFound, serializer.CodeParentNotExist:
+		return http.StatusNotFound
+	case serializer.CodeConflict, serializer.CodeObjectExist, serializer.CodeConflictUploadOngoing:
+		return http.StatusConflict
+	case serializer.CodeFileTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case serializer.CodeInsufficientCapacity, serializer.CodeFolderQuotaExceeded:
+		return http.StatusInsufficientStorage
+	case serializer.CodeMetaMismatch:
+		return tus.StatusChecksumMismatch
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// tusResponse 根据服务结果输出 tus 响应
+func tusResponse(c *gin.Context, res serializer.Response, status int) {
+	if res.Code != 0 {
+		c.String(tusStatus(res.Code), res.Msg)
+		return
+	}
+
+	c.Status(status)
+}
+
+// TusOptions 返回服务端支持的 tus 协议能力
+func TusOptions(c *gin.Context) {
+	c.Header(tus.HeaderVersion, tus.Version)
+	c.Header(tus.HeaderExtension, tus.Extensions)
+	c.Header(tus.HeaderChecksumAlgorithm, tus.ChecksumAlgorithms)
+	c.Status(http.StatusNoContent)
+}
+
+// TusCreate 创建 tus 上传
+func TusCreate(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.TusService
+	tusResponse(c, service.Create(ctx, c), http.StatusCreated)
+}
+
+// TusHead 查询 tus 上传的偏移量
+func TusHead(c *gin.Context) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.TusService
+	if err := c.ShouldBindUri(&service); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tusResponse(c, service.Head(ctx, c), http.StatusOK)
+}
+
+// TusPatch 上传 tus 文件数据
+func TusPatch(c *gin.Context) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if c.ContentType() != tus.ContentType {
+		c.String(http.StatusUnsupportedMediaType, "Content-Type must be "+tus.ContentType)
+		return
+	}
+
+	var service explorer.TusService
+	if err := c.ShouldBindUri(&service); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tusResponse(c, service.Patch(ctx, c), http.StatusNoContent)
+}
+
+// TusTerminate 终止 tus 上传
+func TusTerminate(c *gin.Context) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var service explorer.TusService
+	if err := c.ShouldBindUri(&service); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tusResponse(c, service.Terminate(ctx, c), http.StatusNoContent)
+}
@@ -396,3 +396,13 @@ func UserPerformCopySession(c *gin.Context) {
 		c.JSON(200, ErrorResponse(err))
 	}
 }
+
+// UserEvents 推送用户事件
+func UserEvents(c *gin.Context) {
+	var service user.EventService
+	if err := c.ShouldBindQuery(&service); err == nil {
+		service.Stream(c, CurrentUser(c))
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
@@ -93,6 +93,22 @@ func UpdateWebDAVAccounts(c *gin.Context) {
 	}
 }
 
+// RestrictWebDAVAccount 设定WebDAV账户的访问控制
+func RestrictWebDAVAccount(c *gin.Context) {
+	var service setting.WebDAVAccountRestrictService
+	if err := c.ShouldBindUri(&service); err != nil {
+		c.JSON(200, ErrorResponse(err))
+		return
+	}
+
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Restrict(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
 // CreateWebDAVAccounts 创建WebDAV账户
 func CreateWebDAVAccounts(c *gin.Context) {
 	var service setting.WebDAVAccountCreateService
@@ -2,8 +2,10 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"net/http"
 
+	"github.com/Jaylenwa/Vfoy/pkg/filesystem/lock"
 	"github.com/Jaylenwa/Vfoy/pkg/serializer"
 	"github.com/Jaylenwa/Vfoy/pkg/wopi"
 	"github.com/Jaylenwa/Vfoy/service/explorer"
@@ -48,6 +50,11 @@ func PutFile(c *gin.Context) {
 	case serializer.CodeNotFound:
 		c.Status(http.StatusNotFound)
 		c.Header(wopi.ServerErrorHeader, res.Error)
+	case serializer.CodeFileLocked:
+		c.Status(http.StatusConflict)
+		if current, ok := lock.Get(c.GetUint("object_id")); ok {
+			c.Header(wopi.LockHeader, current.Token)
+		}
 	case 0:
 		c.Status(http.StatusOK)
 	default:
@@ -60,8 +67,23 @@ func PutFile(c *gin.Context) {
 func ModifyFile(c *gin.Context) {
 	action := c.GetHeader(wopi.OverwriteHeader)
 	switch action {
-	case wopi.MethodLock, wopi.MethodRefreshLock, wopi.MethodUnlock:
-		c.Status(http.StatusOK)
+	case wopi.MethodLock, wopi.MethodRefreshLock, wopi.MethodUnlock, wopi.MethodGetLock:
+		var service explorer.WopiService
+		current, err := service.Lock(c, action)
+		switch {
+		case errors.Is(err, lock.ErrLocked), errors.Is(err, lock.ErrNoSuchLock):
+			c.Header(wopi.LockHeader, current)
+			c.Header(wopi.LockFailureHeader, err.Error())
+			c.Status(http.StatusConflict)
+		case err != nil:
+			c.Status(http.StatusInternalServerError)
+			c.Header(wopi.ServerErrorHeader, err.Error())
+		default:
+			if action == wopi.MethodGetLock {
+				c.Header(wopi.LockHeader, current)
+			}
+			c.Status(http.StatusOK)
+		}
 		return
 	case wopi.MethodRename:
 		var service explorer.WopiService
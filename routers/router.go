@@ -412,6 +412,22 @@ func InitMasterRouter() *gin.Engine {
 			wopi.POST("files/:id", middleware.WopiWriteAccess(), controllers.ModifyFile)
 		}
 
+		// tus 断点续传协议
+		tus := v3.Group("tus", middleware.TusResumable(), middleware.TusAuth())
+		{
+			// 查询服务端能力
+			tus.OPTIONS("", controllers.TusOptions)
+			tus.OPTIONS(":sessionId", controllers.TusOptions)
+			// 创建上传
+			tus.POST("", controllers.TusCreate)
+			// 查询上传偏移量
+			tus.HEAD(":sessionId", controllers.TusHead)
+			// 上传数据
+			tus.PATCH(":sessionId", controllers.TusPatch)
+			// 终止上传
+			tus.DELETE(":sessionId", controllers.TusTerminate)
+		}
+
 		// 需要登录保护的
 		auth := v3.Group("")
 		auth.Use(middleware.AuthRequired())
@@ -515,6 +531,8 @@ func InitMasterRouter() *gin.Engine {
 					// 列出用户或外部文件系统目录
 					file.GET("folders/:type/:id/*path",
 						controllers.AdminListFolders)
+					// 设置目录配额
+					file.PUT("folder/quota", controllers.AdminSetFolderQuota)
 				}
 
 				share := admin.Group("share")
@@ -541,6 +559,24 @@ func InitMasterRouter() *gin.Engine {
 					task.POST("delete", controllers.AdminDeleteTask)
 					// 新建文件导入任务
 					task.POST("import", controllers.AdminCreateImportTask)
+					// 新建存储策略迁移任务
+					task.POST("migrate", controllers.AdminCreateMigrateTask)
+					// 新建副本补全任务
+					task.POST("replicate", controllers.AdminCreateReplicateTask)
+				}
+
+				lifecycle := admin.Group("lifecycle")
+				{
+					// 列出生命周期规则
+					lifecycle.POST("list", controllers.AdminListLifecycleRule)
+					// 获取生命周期规则
+					lifecycle.GET(":id", controllers.AdminGetLifecycleRule)
+					// 试运行生命周期规则
+					lifecycle.GET(":id/dryrun", controllers.AdminDryRunLifecycleRule)
+					// 创建/保存生命周期规则
+					lifecycle.POST("", controllers.AdminAddLifecycleRule)
+					// 删除
+					lifecycle.DELETE(":id", controllers.AdminDeleteLifecycleRule)
 				}
 
 				node := admin.Group("node")
@@ -568,6 +604,8 @@ func InitMasterRouter() *gin.Engine {
 				user.GET("me", controllers.UserMe)
 				// 存储信息
 				user.GET("storage", controllers.UserStorage)
+				// 事件推送
+				user.GET("events", controllers.UserEvents)
 				// 退出登录
 				user.DELETE("session", controllers.UserSignOut)
 				// Generate temp URL for copying client-side session, used in adding accounts
@@ -610,6 +648,8 @@ func InitMasterRouter() *gin.Engine {
 					upload.POST(":sessionId/:index", controllers.FileUpload)
 					// 创建上传会话
 					upload.PUT("", controllers.GetUploadSession)
+					// 查询上传会话已接收的分片
+					upload.GET(":sessionId", controllers.GetUploadSessionStatus)
 					// 删除给定上传会话
 					upload.DELETE(":sessionId", controllers.DeleteUploadSession)
 					// 删除全部上传会话
@@ -617,6 +657,12 @@ func InitMasterRouter() *gin.Engine {
 				}
 				// 更新文件
 				file.PUT("update/:id", controllers.PutContent)
+				// 列出文件历史版本
+				file.GET("version/:id", controllers.ListFileVersions)
+				// 创建文件历史版本下载会话
+				file.PUT("version/:id/:version", controllers.CreateVersionDownloadSession)
+				// 恢复文件历史版本
+				file.POST("version/:id/:version", controllers.RestoreFileVersion)
 				// 创建空白文件
 				file.POST("create", controllers.CreateFile)
 				// 创建文件下载会话
@@ -633,12 +679,18 @@ func InitMasterRouter() *gin.Engine {
 				file.POST("source", controllers.GetSource)
 				// 打包要下载的文件
 				file.POST("archive", controllers.Archive)
+				// 列出压缩文件中的条目
+				file.GET("entries/:id", controllers.ListArchiveEntries)
+				// 预览或下载压缩文件中的单个条目
+				file.GET("entries/:id/content", middleware.Sandbox(), controllers.GetArchiveEntry)
 				// 创建文件压缩任务
 				file.POST("compress", controllers.Compress)
 				// 创建文件解压缩任务
 				file.POST("decompress", controllers.Decompress)
 				// 创建文件解压缩任务
-				file.GET("search/:type/:keywords", controllers.SearchFile)
+				file.GET("search/:type/*keywords", controllers.SearchFile)
+				// 重建全文索引
+				file.POST("search/index", controllers.RebuildSearchIndex)
 			}
 
 			// 离线下载任务
@@ -663,6 +715,10 @@ func InitMasterRouter() *gin.Engine {
 			{
 				// 创建目录
 				directory.PUT("", controllers.CreateDirectory)
+				// 设置目录配额
+				directory.PUT("quota", controllers.SetFolderQuota)
+				// 列出变更增量，GET 会与列目录的通配路由冲突
+				directory.POST("delta", controllers.ListDelta)
 				// 列出目录下内容
 				directory.GET("*path", controllers.ListDirectory)
 			}
@@ -680,6 +736,26 @@ func InitMasterRouter() *gin.Engine {
 				object.POST("rename", controllers.Rename)
 				// 获取对象属性
 				object.GET("property/:id", controllers.GetProperty)
+				// 设置和删除对象的自定义元数据
+				object.PATCH("metadata/:id", controllers.UpdateMetadata)
+				// 列出收藏的对象
+				object.GET("starred", controllers.ListStarred)
+				// 收藏对象
+				object.PUT("starred", controllers.Star)
+				// 取消收藏对象
+				object.DELETE("starred", controllers.Unstar)
+				// 列出最近的文件
+				object.GET("recent", controllers.ListRecent)
+				// 列出持有的文件锁
+				object.GET("locks", controllers.ListLocks)
+				// 强制解除文件锁
+				object.DELETE("locks/:id", middleware.HashID(hashid.FileID), controllers.BreakLock)
+				// 列出回收站内容
+				object.GET("trash", controllers.ListTrash)
+				// 恢复回收站中的对象
+				object.POST("trash/restore", controllers.RestoreTrash)
+				// 彻底删除回收站中的对象
+				object.DELETE("trash", controllers.PurgeTrash)
 			}
 
 			// 分享
@@ -723,6 +799,8 @@ func InitMasterRouter() *gin.Engine {
 				webdav.DELETE("accounts/:id", controllers.DeleteWebDAVAccounts)
 				// 更新账号可读性和是否使用代理服务
 				webdav.PATCH("accounts", controllers.UpdateWebDAVAccounts)
+				// 设定账号访问控制
+				webdav.PUT("accounts/:id/restrictions", controllers.RestrictWebDAVAccount)
 			}
 
 		}
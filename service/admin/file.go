@@ -7,6 +7,7 @@ import (
 	model "github.com/Jaylenwa/Vfoy/models"
 	"github.com/Jaylenwa/Vfoy/pkg/filesystem"
 	"github.com/Jaylenwa/Vfoy/pkg/filesystem/fsctx"
+	"github.com/Jaylenwa/Vfoy/pkg/hashid"
 	"github.com/Jaylenwa/Vfoy/pkg/serializer"
 	"github.com/Jaylenwa/Vfoy/service/explorer"
 	"github.com/gin-gonic/gin"
@@ -206,3 +207,29 @@ func (service *AdminListService) Files() serializer.Response {
 		"users": users,
 	}}
 }
+
+// SetFolderQuota 设置任意用户目录的配额
+func SetFolderQuota(c *gin.Context, service *explorer.FolderQuotaService) serializer.Response {
+	id, err := hashid.DecodeHashID(service.ID, hashid.FolderID)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotFound, "", err)
+	}
+
+	folder, err := model.GetFolderByID(id)
+	if err != nil {
+		return serializer.Err(serializer.CodeParentNotExist, "", err)
+	}
+
+	user, err := model.GetUserByID(folder.OwnerID)
+	if err != nil {
+		return serializer.Err(serializer.CodeUserNotFound, "", err)
+	}
+
+	fs, err := filesystem.NewFileSystem(&user)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	return service.Apply(c, fs, folder)
+}
@@ -0,0 +1,167 @@
+package admin
+
+import (
+	"time"
+
+	model "github.com/Jaylenwa/Vfoy/models"
+	"github.com/Jaylenwa/Vfoy/pkg/serializer"
+)
+
+// lifecycleDryRunSamples 生命周期规则试运行报告中列出的最多文件数
+const lifecycleDryRunSamples = 100
+
+// lifecycleDryRunBatchSize 试运行时每批查询的文件数
+const lifecycleDryRunBatchSize = 1000
+
+// AddLifecycleRuleService 生命周期规则添加服务
+type AddLifecycleRuleService struct {
+	Rule model.LifecycleRule `json:"rule" binding:"required"`
+}
+
+// LifecycleRuleService 生命周期规则ID服务
+type LifecycleRuleService struct {
+	ID uint `uri:"id" json:"id" binding:"required"`
+}
+
+// Add 添加或保存生命周期规则
+func (service *AddLifecycleRuleService) Add() serializer.Response {
+	rule := &service.Rule
+	switch rule.Action {
+	case model.LifecycleActionTransition:
+		if rule.DstPolicyID == rule.PolicyID {
+			return serializer.ParamErr("Destination policy must differ from the source policy", nil)
+		}
+		if _, err := model.GetPolicyByID(rule.DstPolicyID); err != nil {
+			return serializer.Err(serializer.CodePolicyNotExist, "", err)
+		}
+	case model.LifecycleActionDelete:
+		rule.DstPolicyID = 0
+	default:
+		return serializer.ParamErr("Unknown lifecycle action", nil)
+	}
+
+	// 避免规则匹配全部文件
+	if rule.MinAge <= 0 && rule.MinIdle <= 0 {
+		return serializer.ParamErr("Either min age or min idle days must be set", nil)
+	}
+
+	if err := rule.Save(); err != nil {
+		return serializer.DBErr("Failed to save lifecycle rule", err)
+	}
+
+	return serializer.Response{Data: rule.ID}
+}
+
+// Get 获取生命周期规则详情
+func (service *LifecycleRuleService) Get() serializer.Response {
+	rule, err := model.GetLifecycleRuleByID(service.ID)
+	if err != nil {
+		return serializer.Err(serializer.CodeLifecycleRuleNotFound, "", err)
+	}
+
+	return serializer.Response{Data: rule}
+}
+
+// Delete 删除生命周期规则
+func (service *LifecycleRuleService) Delete() serializer.Response {
+	rule, err := model.GetLifecycleRuleByID(service.ID)
+	if err != nil {
+		return serializer.Err(serializer.CodeLifecycleRuleNotFound, "", err)
+	}
+
+	if err := rule.Delete(); err != nil {
+		return serializer.DBErr("Failed to delete lifecycle rule", err)
+	}
+
+	return serializer.Response{}
+}
+
+// DryRun 列出规则当前匹配的文件，不执行任何操作
+func (service *LifecycleRuleService) DryRun() serializer.Response {
+	rule, err := model.GetLifecycleRuleByID(service.ID)
+	if err != nil {
+		return serializer.Err(serializer.CodeLifecycleRuleNotFound, "", err)
+	}
+
+	folderIDs, err := rule.FolderIDs()
+	if err != nil {
+		return serializer.DBErr("Failed to list folders", err)
+	}
+
+	var (
+		total   int
+		size    uint64
+		after   uint
+		samples = make([]model.File, 0)
+		now     = time.Now()
+	)
+	for {
+		files, err := model.GetLifecycleMatches(rule, folderIDs, now, after, lifecycleDryRunBatchSize)
+		if err != nil {
+			return serializer.DBErr("Failed to list files", err)
+		}
+
+		if len(files) == 0 {
+			break
+		}
+
+		for _, file := range files {
+			total++
+			size += file.Size
+			if len(samples) < lifecycleDryRunSamples {
+				samples = append(samples, file)
+			}
+		}
+		after = files[len(files)-1].ID
+	}
+
+	// 查询对应用户
+	users := make(map[uint]model.User)
+	userIDs := make([]uint, 0)
+	for _, file := range samples {
+		if _, ok := users[file.UserID]; !ok {
+			users[file.UserID] = model.User{}
+			userIDs = append(userIDs, file.UserID)
+		}
+	}
+
+	var userList []model.User
+	model.DB.Where("id in (?)", userIDs).Find(&userList)
+	for _, v := range userList {
+		users[v.ID] = v
+	}
+
+	return serializer.Response{Data: map[string]interface{}{
+		"action": rule.Action,
+		"total":  total,
+		"size":   size,
+		"items":  samples,
+		"users":  users,
+	}}
+}
+
+// LifecycleRules 列出生命周期规则
+func (service *AdminListService) LifecycleRules() serializer.Response {
+	var res []model.LifecycleRule
+	total := 0
+
+	tx := model.DB.Model(&model.LifecycleRule{})
+	if service.OrderBy != "" {
+		tx = tx.Order(service.OrderBy)
+	}
+
+	for k, v := range service.Conditions {
+		tx = tx.Where(k+" = ?", v)
+	}
+
+	// 计算总数用于分页
+	tx.Count(&total)
+
+	// 查询记录
+	tx.Limit(service.PageSize).Offset((service.Page - 1) * service.PageSize).Find(&res)
+
+	return serializer.Response{Data: map[string]interface{}{
+		"total": total,
+		"items": res,
+	}}
+}
@@ -34,6 +34,71 @@ func (service *ImportTaskService) Create(c *gin.Context, user *model.User) seria
 	return serializer.Response{}
 }
 
+// MigrateTaskService 存储策略迁移任务，范围字段为 0 时不作限制
+type MigrateTaskService struct {
+	DstPolicyID uint `json:"dst_policy_id" binding:"required"`
+	UserID      uint `json:"user_id"`
+	GroupID     uint `json:"group_id"`
+	FolderID    uint `json:"folder_id"`
+	PolicyID    uint `json:"policy_id"`
+}
+
+// Create 新建存储策略迁移任务
+func (service *MigrateTaskService) Create(c *gin.Context, user *model.User) serializer.Response {
+	if _, err := model.GetPolicyByID(service.DstPolicyID); err != nil {
+		return serializer.Err(serializer.CodePolicyNotExist, "", err)
+	}
+
+	if service.PolicyID > 0 && service.PolicyID == service.DstPolicyID {
+		return serializer.ParamErr("Source and destination policy cannot be the same", nil)
+	}
+
+	if service.FolderID > 0 {
+		if _, err := model.GetFolderByID(service.FolderID); err != nil {
+			return serializer.Err(serializer.CodeParentNotExist, "", err)
+		}
+	}
+
+	job, err := task.NewMigrateTask(user.ID, task.MigrateProps{
+		DstPolicyID: service.DstPolicyID,
+		UserID:      service.UserID,
+		GroupID:     service.GroupID,
+		FolderID:    service.FolderID,
+		PolicyID:    service.PolicyID,
+	})
+	if err != nil {
+		return serializer.DBErr("Failed to create task record.", err)
+	}
+	task.TaskPoll.Submit(job)
+	return serializer.Response{}
+}
+
+// ReplicateTaskService 副本补全任务，PolicyID 为 0 时处理全部设定了副本的存储策略
+type ReplicateTaskService struct {
+	PolicyID uint `json:"policy_id"`
+}
+
+// Create 新建副本补全任务
+func (service *ReplicateTaskService) Create(c *gin.Context, user *model.User) serializer.Response {
+	if service.PolicyID > 0 {
+		policy, err := model.GetPolicyByID(service.PolicyID)
+		if err != nil {
+			return serializer.Err(serializer.CodePolicyNotExist, "", err)
+		}
+
+		if policy.OptionsSerialized.ReplicaPolicyID == 0 {
+			return serializer.ParamErr("Replica policy is not set for this policy", nil)
+		}
+	}
+
+	job, err := task.NewReplicateTask(user.ID, service.PolicyID)
+	if err != nil {
+		return serializer.DBErr("Failed to create task record.", err)
+	}
+	task.TaskPoll.Submit(job)
+	return serializer.Response{}
+}
+
 // Delete 删除任务
 func (service *TaskBatchService) Delete(c *gin.Context) serializer.Response {
 	if err := model.DB.Where("id in (?)", service.ID).Delete(&model.Download{}).Error; err != nil {
@@ -139,10 +139,12 @@ func ProcessCallback(service CallbackProcessService, c *gin.Context) serializer.
 	// 占位符未扣除容量需要校验和扣除
 	if !fs.Policy.IsUploadPlaceholderWithSize() {
 		fs.Use("AfterUpload", filesystem.HookValidateCapacity)
+		fs.Use("AfterUpload", filesystem.HookValidateFolderQuota)
 		fs.Use("AfterUpload", filesystem.HookChunkUploaded)
 	}
 
 	fs.Use("AfterUpload", filesystem.HookPopPlaceholderToFile(callbackBody.PicInfo))
+	fs.Use("AfterUpload", filesystem.HookReplicateFile)
 	fs.Use("AfterValidateFailed", filesystem.HookDeleteTempFile)
 	err = fs.Upload(context.Background(), &fileData)
 	if err != nil {
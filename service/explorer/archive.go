@@ -0,0 +1,106 @@
+package explorer
+
+import (
+	"context"
+	"io"
+	"mime"
+	"net/url"
+	"path"
+	"strconv"
+
+	model "github.com/Jaylenwa/Vfoy/models"
+	"github.com/Jaylenwa/Vfoy/pkg/filesystem"
+	"github.com/Jaylenwa/Vfoy/pkg/serializer"
+	"github.com/gin-gonic/gin"
+)
+
+// ArchiveEntryService 浏览压缩文件条目服务
+type ArchiveEntryService struct {
+	Encoding string `form:"encoding"`
+	Name     string `form:"name"`
+	Download bool   `form:"download"`
+}
+
+// openArchive 打开当前请求的压缩文件
+func (service *ArchiveEntryService) openArchive(ctx context.Context, c *gin.Context, fs *filesystem.FileSystem) (*filesystem.Archive, error) {
+	fileID, _ := c.Get("object_id")
+	files, err := model.GetFilesByIDs([]uint{fileID.(uint)}, fs.User.ID)
+	if err != nil || len(files) == 0 {
+		return nil, serializer.NewError(serializer.CodeFileNotFound, "", err)
+	}
+
+	return fs.OpenArchive(ctx, &files[0], service.Encoding)
+}
+
+// List 列出压缩文件中的条目
+func (service *ArchiveEntryService) List(ctx context.Context, c *gin.Context) serializer.Response {
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	archive, err := service.openArchive(ctx, c, fs)
+	if err != nil {
+		return serializer.Err(serializer.CodeUnsupportedArchiveType, "Failed to open archive file", err)
+	}
+	defer archive.Close()
+
+	res := make([]serializer.ArchiveEntry, 0, len(archive.Entries))
+	for _, entry := range archive.Entries {
+		res = append(res, serializer.ArchiveEntry{
+			Name:      entry.Name,
+			Size:      entry.Size,
+			Modified:  entry.Modified,
+			IsDir:     entry.IsDir,
+			Encrypted: entry.Encrypted,
+		})
+	}
+
+	return serializer.Response{Data: res}
+}
+
+// Stream 读取压缩文件中单个条目的内容，用于预览或下载
+func (service *ArchiveEntryService) Stream(ctx context.Context, c *gin.Context) serializer.Response {
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	archive, err := service.openArchive(ctx, c, fs)
+	if err != nil {
+		return serializer.Err(serializer.CodeUnsupportedArchiveType, "Failed to open archive file", err)
+	}
+	defer archive.Close()
+
+	entry, ok := archive.Entry(service.Name)
+	if !ok || entry.IsDir || !entry.Regular() {
+		return serializer.Err(serializer.CodeNotFound, "", filesystem.ErrArchiveEntryNotExist)
+	}
+
+	rc, err := entry.Open()
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, "", err)
+	}
+	defer rc.Close()
+
+	name := path.Base(entry.Name)
+	disposition := "inline"
+	if service.Download {
+		disposition = "attachment"
+	}
+
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Length", strconv.FormatUint(entry.Size, 10))
+	c.Header("Content-Disposition", disposition+"; filename=\""+url.PathEscape(name)+"\"")
+	c.Status(200)
+	io.Copy(c.Writer, rc)
+
+	return serializer.Response{}
+}
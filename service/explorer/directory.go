@@ -3,7 +3,9 @@ package explorer
 import (
 	"context"
 
+	model "github.com/Jaylenwa/Vfoy/models"
 	"github.com/Jaylenwa/Vfoy/pkg/filesystem"
+	"github.com/Jaylenwa/Vfoy/pkg/hashid"
 	"github.com/Jaylenwa/Vfoy/pkg/serializer"
 	"github.com/gin-gonic/gin"
 )
@@ -13,6 +15,22 @@ type DirectoryService struct {
 	Path string `uri:"path" json:"path" binding:"required,min=1,max=65535"`
 }
 
+// defaultDeltaLimit 未指定时每次返回的最多变更数
+const defaultDeltaLimit = 200
+
+// DeltaService 列出游标之后的文件系统变更的服务，游标为 0 时返回当前游标
+type DeltaService struct {
+	Cursor uint `json:"cursor"`
+	Limit  int  `json:"limit" binding:"min=0,max=1000"`
+}
+
+// FolderQuotaService 目录配额设置服务，上限均为 0 时取消配额
+type FolderQuotaService struct {
+	ID       string `json:"id" binding:"required"`
+	MaxSize  uint64 `json:"max_size"`
+	MaxFiles uint64 `json:"max_files"`
+}
+
 // ListDirectory 列出目录内容
 func (service *DirectoryService) ListDirectory(c *gin.Context) serializer.Response {
 	// 创建文件系统
@@ -37,9 +55,49 @@ func (service *DirectoryService) ListDirectory(c *gin.Context) serializer.Respon
 		parentID = fs.DirTarget[0].ID
 	}
 
+	res := serializer.BuildObjectList(parentID, objects, fs.Policy)
+	if parentID > 0 {
+		if quota, err := model.GetFolderQuotaByFolderID(parentID); err == nil {
+			res.Quota = serializer.BuildFolderQuota(quota)
+		}
+	}
+
 	return serializer.Response{
 		Code: 0,
-		Data: serializer.BuildObjectList(parentID, objects, fs.Policy),
+		Data: res,
+	}
+}
+
+// Set 设置用户自己目录的配额
+func (service *FolderQuotaService) Set(c *gin.Context, user *model.User) serializer.Response {
+	id, err := hashid.DecodeHashID(service.ID, hashid.FolderID)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotFound, "", err)
+	}
+
+	folders, err := model.GetFoldersByIDs([]uint{id}, user.ID)
+	if err != nil || len(folders) == 0 {
+		return serializer.Err(serializer.CodeParentNotExist, "", err)
+	}
+
+	fs, err := filesystem.NewFileSystem(user)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	return service.Apply(c, fs, &folders[0])
+}
+
+// Apply 在目录上应用配额设置，folder 需属于 fs 的用户
+func (service *FolderQuotaService) Apply(c *gin.Context, fs *filesystem.FileSystem, folder *model.Folder) serializer.Response {
+	quota, err := fs.SetFolderQuota(c, folder, service.MaxSize, service.MaxFiles)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	return serializer.Response{
+		Data: serializer.BuildFolderQuota(quota),
 	}
 }
 
@@ -66,3 +124,39 @@ func (service *DirectoryService) CreateDirectory(c *gin.Context) serializer.Resp
 	}
 
 }
+
+// List 列出用户在游标之后的变更
+func (service *DeltaService) List(c *gin.Context, user *model.User) serializer.Response {
+	if service.Limit == 0 {
+		service.Limit = defaultDeltaLimit
+	}
+
+	first, last, err := model.GetChangeCursorRange()
+	if err != nil {
+		return serializer.DBErr("Failed to read change journal", err)
+	}
+
+	// 游标之后的日志已被清理或游标无效时，需要客户端重新同步
+	if service.Cursor == 0 || service.Cursor > last || service.Cursor+1 < first {
+		res := serializer.BuildDelta(last, false, nil)
+		res.Reset = true
+		return serializer.Response{Data: res}
+	}
+
+	changes, err := model.GetChangesAfter(user.ID, service.Cursor, service.Limit+1)
+	if err != nil {
+		return serializer.DBErr("Failed to list changes", err)
+	}
+
+	// 游标只前进到实际返回的最后一条变更，避免跳过并发事务中 ID 更小、稍后才提交的变更
+	cursor := service.Cursor
+	hasMore := len(changes) > service.Limit
+	if hasMore {
+		changes = changes[:service.Limit]
+	}
+	if len(changes) > 0 {
+		cursor = changes[len(changes)-1].ID
+	}
+
+	return serializer.Response{Data: serializer.BuildDelta(cursor, hasMore, changes)}
+}
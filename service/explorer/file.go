@@ -11,6 +11,7 @@ import (
 	"path"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Jaylenwa/Vfoy/pkg/util"
 
@@ -18,6 +19,7 @@ import (
 	"github.com/Jaylenwa/Vfoy/pkg/cache"
 	"github.com/Jaylenwa/Vfoy/pkg/filesystem"
 	"github.com/Jaylenwa/Vfoy/pkg/filesystem/fsctx"
+	"github.com/Jaylenwa/Vfoy/pkg/filesystem/lock"
 	"github.com/Jaylenwa/Vfoy/pkg/serializer"
 	"github.com/Jaylenwa/Vfoy/pkg/wopi"
 	"github.com/gin-gonic/gin"
@@ -125,6 +127,10 @@ func (service *ArchiveService) DownloadArchived(ctx context.Context, c *gin.Cont
 	c.Header("Content-Type", "application/zip")
 	itemService := archiveSession.(ItemIDService)
 	items := itemService.Raw()
+	if itemService.Store {
+		return service.serveArchiveLayout(c, fs, itemService, &user)
+	}
+
 	ctx = context.WithValue(ctx, fsctx.GinCtx, c)
 	err = fs.Compress(ctx, c.Writer, items.Dirs, items.Items, true)
 	if err != nil {
@@ -136,6 +142,40 @@ func (service *ArchiveService) DownloadArchived(ctx context.Context, c *gin.Cont
 	}
 }
 
+// serveArchiveLayout 按会话记录的文件清单发送 zip 文件，支持 Range 请求，每次请求都会为会话续期
+func (service *ArchiveService) serveArchiveLayout(c *gin.Context, fs *filesystem.FileSystem, itemService ItemIDService, user *model.User) serializer.Response {
+	manifestRaw, exist := cache.Get("archive_manifest_" + service.ID)
+	if !exist {
+		c.Status(http.StatusNotFound)
+		return serializer.Err(serializer.CodeNotFound, "Archive session not exist", nil)
+	}
+	manifest := manifestRaw.(filesystem.ArchiveManifest)
+
+	ttl := model.GetIntSetting("archive_timeout", 30)
+	cache.Set("archive_"+service.ID, itemService, ttl)
+	cache.Set("archive_user_"+service.ID, *user, ttl)
+	cache.Set("archive_manifest_"+service.ID, manifest, ttl)
+
+	layout, err := fs.NewArchiveLayout(c.Request.Context(), &manifest)
+	if err == filesystem.ErrArchiveChanged {
+		// 打包的文件已变化，无法生成与之前相同的内容，需重新创建会话
+		c.Status(http.StatusConflict)
+		return serializer.Err(serializer.CodeConflict, err.Error(), err)
+	} else if err != nil {
+		return serializer.Err(serializer.CodeNotSet, "Failed to compress file", err)
+	}
+	layout.CacheCRC("archive_crc_"+service.ID+"_", ttl)
+
+	reader := layout.Reader(c.Request.Context())
+	defer reader.Close()
+
+	// 以文件清单生成 ETag 供 If-Range 校验
+	c.Header("ETag", manifest.ETag())
+	http.ServeContent(c.Writer, c.Request, "archive.zip", time.Time{}, reader)
+
+	return serializer.Response{}
+}
+
 // Download 签名的匿名文件下载
 func (service *FileAnonymousGetService) Download(ctx context.Context, c *gin.Context) serializer.Response {
 	fs, err := filesystem.NewAnonymousFileSystem()
@@ -228,6 +268,12 @@ func (service *FileIDService) CreateDocPreviewSession(ctx context.Context, c *gi
 		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
 	}
 
+	if editable {
+		fs.RecordRecent(&fs.FileTarget[0], model.RecentEdit)
+	} else {
+		fs.RecordRecent(&fs.FileTarget[0], model.RecentPreview)
+	}
+
 	// For newer version of Vfoy - Local Policy
 	// When do not use a cdn, the downloadURL withouts hosts, like "/api/v3/file/download/xxx"
 	if strings.HasPrefix(downloadURL, "/") {
@@ -299,6 +345,7 @@ func (service *FileIDService) CreateDownloadSession(ctx context.Context, c *gin.
 	if err != nil {
 		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
 	}
+	fs.RecordRecent(&fs.FileTarget[0], model.RecentDownload)
 
 	return serializer.Response{
 		Code: 0,
@@ -381,6 +428,7 @@ func (service *FileIDService) PreviewContent(ctx context.Context, c *gin.Context
 	if err != nil {
 		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
 	}
+	fs.RecordRecent(&fs.FileTarget[0], model.RecentPreview)
 
 	// 重定向到文件源
 	if resp.Redirect {
@@ -440,9 +488,17 @@ func (service *FileIDService) PutContent(ctx context.Context, c *gin.Context) se
 	}
 	fileData.Name = originFile[0].Name
 
+	// 检查文件锁，WOPI 保存时携带锁标识
+	if _, err := lock.Check(originFile[0].ID, c.GetHeader(wopi.LockHeader)); err != nil {
+		return serializer.Err(serializer.CodeFileLocked, "", err)
+	}
+
+	// 启用历史版本时，新内容写入新路径，原始内容保存为历史版本
+	versioned := fs.VersioningEnabled() && fs.UseVersionedUpdate(uploadCtx, &originFile[0], &fileData)
+
 	// 检查此文件是否有软链接
 	fileList, err := model.RemoveFilesWithSoftLinks([]model.File{originFile[0]})
-	if err == nil && len(fileList) == 0 {
+	if !versioned && err == nil && len(fileList) == 0 {
 		// 如果包含软连接，应重新生成新文件副本，并更新source_name
 		originFile[0].SourceName = fs.GenerateSavePath(uploadCtx, &fileData)
 		fileData.Mode &= ^fsctx.Overwrite
@@ -456,10 +512,14 @@ func (service *FileIDService) PutContent(ctx context.Context, c *gin.Context) se
 	}
 
 	// 给文件系统分配钩子
-	fs.Use("BeforeUpload", filesystem.HookResetPolicy)
-	fs.Use("BeforeUpload", filesystem.HookValidateFile)
-	fs.Use("BeforeUpload", filesystem.HookValidateCapacityDiff)
-	fs.Use("AfterUpload", filesystem.GenericAfterUpdate)
+	if !versioned {
+		fs.Use("BeforeUpload", filesystem.HookResetPolicy)
+		fs.Use("BeforeUpload", filesystem.HookValidateFile)
+		fs.Use("BeforeUpload", filesystem.HookValidateCapacityDiff)
+		fs.Use("BeforeUpload", filesystem.HookValidateFolderQuotaDiff)
+		fs.Use("AfterUpload", filesystem.GenericAfterUpdate)
+	}
+	fs.Use("AfterUpload", filesystem.HookReplicateFile)
 
 	// 执行上传
 	uploadCtx = context.WithValue(uploadCtx, fsctx.FileModelCtx, originFile[0])
@@ -0,0 +1,63 @@
+package explorer
+
+import (
+	"errors"
+
+	model "github.com/Jaylenwa/Vfoy/models"
+	"github.com/Jaylenwa/Vfoy/pkg/filesystem/lock"
+	"github.com/Jaylenwa/Vfoy/pkg/hashid"
+	"github.com/Jaylenwa/Vfoy/pkg/serializer"
+	"github.com/gin-gonic/gin"
+)
+
+// ListLocks 列出用户持有的文件锁
+func ListLocks(c *gin.Context, user *model.User) serializer.Response {
+	locks := lock.ListByUser(user.ID)
+
+	ids := make([]uint, 0, len(locks))
+	for _, l := range locks {
+		ids = append(ids, l.FileID)
+	}
+
+	files, err := model.GetFilesByIDs(ids, user.ID)
+	if err != nil {
+		return serializer.DBErr("Failed to query files", err)
+	}
+
+	names := make(map[uint]string, len(files))
+	for _, file := range files {
+		names[file.ID] = file.Name
+	}
+
+	res := make([]serializer.FileLock, 0, len(locks))
+	for _, l := range locks {
+		name, ok := names[l.FileID]
+		if !ok {
+			continue
+		}
+
+		res = append(res, serializer.FileLock{
+			ID:        hashid.HashID(l.FileID, hashid.FileID),
+			Name:      name,
+			App:       l.App,
+			Owner:     l.Owner,
+			CreatedAt: l.CreatedAt,
+			ExpiresAt: l.ExpiresAt,
+		})
+	}
+
+	return serializer.Response{Data: res}
+}
+
+// BreakLock 强制解除用户自己持有的文件锁
+func BreakLock(c *gin.Context, user *model.User) serializer.Response {
+	err := lock.Break(user.ID, c.GetUint("object_id"))
+	switch {
+	case errors.Is(err, lock.ErrNoSuchLock), errors.Is(err, lock.ErrNotOwner):
+		return serializer.Err(serializer.CodeNotFound, "Lock not exist", err)
+	case err != nil:
+		return serializer.Err(serializer.CodeInternalSetting, "Failed to break lock", err)
+	}
+
+	return serializer.Response{}
+}
@@ -46,13 +46,19 @@ type ItemIDService struct {
 	Source     *ItemService
 	Force      bool `json:"force"`
 	UnlinkOnly bool `json:"unlink"`
+	// 打包下载时预先计算不压缩的 zip 布局，支持断点续传
+	Store bool `json:"store"`
 }
 
 // ItemCompressService 文件压缩任务服务
 type ItemCompressService struct {
-	Src  ItemIDService `json:"src"`
-	Dst  string        `json:"dst" binding:"required,min=1,max=65535"`
-	Name string        `json:"name" binding:"required,min=1,max=255"`
+	Src    ItemIDService `json:"src"`
+	Dst    string        `json:"dst" binding:"required,min=1,max=65535"`
+	Name   string        `json:"name" binding:"required,min=1,max=255"`
+	Format string        `json:"format" binding:"omitempty,oneof=zip tar.gz tar.zst 7z"`
+	Level  int           `json:"level" binding:"min=0,max=22"`
+	// 不为空时创建 AES-256 加密的 zip 文件
+	Password string `json:"password" binding:"max=255"`
 }
 
 // ItemDecompressService 文件解压缩任务服务
@@ -60,6 +66,10 @@ type ItemDecompressService struct {
 	Src      string `json:"src"`
 	Dst      string `json:"dst" binding:"required,min=1,max=65535"`
 	Encoding string `json:"encoding"`
+	// 仅解压压缩文件中选中的条目，为空时解压全部
+	Entries []string `json:"entries"`
+	// 加密压缩文件的密码
+	Password string `json:"password" binding:"max=255"`
 }
 
 // ItemPropertyService 获取对象属性服务
@@ -69,6 +79,14 @@ type ItemPropertyService struct {
 	IsFolder  bool   `form:"is_folder"`
 }
 
+// ItemMetadataService 设置和删除对象的用户自定义元数据服务
+type ItemMetadataService struct {
+	ID       string            `json:"-" binding:"required"`
+	IsFolder bool              `json:"is_folder"`
+	Set      map[string]string `json:"set"`
+	Delete   []string          `json:"delete"`
+}
+
 func init() {
 	gob.Register(ItemIDService{})
 }
@@ -124,19 +142,20 @@ func (service *ItemDecompressService) CreateDecompressTask(c *gin.Context) seria
 		return serializer.Err(serializer.CodeFileNotFound, "", nil)
 	}
 
-	// 文件尺寸限制
-	if fs.User.Group.OptionsSerialized.DecompressSize != 0 && file.Size > fs.User.Group.
+	// 文件尺寸限制，只解压选中的条目时无需下载完整的压缩文件，不做限制
+	if len(service.Entries) == 0 && fs.User.Group.OptionsSerialized.DecompressSize != 0 && file.Size > fs.User.Group.
 		OptionsSerialized.DecompressSize {
 		return serializer.Err(serializer.CodeFileTooLarge, "", nil)
 	}
 
-	// 支持的压缩格式后缀
+	// 支持的压缩格式后缀，实际格式在解压时根据文件头识别
 	var (
-		suffixes = []string{".zip", ".gz", ".xz", ".tar", ".rar"}
+		suffixes = []string{".zip", ".gz", ".tgz", ".xz", ".txz", ".bz2", ".tbz2", ".zst", ".tar", ".rar", ".7z"}
+		name     = strings.ToLower(file.Name)
 		matched  bool
 	)
 	for _, suffix := range suffixes {
-		if strings.HasSuffix(file.Name, suffix) {
+		if strings.HasSuffix(name, suffix) {
 			matched = true
 			break
 		}
@@ -146,7 +165,8 @@ func (service *ItemDecompressService) CreateDecompressTask(c *gin.Context) seria
 	}
 
 	// 创建任务
-	job, err := task.NewDecompressTask(fs.User, service.Src, service.Dst, service.Encoding)
+	job, err := task.NewDecompressTask(fs.User, service.Src, service.Dst, service.Encoding, service.Password,
+		service.Entries...)
 	if err != nil {
 		return serializer.Err(serializer.CodeCreateTaskError, "", err)
 	}
@@ -171,8 +191,16 @@ func (service *ItemCompressService) CreateCompressTask(c *gin.Context) serialize
 	}
 
 	// 补齐压缩文件扩展名（如果没有）
-	if !strings.HasSuffix(service.Name, ".zip") {
-		service.Name += ".zip"
+	if service.Format == "" {
+		service.Format = filesystem.CompressFormatZip
+	}
+	// 压缩级别的范围因格式而异，仅 zip 格式支持加密
+	option := filesystem.CompressOption{Format: service.Format, Level: service.Level, Password: service.Password}
+	if err := option.Validate(); err != nil {
+		return serializer.Err(serializer.CodeParamErr, "", err)
+	}
+	if !strings.HasSuffix(service.Name, "."+service.Format) {
+		service.Name += "." + service.Format
 	}
 
 	// 存放目录是否存在，是否重名
@@ -203,6 +231,13 @@ func (service *ItemCompressService) CreateCompressTask(c *gin.Context) serialize
 		return serializer.DBErr("Failed to list files", err)
 	}
 
+	// 列出直接选中的待压缩文件
+	items, err := model.GetFilesByIDs(service.Src.Raw().Items, fs.User.ID)
+	if err != nil {
+		return serializer.DBErr("Failed to list files", err)
+	}
+	files = append(files, items...)
+
 	// 计算待压缩文件大小
 	var totalSize uint64
 	for i := 0; i < len(files); i++ {
@@ -224,7 +259,7 @@ func (service *ItemCompressService) CreateCompressTask(c *gin.Context) serialize
 
 	// 创建任务
 	job, err := task.NewCompressTask(fs.User, path.Join(service.Dst, service.Name), service.Src.Raw().Dirs,
-		service.Src.Raw().Items)
+		service.Src.Raw().Items, service.Format, service.Level, service.Password)
 	if err != nil {
 		return serializer.Err(serializer.CodeCreateTaskError, "", err)
 	}
@@ -251,12 +286,25 @@ func (service *ItemIDService) Archive(ctx context.Context, c *gin.Context) seria
 	// 创建打包下载会话
 	ttl := model.GetIntSetting("archive_timeout", 30)
 	downloadSessionID := util.RandStringRunes(16)
+	signTTL := int64(ttl)
+	if service.Store {
+		// 记录文件清单，同一会话的各次请求生成相同的 zip 文件
+		items := service.Raw()
+		manifest, err := fs.NewArchiveManifest(ctx, items.Dirs, items.Items)
+		if err != nil {
+			return serializer.Err(serializer.CodeNotSet, "Failed to list archived files", err)
+		}
+		cache.Set("archive_manifest_"+downloadSessionID, *manifest, ttl)
+
+		// 会话在每次下载请求时续期，链接随会话失效，不再单独限制签名有效期
+		signTTL = 0
+	}
 	cache.Set("archive_"+downloadSessionID, *service, ttl)
 	cache.Set("archive_user_"+downloadSessionID, *fs.User, ttl)
 	signURL, err := auth.SignURI(
 		auth.General,
 		fmt.Sprintf("/api/v3/file/archive/%s/archive.zip", downloadSessionID),
-		int64(ttl),
+		signTTL,
 	)
 
 	return serializer.Response{
@@ -280,10 +328,9 @@ func (service *ItemIDService) Delete(ctx context.Context, c *gin.Context) serial
 		unlink = service.UnlinkOnly
 	}
 
-	// 删除对象
+	// 删除对象，启用回收站时移入回收站
 	items := service.Raw()
-	err = fs.Delete(ctx, items.Dirs, items.Items, force, unlink)
-	if err != nil {
+	if err := fs.Remove(ctx, items.Dirs, items.Items, force, unlink); err != nil {
 		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
 	}
 
@@ -366,6 +413,34 @@ func (service *ItemRenameService) Rename(ctx context.Context, c *gin.Context) se
 	}
 }
 
+// Update 更新对象的用户自定义元数据
+func (service *ItemMetadataService) Update(ctx context.Context, c *gin.Context) serializer.Response {
+	idType := hashid.FileID
+	if service.IsFolder {
+		idType = hashid.FolderID
+	}
+
+	id, err := hashid.DecodeHashID(service.ID, idType)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotFound, "", err)
+	}
+
+	// 创建文件系统
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	metadata, err := fs.UpdateMetadata(ctx, id, service.IsFolder, service.Set, service.Delete)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	// 目录属性缓存中不含元数据，无需清除
+	return serializer.Response{Data: metadata}
+}
+
 // GetProperty 获取对象的属性
 func (service *ItemPropertyService) GetProperty(ctx context.Context, c *gin.Context) serializer.Response {
 	userCtx, _ := c.Get("user")
@@ -390,6 +465,11 @@ func (service *ItemPropertyService) GetProperty(ctx context.Context, c *gin.Cont
 		props.UpdatedAt = file[0].UpdatedAt
 		props.Policy = file[0].GetPolicy().Name
 		props.Size = file[0].Size
+		props.Checksums = file[0].Checksums()
+		props.Metadata = model.UserMetadata(file[0].MetadataSerialized)
+
+		// 尚未计算校验和的已有文件，在后台计算
+		filesystem.ScheduleChecksum(user, &file[0])
 
 		// 查找父目录
 		if service.TraceRoot {
@@ -423,6 +503,8 @@ func (service *ItemPropertyService) GetProperty(ctx context.Context, c *gin.Cont
 			res := cacheRes.(serializer.ObjectProps)
 			res.CreatedAt = props.CreatedAt
 			res.UpdatedAt = props.UpdatedAt
+			res.Quota = folderQuotaProps(folder[0].ID)
+			res.Metadata = model.UserMetadata(folder[0].MetadataSerialized)
 			return serializer.Response{Data: res}
 		}
 
@@ -458,6 +540,10 @@ func (service *ItemPropertyService) GetProperty(ctx context.Context, c *gin.Cont
 		// 如果列取对象是目录，则缓存结果
 		cache.Set(fmt.Sprintf("folder_props_%d", res), props,
 			model.GetIntSetting("folder_props_timeout", 300))
+
+		// 配额用量随上传实时变化，元数据可随时修改，均不缓存
+		props.Quota = folderQuotaProps(folder[0].ID)
+		props.Metadata = model.UserMetadata(folder[0].MetadataSerialized)
 	}
 
 	return serializer.Response{
@@ -465,3 +551,13 @@ func (service *ItemPropertyService) GetProperty(ctx context.Context, c *gin.Cont
 		Data: props,
 	}
 }
+
+// folderQuotaProps 获取目录配额，未设定配额时返回 nil
+func folderQuotaProps(folderID uint) *serializer.FolderQuota {
+	quota, err := model.GetFolderQuotaByFolderID(folderID)
+	if err != nil {
+		return nil
+	}
+
+	return serializer.BuildFolderQuota(quota)
+}
@@ -2,20 +2,34 @@ package explorer
 
 import (
 	"context"
+	"errors"
 	"strings"
 
 	model "github.com/Jaylenwa/Vfoy/models"
 	"github.com/Jaylenwa/Vfoy/pkg/filesystem"
 	"github.com/Jaylenwa/Vfoy/pkg/hashid"
+	"github.com/Jaylenwa/Vfoy/pkg/search"
 	"github.com/Jaylenwa/Vfoy/pkg/serializer"
+	"github.com/Jaylenwa/Vfoy/pkg/task"
 	"github.com/gin-gonic/gin"
 )
 
+const (
+	// searchDefaultPageSize 结构化搜索的默认分页大小
+	searchDefaultPageSize = 50
+	// searchMaxPageSize 结构化搜索的最大分页大小
+	searchMaxPageSize = 1000
+)
+
 // ItemSearchService 文件搜索服务
 type ItemSearchService struct {
-	Type     string `uri:"type" binding:"required"`
-	Keywords string `uri:"keywords" binding:"required"`
-	Path     string `form:"path"`
+	Type           string `uri:"type" binding:"required"`
+	Keywords       string `uri:"keywords" binding:"required"`
+	Path           string `form:"path"`
+	Page           int    `form:"page" binding:"omitempty,min=1"`
+	PageSize       int    `form:"page_size" binding:"omitempty,min=1,max=1000"`
+	OrderBy        string `form:"order_by" binding:"omitempty,oneof=name size created_at updated_at"`
+	OrderDirection string `form:"order_direction" binding:"omitempty,oneof=asc desc"`
 }
 
 // Search 执行搜索
@@ -27,6 +41,12 @@ func (service *ItemSearchService) Search(c *gin.Context) serializer.Response {
 	}
 	defer fs.Recycle()
 
+	// 关键字中可包含路径，以通配路由参数传入
+	service.Keywords = strings.TrimPrefix(service.Keywords, "/")
+	if service.Keywords == "" {
+		return serializer.ParamErr("Keywords cannot be empty", nil)
+	}
+
 	if service.Path != "" {
 		ok, parent := fs.IsPathExist(service.Path)
 		if !ok {
@@ -38,25 +58,23 @@ func (service *ItemSearchService) Search(c *gin.Context) serializer.Response {
 
 	switch service.Type {
 	case "keywords":
-		return service.SearchKeywords(c, fs, "%"+service.Keywords+"%")
-	case "image":
-		return service.SearchKeywords(c, fs, "%.bmp", "%.iff", "%.png", "%.gif", "%.jpg", "%.jpeg", "%.psd", "%.svg", "%.webp")
-	case "video":
-		return service.SearchKeywords(c, fs, "%.mp4", "%.flv", "%.avi", "%.wmv", "%.mkv", "%.rm", "%.rmvb", "%.mov", "%.ogv")
-	case "audio":
-		return service.SearchKeywords(c, fs, "%.mp3", "%.flac", "%.ape", "%.wav", "%.acc", "%.ogg", "%.midi", "%.mid")
-	case "doc":
-		return service.SearchKeywords(c, fs, "%.txt", "%.md", "%.pdf", "%.doc", "%.docx", "%.ppt", "%.pptx", "%.xls", "%.xlsx", "%.pub")
+		return service.SearchKeywords(c, fs, "%"+model.EscapeLike(service.Keywords)+"%")
+	case "content":
+		return service.SearchContent(c, fs)
+	case "image", "video", "audio", "doc":
+		exts := search.Categories[service.Type]
+		patterns := make([]interface{}, len(exts))
+		for i, ext := range exts {
+			patterns[i] = "%." + ext
+		}
+		return service.SearchKeywords(c, fs, patterns...)
+	case "query":
+		return service.SearchQuery(c, fs)
 	case "tag":
 		if tid, err := hashid.DecodeHashID(service.Keywords, hashid.TagID); err == nil {
 			if tag, err := model.GetTagsByID(tid, fs.User.ID); err == nil {
 				if tag.Type == model.FileTagType {
-					exp := strings.Split(tag.Expression, "\n")
-					expInput := make([]interface{}, len(exp))
-					for i := 0; i < len(exp); i++ {
-						expInput[i] = exp[i]
-					}
-					return service.SearchKeywords(c, fs, expInput...)
+					return service.SearchTag(c, fs, tag)
 				}
 			}
 		}
@@ -86,3 +104,105 @@ func (service *ItemSearchService) SearchKeywords(c *gin.Context, fs *filesystem.
 		},
 	}
 }
+
+// SearchQuery 按结构化搜索语句搜索目录和文件
+func (service *ItemSearchService) SearchQuery(c *gin.Context, fs *filesystem.FileSystem) serializer.Response {
+	query, err := search.ParseQuery(service.Keywords)
+	if err != nil {
+		return serializer.ParamErr(err.Error(), err)
+	}
+
+	objects, total, err := fs.SearchQuery(c, query, service.condition())
+	return service.searchResult(objects, total, err)
+}
+
+// SearchTag 按文件分类标签搜索文件
+func (service *ItemSearchService) SearchTag(c *gin.Context, fs *filesystem.FileSystem, tag *model.Tag) serializer.Response {
+	// 旧版标签的表达式为文件名匹配模式，结果不分页
+	if !tag.IsQuery {
+		exp := strings.Split(model.EscapeLikeEscapeChar(tag.Expression), "\n")
+		expInput := make([]interface{}, len(exp))
+		for i := 0; i < len(exp); i++ {
+			expInput[i] = exp[i]
+		}
+		return service.SearchKeywords(c, fs, expInput...)
+	}
+
+	objects, total, err := fs.SearchTag(c, tag, service.condition())
+	return service.searchResult(objects, total, err)
+}
+
+// condition 根据分页和排序参数生成查询条件
+func (service *ItemSearchService) condition() *model.ObjectSearchCondition {
+	page, pageSize := service.Page, service.PageSize
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 || pageSize > searchMaxPageSize {
+		pageSize = searchDefaultPageSize
+	}
+
+	return &model.ObjectSearchCondition{
+		OrderBy: service.OrderBy,
+		Desc:    service.OrderDirection == "desc",
+		Offset:  (page - 1) * pageSize,
+		Limit:   pageSize,
+	}
+}
+
+func (service *ItemSearchService) searchResult(objects []serializer.Object, total int, err error) serializer.Response {
+	if errors.Is(err, search.ErrInvalidQuery) {
+		return serializer.ParamErr(err.Error(), err)
+	}
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	return serializer.Response{
+		Code: 0,
+		Data: map[string]interface{}{
+			"parent":  0,
+			"objects": objects,
+			"total":   total,
+		},
+	}
+}
+
+// SearchContent 根据关键字搜索文件内容
+func (service *ItemSearchService) SearchContent(c *gin.Context, fs *filesystem.FileSystem) serializer.Response {
+	if !model.IsTrueVal(model.GetSettingByName("search_index_enabled")) {
+		return serializer.Err(serializer.CodeFeatureNotEnabled, "Content search is not enabled", nil)
+	}
+
+	objects, err := fs.SearchContent(c, service.Keywords)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	return serializer.Response{
+		Code: 0,
+		Data: map[string]interface{}{
+			"parent":  0,
+			"objects": objects,
+		},
+	}
+}
+
+// SearchIndexService 全文索引服务
+type SearchIndexService struct {
+}
+
+// Rebuild 在后台重建当前用户全部文件的全文索引
+func (service *SearchIndexService) Rebuild(c *gin.Context, user *model.User) serializer.Response {
+	if !model.IsTrueVal(model.GetSettingByName("search_index_enabled")) {
+		return serializer.Err(serializer.CodeFeatureNotEnabled, "Content search is not enabled", nil)
+	}
+
+	job, err := task.NewIndexTask(user)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateTaskError, "", err)
+	}
+	task.TaskPoll.Submit(job)
+
+	return serializer.Response{}
+}
@@ -0,0 +1,82 @@
+package explorer
+
+import (
+	"context"
+
+	"github.com/Jaylenwa/Vfoy/pkg/filesystem"
+	"github.com/Jaylenwa/Vfoy/pkg/serializer"
+	"github.com/gin-gonic/gin"
+)
+
+// defaultRecentLimit 默认列出的最近文件数
+const defaultRecentLimit = 50
+
+// RecentListService 列出最近文件服务
+type RecentListService struct {
+	Limit int `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+// Star 收藏或取消收藏对象
+func (service *ItemIDService) Star(ctx context.Context, c *gin.Context, starred bool) serializer.Response {
+	// 创建文件系统
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	items := service.Raw()
+	if starred {
+		err = fs.Star(ctx, items.Dirs, items.Items)
+	} else {
+		err = fs.Unstar(ctx, items.Dirs, items.Items)
+	}
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	return serializer.Response{}
+}
+
+// ListStarred 列出收藏的对象
+func ListStarred(ctx context.Context, c *gin.Context) serializer.Response {
+	// 创建文件系统
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	objects, err := fs.ListStarred(ctx)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	return serializer.Response{
+		Data: serializer.BuildObjectList(0, objects, nil),
+	}
+}
+
+// List 列出最近访问或修改的文件
+func (service *RecentListService) List(ctx context.Context, c *gin.Context) serializer.Response {
+	// 创建文件系统
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	limit := service.Limit
+	if limit == 0 {
+		limit = defaultRecentLimit
+	}
+
+	objects, err := fs.ListRecent(ctx, limit)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	return serializer.Response{
+		Data: serializer.BuildObjectList(0, objects, nil),
+	}
+}
@@ -6,6 +6,7 @@ import (
 
 	model "github.com/Jaylenwa/Vfoy/models"
 	"github.com/Jaylenwa/Vfoy/pkg/hashid"
+	"github.com/Jaylenwa/Vfoy/pkg/search"
 	"github.com/Jaylenwa/Vfoy/pkg/serializer"
 	"github.com/gin-gonic/gin"
 )
@@ -16,6 +17,7 @@ type FilterTagCreateService struct {
 	Icon       string `json:"icon" binding:"required,min=1,max=255"`
 	Name       string `json:"name" binding:"required,min=1,max=255"`
 	Color      string `json:"color" binding:"hexcolor|rgb|rgba|hsl"`
+	Query      bool   `json:"query"`
 }
 
 // LinkTagCreateService 目录快捷方式标签创建服务
@@ -59,13 +61,9 @@ func (service *LinkTagCreateService) Create(c *gin.Context, user *model.User) se
 
 // Create 创建标签
 func (service *FilterTagCreateService) Create(c *gin.Context, user *model.User) serializer.Response {
-	// 分割表达式，将通配符转换为SQL内的%
-	expressions := strings.Split(service.Expression, "\n")
-	for i := 0; i < len(expressions); i++ {
-		expressions[i] = strings.ReplaceAll(expressions[i], "*", "%")
-		if expressions[i] == "" {
-			return serializer.ParamErr(fmt.Sprintf("The %d line contains an empty match expression", i+1), nil)
-		}
+	expression, err := service.expression()
+	if err != nil {
+		return serializer.ParamErr(err.Error(), err)
 	}
 
 	// 创建标签
@@ -74,7 +72,8 @@ func (service *FilterTagCreateService) Create(c *gin.Context, user *model.User)
 		Icon:       service.Icon,
 		Color:      service.Color,
 		Type:       model.FileTagType,
-		Expression: strings.Join(expressions, "\n"),
+		Expression: expression,
+		IsQuery:    service.Query,
 		UserID:     user.ID,
 	}
 	id, err := tag.Create()
@@ -86,3 +85,26 @@ func (service *FilterTagCreateService) Create(c *gin.Context, user *model.User)
 		Data: hashid.HashID(id, hashid.TagID),
 	}
 }
+
+// expression 校验并整理标签的表达式
+func (service *FilterTagCreateService) expression() (string, error) {
+	// 结构化搜索语句保存前先检查能否解析
+	if service.Query {
+		expression := strings.TrimSpace(service.Expression)
+		if _, err := search.ParseQuery(expression); err != nil {
+			return "", err
+		}
+		return expression, nil
+	}
+
+	// 分割表达式，将通配符转换为SQL内的%
+	expressions := strings.Split(service.Expression, "\n")
+	for i := 0; i < len(expressions); i++ {
+		expressions[i] = strings.ReplaceAll(expressions[i], "*", "%")
+		if expressions[i] == "" {
+			return "", fmt.Errorf("The %d line contains an empty match expression", i+1)
+		}
+	}
+
+	return strings.Join(expressions, "\n"), nil
+}
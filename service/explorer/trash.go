@@ -0,0 +1,99 @@
+package explorer
+
+import (
+	"context"
+
+	model "github.com/Jaylenwa/Vfoy/models"
+	"github.com/Jaylenwa/Vfoy/pkg/filesystem"
+	"github.com/Jaylenwa/Vfoy/pkg/hashid"
+	"github.com/Jaylenwa/Vfoy/pkg/serializer"
+	"github.com/gin-gonic/gin"
+)
+
+// TrashListService 列出回收站内容服务
+type TrashListService struct {
+	Page    uint   `form:"page" binding:"required,min=1"`
+	OrderBy string `form:"order_by" binding:"required,eq=created_at|eq=name|eq=size"`
+	Order   string `form:"order" binding:"required,eq=DESC|eq=ASC"`
+}
+
+// TrashRestoreService 恢复回收站对象服务
+type TrashRestoreService struct {
+	Items    []string `json:"items" binding:"required,min=1"`
+	Conflict string   `json:"conflict" binding:"omitempty,eq=fail|eq=rename|eq=overwrite"`
+}
+
+// TrashPurgeService 彻底删除回收站对象服务，Items 为空时清空回收站
+type TrashPurgeService struct {
+	Items []string `json:"items"`
+}
+
+// rawTrashIDs 解码回收站记录HashID
+func rawTrashIDs(items []string) []uint {
+	ids := make([]uint, 0, len(items))
+	for _, item := range items {
+		id, err := hashid.DecodeHashID(item, hashid.TrashID)
+		if err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// List 列出回收站内容
+func (service *TrashListService) List(c *gin.Context, user *model.User) serializer.Response {
+	items, total := model.ListTrash(user.ID, int(service.Page), 50, service.OrderBy+" "+service.Order)
+	return serializer.BuildTrashList(items, total)
+}
+
+// Restore 恢复回收站中的对象
+func (service *TrashRestoreService) Restore(ctx context.Context, c *gin.Context) serializer.Response {
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	ids := rawTrashIDs(service.Items)
+	if len(ids) == 0 {
+		return serializer.Err(serializer.CodeNotFound, "", nil)
+	}
+
+	items, err := model.GetTrashByIDs(ids, fs.User.ID)
+	if err != nil || len(items) == 0 {
+		return serializer.Err(serializer.CodeNotFound, "", err)
+	}
+
+	if err := fs.RestoreTrash(ctx, items, service.Conflict); err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	return serializer.Response{}
+}
+
+// Purge 彻底删除回收站中的对象
+func (service *TrashPurgeService) Purge(ctx context.Context, c *gin.Context) serializer.Response {
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	var ids []uint
+	if len(service.Items) > 0 {
+		if ids = rawTrashIDs(service.Items); len(ids) == 0 {
+			return serializer.Err(serializer.CodeNotFound, "", nil)
+		}
+	}
+
+	items, err := model.GetTrashByIDs(ids, fs.User.ID)
+	if err != nil {
+		return serializer.DBErr("Failed to list trash items", err)
+	}
+
+	if err := fs.PurgeTrash(ctx, items); err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	return serializer.Response{}
+}
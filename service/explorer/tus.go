@@ -0,0 +1,253 @@
+package explorer
+
+import (
+	"bytes"
+	"context"
+	"hash"
+	"io"
+	"io/ioutil"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	model "github.com/Jaylenwa/Vfoy/models"
+	"github.com/Jaylenwa/Vfoy/pkg/filesystem"
+	"github.com/Jaylenwa/Vfoy/pkg/filesystem/fsctx"
+	"github.com/Jaylenwa/Vfoy/pkg/serializer"
+	"github.com/Jaylenwa/Vfoy/pkg/tus"
+	"github.com/gin-gonic/gin"
+)
+
+// TusService tus 断点续传协议服务，上传会话与网页端上传共用
+type TusService struct {
+	ID string `uri:"sessionId"`
+}
+
+// tusBody 计算校验和的同时读取请求正文
+type tusBody struct {
+	io.Reader
+	io.Closer
+}
+
+// Create 根据 Upload-Length 和 Upload-Metadata 创建上传会话
+func (service *TusService) Create(ctx context.Context, c *gin.Context) serializer.Response {
+	if c.GetHeader(tus.HeaderUploadDeferLength) != "" {
+		return serializer.ParamErr("Upload-Defer-Length is not supported", nil)
+	}
+
+	size, err := strconv.ParseUint(c.GetHeader(tus.HeaderUploadLength), 10, 64)
+	if err != nil {
+		return serializer.ParamErr("Invalid Upload-Length", err)
+	}
+
+	metadata, err := tus.ParseMetadata(c.GetHeader(tus.HeaderUploadMetadata))
+	if err != nil {
+		return serializer.ParamErr(err.Error(), err)
+	}
+
+	name := metadata["filename"]
+	if name == "" {
+		name = metadata["name"]
+	}
+	if name == "" {
+		return serializer.ParamErr("File name is required in Upload-Metadata", nil)
+	}
+
+	// 上传目录时文件位于相对路径下
+	dir := metadata["path"]
+	if relativePath := metadata["relativePath"]; relativePath != "" {
+		dir = path.Join(dir, path.Dir(relativePath))
+	}
+
+	// 使用应用密码时限制在账户根目录下
+	dir = path.Join("/", dir)
+	if webdav, ok := c.Get("webdav"); ok {
+		dir = path.Join("/", webdav.(*model.Webdav).Root, dir)
+	}
+
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	// 文件数据需经由本机写入存储
+	if !fs.Policy.IsTransitUpload(size) {
+		return serializer.Err(serializer.CodePolicyNotAllowed, "Storage policy does not support tus uploads", nil)
+	}
+
+	mimeType := metadata["filetype"]
+	if mimeType == "" {
+		mimeType = metadata["type"]
+	}
+
+	file := &fsctx.FileStream{
+		Size:        size,
+		Name:        name,
+		VirtualPath: dir,
+		File:        ioutil.NopCloser(strings.NewReader("")),
+		MimeType:    mimeType,
+	}
+	if lastModified, err := strconv.ParseInt(metadata["lastModified"], 10, 64); err == nil && lastModified > 0 {
+		modified := time.UnixMilli(lastModified)
+		file.LastModified = &modified
+	}
+
+	credential, err := fs.CreateUploadSession(ctx, file)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	c.Header("Location", path.Join(c.Request.URL.Path, credential.SessionID))
+	c.Header(tus.HeaderUploadExpires, tus.FormatExpires(time.Unix(credential.Expires, 0)))
+
+	// 空文件不会收到 PATCH 请求，直接完成上传
+	if size == 0 {
+		session, placeholder, err := service.session(fs, credential.SessionID)
+		if err != nil {
+			return serializer.Err(serializer.CodeUploadSessionExpired, "", err)
+		}
+
+		fs.CleanHooks("")
+		return uploadTusChunk(ctx, c, fs, session, placeholder, 0, ioutil.NopCloser(strings.NewReader("")), 0, nil, nil)
+	}
+
+	return serializer.Response{}
+}
+
+// Head 返回上传会话已接收的数据量
+func (service *TusService) Head(ctx context.Context, c *gin.Context) serializer.Response {
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	session, placeholder, err := service.session(fs, service.ID)
+	if err != nil {
+		return serializer.Err(serializer.CodeUploadSessionExpired, "", err)
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header(tus.HeaderUploadOffset, strconv.FormatUint(placeholder.Size, 10))
+	c.Header(tus.HeaderUploadLength, strconv.FormatUint(session.Size, 10))
+	service.setExpires(c)
+	return serializer.Response{}
+}
+
+// Patch 从 Upload-Offset 处继续写入文件数据
+func (service *TusService) Patch(ctx context.Context, c *gin.Context) serializer.Response {
+	offset, err := strconv.ParseUint(c.GetHeader(tus.HeaderUploadOffset), 10, 64)
+	if err != nil {
+		return serializer.ParamErr("Invalid Upload-Offset", err)
+	}
+
+	var (
+		checksum hash.Hash
+		expected []byte
+	)
+	if header := c.GetHeader(tus.HeaderUploadChecksum); header != "" {
+		checksum, expected, err = tus.ParseChecksum(header)
+		if err != nil {
+			return serializer.ParamErr(err.Error(), err)
+		}
+	}
+
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	session, placeholder, err := service.session(fs, service.ID)
+	if err != nil {
+		return serializer.Err(serializer.CodeUploadSessionExpired, "", err)
+	}
+
+	if offset != placeholder.Size {
+		return serializer.Err(serializer.CodeConflict, "Upload-Offset does not match the current offset", nil)
+	}
+
+	length := c.Request.ContentLength
+	if length < 0 || offset+uint64(length) > session.Size {
+		return serializer.Err(serializer.CodeInvalidContentLength, "Invalid Content-Length", nil)
+	}
+
+	// 上传完成后会话即被删除，需提前设置过期时间
+	service.setExpires(c)
+	res := uploadTusChunk(ctx, c, fs, session, placeholder, offset, c.Request.Body, uint64(length), checksum, expected)
+	if res.Code != 0 {
+		return res
+	}
+
+	c.Header(tus.HeaderUploadOffset, strconv.FormatUint(offset+uint64(length), 10))
+	return res
+}
+
+// Terminate 终止上传并删除已接收的数据
+func (service *TusService) Terminate(ctx context.Context, c *gin.Context) serializer.Response {
+	uploadSession := &UploadSessionService{ID: service.ID}
+	return uploadSession.Delete(ctx, c)
+}
+
+// session 查找当前用户的上传会话及其占位文件
+func (service *TusService) session(fs *filesystem.FileSystem, id string) (*serializer.UploadSession, *model.File, error) {
+	session, ok := filesystem.GetUploadSession(id)
+	if !ok || session.UID != fs.User.ID {
+		return nil, nil, filesystem.ErrUploadSessionExpired
+	}
+
+	placeholder, err := model.GetFilesByUploadSession(id, fs.User.ID)
+	if err != nil {
+		return nil, nil, filesystem.ErrUploadSessionExpired
+	}
+
+	return session, placeholder, nil
+}
+
+// setExpires 设置 Upload-Expires 响应头
+func (service *TusService) setExpires(c *gin.Context) {
+	if record, err := model.GetUploadSessionByID(service.ID); err == nil {
+		c.Header(tus.HeaderUploadExpires, tus.FormatExpires(record.ExpiredAt))
+	}
+}
+
+// uploadTusChunk 将从 offset 开始的数据追加到文件，checksum 不为空时校验本次接收的数据
+func uploadTusChunk(ctx context.Context, c *gin.Context, fs *filesystem.FileSystem, session *serializer.UploadSession, file *model.File, offset uint64, body io.ReadCloser, length uint64, checksum hash.Hash, expected []byte) serializer.Response {
+	fs.Policy = &session.Policy
+	if err := fs.DispatchHandler(); err != nil {
+		return serializer.Err(serializer.CodePolicyNotExist, "", err)
+	}
+
+	// 校验失败时由后续钩子将文件截断至 offset
+	if checksum != nil {
+		body = tusBody{Reader: io.TeeReader(body, checksum), Closer: body}
+		fs.Use("AfterUpload", func(ctx context.Context, fs *filesystem.FileSystem, fileHeader fsctx.FileHeader) error {
+			if !bytes.Equal(checksum.Sum(nil), expected) {
+				return filesystem.ErrChecksumMismatch
+			}
+			return nil
+		})
+	}
+
+	// 非首个分片时需要允许覆盖
+	mode := fsctx.Append
+	if offset > 0 {
+		mode |= fsctx.Overwrite
+	}
+
+	fileData := &fsctx.FileStream{
+		File:         body,
+		Size:         length,
+		Name:         session.Name,
+		VirtualPath:  session.VirtualPath,
+		SavePath:     session.SavePath,
+		Mode:         mode,
+		AppendStart:  offset,
+		Model:        file,
+		LastModified: session.LastModified,
+	}
+
+	return uploadChunk(ctx, c, fs, session, file, fileData, offset+length == session.Size)
+}
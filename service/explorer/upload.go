@@ -28,6 +28,7 @@ type CreateUploadSessionService struct {
 	PolicyID     string `json:"policy_id" binding:"required"`
 	LastModified int64  `json:"last_modified"`
 	MimeType     string `json:"mime_type"`
+	Hash         string `json:"hash" binding:"omitempty,len=64,hexadecimal"`
 }
 
 // Create 创建新的上传会话
@@ -54,6 +55,7 @@ func (service *CreateUploadSessionService) Create(ctx context.Context, c *gin.Co
 		VirtualPath: service.Path,
 		File:        ioutil.NopCloser(strings.NewReader("")),
 		MimeType:    service.MimeType,
+		Hash:        strings.ToLower(service.Hash),
 	}
 	if service.LastModified > 0 {
 		lastModified := time.UnixMilli(service.LastModified)
@@ -78,13 +80,11 @@ type UploadService struct {
 
 // LocalUpload 处理本机文件分片上传
 func (service *UploadService) LocalUpload(ctx context.Context, c *gin.Context) serializer.Response {
-	uploadSessionRaw, ok := cache.Get(filesystem.UploadSessionCachePrefix + service.ID)
+	uploadSession, ok := filesystem.GetUploadSession(service.ID)
 	if !ok {
 		return serializer.Err(serializer.CodeUploadSessionExpired, "", nil)
 	}
 
-	uploadSession := uploadSessionRaw.(serializer.UploadSession)
-
 	fs, err := filesystem.NewFileSystemFromContext(c)
 	if err != nil {
 		return serializer.Err(serializer.CodePolicyNotAllowed, err.Error(), err)
@@ -124,7 +124,7 @@ func (service *UploadService) LocalUpload(ctx context.Context, c *gin.Context) s
 		util.Log().Info("Trying to overwrite chunk[%d] Start=%d", service.Index, actualSizeStart)
 	}
 
-	return processChunkUpload(ctx, c, fs, &uploadSession, service.Index, file, fsctx.Append)
+	return processChunkUpload(ctx, c, fs, uploadSession, service.Index, file, fsctx.Append)
 }
 
 // SlaveUpload 处理从机文件分片上传
@@ -189,17 +189,33 @@ func processChunkUpload(ctx context.Context, c *gin.Context, fs *filesystem.File
 		LastModified: session.LastModified,
 	}
 
+	return uploadChunk(ctx, c, fs, session, file, &fileData, isLastChunk)
+}
+
+// uploadChunk 为分片分配钩子并执行上传，file 为空时为从机上传
+func uploadChunk(ctx context.Context, c *gin.Context, fs *filesystem.FileSystem, session *serializer.UploadSession, file *model.File, fileData *fsctx.FileStream, isLastChunk bool) serializer.Response {
+	// 从之前的分片继续计算内容摘要
+	if file != nil {
+		fileData.HashState = filesystem.ChunkHashState(file, fileData.AppendStart)
+	}
+
 	// 给文件系统分配钩子
 	fs.Use("AfterUploadCanceled", filesystem.HookTruncateFileTo(fileData.AppendStart))
 	fs.Use("AfterValidateFailed", filesystem.HookTruncateFileTo(fileData.AppendStart))
 
 	if file != nil {
 		fs.Use("BeforeUpload", filesystem.HookValidateCapacity)
+		fs.Use("BeforeUpload", filesystem.HookValidateFolderQuota)
+		if isLastChunk && session.Hash != "" {
+			fs.Use("AfterUpload", filesystem.HookVerifyChecksum(map[string]string{model.ChecksumSHA256: session.Hash}))
+		}
 		fs.Use("AfterUpload", filesystem.HookChunkUploaded)
+		fs.Use("AfterUpload", filesystem.HookSaveChunkHash(isLastChunk))
 		fs.Use("AfterValidateFailed", filesystem.HookChunkUploadFailed)
 		if isLastChunk {
 			fs.Use("AfterUpload", filesystem.HookPopPlaceholderToFile(""))
 			fs.Use("AfterUpload", filesystem.HookDeleteUploadSession(session.Key))
+			fs.Use("AfterUpload", filesystem.HookReplicateFile)
 		}
 	} else {
 		if isLastChunk {
@@ -210,8 +226,7 @@ func processChunkUpload(ctx context.Context, c *gin.Context, fs *filesystem.File
 
 	// 执行上传
 	uploadCtx := context.WithValue(ctx, fsctx.GinCtx, c)
-	err = fs.Upload(uploadCtx, &fileData)
-	if err != nil {
+	if err := fs.Upload(uploadCtx, fileData); err != nil {
 		return serializer.Err(serializer.CodeUploadFailed, err.Error(), err)
 	}
 
@@ -246,6 +261,33 @@ func (service *UploadSessionService) Delete(ctx context.Context, c *gin.Context)
 	return serializer.Response{}
 }
 
+// Status 查询上传会话已接收的分片
+func (service *UploadSessionService) Status(ctx context.Context, c *gin.Context) serializer.Response {
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	session, ok := filesystem.GetUploadSession(service.ID)
+	if !ok || session.UID != fs.User.ID {
+		return serializer.Err(serializer.CodeUploadSessionExpired, "", nil)
+	}
+
+	file, err := model.GetFilesByUploadSession(service.ID, fs.User.ID)
+	if err != nil {
+		return serializer.Err(serializer.CodeUploadSessionExpired, "", err)
+	}
+
+	// 仅中转上传时由本机接收分片，占位文件大小即为已接收的数据量
+	received := uint64(0)
+	if session.Policy.IsTransitUpload(session.Size) {
+		received = file.Size
+	}
+
+	return serializer.Response{Data: serializer.BuildUploadSessionStatus(session, received)}
+}
+
 // SlaveDelete 从机删除指定上传会话
 func (service *UploadSessionService) SlaveDelete(ctx context.Context, c *gin.Context) serializer.Response {
 	// 创建文件系统
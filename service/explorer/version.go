@@ -0,0 +1,118 @@
+package explorer
+
+import (
+	"context"
+
+	model "github.com/Jaylenwa/Vfoy/models"
+	"github.com/Jaylenwa/Vfoy/pkg/filesystem"
+	"github.com/Jaylenwa/Vfoy/pkg/hashid"
+	"github.com/Jaylenwa/Vfoy/pkg/serializer"
+	"github.com/gin-gonic/gin"
+)
+
+// FileVersionService 文件历史版本服务
+type FileVersionService struct {
+	Version string `uri:"version" binding:"required"`
+}
+
+// ListVersions 列出文件的历史版本
+func (service *FileIDService) ListVersions(ctx context.Context, c *gin.Context) serializer.Response {
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	fileID, _ := c.Get("object_id")
+	files, err := model.GetFilesByIDs([]uint{fileID.(uint)}, fs.User.ID)
+	if err != nil || len(files) == 0 {
+		return serializer.Err(serializer.CodeFileNotFound, "", err)
+	}
+
+	versions, err := model.GetFileVersions(files[0].ID, fs.User.ID)
+	if err != nil {
+		return serializer.DBErr("Failed to list file versions", err)
+	}
+
+	// 查找版本的操作者
+	authors := make(map[uint]string)
+	for _, version := range versions {
+		if _, ok := authors[version.AuthorID]; ok {
+			continue
+		}
+
+		authors[version.AuthorID] = ""
+		if author, err := model.GetUserByID(version.AuthorID); err == nil {
+			authors[version.AuthorID] = author.Nick
+		}
+	}
+
+	return serializer.BuildFileVersionList(versions, authors)
+}
+
+// version 查找当前请求的文件及其历史版本
+func (service *FileVersionService) version(c *gin.Context, fs *filesystem.FileSystem) (*model.File, *model.FileVersion, error) {
+	fileID, _ := c.Get("object_id")
+	files, err := model.GetFilesByIDs([]uint{fileID.(uint)}, fs.User.ID)
+	if err != nil || len(files) == 0 {
+		return nil, nil, serializer.NewError(serializer.CodeFileNotFound, "", err)
+	}
+
+	versionID, err := hashid.DecodeHashID(service.Version, hashid.FileVersionID)
+	if err != nil {
+		return nil, nil, serializer.NewError(serializer.CodeNotFound, "", err)
+	}
+
+	version, err := model.GetFileVersionByID(versionID, files[0].ID, fs.User.ID)
+	if err != nil {
+		return nil, nil, serializer.NewError(serializer.CodeNotFound, "File version not exist", err)
+	}
+
+	return &files[0], version, nil
+}
+
+// CreateDownloadSession 创建历史版本的下载会话
+func (service *FileVersionService) CreateDownloadSession(ctx context.Context, c *gin.Context) serializer.Response {
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	file, version, err := service.version(c, fs)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	// 以历史版本的物理文件作为下载目标
+	fs.FileTarget = []model.File{version.AsFile(file)}
+	downloadURL, err := fs.GetDownloadURL(ctx, file.ID, "download_timeout")
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	return serializer.Response{
+		Code: 0,
+		Data: downloadURL,
+	}
+}
+
+// Restore 将文件恢复为历史版本
+func (service *FileVersionService) Restore(ctx context.Context, c *gin.Context) serializer.Response {
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	file, version, err := service.version(c, fs)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	if err := fs.RestoreVersion(ctx, file, version); err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	return serializer.Response{}
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/Jaylenwa/Vfoy/middleware"
 	model "github.com/Jaylenwa/Vfoy/models"
 	"github.com/Jaylenwa/Vfoy/pkg/filesystem"
+	"github.com/Jaylenwa/Vfoy/pkg/filesystem/lock"
 	"github.com/Jaylenwa/Vfoy/pkg/hashid"
 	"github.com/Jaylenwa/Vfoy/pkg/serializer"
 	"github.com/Jaylenwa/Vfoy/pkg/wopi"
@@ -29,6 +30,57 @@ func (service *WopiService) Rename(c *gin.Context) error {
 	return fs.Rename(c, []uint{}, []uint{c.MustGet("object_id").(uint)}, c.GetHeader(wopi.RenameRequestHeader))
 }
 
+// Lock 处理 WOPI 的加锁、解锁、刷新与查询请求，返回文件上当前的锁标识。
+// 锁标识不匹配时返回 lock.ErrLocked 或 lock.ErrNoSuchLock
+func (service *WopiService) Lock(c *gin.Context, method string) (string, error) {
+	fs, _, err := service.prepareFs(c)
+	if err != nil {
+		return "", err
+	}
+
+	defer fs.Recycle()
+
+	fileID := fs.FileTarget[0].ID
+	token := c.GetHeader(wopi.LockHeader)
+	if method == wopi.MethodGetLock {
+		if current, ok := lock.Get(fileID); ok {
+			return current.Token, nil
+		}
+		return "", nil
+	}
+
+	if token == "" {
+		return "", lock.ErrNoSuchLock
+	}
+
+	var current *lock.Lock
+	switch method {
+	case wopi.MethodLock:
+		l := lock.Lock{
+			FileID: fileID,
+			UserID: fs.User.ID,
+			Token:  token,
+			App:    lock.AppWOPI,
+			Owner:  fs.User.Nick,
+		}
+		if oldToken := c.GetHeader(wopi.OldLockHeader); oldToken != "" {
+			current, err = lock.Replace(oldToken, l, wopi.LockTTL)
+		} else {
+			current, err = lock.Acquire(l, wopi.LockTTL)
+		}
+	case wopi.MethodRefreshLock:
+		current, err = lock.Refresh(fileID, token, wopi.LockTTL)
+	case wopi.MethodUnlock:
+		current, err = lock.Release(fileID, token)
+	}
+
+	if current != nil {
+		return current.Token, err
+	}
+
+	return "", err
+}
+
 func (service *WopiService) GetFile(c *gin.Context) error {
 	fs, _, err := service.prepareFs(c)
 	if err != nil {
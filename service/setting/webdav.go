@@ -1,6 +1,9 @@
 package setting
 
 import (
+	"strings"
+	"time"
+
 	model "github.com/Jaylenwa/Vfoy/models"
 	"github.com/Jaylenwa/Vfoy/pkg/serializer"
 	"github.com/Jaylenwa/Vfoy/pkg/util"
@@ -16,10 +19,26 @@ type WebDAVAccountService struct {
 	ID uint `uri:"id" binding:"required,min=1"`
 }
 
+// WebDAVAccountRestrictions WebDAV 账号访问控制，留空表示不限
+type WebDAVAccountRestrictions struct {
+	AllowedIPs     []string   `json:"allowed_ips" binding:"max=100,dive,max=64"`
+	AllowedMethods []string   `json:"allowed_methods" binding:"dive,oneof=GET HEAD POST PUT DELETE MKCOL COPY MOVE LOCK UNLOCK PROPFIND PROPPATCH"`
+	ExpiresAt      *time.Time `json:"expires_at"`
+	RequestLimit   int        `json:"request_limit" binding:"min=0"`
+	SpeedLimit     int        `json:"speed_limit" binding:"min=0"`
+}
+
 // WebDAVAccountCreateService WebDAV 账号创建服务
 type WebDAVAccountCreateService struct {
 	Path string `json:"path" binding:"required,min=1,max=65535"`
 	Name string `json:"name" binding:"required,min=1,max=255"`
+	WebDAVAccountRestrictions
+}
+
+// WebDAVAccountRestrictService WebDAV 账号访问控制设定服务
+type WebDAVAccountRestrictService struct {
+	ID uint `uri:"id" json:"-" binding:"required,min=1"`
+	WebDAVAccountRestrictions
 }
 
 // WebDAVAccountUpdateService WebDAV 修改只读性和是否使用代理服务
@@ -37,11 +56,21 @@ type WebDAVMountCreateService struct {
 
 // Create 创建WebDAV账户
 func (service *WebDAVAccountCreateService) Create(c *gin.Context, user *model.User) serializer.Response {
+	restrictions, err := service.columns()
+	if err != nil {
+		return serializer.ParamErr("Invalid IP or CIDR", err)
+	}
+
 	account := model.Webdav{
-		Name:     service.Name,
-		Password: util.RandStringRunes(32),
-		UserID:   user.ID,
-		Root:     service.Path,
+		Name:           service.Name,
+		Password:       util.RandStringRunes(32),
+		UserID:         user.ID,
+		Root:           service.Path,
+		AllowedIPs:     restrictions["allowed_ips"].(string),
+		AllowedMethods: restrictions["allowed_methods"].(string),
+		ExpiresAt:      service.ExpiresAt,
+		RequestLimit:   service.RequestLimit,
+		SpeedLimit:     service.SpeedLimit,
 	}
 
 	if _, err := account.Create(); err != nil {
@@ -57,6 +86,41 @@ func (service *WebDAVAccountCreateService) Create(c *gin.Context, user *model.Us
 	}
 }
 
+// columns 转换为账户中对应的字段
+func (r *WebDAVAccountRestrictions) columns() (map[string]interface{}, error) {
+	allowedIPs, err := model.NormalizeAllowedIPs(r.AllowedIPs)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"allowed_ips":     allowedIPs,
+		"allowed_methods": strings.Join(r.AllowedMethods, ","),
+		"expires_at":      r.ExpiresAt,
+		"request_limit":   r.RequestLimit,
+		"speed_limit":     r.SpeedLimit,
+	}, nil
+}
+
+// Restrict 设定WebDAV账户的访问控制
+func (service *WebDAVAccountRestrictService) Restrict(c *gin.Context, user *model.User) serializer.Response {
+	account, err := model.GetWebDAVAccountByID(service.ID, user.ID)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotFound, "Account not exist", err)
+	}
+
+	updates, err := service.columns()
+	if err != nil {
+		return serializer.ParamErr("Invalid IP or CIDR", err)
+	}
+
+	if err := account.UpdateRestrictions(updates); err != nil {
+		return serializer.DBErr("Failed to update account", err)
+	}
+
+	return serializer.Response{Data: account}
+}
+
 // Delete 删除WebDAV账户
 func (service *WebDAVAccountService) Delete(c *gin.Context, user *model.User) serializer.Response {
 	model.DeleteWebDAVAccountByID(service.ID, user.ID)
@@ -38,6 +38,7 @@ type ArchiveService struct {
 	Path  string   `json:"path" binding:"required,max=65535"`
 	Items []string `json:"items"`
 	Dirs  []string `json:"dirs"`
+	Store bool     `json:"store"`
 }
 
 // ShareListService 列出分享
@@ -362,6 +363,7 @@ func (service *ArchiveService) Archive(c *gin.Context) serializer.Response {
 	subService := explorer.ItemIDService{
 		Dirs:  service.Dirs,
 		Items: service.Items,
+		Store: service.Store,
 	}
 
 	return subService.Archive(ctx, c)
@@ -0,0 +1,137 @@
+package user
+
+import (
+	"io"
+	"strconv"
+	"time"
+
+	model "github.com/Jaylenwa/Vfoy/models"
+	"github.com/Jaylenwa/Vfoy/pkg/mq"
+	"github.com/Jaylenwa/Vfoy/pkg/serializer"
+	"github.com/Jaylenwa/Vfoy/pkg/util"
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// eventHeartbeatInterval 心跳事件的间隔
+	eventHeartbeatInterval = 30 * time.Second
+	// eventRetryInterval 建议客户端断线重连的间隔，单位为毫秒
+	eventRetryInterval = 3000
+	// eventBufferSize 每个连接缓冲的事件数
+	eventBufferSize = 16
+	// eventReplayBatchSize 补发文件变更时每个事件包含的最多变更数
+	eventReplayBatchSize = 200
+)
+
+// 推送连接自身产生的事件
+const (
+	eventReady     = "ready"
+	eventReset     = "reset"
+	eventHeartbeat = "heartbeat"
+)
+
+// EventService 用户事件推送服务，LastEventID 为断线前收到的最后一个事件ID，
+// 浏览器重连时会通过 Last-Event-ID 请求头携带
+type EventService struct {
+	LastEventID uint `form:"last_event_id"`
+}
+
+// Stream 通过 Server-Sent Events 持续推送用户事件，重连时补发期间的文件变更
+func (service *EventService) Stream(c *gin.Context, user *model.User) {
+	cursor := service.LastEventID
+	if header := c.GetHeader("Last-Event-ID"); header != "" {
+		if id, err := strconv.ParseUint(header, 10, 64); err == nil {
+			cursor = uint(id)
+		}
+	}
+
+	// 先订阅再补发，避免遗漏补发期间产生的事件
+	topic := mq.UserTopic(user.ID)
+	events := mq.GlobalMQ.Subscribe(topic, eventBufferSize)
+	defer mq.GlobalMQ.Unsubscribe(topic, events)
+
+	c.Header("X-Accel-Buffering", "no")
+	cursor, err := service.replay(c, user, cursor)
+	if err != nil {
+		util.Log().Warning("Failed to replay changes for user %d: %s", user.ID, err)
+		return
+	}
+
+	heartbeat := time.NewTicker(eventHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case <-heartbeat.C:
+			c.Render(-1, sse.Event{Event: eventHeartbeat, Data: time.Now().Unix()})
+		case msg := <-events:
+			if delta, ok := msg.Content.(serializer.Delta); ok {
+				// 跳过已补发的变更
+				if delta.Cursor <= cursor {
+					return true
+				}
+				cursor = delta.Cursor
+				c.Render(-1, sse.Event{Id: formatEventID(cursor), Event: msg.Event, Data: delta})
+				return true
+			}
+
+			c.Render(-1, sse.Event{Event: msg.Event, Data: msg.Content})
+		}
+		return true
+	})
+}
+
+// replay 补发游标之后的文件变更，并推送带有最新游标的连接就绪事件，返回最新游标。
+// 游标之后的变更日志已被清理时推送 reset 事件，客户端需重新列取目录
+func (service *EventService) replay(c *gin.Context, user *model.User, cursor uint) (uint, error) {
+	first, last, err := model.GetChangeCursorRange()
+	if err != nil {
+		return 0, err
+	}
+
+	event := eventReady
+	if cursor > 0 && (cursor > last || cursor+1 < first) {
+		event = eventReset
+	} else if cursor > 0 {
+		for {
+			changes, err := model.GetChangesAfter(user.ID, cursor, eventReplayBatchSize)
+			if err != nil {
+				return 0, err
+			}
+
+			if len(changes) == 0 {
+				break
+			}
+
+			cursor = changes[len(changes)-1].ID
+			c.Render(-1, sse.Event{
+				Id:    formatEventID(cursor),
+				Event: mq.EventFileChange,
+				Data:  serializer.BuildDelta(cursor, false, changes),
+			})
+		}
+	}
+
+	// 仅首次连接或需重新同步时以日志末尾作为起点，补发后保持在最后一条已推送的变更，
+	// 避免跳过并发事务中 ID 更小、稍后才提交的变更
+	if cursor == 0 || event == eventReset {
+		cursor = last
+	}
+
+	c.Render(-1, sse.Event{
+		Id:    formatEventID(cursor),
+		Event: event,
+		Retry: eventRetryInterval,
+		Data:  map[string]interface{}{"cursor": cursor},
+	})
+	c.Writer.Flush()
+
+	return cursor, nil
+}
+
+func formatEventID(id uint) string {
+	return strconv.FormatUint(uint64(id), 10)
+}